@@ -0,0 +1,221 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// PackedData returns a copy of the accessor's elements as tightly packed bytes, i.e. with any interleaving or padding
+// from the buffer view's byteStride removed, and with sparse substitutions applied. The result is always Count *
+// Stride() bytes long.
+func (a *ResolvedAccessor) PackedData() ([]byte, error) {
+	elemSize := a.Stride()
+	rval := make([]byte, a.Count*elemSize)
+
+	if a.BufferView != nil {
+		stride := elemSize
+		if a.BufferView.ByteStride != 0 {
+			stride = int(a.BufferView.ByteStride)
+		}
+
+		src := a.BufferView.Data
+		if a.Count > 0 {
+			if end := int(a.ByteOffset) + (a.Count-1)*stride + elemSize; end > len(src) {
+				return nil, fmt.Errorf("Accessor %q extends past the end of its buffer view: needs %d bytes, have %d", a.Name, end, len(src))
+			}
+		}
+
+		for i := 0; i < a.Count; i++ {
+			start := int(a.ByteOffset) + i*stride
+			copy(rval[i*elemSize:(i+1)*elemSize], src[start:start+elemSize])
+		}
+	}
+
+	if a.Sparse != nil && a.SparseIndices != nil && a.SparseValues != nil {
+		idxSize := a.Sparse.Indices.ComponentType.Size()
+		idxData := a.SparseIndices.Data[a.Sparse.Indices.ByteOffset:]
+		valData := a.SparseValues.Data[a.Sparse.Values.ByteOffset:]
+		if len(idxData) < a.Sparse.Count*idxSize || len(valData) < a.Sparse.Count*elemSize {
+			return nil, fmt.Errorf("Sparse data for accessor %q is shorter than its count of %d", a.Name, a.Sparse.Count)
+		}
+
+		for i := 0; i < a.Sparse.Count; i++ {
+			target := int(readUint(idxData[i*idxSize:], a.Sparse.Indices.ComponentType))
+			if target >= a.Count {
+				return nil, fmt.Errorf("Sparse index %d for accessor %q is out of range", target, a.Name)
+			}
+			copy(rval[target*elemSize:(target+1)*elemSize], valData[i*elemSize:(i+1)*elemSize])
+		}
+	}
+
+	return rval, nil
+}
+
+// readUint reads a single little-endian unsigned integer component of type ct from the start of b.
+func readUint(b []byte, ct ComponentTypeEnum) uint32 {
+	switch ct {
+	case UNSIGNED_BYTE:
+		return uint32(b[0])
+	case UNSIGNED_SHORT:
+		return uint32(binary.LittleEndian.Uint16(b))
+	case UNSIGNED_INT:
+		return binary.LittleEndian.Uint32(b)
+	}
+	panic(fmt.Sprint("not an unsigned integer ComponentType:", ct))
+}
+
+// ReadFloats returns every component of every element in the accessor as a float32, in element order, so the result
+// has Count * Type.Count() values. Normalized integer components are converted to the [0, 1] or [-1, 1] range
+// according to the spec; non-normalized integers are converted directly.
+func (a *ResolvedAccessor) ReadFloats() ([]float32, error) {
+	data, err := a.PackedData()
+	if err != nil {
+		return nil, err
+	}
+
+	size := a.ComponentType.Size()
+	rval := make([]float32, len(data)/size)
+	for i := range rval {
+		rval[i] = readComponent(data[i*size:], a.ComponentType, a.Normalized)
+	}
+	return rval, nil
+}
+
+// ReadIndices returns the values of a SCALAR accessor with an unsigned integer component type, such as the indices of a
+// primitive or the joints of a skin.
+func (a *ResolvedAccessor) ReadIndices() ([]uint32, error) {
+	if a.Type != SCALAR {
+		return nil, fmt.Errorf("Accessor %q is not a SCALAR accessor", a.Name)
+	} else if a.ComponentType != UNSIGNED_BYTE && a.ComponentType != UNSIGNED_SHORT && a.ComponentType != UNSIGNED_INT {
+		return nil, fmt.Errorf("Accessor %q does not have an unsigned integer component type", a.Name)
+	}
+
+	data, err := a.PackedData()
+	if err != nil {
+		return nil, err
+	}
+
+	size := a.ComponentType.Size()
+	rval := make([]uint32, a.Count)
+	for i := range rval {
+		rval[i] = readUint(data[i*size:], a.ComponentType)
+	}
+	return rval, nil
+}
+
+// readComponent reads a single little-endian component of type ct from the start of b, applying the spec's
+// normalization rules if normalized is set.
+func readComponent(b []byte, ct ComponentTypeEnum, normalized bool) float32 {
+	switch ct {
+	case BYTE:
+		v := float32(int8(b[0]))
+		if normalized {
+			return max32(v/127, -1)
+		}
+		return v
+	case UNSIGNED_BYTE:
+		v := float32(b[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case SHORT:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return max32(v/32767, -1)
+		}
+		return v
+	case UNSIGNED_SHORT:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case UNSIGNED_INT:
+		return float32(binary.LittleEndian.Uint32(b))
+	case FLOAT:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	panic(fmt.Sprint("unknown ComponentType:", ct))
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// NewResolvedAccessor creates a standalone accessor holding data, which must be tightly packed elements of the given
+// component type and accessor type. The accessor has its own buffer view and buffer, none of which are part of any
+// document, and can be used anywhere a resolved accessor is expected, such as in ResolvedPrimitive.Attributes.
+func NewResolvedAccessor(ct ComponentTypeEnum, t AccessorTypeEnum, normalized bool, data []byte) *ResolvedAccessor {
+	buf := &ResolvedBuffer{
+		Buffer: &Buffer{ByteLength: uint(len(data))},
+		Data:   data,
+	}
+	bv := &ResolvedBufferView{
+		BufferView: &BufferView{ByteLength: uint(len(data))},
+		Buffer:     buf,
+		Data:       data,
+	}
+	return &ResolvedAccessor{
+		Accessor: &Accessor{
+			ComponentType: ct,
+			Normalized:    normalized,
+			Count:         len(data) / (ct.Size() * t.Count()),
+			Type:          t,
+		},
+		BufferView: bv,
+	}
+}
+
+// NewFloatAccessor creates a standalone FLOAT accessor holding values, as with NewResolvedAccessor. Min and Max are
+// set from the data.
+func NewFloatAccessor(t AccessorTypeEnum, values []float32) *ResolvedAccessor {
+	data := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+
+	rval := NewResolvedAccessor(FLOAT, t, false, data)
+	n := t.Count()
+	if len(values) >= n {
+		rval.Min, rval.Max = make([]float64, n), make([]float64, n)
+		for c := 0; c < n; c++ {
+			rval.Min[c], rval.Max[c] = math.Inf(1), math.Inf(-1)
+		}
+		for i, v := range values {
+			rval.Min[i%n] = math.Min(rval.Min[i%n], float64(v))
+			rval.Max[i%n] = math.Max(rval.Max[i%n], float64(v))
+		}
+	}
+	return rval
+}
+
+// NewIndexAccessor creates a standalone SCALAR accessor holding indices, as with NewResolvedAccessor, using the
+// smallest of UNSIGNED_SHORT or UNSIGNED_INT that fits every index.
+func NewIndexAccessor(indices []uint32) *ResolvedAccessor {
+	data, ct, _ := encodeIndices(indices, 0)
+	return NewResolvedAccessor(ct, SCALAR, false, data)
+}
+
+// Gather returns a standalone accessor, as with NewResolvedAccessor, whose element i is a copy of element
+// elements[i] of a.
+func (a *ResolvedAccessor) Gather(elements []uint32) (*ResolvedAccessor, error) {
+	src, err := a.PackedData()
+	if err != nil {
+		return nil, err
+	}
+
+	size := a.Stride()
+	data := make([]byte, len(elements)*size)
+	for i, e := range elements {
+		if int(e) >= a.Count {
+			return nil, fmt.Errorf("Element %d is out of range for accessor %q with count %d", e, a.Name, a.Count)
+		}
+		copy(data[i*size:], src[int(e)*size:int(e+1)*size])
+	}
+	return NewResolvedAccessor(a.ComponentType, a.Type, a.Normalized, data), nil
+}
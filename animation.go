@@ -0,0 +1,173 @@
+package gltf
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// KeyTimes returns the distinct keyframe times, in seconds, of every sampler in the animation, in increasing order.
+func (a *ResolvedAnimation) KeyTimes() ([]float32, error) {
+	seen := make(map[float32]bool)
+	var rval []float32
+	for i := range a.Samplers {
+		times, err := a.Samplers[i].Input.ReadFloats()
+		if err != nil {
+			return nil, fmt.Errorf("Sampler %d: %w", i, err)
+		}
+		for _, t := range times {
+			if !seen[t] {
+				seen[t] = true
+				rval = append(rval, t)
+			}
+		}
+	}
+	sort.Slice(rval, func(i, j int) bool { return rval[i] < rval[j] })
+	return rval, nil
+}
+
+// Pose evaluates the animation at time t, in seconds, and returns the resulting local transform of every node that it
+// animates. Times before the first keyframe or after the last are clamped. Morph target weights are not included.
+func (a *ResolvedAnimation) Pose(t float32) (Pose, error) {
+	type trs struct {
+		t vkm.Vec3
+		r vkm.Vec
+		s vkm.Vec3
+	}
+	animated := make(map[*ResolvedNode]*trs)
+
+	for i := range a.Channels {
+		ch := &a.Channels[i]
+		n := ch.Target.Node
+		if n == nil || ch.Target.Path == WEIGHTS {
+			continue
+		}
+
+		value, err := ch.Sampler.Sample(t, ch.Target.Path == ROTATION)
+		if err != nil {
+			return nil, fmt.Errorf("Channel %d: %w", i, err)
+		}
+		current := animated[n]
+		if current == nil {
+			current = &trs{}
+			current.t, current.r, current.s = n.trs()
+			animated[n] = current
+		}
+
+		switch ch.Target.Path {
+		case TRANSLATION:
+			copy(current.t[:], value)
+		case ROTATION:
+			copy(current.r[:], value)
+		case SCALE:
+			copy(current.s[:], value)
+		}
+	}
+
+	rval := make(Pose, len(animated))
+	for n, v := range animated {
+		rval[n] = trsMatrix(v.t, v.r, v.s)
+	}
+	return rval, nil
+}
+
+// Sample returns the sampler's output value at time t, in seconds, with its interpolation applied. Set rotation when
+// the output is a quaternion, so that it is interpolated on the unit sphere and normalized.
+func (s *ResolvedAnimationSampler) Sample(t float32, rotation bool) ([]float32, error) {
+	times, err := s.Input.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	values, err := s.Output.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	if len(times) == 0 {
+		return nil, fmt.Errorf("Animation sampler has no keyframes")
+	}
+
+	// Cubic spline outputs hold an in-tangent, a value and an out-tangent for each keyframe.
+	interp := s.Interpolation
+	elements := len(times)
+	if interp == CUBIC_SPLINE {
+		elements *= 3
+	}
+	if len(values)%elements != 0 {
+		return nil, fmt.Errorf("Animation sampler output count does not match its %d keyframes", len(times))
+	}
+	n := len(values) / elements
+	value := func(key, part int) []float32 {
+		if interp == CUBIC_SPLINE {
+			start := (3*key + part) * n
+			return values[start : start+n]
+		}
+		return values[key*n : (key+1)*n]
+	}
+
+	k := sort.Search(len(times), func(i int) bool { return times[i] > t }) - 1
+	if k < 0 {
+		return append([]float32(nil), value(0, 1)...), nil
+	} else if k >= len(times)-1 {
+		return append([]float32(nil), value(len(times)-1, 1)...), nil
+	}
+
+	dt := times[k+1] - times[k]
+	u := (t - times[k]) / dt
+	rval := make([]float32, n)
+	switch interp {
+	case STEP:
+		copy(rval, value(k, 1))
+	case CUBIC_SPLINE:
+		u2, u3 := u*u, u*u*u
+		p0, m0, p1, m1 := value(k, 1), value(k, 2), value(k+1, 1), value(k+1, 0)
+		for c := range rval {
+			rval[c] = (2*u3-3*u2+1)*p0[c] + (u3-2*u2+u)*dt*m0[c] + (-2*u3+3*u2)*p1[c] + (u3-u2)*dt*m1[c]
+		}
+	default:
+		a, b := value(k, 1), value(k+1, 1)
+		if rotation && n == 4 {
+			return slerp(a, b, u), nil
+		}
+		for c := range rval {
+			rval[c] = a[c] + (b[c]-a[c])*u
+		}
+	}
+
+	if rotation && n == 4 {
+		var q vkm.Vec
+		copy(q[:], rval)
+		q = normalizeQuat(q)
+		copy(rval, q[:])
+	}
+	return rval, nil
+}
+
+// slerp spherically interpolates between the quaternions a and b, taking the shorter path.
+func slerp(a, b []float32, u float32) []float32 {
+	qa, qb := vkm.Vec{a[0], a[1], a[2], a[3]}, vkm.Vec{b[0], b[1], b[2], b[3]}
+	d := dot4(qa, qb)
+	if d < 0 {
+		qb, d = qb.Scale(-1), -d
+	}
+
+	var q vkm.Vec
+	if d > 0.9995 {
+		// Nearly parallel, where slerp is numerically unstable and indistinguishable from lerp.
+		q = add4(qa.Scale(1-u), qb.Scale(u))
+	} else {
+		theta := math32.Acos(d)
+		sin := math32.Sin(theta)
+		q = add4(qa.Scale(math32.Sin((1-u)*theta)/sin), qb.Scale(math32.Sin(u*theta)/sin))
+	}
+	q = normalizeQuat(q)
+	return q[:]
+}
+
+func normalizeQuat(q vkm.Vec) vkm.Vec {
+	if l := math32.Sqrt(dot4(q, q)); l > 0 {
+		return q.Scale(1 / l)
+	}
+	return vkm.Vec{0, 0, 0, 1}
+}
@@ -0,0 +1,26 @@
+package gltf
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSampleRotation(t *testing.T) {
+	s := &ResolvedAnimationSampler{
+		AnimationSampler: &AnimationSampler{Interpolation: LINEAR},
+		Input:            NewFloatAccessor(SCALAR, []float32{0, 1}),
+		Output:           NewFloatAccessor(VEC4, []float32{0, 0, 0, 1, 0, 0, 0.7071068, 0.7071068}),
+	}
+	q, err := s.Sample(0.5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Half way through a 90 degree turn about Z is a 45 degree turn.
+	expected := []float32{0, 0, 0.3826834, 0.9238795}
+	for i := range expected {
+		if math.Abs(float64(q[i]-expected[i])) > 1e-5 {
+			t.Fatalf("expected %v, got %v", expected, q)
+		}
+	}
+}
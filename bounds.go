@@ -0,0 +1,511 @@
+package gltf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// AABB is an axis-aligned bounding box. A box with Min greater than Max on any axis is empty; EmptyAABB returns one.
+type AABB struct {
+	Min, Max vkm.Vec3
+}
+
+// EmptyAABB returns a box containing nothing, which can be grown with Extend or Union.
+func EmptyAABB() AABB {
+	inf := float32(math.Inf(1))
+	return AABB{vkm.Vec3{inf, inf, inf}, vkm.Vec3{-inf, -inf, -inf}}
+}
+
+func (b AABB) IsEmpty() bool {
+	return b.Min[0] > b.Max[0] || b.Min[1] > b.Max[1] || b.Min[2] > b.Max[2]
+}
+
+// Extend returns the smallest box containing both b and the point p.
+func (b AABB) Extend(p vkm.Vec3) AABB {
+	for c := 0; c < 3; c++ {
+		b.Min[c] = math32.Min(b.Min[c], p[c])
+		b.Max[c] = math32.Max(b.Max[c], p[c])
+	}
+	return b
+}
+
+// Union returns the smallest box containing both b and o.
+func (b AABB) Union(o AABB) AABB {
+	if o.IsEmpty() {
+		return b
+	} else if b.IsEmpty() {
+		return o
+	}
+	return b.Extend(o.Min).Extend(o.Max)
+}
+
+func (b AABB) Center() vkm.Vec3 {
+	return b.Min.Add(b.Max).Scale(0.5)
+}
+
+func (b AABB) Size() vkm.Vec3 {
+	return b.Max.Sub(b.Min)
+}
+
+// Transform returns the smallest axis-aligned box containing b after it is transformed by m, which must be affine.
+func (b AABB) Transform(m vkm.Mat) AABB {
+	if b.IsEmpty() {
+		return b
+	}
+	// Arvo, "Transforming Axis-Aligned Bounding Boxes", Graphics Gems, 1990.
+	rval := AABB{vkm.Vec3{m[3][0], m[3][1], m[3][2]}, vkm.Vec3{m[3][0], m[3][1], m[3][2]}}
+	for row := 0; row < 3; row++ {
+		for col := 0; col < 3; col++ {
+			lo, hi := m[col][row]*b.Min[col], m[col][row]*b.Max[col]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			rval.Min[row] += lo
+			rval.Max[row] += hi
+		}
+	}
+	return rval
+}
+
+// BoundingSphere is a sphere containing some geometry. A sphere with a negative radius is empty; EmptySphere returns
+// one.
+type BoundingSphere struct {
+	Center vkm.Vec3
+	Radius float32
+}
+
+func EmptySphere() BoundingSphere {
+	return BoundingSphere{Radius: -1}
+}
+
+func (s BoundingSphere) IsEmpty() bool {
+	return s.Radius < 0
+}
+
+// Union returns a sphere containing both s and o. It is the smallest such sphere.
+func (s BoundingSphere) Union(o BoundingSphere) BoundingSphere {
+	if o.IsEmpty() {
+		return s
+	} else if s.IsEmpty() {
+		return o
+	}
+
+	d := o.Center.Sub(s.Center).Length()
+	if d+o.Radius <= s.Radius {
+		return s
+	} else if d+s.Radius <= o.Radius {
+		return o
+	}
+	r := (d + s.Radius + o.Radius) / 2
+	return BoundingSphere{
+		Center: s.Center.Add(o.Center.Sub(s.Center).Scale((r - s.Radius) / d)),
+		Radius: r,
+	}
+}
+
+// Transform returns a sphere containing s after it is transformed by m, which must be affine. Non-uniform scales give a
+// sphere large enough for the largest scale.
+func (s BoundingSphere) Transform(m vkm.Mat) BoundingSphere {
+	if s.IsEmpty() {
+		return s
+	}
+	scale := float32(0)
+	for col := 0; col < 3; col++ {
+		scale = math32.Max(scale, vkm.Vec3{m[col][0], m[col][1], m[col][2]}.Length())
+	}
+	return BoundingSphere{transformPoint(m, s.Center), s.Radius * scale}
+}
+
+// Bounds holds both kinds of bounding volume for some geometry.
+type Bounds struct {
+	Box    AABB
+	Sphere BoundingSphere
+}
+
+func EmptyBounds() Bounds {
+	return Bounds{EmptyAABB(), EmptySphere()}
+}
+
+func (b Bounds) IsEmpty() bool {
+	return b.Box.IsEmpty()
+}
+
+func (b Bounds) Union(o Bounds) Bounds {
+	return Bounds{b.Box.Union(o.Box), b.Sphere.Union(o.Sphere)}
+}
+
+func (b Bounds) Transform(m vkm.Mat) Bounds {
+	return Bounds{b.Box.Transform(m), b.Sphere.Transform(m)}
+}
+
+// ComputeBounds returns the per-component minimum and maximum of the accessor's data, as they would be written to
+// Min and Max. Normalized integer components are reported in their normalized form.
+func (a *ResolvedAccessor) ComputeBounds() (min, max []float64, err error) {
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, nil, err
+	}
+	n := a.Type.Count()
+	if len(values) < n {
+		return nil, nil, nil
+	}
+	min, max = make([]float64, n), make([]float64, n)
+	for c := 0; c < n; c++ {
+		min[c], max[c] = math.Inf(1), math.Inf(-1)
+	}
+	for i, v := range values {
+		min[i%n] = math.Min(min[i%n], float64(v))
+		max[i%n] = math.Max(max[i%n], float64(v))
+	}
+	return min, max, nil
+}
+
+// CheckBounds verifies the accessor's Min and Max, if it has them, against its data. Float data is compared with a
+// small relative tolerance, to allow for rounding by the exporter.
+func (a *ResolvedAccessor) CheckBounds() error {
+	if a.Min == nil && a.Max == nil {
+		return nil
+	}
+	n := a.Type.Count()
+	if len(a.Min) != n || len(a.Max) != n {
+		return fmt.Errorf("Accessor %q has min and max with the wrong number of components for %s", a.Name, a.Type)
+	}
+
+	min, max, err := a.ComputeBounds()
+	if err != nil || min == nil {
+		return err
+	}
+	for c := 0; c < n; c++ {
+		tolerance := 1e-5 * math.Max(1, math.Max(math.Abs(min[c]), math.Abs(max[c])))
+		if math.Abs(min[c]-a.Min[c]) > tolerance || math.Abs(max[c]-a.Max[c]) > tolerance {
+			return fmt.Errorf("Accessor %q declares bounds %v - %v, but its data has %v - %v", a.Name, a.Min, a.Max, min, max)
+		}
+	}
+	return nil
+}
+
+// positionBox returns the bounding box of a POSITION accessor, from its Min and Max when present or else its data.
+func positionBox(a *ResolvedAccessor) (AABB, error) {
+	min, max := a.Min, a.Max
+	if len(min) != 3 || len(max) != 3 {
+		var err error
+		if min, max, err = a.ComputeBounds(); err != nil {
+			return AABB{}, err
+		} else if min == nil {
+			return EmptyAABB(), nil
+		}
+	}
+	return AABB{
+		vkm.Vec3{float32(min[0]), float32(min[1]), float32(min[2])},
+		vkm.Vec3{float32(max[0]), float32(max[1]), float32(max[2])},
+	}, nil
+}
+
+// morphExtent returns the box that the primitive's morph targets can move a vertex within, relative to its base
+// position, assuming weights between 0 and 1.
+func (p *ResolvedPrimitive) morphExtent() (AABB, error) {
+	rval := AABB{}
+	for i, target := range p.Targets {
+		a := target[POSITION]
+		if a == nil {
+			continue
+		}
+		box, err := positionBox(a)
+		if err != nil {
+			return rval, fmt.Errorf("Morph target %d: %w", i, err)
+		} else if box.IsEmpty() {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			rval.Min[c] += math32.Min(box.Min[c], 0)
+			rval.Max[c] += math32.Max(box.Max[c], 0)
+		}
+	}
+	return rval, nil
+}
+
+// Bounds returns the bounding volumes of the primitive, in its local space. The box is taken from the Min and Max of the
+// POSITION accessor when present, or else computed from its data; the sphere always needs the data. Morph targets are
+// accounted for conservatively, assuming weights between 0 and 1, from the Min and Max of their POSITION accessors.
+func (p *ResolvedPrimitive) Bounds() (Bounds, error) {
+	posAcc := p.Attributes[POSITION]
+	if posAcc == nil {
+		return EmptyBounds(), errors.New("Primitive has no POSITION attribute")
+	}
+
+	box, err := positionBox(posAcc)
+	if err != nil || box.IsEmpty() {
+		return EmptyBounds(), err
+	}
+	pos, err := posAcc.ReadVec3s()
+	if err != nil {
+		return EmptyBounds(), err
+	}
+	sphere := BoundingSphere{Center: box.Center()}
+	for _, v := range pos {
+		sphere.Radius = math32.Max(sphere.Radius, v.Sub(sphere.Center).Length())
+	}
+
+	morph, err := p.morphExtent()
+	if err != nil {
+		return EmptyBounds(), err
+	}
+	box.Min, box.Max = box.Min.Add(morph.Min), box.Max.Add(morph.Max)
+	for c := 0; c < 3; c++ {
+		morph.Max[c] = math32.Max(morph.Max[c], -morph.Min[c])
+	}
+	sphere.Radius += morph.Max.Length()
+
+	return Bounds{box, sphere}, nil
+}
+
+// Bounds returns the union of the bounding volumes of the mesh's primitives.
+func (m *ResolvedMesh) Bounds() (Bounds, error) {
+	rval := EmptyBounds()
+	for i := range m.Primitives {
+		b, err := m.Primitives[i].Bounds()
+		if err != nil {
+			return rval, fmt.Errorf("Primitive %d: %w", i, err)
+		}
+		rval = rval.Union(b)
+	}
+	return rval, nil
+}
+
+// BoundsOptions controls the bounds computed for scenes and nodes.
+type BoundsOptions struct {
+	// Pose is the pose to compute bounds for; nil is the rest pose.
+	Pose Pose
+	// Animations are sampled at each of their keyframes, and the bounds cover every sampled pose as well as Pose.
+	// Motion between keyframes is not accounted for.
+	Animations []*ResolvedAnimation
+	// Skinning bounds skinned meshes by the poses of their joints, as they are drawn. Otherwise they are bounded by
+	// their bind pose, transformed by the node that holds the mesh.
+	Skinning bool
+}
+
+// Bounds returns the world space bounding volumes of everything in the scene.
+func (s *ResolvedScene) Bounds(opts BoundsOptions) (Bounds, error) {
+	nodes, err := s.NodeBounds(opts)
+	if err != nil {
+		return EmptyBounds(), err
+	}
+	rval := EmptyBounds()
+	for _, n := range s.Nodes {
+		if b, found := nodes[n]; found {
+			rval = rval.Union(b)
+		}
+	}
+	return rval, nil
+}
+
+// NodeBounds returns the world space bounding volumes of every node in the scene that has a mesh, either on itself or
+// on one of its descendants, covering the node and all of its descendants. A node with EXT_mesh_gpu_instancing is
+// bounded by every instance of its mesh.
+func (s *ResolvedScene) NodeBounds(opts BoundsOptions) (map[*ResolvedNode]Bounds, error) {
+	poses := []Pose{opts.Pose}
+	for i, a := range opts.Animations {
+		times, err := a.KeyTimes()
+		if err != nil {
+			return nil, fmt.Errorf("Animation %d: %w", i, err)
+		}
+		for _, t := range times {
+			animated, err := a.Pose(t)
+			if err != nil {
+				return nil, fmt.Errorf("Animation %d: %w", i, err)
+			}
+			for n, m := range opts.Pose {
+				if _, found := animated[n]; !found {
+					animated[n] = m
+				}
+			}
+			poses = append(poses, animated)
+		}
+	}
+
+	c := boundsCache{
+		meshes: make(map[*ResolvedMesh]Bounds),
+		joints: make(map[*ResolvedPrimitive][]jointBounds),
+	}
+	rval := make(map[*ResolvedNode]Bounds)
+	for _, pose := range poses {
+		world := s.WorldMatrices(pose)
+		visited := make(map[*ResolvedNode]bool)
+		for _, n := range s.Nodes {
+			if _, err := c.subtree(n, world, opts.Skinning, visited, rval); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rval, nil
+}
+
+// boundsCache holds the local bounds of meshes and skinned primitives, which do not change between poses.
+type boundsCache struct {
+	meshes map[*ResolvedMesh]Bounds
+	joints map[*ResolvedPrimitive][]jointBounds
+}
+
+// jointBounds is the bounding box, in the space of a joint, of the vertices it influences.
+type jointBounds struct {
+	joint int
+	box   AABB
+}
+
+func (c *boundsCache) subtree(n *ResolvedNode, world map[*ResolvedNode]vkm.Mat, skinning bool, visited map[*ResolvedNode]bool, out map[*ResolvedNode]Bounds) (Bounds, error) {
+	rval := EmptyBounds()
+	if visited[n] {
+		return rval, nil
+	}
+	visited[n] = true
+
+	if n.Mesh != nil {
+		// Skinning is not combined with instancing, so each instance is bounded by its bind pose.
+		if skinning && n.Skin != nil && n.Instances == nil {
+			for i := range n.Mesh.Primitives {
+				b, err := c.skinnedBounds(&n.Mesh.Primitives[i], n.Skin, world, world[n])
+				if err != nil {
+					return rval, fmt.Errorf("Node %q: %w", n.Name, err)
+				}
+				rval = rval.Union(b)
+			}
+		} else {
+			local, found := c.meshes[n.Mesh]
+			if !found {
+				var err error
+				if local, err = n.Mesh.Bounds(); err != nil {
+					return rval, fmt.Errorf("Node %q: %w", n.Name, err)
+				}
+				c.meshes[n.Mesh] = local
+			}
+			for _, m := range n.InstanceWorldMatrices(world[n]) {
+				rval = rval.Union(local.Transform(m))
+			}
+		}
+	}
+
+	for _, child := range n.Children {
+		b, err := c.subtree(child, world, skinning, visited, out)
+		if err != nil {
+			return rval, err
+		}
+		rval = rval.Union(b)
+	}
+
+	if !rval.IsEmpty() {
+		if existing, found := out[n]; found {
+			rval = existing.Union(rval)
+		}
+		out[n] = rval
+	}
+	return rval, nil
+}
+
+// skinnedBounds bounds a skinned primitive in world space. Each skinned vertex is a weighted average of the vertex
+// transformed by each of its joints, so it lies within the union of the per-joint boxes transformed by their joints.
+func (c *boundsCache) skinnedBounds(p *ResolvedPrimitive, skin *ResolvedSkin, world map[*ResolvedNode]vkm.Mat, fallback vkm.Mat) (Bounds, error) {
+	joints, found := c.joints[p]
+	if !found {
+		var err error
+		if joints, err = p.jointBounds(skin); err != nil {
+			return EmptyBounds(), err
+		}
+		c.joints[p] = joints
+	}
+
+	box := EmptyAABB()
+	for _, jb := range joints {
+		m, found := world[skin.Joints[jb.joint]]
+		if !found {
+			m = fallback
+		}
+		box = box.Union(jb.box.Transform(m))
+	}
+	return Bounds{box, BoundingSphere{box.Center(), box.Size().Length() / 2}}, nil
+}
+
+// jointBounds computes the box of the vertices influenced by each joint of the skin, in the joint's space.
+func (p *ResolvedPrimitive) jointBounds(skin *ResolvedSkin) ([]jointBounds, error) {
+	posAcc := p.Attributes[POSITION]
+	if posAcc == nil {
+		return nil, errors.New("Primitive has no POSITION attribute")
+	}
+	pos, err := posAcc.ReadVec3s()
+	if err != nil {
+		return nil, err
+	}
+	morph, err := p.morphExtent()
+	if err != nil {
+		return nil, err
+	}
+
+	inverseBind := make([]vkm.Mat, len(skin.Joints))
+	for i := range inverseBind {
+		inverseBind[i] = vkm.Identity()
+	}
+	if skin.InverseBindMatrices != nil {
+		values, err := skin.InverseBindMatrices.ReadFloats()
+		if err != nil {
+			return nil, err
+		}
+		for i := range inverseBind {
+			if len(values) >= 16*(i+1) {
+				for col := 0; col < 4; col++ {
+					copy(inverseBind[i][col][:], values[16*i+4*col:16*i+4*col+4])
+				}
+			}
+		}
+	}
+
+	boxes := make([]AABB, len(skin.Joints))
+	for i := range boxes {
+		boxes[i] = EmptyAABB()
+	}
+	for set := uint(0); ; set++ {
+		jointsAcc, weightsAcc := p.Attributes[AttributeKey(fmt.Sprintf("JOINTS_%d", set))], p.Attributes[AttributeKey(fmt.Sprintf("WEIGHTS_%d", set))]
+		if jointsAcc == nil || weightsAcc == nil {
+			break
+		}
+		js, err := jointsAcc.ReadFloats()
+		if err != nil {
+			return nil, err
+		}
+		ws, err := weightsAcc.ReadFloats()
+		if err != nil {
+			return nil, err
+		}
+		if len(js) < 4*len(pos) || len(ws) < 4*len(pos) {
+			return nil, errors.New("Primitive JOINTS and WEIGHTS attributes have too few elements")
+		}
+		for v, p := range pos {
+			for k := 0; k < 4; k++ {
+				if ws[4*v+k] <= 0 {
+					continue
+				}
+				j := int(js[4*v+k])
+				if j >= len(boxes) {
+					return nil, fmt.Errorf("Vertex %d uses joint %d, but the skin has %d joints", v, j, len(boxes))
+				}
+				boxes[j] = boxes[j].Extend(transformPoint(inverseBind[j], p))
+			}
+		}
+	}
+
+	var rval []jointBounds
+	for j, box := range boxes {
+		if box.IsEmpty() {
+			continue
+		}
+		// The morph extent is a box of offsets, so only the linear part of the inverse bind matrix applies.
+		linear := inverseBind[j]
+		linear[3] = vkm.Vec{0, 0, 0, 1}
+		offsets := morph.Transform(linear)
+		box.Min, box.Max = box.Min.Add(offsets.Min), box.Max.Add(offsets.Max)
+		rval = append(rval, jointBounds{j, box})
+	}
+	return rval, nil
+}
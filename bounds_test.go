@@ -0,0 +1,97 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// animatedTriangleDoc has a triangle with a morph target that lifts its first vertex, on a scaled node under a
+// translated parent, and an animation that moves the scaled node up by 5 over one second.
+const animatedTriangleDoc = `{
+	"asset": {"version": "2.0"},
+	"scene": 0,
+	"scenes": [{"nodes": [0]}],
+	"nodes": [
+		{"translation": [10, 0, 0], "children": [1]},
+		{"mesh": 0, "scale": [2, 2, 2]}
+	],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0}, "targets": [{"POSITION": 1}]}]}],
+	"animations": [{
+		"channels": [{"sampler": 0, "target": {"node": 1, "path": "translation"}}],
+		"samplers": [{"input": 2, "output": 3}]
+	}],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+		{"bufferView": 0, "byteOffset": 36, "componentType": 5126, "count": 3, "type": "VEC3", "min": [0, 0, 0], "max": [0, 0, 2]},
+		{"bufferView": 0, "byteOffset": 72, "componentType": 5126, "count": 2, "type": "SCALAR"},
+		{"bufferView": 0, "byteOffset": 80, "componentType": 5126, "count": 2, "type": "VEC3"}
+	],
+	"bufferViews": [{"buffer": 0, "byteLength": 104}],
+	"buffers": [{"uri": "animated.bin", "byteLength": 104}]
+}`
+
+func animatedTriangle(t *testing.T) *ResolvedGlTF {
+	data := float32Bytes(
+		0, 0, 0, 1, 0, 0, 0, 1, 0, // positions
+		0, 0, 2, 0, 0, 0, 0, 0, 0, // morph target displacements
+		0, 1, // keyframe times
+		0, 0, 0, 0, 5, 0, // translations
+	)
+	return resolveTestDoc(t, animatedTriangleDoc, map[string][]byte{"animated.bin": data})
+}
+
+func approxBox(a, b AABB) bool {
+	return approxVec3(a.Min, b.Min) && approxVec3(a.Max, b.Max)
+}
+
+func TestPrimitiveBounds(t *testing.T) {
+	root := animatedTriangle(t)
+
+	b, err := root.Meshes[0].Primitives[0].Bounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (AABB{vkm.Vec3{0, 0, 0}, vkm.Vec3{1, 1, 2}}); !approxBox(b.Box, expected) {
+		t.Errorf("expected box %v including the morph target, got %v", expected, b.Box)
+	}
+	if b.Sphere.Radius < 1.5 {
+		t.Errorf("sphere %v does not contain the morphed vertex", b.Sphere)
+	}
+
+	if err := root.Accessors[1].CheckBounds(); err != nil {
+		t.Error(err)
+	}
+	root.Accessors[1].Max = []float64{0, 0, 1}
+	if err := root.Accessors[1].CheckBounds(); err == nil {
+		t.Error("expected an error for an accessor with the wrong max")
+	}
+}
+
+func TestSceneBounds(t *testing.T) {
+	root := animatedTriangle(t)
+
+	rest, err := root.Scene.Bounds(BoundsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (AABB{vkm.Vec3{10, 0, 0}, vkm.Vec3{12, 2, 4}}); !approxBox(rest.Box, expected) {
+		t.Errorf("expected rest pose box %v, got %v", expected, rest.Box)
+	}
+
+	animated, err := root.Scene.NodeBounds(BoundsOptions{Animations: []*ResolvedAnimation{&root.Animations[0]}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (AABB{vkm.Vec3{10, 0, 0}, vkm.Vec3{12, 7, 4}}); !approxBox(animated[&root.Nodes[0]].Box, expected) {
+		t.Errorf("expected animated box %v, got %v", expected, animated[&root.Nodes[0]].Box)
+	}
+
+	pose, err := root.Animations[0].Pose(0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := pose[&root.Nodes[1]]; !approxVec3(vkm.Vec3{m[3][0], m[3][1], m[3][2]}, vkm.Vec3{0, 2.5, 0}) || m[0][0] != 2 {
+		t.Errorf("unexpected pose at t=0.5: %v", m)
+	}
+}
@@ -0,0 +1,347 @@
+package gltf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// BVH is a bounding volume hierarchy over the world space triangles of a scene, for ray casting. It is a snapshot:
+// changes to the scene or its pose after it is built are not reflected.
+type BVH struct {
+	triangles []bvhTriangle
+	nodes     []bvhNode
+}
+
+type bvhTriangle struct {
+	p         [3]vkm.Vec3 // world space positions
+	n         [3]vkm.Vec3 // world space normals, if the primitive has them
+	uv        [3]vkm.Vec2
+	hasNormal bool
+	hasUV     bool
+	node      *ResolvedNode
+	primitive *ResolvedPrimitive
+	index     int
+}
+
+// bvhNode is an inner node if count is zero, with children at first and first+1, or else a leaf holding count
+// triangles starting at first.
+type bvhNode struct {
+	box          AABB
+	first, count int
+}
+
+// Ray is a half-line from Origin in Direction, which need not be normalized.
+type Ray struct {
+	Origin, Direction vkm.Vec3
+}
+
+// RayHit describes where a ray hits a triangle.
+type RayHit struct {
+	// Distance is the ray parameter of the hit, i.e. Point = Origin + Distance * Direction.
+	Distance float32
+	Point    vkm.Vec3
+	Node     *ResolvedNode
+	// Primitive is the primitive that was hit, and Triangle the index of the hit triangle in its TriangleIndices.
+	Primitive *ResolvedPrimitive
+	Triangle  int
+	// Barycentrics holds the weights of the triangle's three vertices at the hit point.
+	Barycentrics vkm.Vec3
+	// UV is interpolated from TEXCOORD_0, if the primitive has it.
+	UV    vkm.Vec2
+	HasUV bool
+	// Normal is the world space normal at the hit point, interpolated from NORMAL if the primitive has it, or else the
+	// triangle's face normal. It is normalized, and not flipped to face the ray.
+	Normal vkm.Vec3
+}
+
+// bvhLeafSize is the largest number of triangles in a leaf, and bvhBins the number of candidate splits per axis.
+const (
+	bvhLeafSize = 4
+	bvhBins     = 12
+)
+
+// BuildBVH collects every triangle in the scene in world space, in the given pose (nil for the rest pose), and builds
+// a BVH over them. Primitives that are points or lines are skipped. Skinning and morph targets are not applied. Meshes
+// with EXT_mesh_gpu_instancing contribute their triangles once per instance.
+func (s *ResolvedScene) BuildBVH(pose Pose) (*BVH, error) {
+	rval := &BVH{}
+	var err error
+	s.walk(pose, func(n *ResolvedNode, world vkm.Mat) {
+		if n.Mesh == nil || err != nil {
+			return
+		}
+		for _, m := range n.InstanceWorldMatrices(world) {
+			for i := range n.Mesh.Primitives {
+				if err = rval.addPrimitive(n, &n.Mesh.Primitives[i], m); err != nil {
+					err = fmt.Errorf("Node %q, primitive %d: %w", n.Name, i, err)
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rval.triangles) > 0 {
+		rval.nodes = append(rval.nodes, bvhNode{first: 0, count: len(rval.triangles)})
+		rval.split(0)
+	}
+	return rval, nil
+}
+
+func (b *BVH) addPrimitive(n *ResolvedNode, p *ResolvedPrimitive, world vkm.Mat) error {
+	switch p.Mode {
+	case POINTS, LINES, LINE_LOOP, LINE_STRIP:
+		return nil
+	}
+	posAcc := p.Attributes[POSITION]
+	if posAcc == nil {
+		return nil
+	}
+	pos, err := posAcc.ReadVec3s()
+	if err != nil {
+		return err
+	}
+	tris, err := p.TriangleIndices()
+	if err != nil {
+		return err
+	}
+
+	var normals []vkm.Vec3
+	if a := p.Attributes[NORMAL]; a != nil {
+		if normals, err = a.ReadVec3s(); err != nil {
+			return err
+		}
+	}
+	var uvs []vkm.Vec2
+	if a := p.Attributes[TEXCOORD_0]; a != nil {
+		if uvs, err = a.ReadVec2s(); err != nil {
+			return err
+		}
+	}
+	normalMatrix := world.Inverse().Transpose()
+
+	for t := 0; t+2 < len(tris); t += 3 {
+		tri := bvhTriangle{node: n, primitive: p, index: t / 3, hasNormal: normals != nil, hasUV: uvs != nil}
+		for k := 0; k < 3; k++ {
+			v := tris[t+k]
+			if int(v) >= len(pos) || (normals != nil && int(v) >= len(normals)) || (uvs != nil && int(v) >= len(uvs)) {
+				return fmt.Errorf("Triangle %d uses vertex %d, which is out of range", t/3, v)
+			}
+			tri.p[k] = transformPoint(world, pos[v])
+			if normals != nil {
+				tri.n[k] = transformDirection(normalMatrix, normals[v])
+			}
+			if uvs != nil {
+				tri.uv[k] = uvs[v]
+			}
+		}
+		b.triangles = append(b.triangles, tri)
+	}
+	return nil
+}
+
+func (t *bvhTriangle) box() AABB {
+	return EmptyAABB().Extend(t.p[0]).Extend(t.p[1]).Extend(t.p[2])
+}
+
+func (t *bvhTriangle) centroid() vkm.Vec3 {
+	return t.p[0].Add(t.p[1]).Add(t.p[2]).Scale(1.0 / 3)
+}
+
+func surfaceArea(b AABB) float32 {
+	if b.IsEmpty() {
+		return 0
+	}
+	d := b.Size()
+	return 2 * (d[0]*d[1] + d[1]*d[2] + d[2]*d[0])
+}
+
+// split computes the bounds of node i and, if it has too many triangles, splits it using the surface area heuristic
+// over binned centroids.
+func (b *BVH) split(i int) {
+	node := &b.nodes[i]
+	tris := b.triangles[node.first : node.first+node.count]
+	node.box = EmptyAABB()
+	centroids := EmptyAABB()
+	for k := range tris {
+		node.box = node.box.Union(tris[k].box())
+		centroids = centroids.Extend(tris[k].centroid())
+	}
+	if len(tris) <= bvhLeafSize {
+		return
+	}
+
+	bestAxis, bestSplit, bestCost := -1, 0, float32(math.Inf(1))
+	for axis := 0; axis < 3; axis++ {
+		lo, extent := centroids.Min[axis], centroids.Max[axis]-centroids.Min[axis]
+		if extent <= 0 {
+			continue
+		}
+		var boxes [bvhBins]AABB
+		var counts [bvhBins]int
+		for k := range boxes {
+			boxes[k] = EmptyAABB()
+		}
+		for k := range tris {
+			bin := binOf(tris[k].centroid()[axis], lo, extent)
+			boxes[bin] = boxes[bin].Union(tris[k].box())
+			counts[bin]++
+		}
+
+		// Cost of splitting after each bin, sweeping from both ends.
+		var rightArea [bvhBins]float32
+		var rightCount [bvhBins]int
+		box, count := EmptyAABB(), 0
+		for k := bvhBins - 1; k > 0; k-- {
+			box, count = box.Union(boxes[k]), count+counts[k]
+			rightArea[k], rightCount[k] = surfaceArea(box), count
+		}
+		box, count = EmptyAABB(), 0
+		for k := 0; k < bvhBins-1; k++ {
+			box, count = box.Union(boxes[k]), count+counts[k]
+			if count == 0 || rightCount[k+1] == 0 {
+				continue
+			}
+			if cost := surfaceArea(box)*float32(count) + rightArea[k+1]*float32(rightCount[k+1]); cost < bestCost {
+				bestAxis, bestSplit, bestCost = axis, k, cost
+			}
+		}
+	}
+
+	if bestAxis < 0 || bestCost >= surfaceArea(node.box)*float32(len(tris)) {
+		return
+	}
+
+	// Partition the triangles in place around the chosen split.
+	lo, extent := centroids.Min[bestAxis], centroids.Max[bestAxis]-centroids.Min[bestAxis]
+	mid := 0
+	for k := range tris {
+		if binOf(tris[k].centroid()[bestAxis], lo, extent) <= bestSplit {
+			tris[k], tris[mid] = tris[mid], tris[k]
+			mid++
+		}
+	}
+
+	first, count := node.first, node.count
+	children := len(b.nodes)
+	node.first, node.count = children, 0
+	b.nodes = append(b.nodes, bvhNode{first: first, count: mid}, bvhNode{first: first + mid, count: count - mid})
+	b.split(children)
+	b.split(children + 1)
+}
+
+func binOf(x, lo, extent float32) int {
+	bin := int(float32(bvhBins) * (x - lo) / extent)
+	if bin >= bvhBins {
+		bin = bvhBins - 1
+	} else if bin < 0 {
+		bin = 0
+	}
+	return bin
+}
+
+// Raycast returns the nearest hit of the ray on any triangle, within maxDistance along the ray (in units of the ray's
+// direction), or false if there is none. Pass float32(math.Inf(1)) for an unlimited distance. Triangles are hit from either side.
+func (b *BVH) Raycast(ray Ray, maxDistance float32) (RayHit, bool) {
+	var hit RayHit
+	if len(b.nodes) == 0 {
+		return hit, false
+	}
+
+	var inv vkm.Vec3
+	for c := 0; c < 3; c++ {
+		inv[c] = 1 / ray.Direction[c]
+	}
+
+	best, bestT, bestU, bestV := -1, maxDistance, float32(0), float32(0)
+	stack := []int{0}
+	for len(stack) > 0 {
+		node := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+		if near, ok := rayBox(ray.Origin, inv, node.box); !ok || near > bestT {
+			continue
+		}
+
+		if node.count == 0 {
+			stack = append(stack, node.first, node.first+1)
+			continue
+		}
+		for k := node.first; k < node.first+node.count; k++ {
+			if t, u, v, ok := rayTriangle(ray, &b.triangles[k]); ok && t <= bestT {
+				best, bestT, bestU, bestV = k, t, u, v
+			}
+		}
+	}
+	if best < 0 {
+		return hit, false
+	}
+
+	tri := &b.triangles[best]
+	w := vkm.Vec3{1 - bestU - bestV, bestU, bestV}
+	hit = RayHit{
+		Distance:     bestT,
+		Point:        ray.Origin.Add(ray.Direction.Scale(bestT)),
+		Node:         tri.node,
+		Primitive:    tri.primitive,
+		Triangle:     tri.index,
+		Barycentrics: w,
+		HasUV:        tri.hasUV,
+	}
+	if tri.hasUV {
+		hit.UV = tri.uv[0].Scale(w[0]).Add(tri.uv[1].Scale(w[1])).Add(tri.uv[2].Scale(w[2]))
+	}
+	face := normalizeOr(cross3(tri.p[1].Sub(tri.p[0]), tri.p[2].Sub(tri.p[0])), defaultNormal)
+	hit.Normal = face
+	if tri.hasNormal {
+		hit.Normal = normalizeOr(tri.n[0].Scale(w[0]).Add(tri.n[1].Scale(w[1])).Add(tri.n[2].Scale(w[2])), face)
+	}
+	return hit, true
+}
+
+// rayBox returns the ray parameter at which the ray enters the box, and whether it hits the box at all.
+func rayBox(origin, inv vkm.Vec3, box AABB) (float32, bool) {
+	near, far := float32(0), float32(math.Inf(1))
+	for c := 0; c < 3; c++ {
+		t0, t1 := (box.Min[c]-origin[c])*inv[c], (box.Max[c]-origin[c])*inv[c]
+		if t0 > t1 {
+			t0, t1 = t1, t0
+		}
+		// NaN from 0 * Inf (the origin on a slab boundary of an axis-parallel ray) is treated as no constraint.
+		if t0 == t0 {
+			near = math32.Max(near, t0)
+		}
+		if t1 == t1 {
+			far = math32.Min(far, t1)
+		}
+	}
+	return near, near <= far
+}
+
+// rayTriangle intersects a ray with a triangle (Möller and Trumbore, 1997), returning the ray parameter and the
+// barycentric weights of the second and third vertices.
+func rayTriangle(ray Ray, tri *bvhTriangle) (t, u, v float32, ok bool) {
+	e1, e2 := tri.p[1].Sub(tri.p[0]), tri.p[2].Sub(tri.p[0])
+	p := cross3(ray.Direction, e2)
+	det := e1.Dot(p)
+	if math32.Abs(det) < 1e-12 {
+		return 0, 0, 0, false
+	}
+	invDet := 1 / det
+	s := ray.Origin.Sub(tri.p[0])
+	u = s.Dot(p) * invDet
+	if u < 0 || u > 1 {
+		return 0, 0, 0, false
+	}
+	q := cross3(s, e1)
+	v = ray.Direction.Dot(q) * invDet
+	if v < 0 || u+v > 1 {
+		return 0, 0, 0, false
+	}
+	t = e2.Dot(q) * invDet
+	return t, u, v, t >= 0
+}
@@ -0,0 +1,85 @@
+package gltf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+func TestRaycast(t *testing.T) {
+	// Two copies of a textured quad facing +Z: one at the origin, and one in front of it, moved towards +Z and scaled.
+	near := &ResolvedNode{Node: &Node{Name: "near", Translation: &vkm.Vec3{0, 0, 1}, Scale: &vkm.Vec3{2, 2, 2}}}
+	far := &ResolvedNode{Node: &Node{Name: "far"}}
+	quad := &ResolvedMesh{Mesh: &Mesh{}, Primitives: []ResolvedPrimitive{*uvQuad(0, 1, 1, 1, 1, 0, 0, 0)}}
+	near.Mesh, far.Mesh = quad, quad
+	scene := &ResolvedScene{Scene: &Scene{}, Nodes: []*ResolvedNode{far, near}}
+
+	bvh, err := scene.BuildBVH(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hit, ok := bvh.Raycast(Ray{vkm.Vec3{0.5, 0.25, 10}, vkm.Vec3{0, 0, -1}}, float32(math.Inf(1)))
+	if !ok {
+		t.Fatal("expected a hit")
+	}
+	if hit.Node != near || hit.Triangle != 0 || math.Abs(float64(hit.Distance-9)) > 1e-5 {
+		t.Errorf("expected to hit the near quad's first triangle at distance 9, got %q triangle %d at %f", hit.Node.Name, hit.Triangle, hit.Distance)
+	}
+	if !approxVec3(hit.Barycentrics, vkm.Vec3{0.75, 0.125, 0.125}) {
+		t.Errorf("unexpected barycentrics %v", hit.Barycentrics)
+	}
+	if !hit.HasUV || math.Abs(float64(hit.UV[0]-0.25)) > 1e-5 || math.Abs(float64(hit.UV[1]-0.875)) > 1e-5 {
+		t.Errorf("unexpected UV %v", hit.UV)
+	}
+	if !approxVec3(hit.Normal, vkm.Vec3{0, 0, 1}) {
+		t.Errorf("unexpected normal %v", hit.Normal)
+	}
+
+	// Outside the near quad, only the far one is hit; beyond the maximum distance, nothing is.
+	if hit, ok := bvh.Raycast(Ray{vkm.Vec3{0.5, 0.5, -10}, vkm.Vec3{0, 0, 2}}, float32(math.Inf(1))); !ok || hit.Node != far || hit.Distance != 5 {
+		t.Errorf("expected to hit the far quad from behind at distance 5, got %v %v", ok, hit.Distance)
+	}
+	if _, ok := bvh.Raycast(Ray{vkm.Vec3{0.5, 0.5, -10}, vkm.Vec3{0, 0, 2}}, 4); ok {
+		t.Error("expected no hit within the maximum distance")
+	}
+	if _, ok := bvh.Raycast(Ray{vkm.Vec3{3, 3, 10}, vkm.Vec3{0, 0, -1}}, float32(math.Inf(1))); ok {
+		t.Error("expected a miss")
+	}
+}
+
+func TestRaycastMatchesBruteForce(t *testing.T) {
+	grid := shuffledGrid(30)
+	scene := &ResolvedScene{Scene: &Scene{}, Nodes: []*ResolvedNode{{
+		Node: &Node{Rotation: &vkm.Vec{0.3826834, 0, 0, 0.9238795}},
+		Mesh: &ResolvedMesh{Mesh: &Mesh{}, Primitives: []ResolvedPrimitive{*grid}},
+	}}}
+	bvh, err := scene.BuildBVH(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bvh.nodes) < 100 {
+		t.Fatalf("expected the grid to be split into many nodes, got %d", len(bvh.nodes))
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 200; i++ {
+		ray := Ray{
+			vkm.Vec3{rng.Float32() * 30, rng.Float32() * 30, 20},
+			vkm.Vec3{rng.Float32() - 0.5, rng.Float32() - 0.5, -1},
+		}
+		best, bestT := -1, float32(math.Inf(1))
+		for k := range bvh.triangles {
+			if t, _, _, ok := rayTriangle(ray, &bvh.triangles[k]); ok && t < bestT {
+				best, bestT = k, t
+			}
+		}
+
+		hit, ok := bvh.Raycast(ray, float32(math.Inf(1)))
+		if ok != (best >= 0) || (ok && hit.Distance != bestT) {
+			t.Fatalf("ray %v: BVH found %v at %f, brute force found %v at %f", ray, ok, hit.Distance, best >= 0, bestT)
+		}
+	}
+}
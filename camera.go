@@ -0,0 +1,108 @@
+package gltf
+
+import (
+	"errors"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// Projection returns the camera's projection matrix, as defined in the glTF spec, for a viewport with the given aspect
+// ratio (width / height). If aspect is zero, the camera's own AspectRatio is used instead, or for an orthographic
+// camera its Xmag; it is an error if a perspective camera has neither. Otherwise the camera's AspectRatio is ignored, and
+// an orthographic camera keeps its Ymag with a horizontal magnification that matches the viewport. A perspective camera
+// without a Zfar has an infinite far plane.
+func (c *Camera) Projection(aspect float32) (vkm.Mat, error) {
+	if aspect < 0 {
+		return vkm.Mat{}, errors.New("Projection aspect ratio must not be negative")
+	}
+	switch c.Type {
+	case PERSPECTIVE:
+		p := &c.Perspective
+		if aspect == 0 {
+			aspect = p.AspectRatio
+		}
+		if aspect <= 0 {
+			return vkm.Mat{}, errors.New("Perspective camera has no aspect ratio, and none was given for the viewport")
+		}
+		if p.Yfov <= 0 || p.Znear <= 0 {
+			return vkm.Mat{}, errors.New("Perspective camera must have a positive yfov and znear")
+		}
+		f := 1 / math32.Tan(p.Yfov/2)
+		m := vkm.Mat{{f / aspect, 0, 0, 0}, {0, f, 0, 0}, {0, 0, -1, -1}, {0, 0, -2 * p.Znear, 0}}
+		if p.Zfar > 0 {
+			if p.Zfar <= p.Znear {
+				return vkm.Mat{}, errors.New("Perspective camera zfar must be greater than znear")
+			}
+			m[2][2] = (p.Zfar + p.Znear) / (p.Znear - p.Zfar)
+			m[3][2] = 2 * p.Zfar * p.Znear / (p.Znear - p.Zfar)
+		}
+		return m, nil
+
+	case ORTHOGRAPHIC:
+		o := &c.Orthographic
+		if o.Ymag == 0 || o.Zfar <= o.Znear {
+			return vkm.Mat{}, errors.New("Orthographic camera must have a non-zero ymag, and zfar greater than znear")
+		}
+		xmag := o.Xmag
+		if aspect > 0 {
+			xmag = o.Ymag * aspect
+		} else if xmag == 0 {
+			return vkm.Mat{}, errors.New("Orthographic camera must have a non-zero xmag")
+		}
+		return vkm.Mat{
+			{1 / xmag, 0, 0, 0},
+			{0, 1 / o.Ymag, 0, 0},
+			{0, 0, 2 / (o.Znear - o.Zfar), 0},
+			{0, 0, (o.Zfar + o.Znear) / (o.Znear - o.Zfar), 1},
+		}, nil
+
+	case "":
+		return vkm.Mat{}, errors.New("Camera type not set on camera node")
+	default:
+		return vkm.Mat{}, errors.New("Camera type not recognized: " + string(c.Type))
+	}
+}
+
+// CameraInstance is a camera placed in a scene by a node.
+type CameraInstance struct {
+	Node   *ResolvedNode
+	Camera *ResolvedCamera
+	// World is the camera's transform to world space, and View its inverse, the view matrix. Any scale in the node's
+	// world transform is removed, so that it does not distort the view.
+	World, View vkm.Mat
+}
+
+// ViewProjection returns the product of the camera's projection, for a viewport with the given aspect ratio as
+// described for Camera.Projection, and its view matrix.
+func (ci *CameraInstance) ViewProjection(aspect float32) (vkm.Mat, error) {
+	proj, err := ci.Camera.Projection(aspect)
+	if err != nil {
+		return vkm.Mat{}, err
+	}
+	return proj.MultM(ci.View), nil
+}
+
+// CameraInstances returns every node in the scene that has a camera, in the given pose (nil for the rest pose), in the
+// order that the scene is traversed: depth first, in document order.
+func (s *ResolvedScene) CameraInstances(pose Pose) []CameraInstance {
+	var rval []CameraInstance
+	s.walk(pose, func(n *ResolvedNode, world vkm.Mat) {
+		if n.Camera == nil {
+			return
+		}
+		world = withoutScale(world)
+		rval = append(rval, CameraInstance{Node: n, Camera: n.Camera, World: world, View: world.Inverse()})
+	})
+	return rval
+}
+
+// withoutScale returns m with the first three columns normalized, which removes scale from a transform that has no
+// shear.
+func withoutScale(m vkm.Mat) vkm.Mat {
+	for col := 0; col < 3; col++ {
+		v := normalizeOr(vkm.Vec3{m[col][0], m[col][1], m[col][2]}, vkm.Vec3{})
+		m[col] = vkm.Vec{v[0], v[1], v[2], 0}
+	}
+	return m
+}
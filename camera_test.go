@@ -0,0 +1,84 @@
+package gltf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// ndc projects a view space point into normalized device coordinates.
+func ndc(m vkm.Mat, p vkm.Vec3) vkm.Vec3 {
+	c := m.MultV(vkm.Vec{p[0], p[1], p[2], 1})
+	return vkm.Vec3{c[0] / c[3], c[1] / c[3], c[2] / c[3]}
+}
+
+func TestProjection(t *testing.T) {
+	finite := &Camera{Type: PERSPECTIVE, Perspective: CameraPerpsective{Yfov: math.Pi / 2, Znear: 1, Zfar: 10}}
+	if _, err := finite.Projection(0); err == nil {
+		t.Error("expected an error for a perspective camera without any aspect ratio")
+	}
+	m, err := finite.Projection(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := ndc(m, vkm.Vec3{2, 1, -1}); !approxVec3(p, vkm.Vec3{1, 1, -1}) {
+		t.Errorf("expected the top right corner of the near plane, got %v", p)
+	}
+	if p := ndc(m, vkm.Vec3{0, 0, -10}); !approxVec3(p, vkm.Vec3{0, 0, 1}) {
+		t.Errorf("expected the far plane at depth 1, got %v", p)
+	}
+
+	infinite := &Camera{Type: PERSPECTIVE, Perspective: CameraPerpsective{Yfov: math.Pi / 2, Znear: 1, AspectRatio: 1}}
+	if m, err = infinite.Projection(0); err != nil {
+		t.Fatal(err)
+	}
+	if p := ndc(m, vkm.Vec3{0, 0, -1}); !approxVec3(p, vkm.Vec3{0, 0, -1}) {
+		t.Errorf("expected the near plane at depth -1, got %v", p)
+	}
+	if p := ndc(m, vkm.Vec3{0, 0, -1e6}); p[2] >= 1 || p[2] < 0.9999 {
+		t.Errorf("expected distant points to approach depth 1, got %v", p)
+	}
+
+	ortho := &Camera{Type: ORTHOGRAPHIC, Orthographic: CameraOrthographic{Xmag: 1, Ymag: 2, Znear: 0, Zfar: 4}}
+	if m, err = ortho.Projection(0); err != nil {
+		t.Fatal(err)
+	}
+	if p := ndc(m, vkm.Vec3{1, 2, -4}); !approxVec3(p, vkm.Vec3{1, 1, 1}) {
+		t.Errorf("expected the camera's own magnification, got %v", p)
+	}
+	if m, err = ortho.Projection(2); err != nil {
+		t.Fatal(err)
+	}
+	if p := ndc(m, vkm.Vec3{4, 2, 0}); !approxVec3(p, vkm.Vec3{1, 1, -1}) {
+		t.Errorf("expected the horizontal magnification to follow the viewport, got %v", p)
+	}
+}
+
+func TestCameraInstances(t *testing.T) {
+	cam := &ResolvedCamera{Camera: &Camera{Type: PERSPECTIVE, Perspective: CameraPerpsective{Yfov: 1, Znear: 0.1}}}
+	// A camera at (0, 0, 5) relative to a parent that is turned 90 degrees about Y and scaled, so the camera ends up at
+	// (10, 0, 0) looking along -X.
+	child := &ResolvedNode{Node: &Node{Name: "camera", Translation: &vkm.Vec3{0, 0, 5}}, Camera: cam}
+	parent := &ResolvedNode{
+		Node:     &Node{Rotation: &vkm.Vec{0, 0.7071068, 0, 0.7071068}, Scale: &vkm.Vec3{2, 2, 2}},
+		Children: []*ResolvedNode{child},
+	}
+	scene := &ResolvedScene{Scene: &Scene{}, Nodes: []*ResolvedNode{parent}}
+
+	instances := scene.CameraInstances(nil)
+	if len(instances) != 1 || instances[0].Node != child || instances[0].Camera != cam {
+		t.Fatalf("expected the one camera instance, got %v", instances)
+	}
+	view := instances[0].View
+	if p := transformPoint(view, vkm.Vec3{10, 0, 0}); !approxVec3(p, vkm.Vec3{}) {
+		t.Errorf("expected the camera position at the view space origin, got %v", p)
+	}
+	if p := transformPoint(view, vkm.Vec3{7, 0, 0}); !approxVec3(p, vkm.Vec3{0, 0, -3}) {
+		t.Errorf("expected an unscaled view looking along -X, got %v", p)
+	}
+
+	if _, err := instances[0].ViewProjection(1.5); err != nil {
+		t.Error(err)
+	}
+}
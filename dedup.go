@@ -0,0 +1,220 @@
+package gltf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+)
+
+// DedupReport lists the original indices of every object removed by Dedup, grouped by object type. Buffer views are
+// only removed when every accessor or image that referenced them was merged into a duplicate.
+type DedupReport struct {
+	Accessors   []uint
+	BufferViews []uint
+	Images      []uint
+	Materials   []uint
+	Meshes      []uint
+	Samplers    []uint
+	Textures    []uint
+}
+
+// Count returns the total number of objects removed.
+func (r DedupReport) Count() int {
+	return len(r.Accessors) + len(r.BufferViews) + len(r.Images) + len(r.Materials) + len(r.Meshes) +
+		len(r.Samplers) + len(r.Textures)
+}
+
+// Dedup merges exact duplicates of accessors, images, samplers, textures, materials and meshes, rewrites every
+// reference to point at the first instance of each, and then compacts the document. Accessors are compared by their
+// element data (after sparse substitution), images by their encoded bytes, and all other objects by their parameters
+// after references have been merged. Names are ignored in every comparison, but extensions and extras are not.
+// Accessors with neither a buffer view nor sparse values, such as those of KHR_draco_mesh_compression, are never merged.
+//
+// Dedup modifies the underlying GlTF and then re-resolves the ResolvedGlTF in place, so any pointers previously taken
+// into the resolved arrays are invalid after this call. Binary data is not repacked; bytes used only by a removed
+// buffer view are left in their buffer.
+func (root *ResolvedGlTF) Dedup() (DedupReport, error) {
+	gltf := root.GlTF
+	var report DedupReport
+
+	viewUsed := gltf.bufferViewUsage()
+
+	// Each stage merges references before the next one compares objects, since e.g. two textures are only identical
+	// once their image and sampler references have been merged.
+	var canon indexMaps
+	stages := []struct {
+		field func(m *indexMaps) *[]uint
+		n     int
+		key   func(i int) (any, error)
+	}{
+		{func(m *indexMaps) *[]uint { return &m.accessors }, len(gltf.Accessors), root.accessorKey},
+		{func(m *indexMaps) *[]uint { return &m.images }, len(gltf.Images), root.imageKey},
+		{func(m *indexMaps) *[]uint { return &m.samplers }, len(gltf.Samplers), func(i int) (any, error) {
+			s := gltf.Samplers[i]
+			s.Name = ""
+			return jsonKey(s)
+		}},
+		{func(m *indexMaps) *[]uint { return &m.textures }, len(gltf.Textures), func(i int) (any, error) {
+			t := gltf.Textures[i]
+			t.Name = ""
+			return jsonKey(t)
+		}},
+		{func(m *indexMaps) *[]uint { return &m.materials }, len(gltf.Materials), func(i int) (any, error) {
+			m := gltf.Materials[i]
+			m.Name = ""
+			return jsonKey(m)
+		}},
+		{func(m *indexMaps) *[]uint { return &m.meshes }, len(gltf.Meshes), func(i int) (any, error) {
+			m := gltf.Meshes[i]
+			m.Name = ""
+			return jsonKey(m)
+		}},
+	}
+
+	for _, stage := range stages {
+		c, err := canonicalIndices(stage.n, stage.key)
+		if err != nil {
+			return report, err
+		}
+		*stage.field(&canon) = c
+
+		var redirect indexMaps
+		*stage.field(&redirect) = c
+		if err := gltf.remapReferences(redirect); err != nil {
+			return report, err
+		}
+	}
+
+	var m indexMaps
+	gltf.Accessors, m.accessors, report.Accessors = compact(gltf.Accessors, isCanonical(canon.accessors))
+	gltf.Images, m.images, report.Images = compact(gltf.Images, isCanonical(canon.images))
+	gltf.Materials, m.materials, report.Materials = compact(gltf.Materials, isCanonical(canon.materials))
+	gltf.Meshes, m.meshes, report.Meshes = compact(gltf.Meshes, isCanonical(canon.meshes))
+	gltf.Samplers, m.samplers, report.Samplers = compact(gltf.Samplers, isCanonical(canon.samplers))
+	gltf.Textures, m.textures, report.Textures = compact(gltf.Textures, isCanonical(canon.textures))
+
+	// A buffer view is dropped only if it was in use before merging and no longer is, so that orphans already in the
+	// document are left for Prune to deal with.
+	viewStillUsed := gltf.bufferViewUsage()
+	keepViews := make([]bool, len(gltf.BufferViews))
+	for i := range keepViews {
+		keepViews[i] = viewStillUsed[i] || !viewUsed[i]
+	}
+	gltf.BufferViews, m.bufferViews, report.BufferViews = compact(gltf.BufferViews, keepViews)
+
+	if err := gltf.remapReferences(m); err != nil {
+		return report, err
+	}
+
+	return report, root.resolveReferences()
+}
+
+type accessorKey struct {
+	ComponentType ComponentTypeEnum
+	Type          AccessorTypeEnum
+	Normalized    bool
+	Count         int
+	Sum           [sha256.Size]byte
+	Other         string
+}
+
+// unmergedAccessor is the key of an accessor that is never merged with another.
+type unmergedAccessor int
+
+func (root *ResolvedGlTF) accessorKey(i int) (any, error) {
+	a := &root.Accessors[i]
+	if a.BufferView == nil && a.Sparse == nil {
+		// The accessor has no data of its own, but the data of an extension such as KHR_draco_mesh_compression usually
+		// stands in for it, so it can not be compared by its elements.
+		return unmergedAccessor(i), nil
+	}
+	data, err := a.PackedData()
+	if err != nil {
+		return nil, err
+	}
+	other, err := jsonKey([]any{a.Extensions, a.Extras})
+	if err != nil {
+		return nil, err
+	}
+	return accessorKey{a.ComponentType, a.Type, a.Normalized, a.Count, sha256.Sum256(data), other}, nil
+}
+
+type imageKey struct {
+	MimeType string
+	Sum      [sha256.Size]byte
+	Uri      string
+	Other    string
+}
+
+// imageKey compares images by content where the content can be loaded, and falls back to comparing URIs otherwise.
+func (root *ResolvedGlTF) imageKey(i int) (any, error) {
+	img := &root.GlTF.Images[i]
+	other, err := jsonKey([]any{img.Extensions, img.Extras})
+	if err != nil {
+		return nil, err
+	}
+
+	if img.BufferView != nil {
+		return imageKey{MimeType: img.MimeType, Sum: sha256.Sum256(root.BufferViews[*img.BufferView].Data), Other: other}, nil
+	} else if data, err := root.readUri(img.Uri); err == nil {
+		return imageKey{MimeType: img.MimeType, Sum: sha256.Sum256(data), Other: other}, nil
+	}
+	return imageKey{MimeType: img.MimeType, Uri: img.Uri, Other: other}, nil
+}
+
+func jsonKey(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(bytes.TrimSpace(b)), err
+}
+
+// canonicalIndices returns a map from each of n objects to the index of the first object with an equal key.
+func canonicalIndices(n int, key func(i int) (any, error)) ([]uint, error) {
+	rval := make([]uint, n)
+	first := make(map[any]uint, n)
+	for i := 0; i < n; i++ {
+		k, err := key(i)
+		if err != nil {
+			return nil, err
+		}
+		if c, found := first[k]; found {
+			rval[i] = c
+		} else {
+			first[k] = uint(i)
+			rval[i] = uint(i)
+		}
+	}
+	return rval, nil
+}
+
+func isCanonical(canon []uint) []bool {
+	rval := make([]bool, len(canon))
+	for i, c := range canon {
+		rval[i] = c == uint(i)
+	}
+	return rval
+}
+
+// bufferViewUsage returns a flag for each buffer view that is referenced by an accessor or image.
+func (gltf *GlTF) bufferViewUsage() []bool {
+	rval := make([]bool, len(gltf.BufferViews))
+	mark := func(i uint) {
+		if i < uint(len(rval)) {
+			rval[i] = true
+		}
+	}
+	for _, a := range gltf.Accessors {
+		if a.BufferView != nil {
+			mark(*a.BufferView)
+		}
+		if a.Sparse != nil {
+			mark(a.Sparse.Indices.BufferView)
+			mark(a.Sparse.Values.BufferView)
+		}
+	}
+	for _, img := range gltf.Images {
+		if img.BufferView != nil {
+			mark(*img.BufferView)
+		}
+	}
+	return rval
+}
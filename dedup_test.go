@@ -0,0 +1,46 @@
+package gltf
+
+import "testing"
+
+const dedupTestDoc = `{
+	"asset": {"version": "2.0"},
+	"scenes": [{"nodes": [0, 1]}],
+	"nodes": [{"mesh": 0}, {"mesh": 1}],
+	"meshes": [
+		{"name": "a", "primitives": [{"attributes": {"POSITION": 0}, "material": 0}]},
+		{"name": "b", "primitives": [{"attributes": {"POSITION": 1}, "material": 1}]}
+	],
+	"materials": [
+		{"name": "red", "pbrMetallicRoughness": {"baseColorFactor": [1, 0, 0, 1]}},
+		{"name": "also red", "pbrMetallicRoughness": {"baseColorFactor": [1, 0, 0, 1]}}
+	],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+		{"bufferView": 1, "componentType": 5126, "count": 3, "type": "VEC3"}
+	],
+	"bufferViews": [
+		{"buffer": 0, "byteLength": 36},
+		{"buffer": 0, "byteOffset": 36, "byteLength": 36}
+	],
+	"buffers": [{"uri": "tri.bin", "byteLength": 72}]
+}`
+
+func TestDedup(t *testing.T) {
+	tri := float32Bytes(0, 0, 0, 1, 0, 0, 0, 1, 0)
+	resolved := resolveTestDoc(t, dedupTestDoc, map[string][]byte{"tri.bin": append(tri, tri...)})
+
+	report, err := resolved.Dedup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Accessors) != 1 || len(report.BufferViews) != 1 || len(report.Materials) != 1 || len(report.Meshes) != 1 {
+		t.Errorf("unexpected dedup report: %+v", report)
+	}
+	if *resolved.GlTF.Nodes[1].Mesh != 0 {
+		t.Errorf("node 1 was not redirected to mesh 0")
+	}
+	if len(resolved.Meshes) != 1 || resolved.Nodes[1].Mesh != &resolved.Meshes[0] {
+		t.Errorf("resolved document was not rebuilt after dedup")
+	}
+}
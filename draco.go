@@ -0,0 +1,957 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const KHR_DRACO_MESH_COMPRESSION = "KHR_draco_mesh_compression"
+
+// KHRDracoMeshCompression is the KHR_draco_mesh_compression extension on a primitive, which holds the primitive's
+// indices and attributes compressed with Draco in a buffer view. Attributes maps each of the primitive's attributes to
+// the unique id of an attribute in the Draco data. The primitive's accessors describe the decoded data, and have no
+// buffer views of their own. See
+// https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Khronos/KHR_draco_mesh_compression
+type KHRDracoMeshCompression struct {
+	BufferView uint                 `json:"bufferView"`
+	Attributes map[AttributeKey]int `json:"attributes"`
+}
+
+func init() {
+	RegisterExtension(KHR_DRACO_MESH_COMPRESSION, ExtensionCodec[KHRDracoMeshCompression]{}, (*Primitive)(nil))
+}
+
+// DracoMesh is a triangle mesh or point cloud decoded from a Draco bitstream.
+type DracoMesh struct {
+	NumPoints int
+	// Faces holds three point indices for each triangle, and is empty for point clouds.
+	Faces      []uint32
+	Attributes []DracoAttribute
+}
+
+// DracoAttribute is an attribute of a DracoMesh, with Components values for each point.
+type DracoAttribute struct {
+	UniqueID   int
+	Components int
+	Normalized bool
+	// Float is set if the attribute holds floating point values, rather than integers.
+	Float  bool
+	Values []float64
+}
+
+// DecodeDracoMesh decodes a mesh or point cloud from a Draco bitstream of version 2.2, as used by
+// KHR_draco_mesh_compression. Meshes may use either sequential or edgebreaker connectivity, and any of the attribute
+// prediction schemes. Point clouds must be sequentially encoded.
+func DecodeDracoMesh(data []byte) (*DracoMesh, error) {
+	d := &dracoDecoder{buf: newDracoBuffer(data)}
+	if err := d.decode(); err != nil {
+		return nil, err
+	}
+	return d.mesh()
+}
+
+// decode returns the decoded data of the primitive's Draco buffer view.
+func (ext *KHRDracoMeshCompression) decode(root *ResolvedGlTF) (*DracoMesh, error) {
+	if ext.BufferView >= uint(len(root.BufferViews)) {
+		return nil, fmt.Errorf("Draco buffer view %d is not a valid buffer view", ext.BufferView)
+	}
+	return DecodeDracoMesh(root.BufferViews[ext.BufferView].Data)
+}
+
+// attribute returns the unique id of the Draco attribute that holds the primitive's attribute, if it is compressed.
+func (ext *KHRDracoMeshCompression) attribute(k AttributeKey) (int, bool) {
+	if ext == nil {
+		return 0, false
+	}
+	id, found := ext.Attributes[k]
+	return id, found
+}
+
+// accessor returns a standalone accessor holding the values of the attribute with the unique id, in the format given
+// by src, which also provides the accessor's name, bounds and extensions.
+func (m *DracoMesh) accessor(uniqueID int, src *Accessor) (*ResolvedAccessor, error) {
+	var a *DracoAttribute
+	for i := range m.Attributes {
+		if m.Attributes[i].UniqueID == uniqueID {
+			a = &m.Attributes[i]
+			break
+		}
+	}
+	if a == nil {
+		return nil, fmt.Errorf("Draco data has no attribute with id %d", uniqueID)
+	}
+	if a.Components != src.Type.Count() {
+		return nil, fmt.Errorf("Draco attribute %d has %d components, but its accessor is %s", uniqueID, a.Components, src.Type)
+	}
+
+	size := src.ComponentType.Size()
+	data := make([]byte, size*len(a.Values))
+	for i, v := range a.Values {
+		if a.Float && src.Normalized {
+			v = math.Round(v * normalizedMax(src.ComponentType))
+		}
+		putComponent(data[i*size:], src.ComponentType, v)
+	}
+	rval := NewResolvedAccessor(src.ComponentType, src.Type, src.Normalized, data)
+	rval.Name, rval.Min, rval.Max = src.Name, src.Min, src.Max
+	rval.Extensions, rval.Extras = src.Extensions, src.Extras
+	return rval, nil
+}
+
+// indices returns a standalone accessor holding the decoded faces, with src's component type.
+func (m *DracoMesh) indices(src *Accessor) (*ResolvedAccessor, error) {
+	data, ct, err := encodeIndices(m.Faces, src.ComponentType)
+	if err != nil {
+		return nil, err
+	}
+	rval := NewResolvedAccessor(ct, SCALAR, false, data)
+	rval.Name, rval.Min, rval.Max = src.Name, src.Min, src.Max
+	rval.Extensions, rval.Extras = src.Extensions, src.Extras
+	return rval, nil
+}
+
+// normalizedMax returns the integer that a normalized component of type ct maps to 1.
+func normalizedMax(ct ComponentTypeEnum) float64 {
+	switch ct {
+	case BYTE:
+		return math.MaxInt8
+	case UNSIGNED_BYTE:
+		return math.MaxUint8
+	case SHORT:
+		return math.MaxInt16
+	case UNSIGNED_SHORT:
+		return math.MaxUint16
+	}
+	return 1
+}
+
+// putComponent writes v to the start of b as a little-endian component of type ct, truncating it to an integer for
+// integer types.
+func putComponent(b []byte, ct ComponentTypeEnum, v float64) {
+	switch ct {
+	case BYTE, UNSIGNED_BYTE:
+		b[0] = byte(int64(v))
+	case SHORT, UNSIGNED_SHORT:
+		binary.LittleEndian.PutUint16(b, uint16(int64(v)))
+	case UNSIGNED_INT:
+		binary.LittleEndian.PutUint32(b, uint32(int64(v)))
+	case FLOAT:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	}
+}
+
+// The decoder below follows the reference implementation's PointCloudDecoder and MeshDecoder classes, and the
+// attribute decoders and sequencers that they create.
+
+// Encoder types and methods from the header.
+const (
+	dracoPointCloud = 0
+	dracoMesh       = 1
+
+	dracoSequentialEncoding  = 0
+	dracoEdgebreakerEncoding = 1
+
+	dracoMetadataFlag = 0x8000
+)
+
+// Attribute types, data types and the sequential decoders of attributes.
+const (
+	dracoPosition        = 0
+	dracoAttributeTypes  = 9
+	dracoInt8            = 1
+	dracoUint8           = 2
+	dracoInt16           = 3
+	dracoUint16          = 4
+	dracoInt32           = 5
+	dracoUint32          = 6
+	dracoInt64           = 7
+	dracoUint64          = 8
+	dracoFloat32         = 9
+	dracoFloat64         = 10
+	dracoBool            = 11
+	dracoDecoderGeneric  = 0
+	dracoDecoderInteger  = 1
+	dracoDecoderQuantize = 2
+	dracoDecoderNormals  = 3
+)
+
+// Edgebreaker attribute decoders and traversal methods.
+const (
+	dracoVertexAttribute = 0
+	dracoCornerAttribute = 1
+
+	dracoTraversalDepthFirst       = 0
+	dracoTraversalPredictionDegree = 1
+)
+
+type dracoDecoder struct {
+	buf       *dracoBuffer
+	numPoints int
+	faces     []uint32
+
+	// corners is only set for edgebreaker connectivity, along with the data of each attribute that has seams.
+	corners       *dracoMeshCorners
+	isVertHole    []bool
+	attributeData []dracoAttributeData
+	posEncoding   dracoEncodingData
+
+	attributes []*dracoAttribute
+}
+
+// dracoAttributeData is the connectivity of an attribute with seams in an edgebreaker mesh.
+type dracoAttributeData struct {
+	corners     *dracoAttributeCorners
+	seamCorners []int
+	encoding    dracoEncodingData
+}
+
+// dracoEncodingData records the order in which a traversal reached the vertices of a corner table, as each vertex has
+// one attribute value, MeshAttributeIndicesEncodingData in the reference implementation.
+type dracoEncodingData struct {
+	valueToCorner []int
+	vertexToValue []int
+}
+
+// dracoAttributesDecoder decodes a group of attributes that share a traversal of the mesh. The corner table and
+// encoding data are nil without edgebreaker connectivity, when the attributes have a value for each point in order.
+type dracoAttributesDecoder struct {
+	corners    dracoCornerTable
+	encoding   *dracoEncodingData
+	traversal  int
+	attributes []*dracoAttribute
+}
+
+type dracoAttribute struct {
+	attributeType int
+	dataType      int
+	components    int
+	normalized    bool
+	uniqueID      int
+	decoderType   int
+
+	// pointToValue maps points to values, or is nil if each point has its own value.
+	pointToValue []int
+	// portable holds the values as they are predicted, before they are dequantized or converted to their data type.
+	portable []int32
+	values   []float64
+
+	min          []float32
+	rangeSize    float32
+	quantization int
+	octahedron   dracoOctahedron
+}
+
+func (a *dracoAttribute) valueIndex(point int) int {
+	if a.pointToValue == nil {
+		return point
+	}
+	if point < 0 || point >= len(a.pointToValue) {
+		return -1
+	}
+	return a.pointToValue[point]
+}
+
+func (a *dracoAttribute) isFloat() bool {
+	return a.dataType == dracoFloat32 || a.dataType == dracoFloat64
+}
+
+func (d *dracoDecoder) decode() error {
+	b := d.buf
+	if magic, err := b.bytes(5); err != nil || string(magic) != "DRACO" {
+		return errors.New("Data is not a Draco bitstream")
+	}
+	header, err := b.bytes(4)
+	if err != nil {
+		return err
+	}
+	major, minor, encoder, method := header[0], header[1], header[2], header[3]
+	if major != 2 || (minor != 2 && !(minor == 3 && encoder == dracoPointCloud)) {
+		return fmt.Errorf("Draco bitstream version %d.%d is not supported", major, minor)
+	}
+	flags, err := b.u16()
+	if err != nil {
+		return err
+	}
+	if flags&dracoMetadataFlag != 0 {
+		if err := d.skipMetadata(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case encoder == dracoPointCloud && method == dracoSequentialEncoding:
+		n, err := b.u32()
+		if err != nil {
+			return err
+		}
+		if n > math.MaxInt32 {
+			return fmt.Errorf("Draco point count %d is too large", n)
+		}
+		d.numPoints = int(n)
+	case encoder == dracoMesh && method == dracoSequentialEncoding:
+		if err := d.decodeSequentialConnectivity(); err != nil {
+			return err
+		}
+	case encoder == dracoMesh && method == dracoEdgebreakerEncoding:
+		traversal, err := b.u8()
+		if err != nil {
+			return err
+		}
+		if traversal != 0 && traversal != 2 {
+			return fmt.Errorf("Draco edgebreaker traversal %d is not supported", traversal)
+		}
+		if err := d.decodeEdgebreakerConnectivity(traversal == 2); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Draco encoder %d with method %d is not supported", encoder, method)
+	}
+	return d.decodeAttributes()
+}
+
+// skipMetadata skips over the metadata of the attributes and of the whole mesh, which glTF has no use for.
+func (d *dracoDecoder) skipMetadata() error {
+	b := d.buf
+	n, err := b.count()
+	if err != nil {
+		return err
+	}
+	if n > b.remaining() {
+		return errDracoTruncated
+	}
+	for i := 0; i < n; i++ {
+		if _, err := b.varint(); err != nil {
+			return err
+		}
+		if err := skipDracoMetadata(b, 0); err != nil {
+			return err
+		}
+	}
+	return skipDracoMetadata(b, 0)
+}
+
+func skipDracoMetadata(b *dracoBuffer, depth int) error {
+	if depth > 32 {
+		return errors.New("Draco metadata is nested too deeply")
+	}
+	n, err := b.count()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := skipDracoName(b); err != nil {
+			return err
+		}
+		size, err := b.count()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return errors.New("Draco metadata entry is empty")
+		}
+		if err := b.skip(size); err != nil {
+			return err
+		}
+	}
+	if n, err = b.count(); err != nil {
+		return err
+	}
+	if n > b.remaining() {
+		return errDracoTruncated
+	}
+	for i := 0; i < n; i++ {
+		if err := skipDracoName(b); err != nil {
+			return err
+		}
+		if err := skipDracoMetadata(b, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func skipDracoName(b *dracoBuffer) error {
+	n, err := b.u8()
+	if err != nil {
+		return err
+	}
+	return b.skip(int(n))
+}
+
+// decodeAttributes reads the attribute decoders, then the attributes of each decoder, and then decodes them.
+func (d *dracoDecoder) decodeAttributes() error {
+	b := d.buf
+	n, err := b.u8()
+	if err != nil {
+		return err
+	}
+	decoders := make([]*dracoAttributesDecoder, n)
+	for i := range decoders {
+		decoders[i] = &dracoAttributesDecoder{}
+		if d.corners != nil {
+			if err := d.createEdgebreakerDecoder(decoders[i]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, dec := range decoders {
+		if err := d.decodeAttributeInfo(dec); err != nil {
+			return err
+		}
+	}
+	for _, dec := range decoders {
+		if err := d.decodeAttributeValues(dec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createEdgebreakerDecoder reads which connectivity an attribute decoder uses, and how it traverses it.
+func (d *dracoDecoder) createEdgebreakerDecoder(dec *dracoAttributesDecoder) error {
+	b := d.buf
+	header, err := b.bytes(3)
+	if err != nil {
+		return err
+	}
+	dataID, decoderType, traversal := int(int8(header[0])), header[1], int(header[2])
+	if dataID >= len(d.attributeData) {
+		return fmt.Errorf("Draco attribute data %d is not valid", dataID)
+	}
+	if traversal != dracoTraversalDepthFirst && traversal != dracoTraversalPredictionDegree {
+		return fmt.Errorf("Draco traversal method %d is not supported", traversal)
+	}
+	dec.traversal = traversal
+
+	switch decoderType {
+	case dracoVertexAttribute:
+		dec.corners, dec.encoding = d.corners, &d.posEncoding
+		if dataID >= 0 {
+			dec.encoding = &d.attributeData[dataID].encoding
+		}
+	case dracoCornerAttribute:
+		if dataID < 0 || traversal != dracoTraversalDepthFirst {
+			return errors.New("Draco corner attribute decoder is invalid")
+		}
+		dec.corners, dec.encoding = d.attributeData[dataID].corners, &d.attributeData[dataID].encoding
+	default:
+		return fmt.Errorf("Draco attribute decoder type %d is not supported", decoderType)
+	}
+	return nil
+}
+
+// decodeAttributeInfo reads the description of each attribute of the decoder, followed by the type of decoder used for
+// each of them.
+func (d *dracoDecoder) decodeAttributeInfo(dec *dracoAttributesDecoder) error {
+	b := d.buf
+	n, err := b.count()
+	if err != nil {
+		return err
+	}
+	if n == 0 || n > 5*b.remaining() {
+		return fmt.Errorf("Draco attribute count %d is invalid", n)
+	}
+	for i := 0; i < n; i++ {
+		info, err := b.bytes(4)
+		if err != nil {
+			return err
+		}
+		a := &dracoAttribute{
+			attributeType: int(info[0]),
+			dataType:      int(info[1]),
+			components:    int(info[2]),
+			normalized:    info[3] > 0,
+		}
+		if a.attributeType >= dracoAttributeTypes || a.dataType < dracoInt8 || a.dataType > dracoBool || a.components == 0 {
+			return errors.New("Draco attribute is invalid")
+		}
+		if a.uniqueID, err = b.count(); err != nil {
+			return err
+		}
+		dec.attributes = append(dec.attributes, a)
+		d.attributes = append(d.attributes, a)
+	}
+
+	for _, a := range dec.attributes {
+		t, err := b.u8()
+		if err != nil {
+			return err
+		}
+		a.decoderType = int(t)
+		switch a.decoderType {
+		case dracoDecoderGeneric, dracoDecoderInteger:
+		case dracoDecoderQuantize:
+			if a.dataType != dracoFloat32 {
+				return errors.New("Draco quantized attribute is not FLOAT32")
+			}
+		case dracoDecoderNormals:
+			if a.dataType != dracoFloat32 || a.components != 3 {
+				return errors.New("Draco normal attribute is not a FLOAT32 vector")
+			}
+		default:
+			return fmt.Errorf("Draco attribute decoder %d is not supported", t)
+		}
+	}
+	return nil
+}
+
+// decodeAttributeValues decodes the attributes of the decoder, which is done in three passes: the predicted values of
+// each attribute, then the data needed to dequantize them, and then the final values.
+func (d *dracoDecoder) decodeAttributeValues(dec *dracoAttributesDecoder) error {
+	var points []int
+	if dec.corners == nil {
+		points = make([]int, d.numPoints)
+		for i := range points {
+			points[i] = i
+		}
+	} else {
+		var err error
+		if points, err = d.traverse(dec); err != nil {
+			return err
+		}
+		mapping := make([]int, d.numPoints)
+		for c, p := range d.faces {
+			v := dec.corners.vertex(c)
+			if v < 0 {
+				return errors.New("Draco attribute connectivity is invalid")
+			}
+			mapping[p] = dec.encoding.vertexToValue[v]
+		}
+		for _, a := range dec.attributes {
+			a.pointToValue = mapping
+		}
+	}
+
+	ctx := &dracoPredictionContext{
+		corners:   dec.corners,
+		encoding:  dec.encoding,
+		pointIDs:  points,
+		positions: d.position(),
+	}
+	for _, a := range dec.attributes {
+		if err := d.decodePortable(a, ctx, len(points)); err != nil {
+			return err
+		}
+	}
+	for _, a := range dec.attributes {
+		if err := d.decodeTransformData(a); err != nil {
+			return err
+		}
+	}
+	for _, a := range dec.attributes {
+		if err := a.decodeOriginal(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// position returns the first position attribute, which predictions of other attributes can depend on.
+func (d *dracoDecoder) position() *dracoAttribute {
+	for _, a := range d.attributes {
+		if a.attributeType == dracoPosition {
+			return a
+		}
+	}
+	return nil
+}
+
+// traverse visits every face of the decoder's corner table, numbering the vertices in the order that they are first
+// reached, and returns the point of each vertex in that order.
+func (d *dracoDecoder) traverse(dec *dracoAttributesDecoder) ([]int, error) {
+	t, enc := dec.corners, dec.encoding
+	enc.vertexToValue = make([]int, t.numVertices())
+	enc.valueToCorner = nil
+	visitedVertex := make([]bool, t.numVertices())
+	visitedFace := make([]bool, t.numFaces())
+	var points []int
+	errInvalid := errors.New("Draco attribute connectivity is invalid")
+
+	visit := func(c int) error {
+		v := t.vertex(c)
+		if v < 0 {
+			return errInvalid
+		}
+		if !visitedVertex[v] {
+			visitedVertex[v] = true
+			points = append(points, int(d.faces[c]))
+			enc.vertexToValue[v] = len(enc.valueToCorner)
+			enc.valueToCorner = append(enc.valueToCorner, c)
+		}
+		return nil
+	}
+	isFaceVisited := func(c int) bool {
+		return c < 0 || visitedFace[c/3]
+	}
+
+	// depthFirst follows the faces to the right of each corner until it reaches a vertex that was already visited, and
+	// then continues to the left or right, splitting the traversal if both are possible.
+	depthFirst := func(start int) error {
+		if visitedFace[start/3] {
+			return nil
+		}
+		if err := visit(dracoNext(start)); err != nil {
+			return err
+		}
+		if err := visit(dracoPrevious(start)); err != nil {
+			return err
+		}
+		stack := []int{start}
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			if isFaceVisited(c) {
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			for {
+				visitedFace[c/3] = true
+				v := t.vertex(c)
+				if v < 0 {
+					return errInvalid
+				}
+				if !visitedVertex[v] {
+					boundary := dracoIsOnBoundary(t, v)
+					if err := visit(c); err != nil {
+						return err
+					}
+					if !boundary {
+						if c = t.opposite(dracoNext(c)); c < 0 {
+							return errInvalid
+						}
+						continue
+					}
+				}
+				right, left := t.opposite(dracoNext(c)), t.opposite(dracoPrevious(c))
+				if isFaceVisited(right) {
+					if isFaceVisited(left) {
+						stack = stack[:len(stack)-1]
+						break
+					}
+					c = left
+				} else if isFaceVisited(left) {
+					c = right
+				} else {
+					stack[len(stack)-1] = left
+					stack = append(stack, right)
+					break
+				}
+			}
+		}
+		return nil
+	}
+
+	// predictionDegree prefers faces whose tip vertex has already been visited, or can be predicted from the most
+	// parallelograms, MaxPredictionDegreeTraverser in the reference implementation.
+	degree := make([]int, t.numVertices())
+	var stacks [3][]int
+	best := 0
+	priority := func(c int) int {
+		v := t.vertex(c)
+		if v < 0 || visitedVertex[v] {
+			return 0
+		}
+		if degree[v]++; degree[v] > 1 {
+			return 1
+		}
+		return 2
+	}
+	push := func(c, p int) {
+		stacks[p] = append(stacks[p], c)
+		if p < best {
+			best = p
+		}
+	}
+	pop := func() int {
+		for i := best; i < len(stacks); i++ {
+			if n := len(stacks[i]); n > 0 {
+				c := stacks[i][n-1]
+				stacks[i] = stacks[i][:n-1]
+				best = i
+				return c
+			}
+		}
+		return -1
+	}
+	predictionDegree := func(start int) error {
+		stacks[0] = append(stacks[0], start)
+		best = 0
+		for _, c := range []int{dracoNext(start), dracoPrevious(start), start} {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+		for c := pop(); c >= 0; c = pop() {
+			if visitedFace[c/3] {
+				continue
+			}
+			for {
+				visitedFace[c/3] = true
+				if err := visit(c); err != nil {
+					return err
+				}
+				right, left := t.opposite(dracoNext(c)), t.opposite(dracoPrevious(c))
+				if !isFaceVisited(left) {
+					p := priority(left)
+					if isFaceVisited(right) && p <= best {
+						c = left
+						continue
+					}
+					push(left, p)
+				}
+				if !isFaceVisited(right) {
+					if p := priority(right); p <= best {
+						c = right
+						continue
+					} else {
+						push(right, p)
+					}
+				}
+				break
+			}
+		}
+		return nil
+	}
+
+	for f := 0; f < t.numFaces(); f++ {
+		var err error
+		if dec.traversal == dracoTraversalPredictionDegree {
+			err = predictionDegree(3 * f)
+		} else {
+			err = depthFirst(3 * f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+// decodePortable reads the values of an attribute as they are stored, and reverses their prediction.
+func (d *dracoDecoder) decodePortable(a *dracoAttribute, ctx *dracoPredictionContext, numEntries int) error {
+	b := d.buf
+	if a.decoderType == dracoDecoderGeneric {
+		size := dracoDataTypeSize(a.dataType)
+		data, err := b.bytes(numEntries * a.components * size)
+		if err != nil {
+			return err
+		}
+		a.values = make([]float64, numEntries*a.components)
+		for i := range a.values {
+			a.values[i] = dracoReadValue(data[i*size:], a.dataType)
+		}
+		return nil
+	}
+
+	components := a.components
+	if a.decoderType == dracoDecoderNormals {
+		components = 2
+	}
+	method, err := b.u8()
+	if err != nil {
+		return err
+	}
+	var pred *dracoPrediction
+	if int8(method) != dracoPredictionNone {
+		transform, err := b.u8()
+		if err != nil {
+			return err
+		}
+		if pred, err = newDracoPrediction(int(int8(method)), int(int8(transform)), a.decoderType == dracoDecoderNormals, ctx); err != nil {
+			return err
+		}
+		if pred != nil && (pred.method == dracoPredictionTexCoordsPortable || pred.method == dracoPredictionGeometricNormal) {
+			if pos := ctx.positions; pos == nil || pos.portable == nil || pos.components != 3 {
+				return errors.New("Draco prediction needs positions that have already been decoded")
+			}
+		}
+	}
+
+	n := numEntries * components
+	values := make([]int32, n)
+	compressed, err := b.u8()
+	if err != nil {
+		return err
+	}
+	if compressed > 0 {
+		symbols, err := dracoDecodeSymbols(b, n, components)
+		if err != nil {
+			return err
+		}
+		for i, s := range symbols {
+			values[i] = int32(s)
+		}
+	} else {
+		size, err := b.u8()
+		if err != nil {
+			return err
+		}
+		if size == 0 || size > 4 {
+			return fmt.Errorf("Draco value size %d is not supported", size)
+		}
+		data, err := b.bytes(n * int(size))
+		if err != nil {
+			return err
+		}
+		for i := range values {
+			var v uint32
+			for j := int(size) - 1; j >= 0; j-- {
+				v = v<<8 | uint32(data[i*int(size)+j])
+			}
+			values[i] = int32(v)
+		}
+	}
+
+	if pred == nil || !pred.transform.correctionsPositive() {
+		for i, v := range values {
+			values[i] = dracoSigned(uint32(v))
+		}
+	}
+	if pred != nil {
+		if err := pred.decodeData(b); err != nil {
+			return err
+		}
+		if err := pred.original(ctx, values, components); err != nil {
+			return err
+		}
+	}
+	a.portable = values
+	return nil
+}
+
+// decodeTransformData reads the parameters needed to convert the portable values of an attribute to its final values.
+func (d *dracoDecoder) decodeTransformData(a *dracoAttribute) error {
+	b := d.buf
+	switch a.decoderType {
+	case dracoDecoderQuantize:
+		a.min = make([]float32, a.components)
+		for i := range a.min {
+			v, err := b.f32()
+			if err != nil {
+				return err
+			}
+			a.min[i] = v
+		}
+		v, err := b.f32()
+		if err != nil {
+			return err
+		}
+		a.rangeSize = v
+		bits, err := b.u8()
+		if err != nil {
+			return err
+		}
+		if bits < 1 || bits > 30 {
+			return fmt.Errorf("Draco quantization bits %d are out of range", bits)
+		}
+		a.quantization = int(bits)
+	case dracoDecoderNormals:
+		bits, err := b.u8()
+		if err != nil {
+			return err
+		}
+		return a.octahedron.setBits(int(bits))
+	}
+	return nil
+}
+
+// decodeOriginal converts the portable values of an attribute to its final values.
+func (a *dracoAttribute) decodeOriginal() error {
+	switch a.decoderType {
+	case dracoDecoderInteger:
+		a.values = make([]float64, len(a.portable))
+		for i, v := range a.portable {
+			switch a.dataType {
+			case dracoInt8:
+				a.values[i] = float64(int8(v))
+			case dracoUint8:
+				a.values[i] = float64(uint8(v))
+			case dracoInt16:
+				a.values[i] = float64(int16(v))
+			case dracoUint16:
+				a.values[i] = float64(uint16(v))
+			case dracoInt32:
+				a.values[i] = float64(v)
+			case dracoUint32:
+				a.values[i] = float64(uint32(v))
+			default:
+				return fmt.Errorf("Draco integer attribute data type %d is not supported", a.dataType)
+			}
+		}
+	case dracoDecoderQuantize:
+		delta := a.rangeSize / float32(uint32(1)<<a.quantization-1)
+		a.values = make([]float64, len(a.portable))
+		for i, v := range a.portable {
+			a.values[i] = float64(float32(v)*delta + a.min[i%a.components])
+		}
+	case dracoDecoderNormals:
+		a.values = make([]float64, 0, len(a.portable)/2*3)
+		for i := 0; i+1 < len(a.portable); i += 2 {
+			v := a.octahedron.unitVector(a.portable[i], a.portable[i+1])
+			a.values = append(a.values, float64(v[0]), float64(v[1]), float64(v[2]))
+		}
+	}
+	return nil
+}
+
+func dracoDataTypeSize(t int) int {
+	switch t {
+	case dracoInt8, dracoUint8, dracoBool:
+		return 1
+	case dracoInt16, dracoUint16:
+		return 2
+	case dracoInt32, dracoUint32, dracoFloat32:
+		return 4
+	}
+	return 8
+}
+
+// dracoReadValue reads a single little-endian value of the data type from the start of b.
+func dracoReadValue(b []byte, t int) float64 {
+	switch t {
+	case dracoInt8:
+		return float64(int8(b[0]))
+	case dracoUint8, dracoBool:
+		return float64(b[0])
+	case dracoInt16:
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	case dracoUint16:
+		return float64(binary.LittleEndian.Uint16(b))
+	case dracoInt32:
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	case dracoUint32:
+		return float64(binary.LittleEndian.Uint32(b))
+	case dracoInt64:
+		return float64(int64(binary.LittleEndian.Uint64(b)))
+	case dracoUint64:
+		return float64(binary.LittleEndian.Uint64(b))
+	case dracoFloat32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+// mesh returns the decoded mesh, with the values of each attribute expanded to one for each point.
+func (d *dracoDecoder) mesh() (*DracoMesh, error) {
+	rval := &DracoMesh{NumPoints: d.numPoints, Faces: d.faces}
+	for _, a := range d.attributes {
+		n := a.components
+		values := make([]float64, d.numPoints*n)
+		for p := 0; p < d.numPoints; p++ {
+			v := a.valueIndex(p)
+			if v < 0 || (v+1)*n > len(a.values) {
+				return nil, fmt.Errorf("Draco attribute %d has no value for point %d", a.uniqueID, p)
+			}
+			copy(values[p*n:], a.values[v*n:(v+1)*n])
+		}
+		rval.Attributes = append(rval.Attributes, DracoAttribute{
+			UniqueID:   a.uniqueID,
+			Components: n,
+			Normalized: a.normalized,
+			Float:      a.isFloat(),
+			Values:     values,
+		})
+	}
+	return rval, nil
+}
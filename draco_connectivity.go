@@ -0,0 +1,900 @@
+package gltf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The connectivity decoders below follow the reference implementation in Draco's mesh_sequential_decoder.cc,
+// mesh_edgebreaker_decoder_impl.cc and the corner table classes it uses, as they define much of the bitstream. Corners,
+// vertices and faces are plain ints, with -1 for an invalid index.
+
+// dracoCornerTable is the connectivity of a triangle mesh, in which corner c belongs to face c / 3.
+type dracoCornerTable interface {
+	opposite(c int) int
+	vertex(c int) int
+	leftMostCorner(v int) int
+	numVertices() int
+	numFaces() int
+}
+
+func dracoNext(c int) int {
+	if c < 0 {
+		return -1
+	}
+	if c%3 == 2 {
+		return c - 2
+	}
+	return c + 1
+}
+
+func dracoPrevious(c int) int {
+	if c < 0 {
+		return -1
+	}
+	if c%3 == 0 {
+		return c + 2
+	}
+	return c - 1
+}
+
+// dracoSwingLeft returns the corner on the face to the left of c that shares c's vertex.
+func dracoSwingLeft(t dracoCornerTable, c int) int {
+	return dracoNext(t.opposite(dracoNext(c)))
+}
+
+// dracoSwingRight returns the corner on the face to the right of c that shares c's vertex.
+func dracoSwingRight(t dracoCornerTable, c int) int {
+	return dracoPrevious(t.opposite(dracoPrevious(c)))
+}
+
+func dracoIsOnBoundary(t dracoCornerTable, v int) bool {
+	c := t.leftMostCorner(v)
+	return c < 0 || dracoSwingLeft(t, c) < 0
+}
+
+// dracoVertexCorners calls visit for every corner around the vertex of corner start, first swinging left and then, if a
+// boundary is reached, swinging right from start.
+func dracoVertexCorners(t dracoCornerTable, start int, visit func(c int)) {
+	if start < 0 {
+		return
+	}
+	visit(start)
+	for c := dracoSwingLeft(t, start); c != start; c = dracoSwingLeft(t, c) {
+		if c < 0 {
+			for c = dracoSwingRight(t, start); c >= 0; c = dracoSwingRight(t, c) {
+				visit(c)
+			}
+			return
+		}
+		visit(c)
+	}
+}
+
+// dracoMeshCorners is the corner table of the decoded mesh, which is built up face by face.
+type dracoMeshCorners struct {
+	cornerToVertex []int
+	opposites      []int
+	vertexCorners  []int
+}
+
+func newDracoMeshCorners(numFaces int) *dracoMeshCorners {
+	t := &dracoMeshCorners{
+		cornerToVertex: make([]int, 3*numFaces),
+		opposites:      make([]int, 3*numFaces),
+	}
+	for i := range t.cornerToVertex {
+		t.cornerToVertex[i], t.opposites[i] = -1, -1
+	}
+	return t
+}
+
+func (t *dracoMeshCorners) opposite(c int) int {
+	if c < 0 {
+		return -1
+	}
+	return t.opposites[c]
+}
+
+func (t *dracoMeshCorners) vertex(c int) int {
+	if c < 0 {
+		return -1
+	}
+	return t.cornerToVertex[c]
+}
+
+func (t *dracoMeshCorners) leftMostCorner(v int) int {
+	return t.vertexCorners[v]
+}
+
+func (t *dracoMeshCorners) numVertices() int {
+	return len(t.vertexCorners)
+}
+
+func (t *dracoMeshCorners) numFaces() int {
+	return len(t.cornerToVertex) / 3
+}
+
+func (t *dracoMeshCorners) addVertex() int {
+	t.vertexCorners = append(t.vertexCorners, -1)
+	return len(t.vertexCorners) - 1
+}
+
+func (t *dracoMeshCorners) setOpposite(a, b int) {
+	t.opposites[a], t.opposites[b] = b, a
+}
+
+// dracoAttributeCorners is the connectivity of an attribute with seams, across which its values are not shared. It
+// splits the vertices of the mesh's corner table along the seams, MeshAttributeCornerTable in the reference
+// implementation.
+type dracoAttributeCorners struct {
+	mesh           *dracoMeshCorners
+	edgeOnSeam     []bool
+	vertexOnSeam   []bool
+	cornerToVertex []int
+	vertexCorners  []int
+}
+
+func newDracoAttributeCorners(mesh *dracoMeshCorners) *dracoAttributeCorners {
+	return &dracoAttributeCorners{
+		mesh:           mesh,
+		edgeOnSeam:     make([]bool, len(mesh.cornerToVertex)),
+		vertexOnSeam:   make([]bool, mesh.numVertices()),
+		cornerToVertex: make([]int, len(mesh.cornerToVertex)),
+	}
+}
+
+func (t *dracoAttributeCorners) opposite(c int) int {
+	if c < 0 || t.edgeOnSeam[c] {
+		return -1
+	}
+	return t.mesh.opposite(c)
+}
+
+func (t *dracoAttributeCorners) vertex(c int) int {
+	if c < 0 {
+		return -1
+	}
+	return t.cornerToVertex[c]
+}
+
+func (t *dracoAttributeCorners) leftMostCorner(v int) int {
+	return t.vertexCorners[v]
+}
+
+func (t *dracoAttributeCorners) numVertices() int {
+	return len(t.vertexCorners)
+}
+
+func (t *dracoAttributeCorners) numFaces() int {
+	return t.mesh.numFaces()
+}
+
+func (t *dracoAttributeCorners) isCornerOnSeam(c int) bool {
+	return t.vertexOnSeam[t.mesh.vertex(c)]
+}
+
+func (t *dracoAttributeCorners) addSeamEdge(c int) {
+	for _, c := range []int{c, t.mesh.opposite(c)} {
+		if c < 0 {
+			continue
+		}
+		t.edgeOnSeam[c] = true
+		t.vertexOnSeam[t.mesh.vertex(dracoNext(c))] = true
+		t.vertexOnSeam[t.mesh.vertex(dracoPrevious(c))] = true
+	}
+}
+
+// recomputeVertices splits the mesh's vertices into attribute vertices, one for each run of corners between seams.
+func (t *dracoAttributeCorners) recomputeVertices() error {
+	t.vertexCorners = t.vertexCorners[:0]
+	for v := 0; v < t.mesh.numVertices(); v++ {
+		c := t.mesh.leftMostCorner(v)
+		if c < 0 {
+			continue
+		}
+		id := len(t.vertexCorners)
+		first := c
+		if t.vertexOnSeam[v] {
+			// Find the first corner on a seam, counter-clockwise.
+			for act := dracoSwingLeft(t, first); act >= 0; act = dracoSwingLeft(t, act) {
+				if act == c {
+					return errors.New("Draco attribute seams are invalid")
+				}
+				first = act
+			}
+		}
+		t.cornerToVertex[first] = id
+		t.vertexCorners = append(t.vertexCorners, first)
+		for act := dracoSwingRight(t.mesh, first); act >= 0 && act != first; act = dracoSwingRight(t.mesh, act) {
+			if t.edgeOnSeam[dracoNext(act)] {
+				id = len(t.vertexCorners)
+				t.vertexCorners = append(t.vertexCorners, act)
+			}
+			t.cornerToVertex[act] = id
+		}
+	}
+	return nil
+}
+
+// decodeSequentialConnectivity reads the faces of a mesh that was encoded as a plain list of indices.
+func (d *dracoDecoder) decodeSequentialConnectivity() error {
+	b := d.buf
+	numFaces, err := b.count()
+	if err != nil {
+		return err
+	}
+	if d.numPoints, err = b.count(); err != nil {
+		return err
+	}
+	if numFaces > 1<<29 {
+		return fmt.Errorf("Draco face count %d is too large", numFaces)
+	}
+	method, err := b.u8()
+	if err != nil {
+		return err
+	}
+
+	d.faces = make([]uint32, 3*numFaces)
+	switch method {
+	case 0:
+		symbols, err := dracoDecodeSymbols(b, len(d.faces), 1)
+		if err != nil {
+			return err
+		}
+		// Each index is coded as the difference from the previous one, with the sign in the lowest bit.
+		var last int64
+		for i, s := range symbols {
+			diff := int64(s >> 1)
+			if s&1 != 0 {
+				diff = -diff
+			}
+			last += diff
+			if last < 0 {
+				return errors.New("Draco index is negative")
+			}
+			d.faces[i] = uint32(last)
+		}
+	case 1:
+		for i := range d.faces {
+			var v uint64
+			switch {
+			case d.numPoints < 1<<8:
+				var u uint8
+				u, err = b.u8()
+				v = uint64(u)
+			case d.numPoints < 1<<16:
+				var u uint16
+				u, err = b.u16()
+				v = uint64(u)
+			case d.numPoints < 1<<21:
+				v, err = b.varint()
+			default:
+				var u uint32
+				u, err = b.u32()
+				v = uint64(u)
+			}
+			if err != nil {
+				return err
+			}
+			d.faces[i] = uint32(v)
+		}
+	default:
+		return fmt.Errorf("Draco sequential connectivity method %d is not supported", method)
+	}
+
+	for _, idx := range d.faces {
+		if int(idx) >= d.numPoints {
+			return fmt.Errorf("Draco index %d is out of range for %d points", idx, d.numPoints)
+		}
+	}
+	return nil
+}
+
+// Edgebreaker symbols, as the bit patterns of the standard traversal.
+const (
+	dracoTopologyC = 0
+	dracoTopologyS = 1
+	dracoTopologyL = 3
+	dracoTopologyR = 5
+	dracoTopologyE = 7
+)
+
+// dracoEdgebreakerSymbols are the edgebreaker symbols indexed by their position in the valence coded symbol lists.
+var dracoEdgebreakerSymbols = [...]uint32{dracoTopologyC, dracoTopologyS, dracoTopologyL, dracoTopologyR, dracoTopologyE}
+
+// dracoTraversal reads the edgebreaker symbols, the configuration of the start faces, and the attribute seams. The
+// valence traversal predicts the symbols from the valence of the active vertex, and needs to be told how the mesh
+// grows.
+type dracoTraversal struct {
+	symbols     *dracoBuffer
+	startFaces  dracoBitDecoder
+	seams       []dracoBitDecoder
+	valence     bool
+	lastSymbol  uint32
+	valences    []int
+	context     int
+	contexts    [][]uint32
+	corners     *dracoMeshCorners
+	minValence  int
+	maxValence  int
+	contextUsed []int
+}
+
+// start reads the traversal data from b, leaving b at the end of it.
+func (tr *dracoTraversal) start(b *dracoBuffer, numAttributeData, numVertices int) error {
+	if !tr.valence {
+		size, err := b.varint()
+		if err != nil {
+			return err
+		}
+		if size > uint64(b.remaining()) {
+			return errDracoTruncated
+		}
+		tr.symbols = newDracoBuffer(b.data[b.pos : b.pos+int(size)])
+		tr.symbols.startBits()
+		b.pos += int(size)
+	}
+	if err := tr.startFaces.start(b); err != nil {
+		return err
+	}
+	tr.seams = make([]dracoBitDecoder, numAttributeData)
+	for i := range tr.seams {
+		if err := tr.seams[i].start(b); err != nil {
+			return err
+		}
+	}
+	if !tr.valence {
+		return nil
+	}
+
+	numSplitSymbols, err := b.count()
+	if err != nil {
+		return err
+	}
+	if numSplitSymbols >= numVertices && numVertices > 0 {
+		return errors.New("Draco split symbol count is invalid")
+	}
+	mode, err := b.u8()
+	if err != nil {
+		return err
+	}
+	if mode != 0 {
+		return fmt.Errorf("Draco valence mode %d is not supported", mode)
+	}
+	tr.minValence, tr.maxValence = 2, 7
+	tr.contexts = make([][]uint32, tr.maxValence-tr.minValence+1)
+	tr.contextUsed = make([]int, len(tr.contexts))
+	for i := range tr.contexts {
+		n, err := b.count()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			if tr.contexts[i], err = dracoDecodeSymbols(b, n, 1); err != nil {
+				return err
+			}
+		}
+		tr.contextUsed[i] = n
+	}
+	tr.valences = make([]int, numVertices+numSplitSymbols)
+	tr.context = -1
+	return nil
+}
+
+func (tr *dracoTraversal) symbol() uint32 {
+	if !tr.valence {
+		s := tr.symbols.bits(1)
+		if s != dracoTopologyC {
+			s |= tr.symbols.bits(2) << 1
+		}
+		tr.lastSymbol = s
+		return s
+	}
+	if tr.context < 0 {
+		tr.lastSymbol = dracoTopologyC
+		return tr.lastSymbol
+	}
+	// The symbols of each context are read from the back.
+	tr.contextUsed[tr.context]--
+	n := tr.contextUsed[tr.context]
+	if n < 0 || tr.contexts[tr.context][n] >= uint32(len(dracoEdgebreakerSymbols)) {
+		return 2 // Not a valid symbol pattern.
+	}
+	tr.lastSymbol = dracoEdgebreakerSymbols[tr.contexts[tr.context][n]]
+	return tr.lastSymbol
+}
+
+// newActiveCorner updates the vertex valences once a symbol has been decoded, and selects the context for the next one.
+func (tr *dracoTraversal) newActiveCorner(c int) {
+	if !tr.valence {
+		return
+	}
+	t := tr.corners
+	v, next, prev := t.vertex(c), t.vertex(dracoNext(c)), t.vertex(dracoPrevious(c))
+	if v < 0 || next < 0 || prev < 0 || v >= len(tr.valences) || next >= len(tr.valences) || prev >= len(tr.valences) {
+		return
+	}
+	switch tr.lastSymbol {
+	case dracoTopologyC, dracoTopologyS:
+		tr.valences[next]++
+		tr.valences[prev]++
+	case dracoTopologyR:
+		tr.valences[v]++
+		tr.valences[next]++
+		tr.valences[prev] += 2
+	case dracoTopologyL:
+		tr.valences[v]++
+		tr.valences[next] += 2
+		tr.valences[prev]++
+	case dracoTopologyE:
+		tr.valences[v] += 2
+		tr.valences[next] += 2
+		tr.valences[prev] += 2
+	}
+	valence := tr.valences[next]
+	if valence < tr.minValence {
+		valence = tr.minValence
+	} else if valence > tr.maxValence {
+		valence = tr.maxValence
+	}
+	tr.context = valence - tr.minValence
+}
+
+func (tr *dracoTraversal) mergeVertices(dest, src int) {
+	if tr.valence && dest < len(tr.valences) && src < len(tr.valences) {
+		tr.valences[dest] += tr.valences[src]
+	}
+}
+
+// dracoTopologySplit records that an edge of the face decoded for one symbol is the active edge of a later S symbol,
+// with symbols numbered in the encoder's order.
+type dracoTopologySplit struct {
+	sourceSymbol, splitSymbol int
+	rightEdge                 bool
+}
+
+// decodeEdgebreakerConnectivity reads the faces of a mesh that was encoded with the edgebreaker algorithm, along with the
+// seams of its attributes, and assigns points to the corners of the mesh.
+func (d *dracoDecoder) decodeEdgebreakerConnectivity(valence bool) error {
+	b := d.buf
+	numVertices, err := b.count()
+	if err != nil {
+		return err
+	}
+	numFaces, err := b.count()
+	if err != nil {
+		return err
+	}
+	if numFaces > 1<<29 {
+		return fmt.Errorf("Draco face count %d is too large", numFaces)
+	}
+	numAttributeData, err := b.u8()
+	if err != nil {
+		return err
+	}
+	numSymbols, err := b.count()
+	if err != nil {
+		return err
+	}
+	numSplitSymbols, err := b.count()
+	if err != nil {
+		return err
+	}
+	if numSymbols > numFaces || numSplitSymbols > numSymbols {
+		return errors.New("Draco edgebreaker symbol counts are invalid")
+	}
+	if numVertices > numFaces*3+numSplitSymbols+2 {
+		return fmt.Errorf("Draco vertex count %d is too large for %d faces", numVertices, numFaces)
+	}
+
+	d.corners = newDracoMeshCorners(numFaces)
+	d.isVertHole = make([]bool, numVertices+numSplitSymbols)
+	for i := range d.isVertHole {
+		d.isVertHole[i] = true
+	}
+	d.attributeData = make([]dracoAttributeData, numAttributeData)
+
+	// The topology split events follow the traversal data, which has its size encoded first.
+	size, err := b.count()
+	if err != nil {
+		return err
+	}
+	if size == 0 || size > b.remaining() {
+		return errors.New("Draco connectivity size is invalid")
+	}
+	events := newDracoBuffer(b.data[b.pos+size:])
+	splits, err := decodeDracoTopologySplits(events, numFaces)
+	if err != nil {
+		return err
+	}
+
+	tr := &dracoTraversal{valence: valence, corners: d.corners}
+	if err := tr.start(b, int(numAttributeData), numVertices); err != nil {
+		return err
+	}
+	numConnectivityVertices, err := d.decodeEdgebreakerFaces(tr, numSymbols, splits)
+	if err != nil {
+		return err
+	}
+	if err := b.skip(events.pos); err != nil {
+		return err
+	}
+	d.corners.vertexCorners = d.corners.vertexCorners[:numConnectivityVertices]
+
+	if len(d.attributeData) > 0 {
+		for c := 0; c < 3*numFaces; c += 3 {
+			d.decodeAttributeSeams(tr, c)
+		}
+	}
+	for i := range d.attributeData {
+		ad := &d.attributeData[i]
+		ad.corners = newDracoAttributeCorners(d.corners)
+		for _, c := range ad.seamCorners {
+			ad.corners.addSeamEdge(c)
+		}
+		if err := ad.corners.recomputeVertices(); err != nil {
+			return err
+		}
+	}
+	return d.assignPointsToCorners(numConnectivityVertices)
+}
+
+func decodeDracoTopologySplits(b *dracoBuffer, numFaces int) ([]dracoTopologySplit, error) {
+	n, err := b.count()
+	if err != nil {
+		return nil, err
+	}
+	if n > numFaces {
+		return nil, errors.New("Draco topology split count is invalid")
+	}
+	splits := make([]dracoTopologySplit, n)
+	last := 0
+	for i := range splits {
+		delta, err := b.count()
+		if err != nil {
+			return nil, err
+		}
+		source := last + delta
+		if delta, err = b.count(); err != nil {
+			return nil, err
+		}
+		if delta > source {
+			return nil, errors.New("Draco topology split is invalid")
+		}
+		splits[i] = dracoTopologySplit{sourceSymbol: source, splitSymbol: source - delta}
+		last = source
+	}
+	if n > 0 {
+		b.startBits()
+		for i := range splits {
+			splits[i].rightEdge = b.bits(1) == 1
+		}
+		b.endBits()
+	}
+	return splits, nil
+}
+
+// decodeEdgebreakerFaces decodes the symbols into faces, in the reverse of the order that the encoder visited them in,
+// and returns the number of vertices used by the connectivity.
+func (d *dracoDecoder) decodeEdgebreakerFaces(tr *dracoTraversal, numSymbols int, splits []dracoTopologySplit) (int, error) {
+	t := d.corners
+	var active []int
+	splitCorners := make(map[int]int)
+	var invalidVertices []int
+	removeInvalid := len(d.attributeData) == 0
+	maxVertices := len(d.isVertHole)
+	errInvalid := errors.New("Draco edgebreaker connectivity is invalid")
+
+	numFaces := 0
+	for symbolID := 0; symbolID < numSymbols; symbolID++ {
+		corner := 3 * numFaces
+		numFaces++
+		checkSplit := false
+
+		symbol := tr.symbol()
+		switch symbol {
+		case dracoTopologyC:
+			if len(active) == 0 {
+				return 0, errInvalid
+			}
+			cornerA := active[len(active)-1]
+			vertexX := t.vertex(dracoNext(cornerA))
+			cornerB := dracoNext(t.leftMostCorner(vertexX))
+			if cornerA == cornerB || t.opposite(cornerA) >= 0 || t.opposite(cornerB) >= 0 {
+				return 0, errInvalid
+			}
+			t.setOpposite(cornerA, corner+1)
+			t.setOpposite(cornerB, corner+2)
+			vertAPrev := t.vertex(dracoPrevious(cornerA))
+			vertBNext := t.vertex(dracoNext(cornerB))
+			if vertexX == vertAPrev || vertexX == vertBNext {
+				return 0, errInvalid
+			}
+			t.cornerToVertex[corner] = vertexX
+			t.cornerToVertex[corner+1] = vertBNext
+			t.cornerToVertex[corner+2] = vertAPrev
+			t.vertexCorners[vertAPrev] = corner + 2
+			d.isVertHole[vertexX] = false
+			active[len(active)-1] = corner
+
+		case dracoTopologyR, dracoTopologyL:
+			if len(active) == 0 {
+				return 0, errInvalid
+			}
+			cornerA := active[len(active)-1]
+			if t.opposite(cornerA) >= 0 {
+				return 0, errInvalid
+			}
+			oppCorner, cornerL, cornerR := corner+1, corner, corner+2
+			if symbol == dracoTopologyR {
+				oppCorner, cornerL, cornerR = corner+2, corner+1, corner
+			}
+			t.setOpposite(oppCorner, cornerA)
+			v := t.addVertex()
+			if t.numVertices() > maxVertices {
+				return 0, errInvalid
+			}
+			t.cornerToVertex[oppCorner] = v
+			t.vertexCorners[v] = oppCorner
+			vertexR := t.vertex(dracoPrevious(cornerA))
+			t.cornerToVertex[cornerR] = vertexR
+			t.vertexCorners[vertexR] = cornerR
+			t.cornerToVertex[cornerL] = t.vertex(dracoNext(cornerA))
+			active[len(active)-1] = corner
+			checkSplit = true
+
+		case dracoTopologyS:
+			if len(active) == 0 {
+				return 0, errInvalid
+			}
+			cornerB := active[len(active)-1]
+			active = active[:len(active)-1]
+			if c, found := splitCorners[symbolID]; found {
+				active = append(active, c)
+			}
+			if len(active) == 0 {
+				return 0, errInvalid
+			}
+			cornerA := active[len(active)-1]
+			if cornerA == cornerB || t.opposite(cornerA) >= 0 || t.opposite(cornerB) >= 0 {
+				return 0, errInvalid
+			}
+			t.setOpposite(cornerA, corner+2)
+			t.setOpposite(cornerB, corner+1)
+			vertexP := t.vertex(dracoPrevious(cornerA))
+			t.cornerToVertex[corner] = vertexP
+			t.cornerToVertex[corner+1] = t.vertex(dracoNext(cornerA))
+			vertBPrev := t.vertex(dracoPrevious(cornerB))
+			t.cornerToVertex[corner+2] = vertBPrev
+			t.vertexCorners[vertBPrev] = corner + 2
+			cornerN := dracoNext(cornerB)
+			vertexN := t.vertex(cornerN)
+			tr.mergeVertices(vertexP, vertexN)
+			t.vertexCorners[vertexP] = t.vertexCorners[vertexN]
+			// Every corner of vertex n becomes a corner of vertex p.
+			for first := cornerN; cornerN >= 0; {
+				t.cornerToVertex[cornerN] = vertexP
+				if cornerN = dracoSwingLeft(t, cornerN); cornerN == first {
+					return 0, errInvalid
+				}
+			}
+			t.vertexCorners[vertexN] = -1
+			if removeInvalid {
+				invalidVertices = append(invalidVertices, vertexN)
+			}
+			active[len(active)-1] = corner
+
+		case dracoTopologyE:
+			v := t.addVertex()
+			t.addVertex()
+			t.addVertex()
+			if t.numVertices() > maxVertices {
+				return 0, errInvalid
+			}
+			for i := 0; i < 3; i++ {
+				t.cornerToVertex[corner+i] = v + i
+				t.vertexCorners[v+i] = corner + i
+			}
+			active = append(active, corner)
+			checkSplit = true
+
+		default:
+			return 0, errInvalid
+		}
+		tr.newActiveCorner(active[len(active)-1])
+
+		if checkSplit {
+			// Add the edges that later S symbols attach to, from the split events of this symbol.
+			encoderSymbolID := numSymbols - symbolID - 1
+			for len(splits) > 0 {
+				s := splits[len(splits)-1]
+				if s.sourceSymbol > encoderSymbolID {
+					return 0, errInvalid
+				} else if s.sourceSymbol != encoderSymbolID {
+					break
+				}
+				splits = splits[:len(splits)-1]
+				top := active[len(active)-1]
+				if s.rightEdge {
+					splitCorners[numSymbols-s.splitSymbol-1] = dracoNext(top)
+				} else {
+					splitCorners[numSymbols-s.splitSymbol-1] = dracoPrevious(top)
+				}
+			}
+		}
+	}
+	if t.numVertices() > maxVertices {
+		return 0, errInvalid
+	}
+
+	// Close every remaining active edge, with an interior face if the encoder started from one.
+	for len(active) > 0 {
+		corner := active[len(active)-1]
+		active = active[:len(active)-1]
+		if !tr.startFaces.bit() {
+			continue
+		}
+		if numFaces >= t.numFaces() {
+			return 0, errInvalid
+		}
+		vertN := t.vertex(dracoNext(corner))
+		cornerB := dracoNext(t.leftMostCorner(vertN))
+		vertX := t.vertex(dracoNext(cornerB))
+		cornerC := dracoNext(t.leftMostCorner(vertX))
+		if corner == cornerB || corner == cornerC || cornerB == cornerC ||
+			t.opposite(corner) >= 0 || t.opposite(cornerB) >= 0 || t.opposite(cornerC) >= 0 {
+			return 0, errInvalid
+		}
+		vertP := t.vertex(dracoNext(cornerC))
+		newCorner := 3 * numFaces
+		numFaces++
+		t.setOpposite(newCorner, corner)
+		t.setOpposite(newCorner+1, cornerB)
+		t.setOpposite(newCorner+2, cornerC)
+		t.cornerToVertex[newCorner] = vertX
+		t.cornerToVertex[newCorner+1] = vertP
+		t.cornerToVertex[newCorner+2] = vertN
+		for i := 0; i < 3; i++ {
+			d.isVertHole[t.cornerToVertex[newCorner+i]] = false
+		}
+	}
+	if numFaces != t.numFaces() {
+		return 0, errInvalid
+	}
+
+	// The corners of boundary vertices were not necessarily left most when they were set.
+	for v := 0; v < t.numVertices(); v++ {
+		if !d.isVertHole[v] || t.vertexCorners[v] < 0 {
+			continue
+		}
+		first := t.vertexCorners[v]
+		c, act := first, dracoSwingLeft(t, first)
+		for act >= 0 && act != first {
+			c, act = act, dracoSwingLeft(t, act)
+		}
+		if act != first {
+			t.vertexCorners[v] = c
+		}
+	}
+
+	// Move the last valid vertices into the places of those that were merged away by S symbols.
+	numVertices := t.numVertices()
+	for _, invalid := range invalidVertices {
+		src := numVertices - 1
+		for src >= 0 && t.leftMostCorner(src) < 0 {
+			numVertices--
+			src = numVertices - 1
+		}
+		if src < invalid {
+			// Also covers src < 0, when every remaining vertex is invalid.
+			continue
+		}
+		var corners []int
+		dracoVertexCorners(t, t.leftMostCorner(src), func(c int) { corners = append(corners, c) })
+		for _, c := range corners {
+			if t.cornerToVertex[c] != src {
+				return 0, errInvalid
+			}
+			t.cornerToVertex[c] = invalid
+		}
+		t.vertexCorners[invalid] = t.vertexCorners[src]
+		t.vertexCorners[src] = -1
+		d.isVertHole[invalid] = d.isVertHole[src]
+		d.isVertHole[src] = false
+		numVertices--
+	}
+	return numVertices, nil
+}
+
+// decodeAttributeSeams reads which edges of the face at corner c are seams of each attribute. Boundary edges are
+// always seams, and interior edges are only read from the first of their two faces.
+func (d *dracoDecoder) decodeAttributeSeams(tr *dracoTraversal, c int) {
+	face := c / 3
+	for _, corner := range []int{c, dracoNext(c), dracoPrevious(c)} {
+		opp := d.corners.opposite(corner)
+		if opp < 0 {
+			for i := range d.attributeData {
+				d.attributeData[i].seamCorners = append(d.attributeData[i].seamCorners, corner)
+			}
+			continue
+		}
+		if opp/3 < face {
+			continue
+		}
+		for i := range d.attributeData {
+			if tr.seams[i].bit() {
+				d.attributeData[i].seamCorners = append(d.attributeData[i].seamCorners, corner)
+			}
+		}
+	}
+}
+
+// assignPointsToCorners creates a point for each distinct combination of vertex and attribute vertices, and the faces
+// of the mesh from them.
+func (d *dracoDecoder) assignPointsToCorners(numConnectivityVertices int) error {
+	t := d.corners
+	d.faces = make([]uint32, len(t.cornerToVertex))
+	if len(d.attributeData) == 0 {
+		for c, v := range t.cornerToVertex {
+			d.faces[c] = uint32(v)
+		}
+		d.numPoints = numConnectivityVertices
+		return nil
+	}
+
+	cornerToPoint := make([]int, len(t.cornerToVertex))
+	numPoints := 0
+	for v := 0; v < t.numVertices(); v++ {
+		c := t.leftMostCorner(v)
+		if c < 0 {
+			continue
+		}
+		first := c
+		if !d.isVertHole[v] {
+			// Start from a seam of any attribute, if there is one.
+		attributes:
+			for i := range d.attributeData {
+				ac := d.attributeData[i].corners
+				if !ac.isCornerOnSeam(c) {
+					continue
+				}
+				vert := ac.vertex(c)
+				for act := dracoSwingRight(t, c); act != c; act = dracoSwingRight(t, act) {
+					if act < 0 {
+						return errors.New("Draco attribute seams are invalid")
+					}
+					if ac.vertex(act) != vert {
+						first = act
+						break attributes
+					}
+				}
+			}
+		}
+
+		cornerToPoint[first] = numPoints
+		numPoints++
+		prev := first
+		for c = dracoSwingRight(t, first); c >= 0 && c != first; c = dracoSwingRight(t, c) {
+			seam := false
+			for i := range d.attributeData {
+				if ac := d.attributeData[i].corners; ac.vertex(c) != ac.vertex(prev) {
+					seam = true
+					break
+				}
+			}
+			if seam {
+				cornerToPoint[c] = numPoints
+				numPoints++
+			} else {
+				cornerToPoint[c] = cornerToPoint[prev]
+			}
+			prev = c
+		}
+	}
+	for c, p := range cornerToPoint {
+		d.faces[c] = uint32(p)
+	}
+	d.numPoints = numPoints
+	return nil
+}
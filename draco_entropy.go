@@ -0,0 +1,396 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// errDracoTruncated is returned whenever a Draco stream ends before the decoder expects it to.
+var errDracoTruncated = errors.New("Draco data is truncated")
+
+// dracoBuffer reads the primitive values of a Draco stream, including the LSB-first bit sequences that Draco embeds
+// between byte aligned data.
+type dracoBuffer struct {
+	data []byte
+	pos  int
+
+	// bitStart and bitPos are the byte offset and bit offset of the active bit sequence.
+	bitStart int
+	bitPos   int
+}
+
+func newDracoBuffer(data []byte) *dracoBuffer {
+	return &dracoBuffer{data: data}
+}
+
+func (b *dracoBuffer) remaining() int {
+	return len(b.data) - b.pos
+}
+
+func (b *dracoBuffer) bytes(n int) ([]byte, error) {
+	if n < 0 || n > b.remaining() {
+		return nil, errDracoTruncated
+	}
+	b.pos += n
+	return b.data[b.pos-n : b.pos], nil
+}
+
+func (b *dracoBuffer) skip(n int) error {
+	_, err := b.bytes(n)
+	return err
+}
+
+func (b *dracoBuffer) u8() (uint8, error) {
+	p, err := b.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+	return p[0], nil
+}
+
+func (b *dracoBuffer) u16() (uint16, error) {
+	p, err := b.bytes(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(p), nil
+}
+
+func (b *dracoBuffer) u32() (uint32, error) {
+	p, err := b.bytes(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(p), nil
+}
+
+func (b *dracoBuffer) f32() (float32, error) {
+	v, err := b.u32()
+	return math.Float32frombits(v), err
+}
+
+// varint reads an unsigned LEB128 value.
+func (b *dracoBuffer) varint() (uint64, error) {
+	var rval uint64
+	for shift := 0; shift < 64; shift += 7 {
+		c, err := b.u8()
+		if err != nil {
+			return 0, err
+		}
+		rval |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return rval, nil
+		}
+	}
+	return 0, errors.New("Draco varint is too long")
+}
+
+// count reads a varint that is used as a count or size, which must fit in an int32.
+func (b *dracoBuffer) count() (int, error) {
+	v, err := b.varint()
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxInt32 {
+		return 0, fmt.Errorf("Draco count %d is too large", v)
+	}
+	return int(v), nil
+}
+
+// startBits starts reading a bit sequence at the current position. Draco never encodes the size of the sequences read
+// here, and the position moves past the bytes that were used once endBits is called.
+func (b *dracoBuffer) startBits() {
+	b.bitStart, b.bitPos = b.pos, 0
+}
+
+// bits reads n bits, least significant first. Bits past the end of the data read as zero, as in the reference decoder.
+func (b *dracoBuffer) bits(n int) uint32 {
+	var rval uint32
+	for i := 0; i < n; i++ {
+		off := b.bitStart + b.bitPos>>3
+		if off < len(b.data) {
+			rval |= uint32(b.data[off]>>(b.bitPos&7)&1) << i
+		}
+		b.bitPos++
+	}
+	return rval
+}
+
+func (b *dracoBuffer) endBits() {
+	b.pos = b.bitStart + (b.bitPos+7)/8
+	if b.pos > len(b.data) {
+		b.pos = len(b.data)
+	}
+}
+
+// dracoBitDecoder reads booleans coded with Draco's binary rANS coder, RAnsBitDecoder in the reference implementation.
+type dracoBitDecoder struct {
+	probZero uint32
+	data     []byte
+	offset   int
+	state    uint32
+}
+
+const (
+	dracoAnsLBase  = 4096
+	dracoAnsIOBase = 256
+)
+
+func (d *dracoBitDecoder) start(b *dracoBuffer) error {
+	p, err := b.u8()
+	if err != nil {
+		return err
+	}
+	size, err := b.count()
+	if err != nil {
+		return err
+	}
+	data, err := b.bytes(size)
+	if err != nil {
+		return err
+	}
+	d.probZero, d.data = uint32(p), data
+	if size < 1 {
+		return errors.New("Draco bit coder data is empty")
+	}
+
+	switch x := data[size-1] >> 6; {
+	case x == 0:
+		d.offset, d.state = size-1, uint32(data[size-1]&0x3f)
+	case x == 1 && size >= 2:
+		d.offset, d.state = size-2, uint32(binary.LittleEndian.Uint16(data[size-2:]))&0x3fff
+	case x == 2 && size >= 3:
+		d.offset, d.state = size-3, (uint32(data[size-3])|uint32(data[size-2])<<8|uint32(data[size-1])<<16)&0x3fffff
+	default:
+		return errors.New("Draco bit coder state is invalid")
+	}
+	d.state += dracoAnsLBase
+	if d.state >= dracoAnsLBase*dracoAnsIOBase {
+		return errors.New("Draco bit coder state is invalid")
+	}
+	return nil
+}
+
+func (d *dracoBitDecoder) bit() bool {
+	p := 256 - d.probZero
+	if d.state < dracoAnsLBase && d.offset > 0 {
+		d.offset--
+		d.state = d.state*dracoAnsIOBase + uint32(d.data[d.offset])
+	}
+	quot, rem := d.state/256, d.state%256
+	xn := quot * p
+	if rem < p {
+		d.state = xn + rem
+		return true
+	}
+	d.state -= xn + p
+	return false
+}
+
+// dracoSymbolDecoder reads symbols coded with Draco's multi-symbol rANS coder, RAnsSymbolDecoder in the reference
+// implementation.
+type dracoSymbolDecoder struct {
+	precision uint32
+	probs     []uint32
+	cumProbs  []uint32
+	lookup    []uint32
+
+	data   []byte
+	offset int
+	state  uint32
+}
+
+// dracoRansPrecision returns the coder precision for symbols of up to bits bits.
+func dracoRansPrecision(bits int) uint32 {
+	p := (3 * bits) / 2
+	if p < 12 {
+		p = 12
+	} else if p > 20 {
+		p = 20
+	}
+	return 1 << p
+}
+
+// create reads the probability table of the coder.
+func (d *dracoSymbolDecoder) create(b *dracoBuffer, bits int) error {
+	d.precision = dracoRansPrecision(bits)
+	numSymbols, err := b.count()
+	if err != nil {
+		return err
+	}
+	if numSymbols/64 > b.remaining() {
+		// Each byte of the table covers at most 64 symbols.
+		return errDracoTruncated
+	}
+	d.probs = make([]uint32, numSymbols)
+	for i := 0; i < numSymbols; i++ {
+		data, err := b.u8()
+		if err != nil {
+			return err
+		}
+		if token := data & 3; token == 3 {
+			// A run of zero probabilities.
+			offset := int(data >> 2)
+			if i+offset >= numSymbols {
+				return errors.New("Draco symbol probabilities are invalid")
+			}
+			i += offset
+		} else {
+			prob := uint32(data >> 2)
+			for j := 0; j < int(token); j++ {
+				extra, err := b.u8()
+				if err != nil {
+					return err
+				}
+				prob |= uint32(extra) << (8*(j+1) - 2)
+			}
+			d.probs[i] = prob
+		}
+	}
+
+	d.cumProbs = make([]uint32, numSymbols)
+	d.lookup = make([]uint32, d.precision)
+	var cum uint32
+	for i, p := range d.probs {
+		d.cumProbs[i] = cum
+		if cum+p > d.precision {
+			return errors.New("Draco symbol probabilities are invalid")
+		}
+		for j := cum; j < cum+p; j++ {
+			d.lookup[j] = uint32(i)
+		}
+		cum += p
+	}
+	if numSymbols > 0 && cum != d.precision {
+		return errors.New("Draco symbol probabilities are invalid")
+	}
+	return nil
+}
+
+// start reads the coded data, which the symbols are then read from in reverse.
+func (d *dracoSymbolDecoder) start(b *dracoBuffer) error {
+	size, err := b.varint()
+	if err != nil {
+		return err
+	}
+	if size > uint64(b.remaining()) {
+		return errDracoTruncated
+	}
+	if d.data, err = b.bytes(int(size)); err != nil {
+		return err
+	}
+	if size < 1 {
+		return errors.New("Draco symbol coder data is empty")
+	}
+
+	data, n := d.data, int(size)
+	switch data[n-1] >> 6 {
+	case 0:
+		d.offset, d.state = n-1, uint32(data[n-1]&0x3f)
+	case 1:
+		if n < 2 {
+			return errDracoTruncated
+		}
+		d.offset, d.state = n-2, uint32(binary.LittleEndian.Uint16(data[n-2:]))&0x3fff
+	case 2:
+		if n < 3 {
+			return errDracoTruncated
+		}
+		d.offset, d.state = n-3, (uint32(data[n-3])|uint32(data[n-2])<<8|uint32(data[n-1])<<16)&0x3fffff
+	case 3:
+		if n < 4 {
+			return errDracoTruncated
+		}
+		d.offset, d.state = n-4, binary.LittleEndian.Uint32(data[n-4:])&0x3fffffff
+	}
+	base := 4 * d.precision
+	d.state += base
+	if uint64(d.state) >= uint64(base)*dracoAnsIOBase {
+		return errors.New("Draco symbol coder state is invalid")
+	}
+	return nil
+}
+
+func (d *dracoSymbolDecoder) symbol() uint32 {
+	for d.state < 4*d.precision && d.offset > 0 {
+		d.offset--
+		d.state = d.state*dracoAnsIOBase + uint32(d.data[d.offset])
+	}
+	quot, rem := d.state/d.precision, d.state%d.precision
+	s := d.lookup[rem]
+	d.state = quot*d.probs[s] + rem - d.cumProbs[s]
+	return s
+}
+
+// dracoDecodeSymbols reads count unsigned values, coded in groups of components with either of Draco's entropy coding
+// schemes.
+func dracoDecodeSymbols(b *dracoBuffer, count, components int) ([]uint32, error) {
+	rval := make([]uint32, count)
+	if count == 0 {
+		return rval, nil
+	}
+	scheme, err := b.u8()
+	if err != nil {
+		return nil, err
+	}
+
+	switch scheme {
+	case 0:
+		// Tagged: each group of components is preceded by a coded bit length, and the values are stored raw.
+		var tags dracoSymbolDecoder
+		if err := tags.create(b, 5); err != nil {
+			return nil, err
+		}
+		if err := tags.start(b); err != nil {
+			return nil, err
+		}
+		if len(tags.probs) == 0 {
+			return nil, errors.New("Draco tagged symbols have no tags")
+		}
+		b.startBits()
+		for i := 0; i < count; i += components {
+			bits := int(tags.symbol())
+			if bits > 32 {
+				return nil, errors.New("Draco tagged symbol is too long")
+			}
+			for j := 0; j < components && i+j < count; j++ {
+				rval[i+j] = b.bits(bits)
+			}
+		}
+		b.endBits()
+	case 1:
+		maxBits, err := b.u8()
+		if err != nil {
+			return nil, err
+		}
+		if maxBits < 1 || maxBits > 18 {
+			return nil, fmt.Errorf("Draco symbol bit length %d is not supported", maxBits)
+		}
+		var d dracoSymbolDecoder
+		if err := d.create(b, int(maxBits)); err != nil {
+			return nil, err
+		}
+		if len(d.probs) == 0 {
+			return nil, errors.New("Draco symbols have an empty probability table")
+		}
+		if err := d.start(b); err != nil {
+			return nil, err
+		}
+		for i := range rval {
+			rval[i] = d.symbol()
+		}
+	default:
+		return nil, fmt.Errorf("Draco symbol coding scheme %d is not supported", scheme)
+	}
+	return rval, nil
+}
+
+// dracoSigned converts a symbol to the signed value it codes, with the sign in the lowest bit.
+func dracoSigned(v uint32) int32 {
+	if v&1 == 0 {
+		return int32(v >> 1)
+	}
+	return -int32(v>>1) - 1
+}
@@ -0,0 +1,800 @@
+package gltf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The prediction schemes and transforms below follow the reference implementation in Draco's
+// compression/attributes/prediction_schemes directory. Attribute values are predicted from values that have already been
+// decoded, and the stream holds the corrections to those predictions.
+
+// Prediction scheme methods.
+const (
+	dracoPredictionNone                          = -2
+	dracoPredictionDifference                    = 0
+	dracoPredictionParallelogram                 = 1
+	dracoPredictionMultiParallelogram            = 2
+	dracoPredictionTexCoordsDeprecated           = 3
+	dracoPredictionConstrainedMultiParallelogram = 4
+	dracoPredictionTexCoordsPortable             = 5
+	dracoPredictionGeometricNormal               = 6
+)
+
+// Prediction transform types.
+const (
+	dracoTransformNone                    = -1
+	dracoTransformDelta                   = 0
+	dracoTransformWrap                    = 1
+	dracoTransformOctahedron              = 2
+	dracoTransformOctahedronCanonicalized = 3
+)
+
+// dracoTransform combines a predicted value with a decoded correction.
+type dracoTransform interface {
+	decodeData(b *dracoBuffer) error
+	init(components int)
+	original(pred, corr, out []int32)
+	// correctionsPositive reports whether corrections are coded as unsigned values, rather than with the sign in the
+	// lowest bit.
+	correctionsPositive() bool
+}
+
+type dracoDeltaTransform struct{}
+
+func (dracoDeltaTransform) decodeData(*dracoBuffer) error { return nil }
+func (dracoDeltaTransform) init(int)                      {}
+func (dracoDeltaTransform) correctionsPositive() bool     { return false }
+
+func (dracoDeltaTransform) original(pred, corr, out []int32) {
+	for i := range out {
+		out[i] = pred[i] + corr[i]
+	}
+}
+
+// dracoWrapTransform clamps predictions to the range of the values, and wraps corrected values around into it.
+type dracoWrapTransform struct {
+	min, max, maxDif int32
+	clamped          []int32
+}
+
+func (t *dracoWrapTransform) decodeData(b *dracoBuffer) error {
+	lo, err := b.u32()
+	if err != nil {
+		return err
+	}
+	hi, err := b.u32()
+	if err != nil {
+		return err
+	}
+	t.min, t.max = int32(lo), int32(hi)
+	dif := int64(t.max) - int64(t.min)
+	if dif < 0 || dif >= math.MaxInt32 {
+		return errors.New("Draco wrap transform range is invalid")
+	}
+	t.maxDif = int32(dif) + 1
+	return nil
+}
+
+func (t *dracoWrapTransform) init(components int) {
+	t.clamped = make([]int32, components)
+}
+
+func (t *dracoWrapTransform) correctionsPositive() bool { return false }
+
+func (t *dracoWrapTransform) original(pred, corr, out []int32) {
+	for i := range out {
+		p := pred[i]
+		if p > t.max {
+			p = t.max
+		} else if p < t.min {
+			p = t.min
+		}
+		v := p + corr[i]
+		if v > t.max {
+			v -= t.maxDif
+		} else if v < t.min {
+			v += t.maxDif
+		}
+		out[i] = v
+	}
+}
+
+// dracoOctahedron holds the parameters of normals quantized in octahedral coordinates, OctahedronToolBox in the
+// reference implementation.
+type dracoOctahedron struct {
+	bits              int
+	maxQuantizedValue int32
+	maxValue          int32
+	centerValue       int32
+}
+
+func (o *dracoOctahedron) setBits(bits int) error {
+	if bits < 2 || bits > 30 {
+		return fmt.Errorf("Draco normal quantization bits %d are out of range", bits)
+	}
+	o.bits = bits
+	o.maxQuantizedValue = 1<<bits - 1
+	o.maxValue = o.maxQuantizedValue - 1
+	o.centerValue = o.maxValue / 2
+	return nil
+}
+
+func (o *dracoOctahedron) isInDiamond(s, t int32) bool {
+	return abs32(s)+abs32(t) <= o.centerValue
+}
+
+// invertDiamond mirrors a point, relative to the center, across the nearest edge of the central diamond.
+func (o *dracoOctahedron) invertDiamond(s, t *int32) {
+	var signS, signT int32
+	if *s >= 0 && *t >= 0 {
+		signS, signT = 1, 1
+	} else if *s <= 0 && *t <= 0 {
+		signS, signT = -1, -1
+	} else {
+		signS, signT = 1, 1
+		if *s <= 0 {
+			signS = -1
+		}
+		if *t <= 0 {
+			signT = -1
+		}
+	}
+	cornerS, cornerT := uint32(signS*o.centerValue), uint32(signT*o.centerValue)
+	us, ut := uint32(*s), uint32(*t)
+	us = us + us - cornerS
+	ut = ut + ut - cornerT
+	if signS*signT >= 0 {
+		us, ut = -ut, -us
+	} else {
+		us, ut = ut, us
+	}
+	us += cornerS
+	ut += cornerT
+	*s, *t = int32(us)/2, int32(ut)/2
+}
+
+func (o *dracoOctahedron) canonicalizeVector(v []int32) {
+	absSum := int64(abs32(v[0])) + int64(abs32(v[1])) + int64(abs32(v[2]))
+	if absSum == 0 {
+		v[0] = o.centerValue
+		return
+	}
+	v[0] = int32(int64(v[0]) * int64(o.centerValue) / absSum)
+	v[1] = int32(int64(v[1]) * int64(o.centerValue) / absSum)
+	if v[2] >= 0 {
+		v[2] = o.centerValue - abs32(v[0]) - abs32(v[1])
+	} else {
+		v[2] = -(o.centerValue - abs32(v[0]) - abs32(v[1]))
+	}
+}
+
+func (o *dracoOctahedron) vectorToCoords(v []int32) (int32, int32) {
+	var s, t int32
+	if v[0] >= 0 {
+		s, t = v[1]+o.centerValue, v[2]+o.centerValue
+	} else {
+		if v[1] < 0 {
+			s = abs32(v[2])
+		} else {
+			s = o.maxValue - abs32(v[2])
+		}
+		if v[2] < 0 {
+			t = abs32(v[1])
+		} else {
+			t = o.maxValue - abs32(v[1])
+		}
+	}
+
+	// Canonicalize the coordinates on the edges of the unwrapped octahedron, which have two representations.
+	c, m := o.centerValue, o.maxValue
+	switch {
+	case (s == 0 && t == 0) || (s == 0 && t == m) || (s == m && t == 0):
+		s, t = m, m
+	case s == 0 && t > c:
+		t = c - (t - c)
+	case s == m && t < c:
+		t = c + (c - t)
+	case t == m && s < c:
+		s = c + (c - s)
+	case t == 0 && s > c:
+		s = c - (s - c)
+	}
+	return s, t
+}
+
+// unitVector converts quantized octahedral coordinates back to a unit vector.
+func (o *dracoOctahedron) unitVector(s, t int32) [3]float32 {
+	scale := 2 / float32(o.maxValue)
+	y, z := float32(s)*scale-1, float32(t)*scale-1
+	x := 1 - abs(y) - abs(z)
+	offset := -x
+	if offset < 0 {
+		offset = 0
+	}
+	if y < 0 {
+		y += offset
+	} else {
+		y -= offset
+	}
+	if z < 0 {
+		z += offset
+	} else {
+		z -= offset
+	}
+	norm := x*x + y*y + z*z
+	if norm < 1e-6 {
+		return [3]float32{}
+	}
+	d := float32(1 / float64(float32(math.Sqrt(float64(norm)))))
+	return [3]float32{x * d, y * d, z * d}
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// dracoOctahedronTransform corrects predicted normals in octahedral coordinates. The canonicalized variant also rotates
+// predictions into the bottom left quadrant, so that corrections are more alike.
+type dracoOctahedronTransform struct {
+	dracoOctahedron
+	canonicalized bool
+}
+
+func (t *dracoOctahedronTransform) decodeData(b *dracoBuffer) error {
+	v, err := b.u32()
+	if err != nil {
+		return err
+	}
+	maxQuantized := int32(v)
+	if maxQuantized <= 0 || maxQuantized%2 == 0 {
+		return errors.New("Draco octahedron transform range is invalid")
+	}
+	bits := 0
+	for maxQuantized>>bits != 0 {
+		bits++
+	}
+	return t.setBits(bits)
+}
+
+func (t *dracoOctahedronTransform) init(int) {}
+
+func (t *dracoOctahedronTransform) correctionsPositive() bool { return true }
+
+func (t *dracoOctahedronTransform) modMax(x int32) int32 {
+	if x > t.centerValue {
+		return x - t.maxQuantizedValue
+	} else if x < -t.centerValue {
+		return x + t.maxQuantizedValue
+	}
+	return x
+}
+
+func (t *dracoOctahedronTransform) original(pred, corr, out []int32) {
+	ps, pt := pred[0]-t.centerValue, pred[1]-t.centerValue
+	inDiamond := t.isInDiamond(ps, pt)
+	if !inDiamond {
+		t.invertDiamond(&ps, &pt)
+	}
+
+	var os, ot int32
+	if t.canonicalized {
+		bottomLeft := (ps == 0 && pt == 0) || (ps < 0 && pt <= 0)
+		rotation := dracoRotationCount(ps, pt)
+		if !bottomLeft {
+			ps, pt = dracoRotate(ps, pt, rotation)
+		}
+		os, ot = t.modMax(ps+corr[0]), t.modMax(pt+corr[1])
+		if !bottomLeft {
+			os, ot = dracoRotate(os, ot, (4-rotation)%4)
+		}
+	} else {
+		os, ot = t.modMax(ps+corr[0]), t.modMax(pt+corr[1])
+	}
+
+	if !inDiamond {
+		t.invertDiamond(&os, &ot)
+	}
+	out[0], out[1] = os+t.centerValue, ot+t.centerValue
+}
+
+func dracoRotationCount(x, y int32) int {
+	switch {
+	case x == 0 && y == 0:
+		return 0
+	case x == 0 && y > 0:
+		return 3
+	case x == 0:
+		return 1
+	case x > 0 && y >= 0:
+		return 2
+	case x > 0:
+		return 1
+	case y <= 0:
+		return 0
+	}
+	return 3
+}
+
+func dracoRotate(x, y int32, rotation int) (int32, int32) {
+	switch rotation {
+	case 1:
+		return y, -x
+	case 2:
+		return -x, -y
+	case 3:
+		return -y, x
+	}
+	return x, y
+}
+
+// dracoPredictionContext is what a prediction scheme can use besides the values themselves. For meshes, the values are
+// numbered in the order that the traversal reached them, and the corner table is the one the traversal used.
+type dracoPredictionContext struct {
+	corners  dracoCornerTable
+	encoding *dracoEncodingData
+	pointIDs []int
+	// positions is the position attribute, which texture coordinates and normals are predicted from.
+	positions *dracoAttribute
+}
+
+// dracoPrediction is a prediction scheme with its transform and any data of its own.
+type dracoPrediction struct {
+	method    int
+	transform dracoTransform
+
+	creases      [4][]bool
+	orientations []bool
+	flips        dracoBitDecoder
+}
+
+// newDracoPrediction returns the prediction scheme for the method and transform, or nil if the attribute decoder does
+// not support the transform, in which case values are not predicted at all. Methods that need mesh connectivity fall
+// back to difference prediction without it.
+func newDracoPrediction(method, transform int, normals bool, ctx *dracoPredictionContext) (*dracoPrediction, error) {
+	if method < dracoPredictionDifference || method > dracoPredictionGeometricNormal {
+		return nil, fmt.Errorf("Draco prediction method %d is not supported", method)
+	}
+	p := &dracoPrediction{method: method}
+	switch {
+	case normals && (transform == dracoTransformOctahedron || transform == dracoTransformOctahedronCanonicalized):
+		p.transform = &dracoOctahedronTransform{canonicalized: transform == dracoTransformOctahedronCanonicalized}
+		if method != dracoPredictionGeometricNormal || transform != dracoTransformOctahedronCanonicalized {
+			p.method = dracoPredictionDifference
+		}
+	case !normals && transform == dracoTransformWrap:
+		p.transform = &dracoWrapTransform{}
+		if method == dracoPredictionGeometricNormal || method == dracoPredictionTexCoordsDeprecated {
+			p.method = dracoPredictionDifference
+		}
+	case transform >= dracoTransformNone && transform <= dracoTransformOctahedronCanonicalized:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Draco prediction transform %d is not supported", transform)
+	}
+	if ctx.corners == nil {
+		p.method = dracoPredictionDifference
+	}
+	return p, nil
+}
+
+// decodeData reads the data of the prediction scheme and its transform, which follows the corrections.
+func (p *dracoPrediction) decodeData(b *dracoBuffer) error {
+	switch p.method {
+	case dracoPredictionConstrainedMultiParallelogram:
+		for i := range p.creases {
+			n, err := b.count()
+			if err != nil {
+				return err
+			}
+			if n > b.remaining()*8*256 {
+				return errDracoTruncated
+			}
+			if n > 0 {
+				var d dracoBitDecoder
+				if err := d.start(b); err != nil {
+					return err
+				}
+				p.creases[i] = make([]bool, n)
+				for j := range p.creases[i] {
+					p.creases[i][j] = d.bit()
+				}
+			}
+		}
+	case dracoPredictionTexCoordsPortable:
+		v, err := b.u32()
+		if err != nil {
+			return err
+		}
+		n := int32(v)
+		if n < 0 || int(n) > b.remaining()*8*256 {
+			return errors.New("Draco texture coordinate orientations are invalid")
+		}
+		var d dracoBitDecoder
+		if err := d.start(b); err != nil {
+			return err
+		}
+		p.orientations = make([]bool, n)
+		last := true
+		for i := range p.orientations {
+			if !d.bit() {
+				last = !last
+			}
+			p.orientations[i] = last
+		}
+	}
+
+	if err := p.transform.decodeData(b); err != nil {
+		return err
+	}
+	if p.method == dracoPredictionGeometricNormal {
+		return p.flips.start(b)
+	}
+	return nil
+}
+
+// original reverses the prediction, replacing the corrections in values with the original values.
+func (p *dracoPrediction) original(ctx *dracoPredictionContext, values []int32, components int) error {
+	p.transform.init(components)
+	corr := append([]int32(nil), values...)
+	zero := make([]int32, components)
+	numValues := len(values) / components
+	if numValues == 0 {
+		return nil
+	}
+	value := func(i int) []int32 {
+		return values[i*components : (i+1)*components]
+	}
+	// delta restores value i with value i - 1 as the prediction.
+	delta := func(i int) {
+		p.transform.original(value(i-1), corr[i*components:(i+1)*components], value(i))
+	}
+
+	switch p.method {
+	case dracoPredictionDifference:
+		p.transform.original(zero, corr[:components], value(0))
+		for i := 1; i < numValues; i++ {
+			delta(i)
+		}
+		return nil
+
+	case dracoPredictionParallelogram, dracoPredictionMultiParallelogram, dracoPredictionConstrainedMultiParallelogram:
+		if len(ctx.encoding.valueToCorner) != numValues {
+			return errors.New("Draco attribute does not match its connectivity")
+		}
+		p.transform.original(zero, corr[:components], value(0))
+		pred := make([]int32, components)
+		sum := make([]int32, components)
+		creasePos := [4]int{}
+		var preds [4][]int32
+		for i := range preds {
+			preds[i] = make([]int32, components)
+		}
+
+		for i := 1; i < numValues; i++ {
+			start := ctx.encoding.valueToCorner[i]
+			n := 0
+			switch p.method {
+			case dracoPredictionParallelogram:
+				if p.parallelogram(ctx, i, start, values, components, pred) {
+					n = 1
+				}
+			case dracoPredictionMultiParallelogram:
+				for j := range sum {
+					sum[j] = 0
+				}
+				for c := start; c >= 0; {
+					if p.parallelogram(ctx, i, c, values, components, pred) {
+						for j := range sum {
+							sum[j] += pred[j]
+						}
+						n++
+					}
+					if c = dracoSwingRight(ctx.corners, c); c == start {
+						break
+					}
+				}
+				if n > 0 {
+					for j := range pred {
+						pred[j] = sum[j] / int32(n)
+					}
+				}
+			default:
+				// Up to four parallelograms, swinging left and then right from the start corner, each of which may be
+				// excluded as a crease.
+				found := 0
+				firstPass := true
+				for c := start; c >= 0; {
+					if p.parallelogram(ctx, i, c, values, components, preds[found]) {
+						if found++; found == len(preds) {
+							break
+						}
+					}
+					if firstPass {
+						c = dracoSwingLeft(ctx.corners, c)
+					} else {
+						c = dracoSwingRight(ctx.corners, c)
+					}
+					if c == start {
+						break
+					}
+					if c < 0 && firstPass {
+						firstPass = false
+						c = dracoSwingRight(ctx.corners, start)
+					}
+				}
+				for j := range sum {
+					sum[j] = 0
+				}
+				for k := 0; k < found; k++ {
+					context := found - 1
+					if creasePos[context] >= len(p.creases[context]) {
+						return errors.New("Draco crease flags are truncated")
+					}
+					crease := p.creases[context][creasePos[context]]
+					creasePos[context]++
+					if !crease {
+						for j := range sum {
+							sum[j] += preds[k][j]
+						}
+						n++
+					}
+				}
+				if n > 0 {
+					for j := range pred {
+						pred[j] = sum[j] / int32(n)
+					}
+				}
+			}
+
+			if n == 0 {
+				delta(i)
+			} else {
+				p.transform.original(pred, corr[i*components:(i+1)*components], value(i))
+			}
+		}
+		return nil
+
+	case dracoPredictionTexCoordsPortable:
+		if components != 2 {
+			return errors.New("Draco texture coordinate prediction needs two components")
+		}
+		pred := make([]int32, 2)
+		for i := 0; i < numValues; i++ {
+			if err := p.texCoord(ctx, i, values, pred); err != nil {
+				return err
+			}
+			p.transform.original(pred, corr[2*i:2*i+2], value(i))
+		}
+		return nil
+
+	case dracoPredictionGeometricNormal:
+		if components != 2 {
+			return errors.New("Draco normal prediction needs two components")
+		}
+		oct := &p.transform.(*dracoOctahedronTransform).dracoOctahedron
+		normal := make([]int32, 3)
+		pred := make([]int32, 2)
+		for i := 0; i < numValues; i++ {
+			if err := p.normal(ctx, ctx.encoding.valueToCorner[i], normal); err != nil {
+				return err
+			}
+			oct.canonicalizeVector(normal)
+			if p.flips.bit() {
+				normal[0], normal[1], normal[2] = -normal[0], -normal[1], -normal[2]
+			}
+			pred[0], pred[1] = oct.vectorToCoords(normal)
+			p.transform.original(pred, corr[2*i:2*i+2], value(i))
+		}
+		return nil
+	}
+	return fmt.Errorf("Draco prediction method %d is not supported", p.method)
+}
+
+// parallelogram predicts value i from the triangle opposite corner c, if all three of its values are already decoded.
+func (p *dracoPrediction) parallelogram(ctx *dracoPredictionContext, i, c int, values []int32, components int, out []int32) bool {
+	oc := ctx.corners.opposite(c)
+	if oc < 0 {
+		return false
+	}
+	vertexToValue := ctx.encoding.vertexToValue
+	opp := vertexToValue[ctx.corners.vertex(oc)]
+	next := vertexToValue[ctx.corners.vertex(dracoNext(oc))]
+	prev := vertexToValue[ctx.corners.vertex(dracoPrevious(oc))]
+	if opp >= i || next >= i || prev >= i {
+		return false
+	}
+	for j := 0; j < components; j++ {
+		out[j] = int32(int64(values[next*components+j]) + int64(values[prev*components+j]) - int64(values[opp*components+j]))
+	}
+	return true
+}
+
+// position returns the quantized position of the point that value i of the predicted attribute belongs to.
+func (ctx *dracoPredictionContext) position(i int) ([3]int64, error) {
+	var rval [3]int64
+	pos := ctx.positions
+	if i < 0 || i >= len(ctx.pointIDs) {
+		return rval, errors.New("Draco value has no point")
+	}
+	v := pos.valueIndex(ctx.pointIDs[i])
+	if v < 0 || 3*v+2 >= len(pos.portable) {
+		return rval, errors.New("Draco point has no position")
+	}
+	for c := range rval {
+		rval[c] = int64(pos.portable[3*v+c])
+	}
+	return rval, nil
+}
+
+// texCoord predicts texture coordinate i by unfolding the triangle of its corner from position space into texture
+// space, using the decoded orientation to choose the side of the triangle's other edge.
+func (p *dracoPrediction) texCoord(ctx *dracoPredictionContext, i int, values []int32, out []int32) error {
+	c := ctx.encoding.valueToCorner[i]
+	next := ctx.encoding.vertexToValue[ctx.corners.vertex(dracoNext(c))]
+	prev := ctx.encoding.vertexToValue[ctx.corners.vertex(dracoPrevious(c))]
+
+	if prev < i && next < i {
+		nUV := [2]int64{int64(values[2*next]), int64(values[2*next+1])}
+		pUV := [2]int64{int64(values[2*prev]), int64(values[2*prev+1])}
+		if pUV == nUV {
+			out[0], out[1] = int32(pUV[0]), int32(pUV[1])
+			return nil
+		}
+		tip, err := ctx.position(i)
+		if err != nil {
+			return err
+		}
+		nPos, err := ctx.position(next)
+		if err != nil {
+			return err
+		}
+		pPos, err := ctx.position(prev)
+		if err != nil {
+			return err
+		}
+
+		var pn, cn [3]int64
+		var pnNorm2 int64
+		var cnDotPn int64
+		for k := range pn {
+			pn[k], cn[k] = pPos[k]-nPos[k], tip[k]-nPos[k]
+			pnNorm2 += pn[k] * pn[k]
+			cnDotPn += pn[k] * cn[k]
+		}
+		if pnNorm2 != 0 {
+			pnUV := [2]int64{pUV[0] - nUV[0], pUV[1] - nUV[1]}
+			if max64(abs64(nUV[0]), abs64(nUV[1])) > math.MaxInt64/pnNorm2 ||
+				cnDotPn > math.MaxInt64/max64(abs64(pnUV[0]), abs64(pnUV[1])) ||
+				cnDotPn > math.MaxInt64/max64(max64(abs64(pn[0]), abs64(pn[1])), abs64(pn[2])) {
+				return errors.New("Draco texture coordinate prediction overflows")
+			}
+			xUV := [2]int64{nUV[0]*pnNorm2 + cnDotPn*pnUV[0], nUV[1]*pnNorm2 + cnDotPn*pnUV[1]}
+			var cxNorm2 uint64
+			for k := range pn {
+				x := nPos[k] + cnDotPn*pn[k]/pnNorm2
+				d := tip[k] - x
+				cxNorm2 += uint64(d * d)
+			}
+			norm := int64(dracoIntSqrt(cxNorm2 * uint64(pnNorm2)))
+			cxUV := [2]int64{pnUV[1] * norm, -pnUV[0] * norm}
+
+			if len(p.orientations) == 0 {
+				return errors.New("Draco texture coordinate orientations are truncated")
+			}
+			orientation := p.orientations[len(p.orientations)-1]
+			p.orientations = p.orientations[:len(p.orientations)-1]
+			for k := range out {
+				if orientation {
+					out[k] = int32(int64(uint64(xUV[k])+uint64(cxUV[k])) / pnNorm2)
+				} else {
+					out[k] = int32(int64(uint64(xUV[k])-uint64(cxUV[k])) / pnNorm2)
+				}
+			}
+			return nil
+		}
+	}
+
+	// Without both neighbors, the prediction is a neighboring value or the previous value, as in the reference decoder.
+	offset := 0
+	if prev < i {
+		offset = 2 * prev
+	}
+	if next < i {
+		offset = 2 * next
+	} else if i > 0 {
+		offset = 2 * (i - 1)
+	} else {
+		out[0], out[1] = 0, 0
+		return nil
+	}
+	out[0], out[1] = values[offset], values[offset+1]
+	return nil
+}
+
+// normal predicts the normal at corner c as the area weighted sum of the normals of the triangles around its vertex.
+func (p *dracoPrediction) normal(ctx *dracoPredictionContext, c int, out []int32) error {
+	position := func(c int) ([3]int64, error) {
+		return ctx.position(ctx.encoding.vertexToValue[ctx.corners.vertex(c)])
+	}
+	center, err := position(c)
+	if err != nil {
+		return err
+	}
+	var normal [3]int64
+	dracoVertexCorners(ctx.corners, c, func(corner int) {
+		if err != nil {
+			return
+		}
+		var next, prev [3]int64
+		if next, err = position(dracoNext(corner)); err != nil {
+			return
+		}
+		if prev, err = position(dracoPrevious(corner)); err != nil {
+			return
+		}
+		var dn, dp [3]int64
+		for k := range dn {
+			dn[k], dp[k] = next[k]-center[k], prev[k]-center[k]
+		}
+		normal[0] += dn[1]*dp[2] - dn[2]*dp[1]
+		normal[1] += dn[2]*dp[0] - dn[0]*dp[2]
+		normal[2] += dn[0]*dp[1] - dn[1]*dp[0]
+	})
+	if err != nil {
+		return err
+	}
+
+	const upperBound = 1 << 29
+	if sum := abs64(normal[0]) + abs64(normal[1]) + abs64(normal[2]); sum > upperBound {
+		q := sum / upperBound
+		for k := range normal {
+			normal[k] /= q
+		}
+	}
+	for k := range normal {
+		out[k] = int32(normal[k])
+	}
+	return nil
+}
+
+func dracoIntSqrt(n uint64) uint64 {
+	if n == 0 {
+		return 0
+	}
+	root := uint64(1)
+	for act := n; act >= 2; act /= 4 {
+		root *= 2
+	}
+	for {
+		root = (root + n/root) / 2
+		if root*root <= n {
+			return root
+		}
+	}
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,535 @@
+package gltf
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// dracoWriter builds Draco streams for the tests, including the rANS coded data that the decoders read, by following
+// the reference encoder.
+type dracoWriter struct {
+	data []byte
+}
+
+func (w *dracoWriter) bytes(b ...byte) *dracoWriter {
+	w.data = append(w.data, b...)
+	return w
+}
+
+func (w *dracoWriter) u32(v uint32) *dracoWriter {
+	w.data = binary.LittleEndian.AppendUint32(w.data, v)
+	return w
+}
+
+func (w *dracoWriter) f32(v float32) *dracoWriter {
+	return w.u32(math.Float32bits(v))
+}
+
+func (w *dracoWriter) varint(v uint64) *dracoWriter {
+	w.data = binary.AppendUvarint(w.data, v)
+	return w
+}
+
+// bits writes an rANS coded bit sequence, as read by dracoBitDecoder.
+func (w *dracoWriter) bits(bits ...bool) *dracoWriter {
+	zeros := 0
+	for _, b := range bits {
+		if !b {
+			zeros++
+		}
+	}
+	p0 := uint32(128)
+	if len(bits) > 0 {
+		p0 = uint32(float64(zeros)/float64(len(bits))*256 + 0.5)
+	}
+	if p0 > 255 {
+		p0 = 255
+	} else if p0 == 0 {
+		p0 = 1
+	}
+
+	var buf []byte
+	state := uint32(dracoAnsLBase)
+	for i := len(bits) - 1; i >= 0; i-- {
+		p := 256 - p0
+		ls := p0
+		if bits[i] {
+			ls = p
+		}
+		if state >= dracoAnsLBase*ls {
+			buf = append(buf, byte(state))
+			state /= dracoAnsIOBase
+		}
+		quot, rem := state/ls, state%ls
+		state = quot*256 + rem
+		if !bits[i] {
+			state += p
+		}
+	}
+	buf = dracoAnsEnd(buf, state-dracoAnsLBase)
+	return w.bytes(byte(p0)).varint(uint64(len(buf))).bytes(buf...)
+}
+
+// symbols writes the probability table and rANS coded data of a dracoSymbolDecoder with the given precision.
+func (w *dracoWriter) symbols(values []uint32, bits int) *dracoWriter {
+	precision := dracoRansPrecision(bits)
+	counts := map[uint32]uint32{}
+	numSymbols := uint32(0)
+	for _, v := range values {
+		counts[v]++
+		if v+1 > numSymbols {
+			numSymbols = v + 1
+		}
+	}
+	probs := make([]uint32, numSymbols)
+	cum := make([]uint32, numSymbols)
+	total, largest := uint32(0), uint32(0)
+	for s, c := range counts {
+		probs[s] = c * precision / uint32(len(values))
+		total += probs[s]
+		if c > counts[largest] {
+			largest = s
+		}
+	}
+	probs[largest] += precision - total
+	for s := uint32(1); s < numSymbols; s++ {
+		cum[s] = cum[s-1] + probs[s-1]
+	}
+
+	w.varint(uint64(numSymbols))
+	for _, p := range probs {
+		switch {
+		case p == 0:
+			w.bytes(3)
+		case p < 1<<6:
+			w.bytes(byte(p << 2))
+		case p < 1<<14:
+			w.bytes(byte(p<<2|1), byte(p>>6))
+		default:
+			w.bytes(byte(p<<2|2), byte(p>>6), byte(p>>14))
+		}
+	}
+
+	var buf []byte
+	base := 4 * precision
+	state := base
+	for i := len(values) - 1; i >= 0; i-- {
+		p := probs[values[i]]
+		for state >= base/precision*dracoAnsIOBase*p {
+			buf = append(buf, byte(state))
+			state /= dracoAnsIOBase
+		}
+		state = state/p*precision + state%p + cum[values[i]]
+	}
+	buf = dracoAnsEnd(buf, state-base)
+	return w.varint(uint64(len(buf))).bytes(buf...)
+}
+
+// rawSymbols writes values with the raw symbol coding scheme.
+func (w *dracoWriter) rawSymbols(values []uint32, bits int) *dracoWriter {
+	return w.bytes(1, byte(bits)).symbols(values, bits)
+}
+
+// taggedSymbols writes values with the tagged symbol coding scheme, giving each group of components a bit length.
+func (w *dracoWriter) taggedSymbols(values []uint32, components int) *dracoWriter {
+	var tags []uint32
+	var raw dracoBitWriter
+	for i := 0; i < len(values); i += components {
+		max := uint32(0)
+		for _, v := range values[i : i+components] {
+			if v > max {
+				max = v
+			}
+		}
+		n := uint32(1)
+		for max>>n != 0 {
+			n++
+		}
+		tags = append(tags, n)
+		for _, v := range values[i : i+components] {
+			raw.write(v, int(n))
+		}
+	}
+	return w.bytes(0).symbols(tags, 5).bytes(raw.data...)
+}
+
+type dracoBitWriter struct {
+	data []byte
+	pos  int
+}
+
+func (w *dracoBitWriter) write(v uint32, n int) {
+	for i := 0; i < n; i++ {
+		if w.pos%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[w.pos/8] |= byte(v>>i&1) << (w.pos % 8)
+		w.pos++
+	}
+}
+
+func dracoAnsEnd(buf []byte, state uint32) []byte {
+	switch {
+	case state < 1<<6:
+		return append(buf, byte(state))
+	case state < 1<<14:
+		return binary.LittleEndian.AppendUint16(buf, uint16(1<<14+state))
+	case state < 1<<22:
+		v := 2<<22 + state
+		return append(buf, byte(v), byte(v>>8), byte(v>>16))
+	}
+	return binary.LittleEndian.AppendUint32(buf, 3<<30+state)
+}
+
+// zigzag codes signed values as symbols, with the sign in the lowest bit.
+func zigzag(values ...int32) []uint32 {
+	rval := make([]uint32, len(values))
+	for i, v := range values {
+		if v >= 0 {
+			rval[i] = uint32(v) << 1
+		} else {
+			rval[i] = uint32(-v)<<1 - 1
+		}
+	}
+	return rval
+}
+
+func TestDracoEntropyCoding(t *testing.T) {
+	bits := []bool{true, false, false, true, true, true, false, true, false, false, false, true}
+	var w dracoWriter
+	w.bits(bits...)
+	var bd dracoBitDecoder
+	if err := bd.start(newDracoBuffer(w.data)); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range bits {
+		if got := bd.bit(); got != want {
+			t.Errorf("bit %d: expected %v, got %v", i, want, got)
+		}
+	}
+
+	values := []uint32{0, 3, 3, 1, 0, 7, 3, 3, 2, 0, 1, 3, 6, 3}
+	for _, scheme := range []string{"raw", "tagged"} {
+		w = dracoWriter{}
+		if scheme == "raw" {
+			w.rawSymbols(values, 3)
+		} else {
+			w.taggedSymbols(values, 2)
+		}
+		w.bytes(0xaa)
+		b := newDracoBuffer(w.data)
+		got, err := dracoDecodeSymbols(b, len(values), 2)
+		if err != nil {
+			t.Fatalf("%s: %v", scheme, err)
+		}
+		if !reflect.DeepEqual(got, values) {
+			t.Errorf("%s: expected %v, got %v", scheme, values, got)
+		}
+		if b.remaining() != 1 {
+			t.Errorf("%s: expected the symbols to end before the last byte, %d bytes remain", scheme, b.remaining())
+		}
+	}
+
+	if dracoSigned(5) != -3 || dracoSigned(4) != 2 {
+		t.Error("expected symbols to be converted with the sign in the lowest bit")
+	}
+}
+
+// dracoSequentialMesh is a quad of two triangles with sequential connectivity and compressed indices. Positions are
+// quantized and delta coded, normals are octahedral coordinates without prediction, and texture coordinates are
+// integers.
+var dracoSequentialMesh = (&dracoWriter{}).
+	bytes('D', 'R', 'A', 'C', 'O', 2, 2, 1, 0, 0, 0).
+	// Index deltas keep their magnitude above a sign bit, unlike attribute values.
+	varint(2).varint(4).bytes(0).rawSymbols([]uint32{0, 2, 2, 5, 4, 2}, 3).
+	bytes(1).
+	varint(3).
+	bytes(0, 9, 3, 0).varint(5).
+	bytes(1, 9, 3, 0).varint(6).
+	bytes(3, 4, 2, 0).varint(7).
+	bytes(2, 3, 1).
+	bytes(0, 1, 1).taggedSymbols(zigzag(0, 0, 0, 2, 0, 0, 0, 3, 0, -2, 0, 1), 3).u32(0).u32(3).
+	bytes(0xfe, 0, 1).bytes(14, 14, 28, 14, 14, 28, 0, 14).
+	bytes(0xfe, 1).rawSymbols(zigzag(0, 0, 1, 0, 1, 2, 0, 2), 3).
+	f32(-1).f32(0).f32(0.5).f32(6).bytes(2).
+	bytes(4).
+	data
+
+func TestDracoSequentialMesh(t *testing.T) {
+	m, err := DecodeDracoMesh(dracoSequentialMesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NumPoints != 4 || !reflect.DeepEqual(m.Faces, []uint32{0, 1, 2, 0, 2, 3}) {
+		t.Errorf("unexpected connectivity: %d points, faces %v", m.NumPoints, m.Faces)
+	}
+	if len(m.Attributes) != 3 {
+		t.Fatalf("expected 3 attributes, got %d", len(m.Attributes))
+	}
+
+	// Quantized to 2 bits over a range of 6, so each step is 2.
+	positions := []float64{-1, 0, 0.5, 3, 0, 0.5, 3, 6, 0.5, -1, 6, 2.5}
+	normals := []float64{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, -1, 0}
+	texCoords := []float64{0, 0, 1, 0, 1, 2, 0, 2}
+	for i, want := range [][]float64{positions, normals, texCoords} {
+		if a := m.Attributes[i]; a.UniqueID != 5+i || !reflect.DeepEqual(a.Values, want) {
+			t.Errorf("attribute %d: expected id %d with %v, got id %d with %v", i, 5+i, want, a.UniqueID, a.Values)
+		}
+	}
+	if !m.Attributes[0].Float || m.Attributes[2].Float {
+		t.Error("expected floating point positions and integer texture coordinates")
+	}
+
+	for _, n := range []int{4, 30, len(dracoSequentialMesh) - 12, len(dracoSequentialMesh) - 1} {
+		if _, err := DecodeDracoMesh(dracoSequentialMesh[:n]); err == nil {
+			t.Errorf("expected an error for a stream truncated to %d bytes", n)
+		}
+	}
+}
+
+// dracoEdgebreakerMesh is a quad of two triangles with edgebreaker connectivity, decoded from an E symbol followed by
+// an R symbol, with quantized positions predicted by parallelograms.
+var dracoEdgebreakerMesh = func() []byte {
+	startFaces := (&dracoWriter{}).bits(false).data
+	w := (&dracoWriter{}).
+		bytes('D', 'R', 'A', 'C', 'O', 2, 2, 1, 1, 0, 0).
+		bytes(0).
+		varint(4).varint(2).bytes(0).varint(2).varint(0).
+		varint(uint64(2 + len(startFaces))).
+		varint(1).bytes(0x2f).bytes(startFaces...).
+		varint(0)
+
+	// The traversal reaches the vertices in the order 1, 2, 0, 3. Vertex 3 is predicted from the others, as 1 + 2 - 0.
+	w.bytes(1).
+		bytes(0xff, 0, 0).
+		varint(1).bytes(0, 9, 3, 0).varint(0).bytes(2).
+		bytes(1, 1, 0, 1).
+		bytes(byte(zigzag(10)[0]), 0, 0, byte(zigzag(-10)[0]), byte(zigzag(10)[0]), 0, 0, byte(zigzag(-10)[0]), 0, 2, 0, 2).
+		u32(0).u32(11).
+		f32(-1).f32(0).f32(2).f32(15).bytes(4)
+	return w.data
+}()
+
+func TestDracoEdgebreakerMesh(t *testing.T) {
+	m, err := DecodeDracoMesh(dracoEdgebreakerMesh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.NumPoints != 4 || !reflect.DeepEqual(m.Faces, []uint32{0, 1, 2, 2, 1, 3}) {
+		t.Errorf("unexpected connectivity: %d points, faces %v", m.NumPoints, m.Faces)
+	}
+	want := []float64{-1, 0, 2, 9, 0, 2, -1, 10, 2, 10, 10, 3}
+	if got := m.Attributes[0].Values; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected positions %v, got %v", want, got)
+	}
+}
+
+// dracoDocument draws dracoSequentialMesh, which the accessors of its primitive describe without buffer views of their
+// own. Buffer view 0 is unused.
+func dracoDocument() string {
+	return fmt.Sprintf(`{
+		"asset": {"version": "2.0"},
+		"extensionsUsed": ["KHR_draco_mesh_compression"],
+		"extensionsRequired": ["KHR_draco_mesh_compression"],
+		"scenes": [{"nodes": [0]}],
+		"nodes": [{"mesh": 0}],
+		"buffers": [{"uri": "data:application/octet-stream;base64,%s", "byteLength": %d}],
+		"bufferViews": [{"buffer": 0, "byteLength": 4}, {"buffer": 0, "byteLength": %d}],
+		"accessors": [
+			{"componentType": 5126, "count": 4, "type": "VEC3", "min": [-1, 0, 0.5], "max": [3, 6, 2.5]},
+			{"componentType": 5126, "count": 4, "type": "VEC3"},
+			{"componentType": 5126, "count": 4, "type": "VEC2"},
+			{"componentType": 5123, "count": 6, "type": "SCALAR"}
+		],
+		"meshes": [{"primitives": [{
+			"attributes": {"POSITION": 0, "NORMAL": 1, "TEXCOORD_0": 2},
+			"indices": 3,
+			"extensions": {"KHR_draco_mesh_compression": {
+				"bufferView": 1, "attributes": {"POSITION": 5, "NORMAL": 6, "TEXCOORD_0": 7}
+			}}
+		}]}]
+	}`, base64.StdEncoding.EncodeToString(dracoSequentialMesh), len(dracoSequentialMesh), len(dracoSequentialMesh))
+}
+
+func TestDracoResolve(t *testing.T) {
+	doc := dracoDocument()
+	resolved := resolveTestDoc(t, doc, nil)
+	p := resolved.Meshes[0].Primitives[0]
+	positions, err := p.Attributes[POSITION].ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(positions, []float32{-1, 0, 0.5, 3, 0, 0.5, 3, 6, 0.5, -1, 6, 2.5}) {
+		t.Errorf("unexpected positions %v", positions)
+	}
+	if p.Attributes[POSITION].Max[1] != 6 {
+		t.Error("expected the decoded positions to keep the accessor's bounds")
+	}
+	texCoords, err := p.Attributes[TEXCOORD_0].ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(texCoords, []float32{0, 0, 1, 0, 1, 2, 0, 2}) {
+		t.Errorf("unexpected texture coordinates %v", texCoords)
+	}
+	indices, err := p.Indices.ReadIndices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Indices.ComponentType != UNSIGNED_SHORT || !reflect.DeepEqual(indices, []uint32{0, 1, 2, 0, 2, 3}) {
+		t.Errorf("unexpected indices %v of type %d", indices, p.Indices.ComponentType)
+	}
+
+	// A Draco attribute that does not match its accessor is an error.
+	resolved.GlTF.Accessors[2].Type = VEC3
+	if err := resolved.resolveReferences(); err == nil {
+		t.Error("expected an error for an accessor with the wrong number of components")
+	}
+}
+
+func TestDracoDedupPruneAndMarshal(t *testing.T) {
+	resolved := resolveTestDoc(t, dracoDocument(), nil)
+
+	// The POSITION and NORMAL accessors have the same type and count and no data of their own, but must not be merged.
+	report, err := resolved.Dedup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Accessors) != 0 {
+		t.Fatalf("expected no Draco accessors to be merged, got %v", report.Accessors)
+	}
+	p := resolved.Meshes[0].Primitives[0]
+	positions, err := p.Attributes[POSITION].ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	normals, err := p.Attributes[NORMAL].ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(positions, normals) {
+		t.Error("expected the normals to be decoded separately from the positions")
+	}
+
+	b, err := json.Marshal(resolved.GlTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written struct {
+		Accessors []map[string]any `json:"accessors"`
+	}
+	if err := json.Unmarshal(b, &written); err != nil {
+		t.Fatal(err)
+	}
+	for i, a := range written.Accessors {
+		if _, found := a["bufferView"]; found {
+			t.Errorf("expected accessor %d to be written without a bufferView, got %v", i, a)
+		}
+	}
+
+	// Only the Draco data's buffer view is in use.
+	pruned, err := resolved.GlTF.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned.BufferViews) != 1 || pruned.BufferViews[0] != 0 {
+		t.Errorf("expected only the unused buffer view to be removed, got %v", pruned.BufferViews)
+	}
+	if err := resolved.resolveReferences(); err != nil {
+		t.Fatal(err)
+	}
+	if indices, err := resolved.Meshes[0].Primitives[0].Indices.ReadIndices(); err != nil || len(indices) != 6 {
+		t.Errorf("expected the pruned document to decode, got %v, %v", indices, err)
+	}
+}
+
+// dracoGridTriangles are the triangles of testdata/draco_grid.obj, as three positions each.
+var dracoGridTriangles = func() [][3][3]float64 {
+	vertex := func(i int) [3]float64 {
+		x, y := float64((i-1)%3), float64((i-1)/3)
+		return [3]float64{x, y, x * y / 4}
+	}
+	var rval [][3][3]float64
+	for _, f := range [][3]int{{1, 2, 5}, {1, 5, 4}, {2, 3, 6}, {2, 6, 5}, {4, 5, 8}, {4, 8, 7}, {5, 6, 9}, {5, 9, 8}} {
+		rval = append(rval, [3][3]float64{vertex(f[0]), vertex(f[1]), vertex(f[2])})
+	}
+	return rval
+}()
+
+// TestDracoReferenceFixtures decodes testdata/draco_grid.obj as encoded by the reference encoder, which is not part of
+// this repository. The fixtures are made with sequential and edgebreaker connectivity respectively by
+//
+//	draco_encoder -i testdata/draco_grid.obj -o testdata/draco_grid_sequential.drc -cl 0
+//	draco_encoder -i testdata/draco_grid.obj -o testdata/draco_grid_edgebreaker.drc -cl 7
+//
+// and the test is skipped for a fixture that has not been made. The encoder may reorder points and rotate triangles,
+// so the decoded triangles are compared as positions, with their winding, up to the default 11 bit quantization.
+func TestDracoReferenceFixtures(t *testing.T) {
+	for _, method := range []string{"sequential", "edgebreaker"} {
+		path := filepath.Join("testdata", "draco_grid_"+method+".drc")
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			t.Logf("skipping %s, which has not been made with draco_encoder", path)
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := DecodeDracoMesh(data)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		if len(m.Attributes) != 1 || m.Attributes[0].Components != 3 || !m.Attributes[0].Float {
+			t.Fatalf("%s: expected a single position attribute, got %+v", method, m.Attributes)
+		}
+		positions := m.Attributes[0].Values
+		if len(m.Faces) != 3*len(dracoGridTriangles) {
+			t.Fatalf("%s: expected %d triangles, got %d", method, len(dracoGridTriangles), len(m.Faces)/3)
+		}
+
+		// Each expected triangle must be matched by exactly one decoded triangle, starting at any of its corners.
+		matched := make([]bool, len(dracoGridTriangles))
+		for f := 0; f < len(m.Faces); f += 3 {
+			var tri [3][3]float64
+			for c := range tri {
+				p := int(m.Faces[f+c])
+				if p >= m.NumPoints || 3*p+2 >= len(positions) {
+					t.Fatalf("%s: face %d uses point %d of %d", method, f/3, p, m.NumPoints)
+				}
+				copy(tri[c][:], positions[3*p:3*p+3])
+			}
+			found := false
+			for i, want := range dracoGridTriangles {
+				for r := 0; r < 3 && !found && !matched[i]; r++ {
+					if dracoTrianglesEqual([3][3]float64{tri[r], tri[(r+1)%3], tri[(r+2)%3]}, want) {
+						matched[i], found = true, true
+					}
+				}
+			}
+			if !found {
+				t.Errorf("%s: decoded triangle %v is not in the source mesh", method, tri)
+			}
+		}
+	}
+}
+
+// dracoTrianglesEqual reports whether the corners of a and b are the same to within the precision of 11 bit positions
+// over the grid's range of 2.
+func dracoTrianglesEqual(a, b [3][3]float64) bool {
+	for c := range a {
+		for k := range a[c] {
+			if math.Abs(a[c][k]-b[c][k]) > 2.0/2047 {
+				return false
+			}
+		}
+	}
+	return true
+}
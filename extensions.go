@@ -0,0 +1,318 @@
+package gltf
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ExtensionCodec converts an extension object between its JSON form and a Go value of type T. Either function may be
+// nil, in which case encoding/json is used.
+type ExtensionCodec[T any] struct {
+	Decode func(data []byte) (*T, error)
+	Encode func(value *T) ([]byte, error)
+}
+
+// Extensible is any glTF object that has an Extensions map, which includes every object in the document and the
+// resolved objects that embed them.
+type Extensible interface {
+	extensionMap() *Extensions
+}
+
+func (e *Extensions) extensionMap() *Extensions {
+	return e
+}
+
+// registeredExtension is an ExtensionCodec with its type parameter erased.
+type registeredExtension struct {
+	name   string
+	decode func(data []byte) (any, error)
+	encode func(value any) ([]byte, error)
+	// owners holds the types of object that the extension can appear on, or nil for any object.
+	owners map[reflect.Type]bool
+}
+
+var extensionRegistry = struct {
+	sync.RWMutex
+	byType map[reflect.Type]*registeredExtension
+	names  map[string]bool
+}{
+	byType: make(map[reflect.Type]*registeredExtension),
+	names:  make(map[string]bool),
+}
+
+// RegisterExtension registers T as the Go type of the named extension, so that it can be read and written with
+// GetExtension and SetExtension. Extensions that are not registered are left as decoded by encoding/json, and are
+// written back out unchanged.
+//
+// owners optionally limits the extension to the kinds of object given, as typed nil pointers such as (*Node)(nil).
+// Some extensions have a different form on each kind of object that they appear on, which can be registered as
+// separate types under the same name, each limited to its own kind of object. Each type may only be registered once.
+func RegisterExtension[T any](name string, codec ExtensionCodec[T], owners ...Extensible) {
+	reg := &registeredExtension{
+		name: name,
+		decode: func(data []byte) (any, error) {
+			if codec.Decode != nil {
+				return codec.Decode(data)
+			}
+			rval := new(T)
+			err := json.Unmarshal(data, rval)
+			return rval, err
+		},
+		encode: func(value any) ([]byte, error) {
+			if codec.Encode != nil {
+				return codec.Encode(value.(*T))
+			}
+			return json.Marshal(value)
+		},
+	}
+
+	for _, o := range owners {
+		if reg.owners == nil {
+			reg.owners = make(map[reflect.Type]bool)
+		}
+		reg.owners[ownerType(reflect.TypeOf(o))] = true
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	extensionRegistry.Lock()
+	defer extensionRegistry.Unlock()
+	if _, found := extensionRegistry.byType[t]; found {
+		panic(fmt.Sprintf("Extension type %v is already registered", t))
+	}
+	extensionRegistry.byType[t] = reg
+	extensionRegistry.names[name] = true
+}
+
+// RegisteredExtensions returns the names of every registered extension, along with the extensions supported by this
+// package that have no objects to register, such as KHR_mesh_quantization, in sorted order.
+func RegisteredExtensions() []string {
+	extensionRegistry.RLock()
+	defer extensionRegistry.RUnlock()
+	rval := make([]string, 0, len(extensionRegistry.names)+len(implicitExtensions))
+	for name := range extensionRegistry.names {
+		rval = append(rval, name)
+	}
+	for name := range implicitExtensions {
+		if !extensionRegistry.names[name] {
+			rval = append(rval, name)
+		}
+	}
+	sort.Strings(rval)
+	return rval
+}
+
+var extensionsType = reflect.TypeOf(Extensions(nil))
+
+// ownerType returns the type of glTF object that holds the Extensions of a value of type t, looking through pointers
+// and embedded fields, so that a resolved object has the same owner type as its source object.
+func ownerType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous && f.Type == extensionsType {
+			return t
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous {
+			if owner := ownerType(f.Type); owner != nil {
+				return owner
+			}
+		}
+	}
+	return nil
+}
+
+func registration[T any]() (*registeredExtension, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	extensionRegistry.RLock()
+	defer extensionRegistry.RUnlock()
+	if reg, found := extensionRegistry.byType[t]; found {
+		return reg, nil
+	}
+	return nil, fmt.Errorf("Extension type %v is not registered", t)
+}
+
+// typedExtension is how a registered extension is held in an Extensions map once it has been decoded or set, so that
+// it is written back out with its codec.
+type typedExtension struct {
+	reg   *registeredExtension
+	value any
+}
+
+func (te *typedExtension) MarshalJSON() ([]byte, error) {
+	return te.reg.encode(te.value)
+}
+
+// GetExtension returns the extension of type T on obj, decoding it if it was loaded from JSON, and false if obj does not
+// have the extension. The decoded value replaces the JSON form in obj's Extensions, so changes made to it are kept and
+// are written out with the document. As that modifies obj, GetExtension must not be called concurrently with any other
+// use of the same object. It is an error if T has not been registered, or if the extension can not be decoded as a T.
+// If T is registered for other kinds of object than obj, obj is treated as not having the extension.
+func GetExtension[T any](obj Extensible) (*T, bool, error) {
+	reg, err := registration[T]()
+	if err != nil {
+		return nil, false, err
+	}
+	if reg.owners != nil && !reg.owners[ownerType(reflect.TypeOf(obj))] {
+		return nil, false, nil
+	}
+	ext := obj.extensionMap()
+	raw, found := (*ext)[reg.name]
+	if !found {
+		return nil, false, nil
+	}
+
+	switch v := raw.(type) {
+	case *typedExtension:
+		if t, ok := v.value.(*T); ok {
+			return t, true, nil
+		}
+		return nil, true, fmt.Errorf("Extension %s holds a %T, not a %T", reg.name, v.value, (*T)(nil))
+	case *T:
+		(*ext)[reg.name] = &typedExtension{reg, v}
+		return v, true, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, true, fmt.Errorf("Could not encode %s: %w", reg.name, err)
+	}
+	value, err := reg.decode(data)
+	if err != nil {
+		return nil, true, fmt.Errorf("Could not decode %s: %w", reg.name, err)
+	}
+	(*ext)[reg.name] = &typedExtension{reg, value}
+	return value.(*T), true, nil
+}
+
+// SetExtension sets the extension of type T on obj, replacing any existing value. It panics if T has not been
+// registered, or is registered for other kinds of object than obj. The extension is not added to the document's
+// ExtensionsUsed.
+func SetExtension[T any](obj Extensible, value *T) {
+	reg, err := registration[T]()
+	if err != nil {
+		panic(err)
+	}
+	if reg.owners != nil && !reg.owners[ownerType(reflect.TypeOf(obj))] {
+		panic(fmt.Sprintf("Extension type %T can not be set on a %T", value, obj))
+	}
+	ext := obj.extensionMap()
+	if *ext == nil {
+		*ext = make(Extensions)
+	}
+	(*ext)[reg.name] = &typedExtension{reg, value}
+}
+
+// RemoveExtension removes the named extension from obj, whether or not it is registered.
+func RemoveExtension(obj Extensible, name string) {
+	delete(*obj.extensionMap(), name)
+}
+
+// implicitExtensions are extensions that change how the core spec is interpreted, without appearing in the extensions
+// of any object.
+var implicitExtensions = map[string]bool{
+	KHR_MESH_QUANTIZATION: true,
+}
+
+// CheckExtensions compares the document's extensions with those in supported. It returns an error, listing them, if
+// any extension in ExtensionsRequired is not supported, as the document can not be loaded correctly without it. It
+// also returns a warning for every extension in ExtensionsUsed that no object uses, every extension that is used but
+// not listed in ExtensionsUsed, and every extension in ExtensionsRequired that is not in ExtensionsUsed.
+func (gltf *GlTF) CheckExtensions(supported []string) ([]string, error) {
+	isSupported := make(map[string]bool, len(supported))
+	for _, name := range supported {
+		isSupported[name] = true
+	}
+	var unsupported []string
+	for _, name := range gltf.ExtensionsRequired {
+		if !isSupported[name] {
+			unsupported = append(unsupported, name)
+		}
+	}
+	if len(unsupported) > 0 {
+		return nil, fmt.Errorf("Required extensions are not supported: %s", strings.Join(unsupported, ", "))
+	}
+
+	used, err := gltf.referencedExtensions()
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]bool, len(gltf.ExtensionsUsed))
+	var warnings []string
+	for _, name := range gltf.ExtensionsUsed {
+		listed[name] = true
+		if !used[name] && !implicitExtensions[name] {
+			warnings = append(warnings, fmt.Sprintf("Extension %s is listed in extensionsUsed but is not used", name))
+		}
+	}
+	var unlisted []string
+	for name := range used {
+		if !listed[name] {
+			unlisted = append(unlisted, name)
+		}
+	}
+	sort.Strings(unlisted)
+	for _, name := range unlisted {
+		warnings = append(warnings, fmt.Sprintf("Extension %s is used but is not listed in extensionsUsed", name))
+	}
+	for _, name := range gltf.ExtensionsRequired {
+		if !listed[name] {
+			warnings = append(warnings, fmt.Sprintf("Extension %s is listed in extensionsRequired but not in extensionsUsed", name))
+		}
+	}
+	return warnings, nil
+}
+
+// referencedExtensions returns the name of every extension that appears in the extensions of any object in the
+// document, including objects nested inside other extensions.
+func (gltf *GlTF) referencedExtensions() (map[string]bool, error) {
+	b, err := json.Marshal(gltf)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+
+	rval := make(map[string]bool)
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ext, ok := v["extensions"].(map[string]any); ok {
+				for name := range ext {
+					rval[name] = true
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+	return rval, nil
+}
+
+// usesExtension reports whether the extension is listed in the document's ExtensionsUsed.
+func (gltf *GlTF) usesExtension(name string) bool {
+	for _, used := range gltf.ExtensionsUsed {
+		if used == name {
+			return true
+		}
+	}
+	return false
+}
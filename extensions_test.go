@@ -0,0 +1,116 @@
+package gltf
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+// testScale is an extension with a custom codec, which writes its value as a string.
+type testScale struct {
+	Factor float64
+}
+
+func init() {
+	RegisterExtension("TEST_scale", ExtensionCodec[testScale]{
+		Decode: func(data []byte) (*testScale, error) {
+			var s string
+			if err := json.Unmarshal(data, &s); err != nil {
+				return nil, err
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			return &testScale{f}, err
+		},
+		Encode: func(value *testScale) ([]byte, error) {
+			return json.Marshal(strconv.FormatFloat(value.Factor, 'g', -1, 64))
+		},
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	doc, err := FromBytes([]byte(`{
+		"asset": {"version": "2.0"},
+		"extensions": {"KHR_lights_punctual": {"lights": [{"type": "point"}]}},
+		"nodes": [{"extensions": {"TEST_scale": "1.5", "VENDOR_unknown": {"a": [1, 2], "b": "c"}}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := &doc.Nodes[0]
+	scale, found, err := GetExtension[testScale](node)
+	if err != nil || !found || scale.Factor != 1.5 {
+		t.Fatalf("expected the decoded extension, got %v %v %v", scale, found, err)
+	}
+	if again, _, _ := GetExtension[testScale](node); again != scale {
+		t.Error("expected the decoded value to be kept on the node")
+	}
+	scale.Factor = 3
+	if _, found, err := GetExtension[KHRLightsPunctualNode](node); found || err != nil {
+		t.Errorf("expected no light on the node, got %v %v", found, err)
+	}
+	if _, found, err := GetExtension[KHRLightsPunctualNode](doc); found || err != nil {
+		t.Errorf("expected the node form of the lights extension to be ignored on the root, got %v %v", found, err)
+	}
+	if lights, found, err := GetExtension[KHRLightsPunctual](doc); !found || err != nil || len(lights.Lights) != 1 {
+		t.Errorf("expected the root form of the lights extension, got %v %v", found, err)
+	}
+	if _, _, err := GetExtension[struct{}](node); err == nil {
+		t.Error("expected an error for an unregistered type")
+	}
+
+	b, err := doc.ToBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := FromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := reloaded.Nodes[0].Extensions["TEST_scale"]; s != "3" {
+		t.Errorf("expected the changed extension to be written with its codec, got %v", s)
+	}
+	unknown, _ := json.Marshal(reloaded.Nodes[0].Extensions["VENDOR_unknown"])
+	if string(unknown) != `{"a":[1,2],"b":"c"}` {
+		t.Errorf("expected the unknown extension to round trip, got %s", unknown)
+	}
+	if lights, err := reloaded.Lights(); err != nil || len(lights) != 1 || lights[0].Type != POINT {
+		t.Errorf("expected the lights to round trip, got %v %v", lights, err)
+	}
+}
+
+func TestCheckExtensions(t *testing.T) {
+	doc, err := FromBytes([]byte(`{
+		"asset": {"version": "2.0"},
+		"extensionsUsed": ["KHR_lights_punctual", "EXT_unused", "KHR_mesh_quantization"],
+		"extensionsRequired": ["KHR_lights_punctual", "EXT_needed", "EXT_other"],
+		"nodes": [{"extensions": {"EXT_unlisted": {}}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := doc.Resolve(nil); err == nil || err.Error() != "Required extensions are not supported: EXT_needed, EXT_other" {
+		t.Fatalf("Expected an error listing the unsupported extensions, got %v", err)
+	}
+
+	resolved, err := doc.ResolveWithOptions(ResolveOptions{SupportedExtensions: []string{"EXT_needed", "EXT_other"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"Extension KHR_lights_punctual is listed in extensionsUsed but is not used",
+		"Extension EXT_unused is listed in extensionsUsed but is not used",
+		"Extension EXT_unlisted is used but is not listed in extensionsUsed",
+		"Extension EXT_needed is listed in extensionsRequired but not in extensionsUsed",
+		"Extension EXT_other is listed in extensionsRequired but not in extensionsUsed",
+	}
+	if len(resolved.Warnings) != len(expected) {
+		t.Fatalf("Expected warnings %q, got %q", expected, resolved.Warnings)
+	}
+	for i := range expected {
+		if resolved.Warnings[i] != expected[i] {
+			t.Errorf("Expected warning %q, got %q", expected[i], resolved.Warnings[i])
+		}
+	}
+}
@@ -2,6 +2,7 @@ module github.com/bbredesen/gltf
 
 go 1.20
 
-require github.com/bbredesen/vkm v0.2.0
-
-require github.com/chewxy/math32 v1.0.6 // indirect
+require (
+	github.com/bbredesen/vkm v0.2.0
+	github.com/chewxy/math32 v1.0.6
+)
@@ -0,0 +1,34 @@
+package gltf
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg" // glTF images are PNG or JPEG
+	_ "image/png"
+)
+
+// Data returns the encoded bytes of the image, from its buffer view or its URI.
+func (img *ResolvedImage) Data() ([]byte, error) {
+	if img.BufferView != nil {
+		return img.BufferView.Data, nil
+	}
+	if img.Uri == "" {
+		return nil, errors.New("Image has neither a buffer view nor a URI")
+	}
+	if img.root == nil {
+		return nil, errors.New("Image is not part of a resolved document")
+	}
+	return img.root.readUri(img.Uri)
+}
+
+// Decode loads and decodes the image. PNG and JPEG images are supported, which are the formats allowed by the core
+// spec.
+func (img *ResolvedImage) Decode() (image.Image, error) {
+	data, err := img.Data()
+	if err != nil {
+		return nil, err
+	}
+	rval, _, err := image.Decode(bytes.NewReader(data))
+	return rval, err
+}
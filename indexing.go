@@ -0,0 +1,180 @@
+package gltf
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Weld returns an indexed copy of the primitive in which vertices that are equal in every attribute are merged into a
+// single vertex. Two vertices are equal if no component of any of their attributes, read as with ReadFloats, differs
+// by more than epsilon; with an epsilon of zero only vertices with bit-identical values are merged. A merged vertex
+// keeps the values of the first vertex in the group, in index order. Vertices not referenced by the primitive are
+// dropped.
+//
+// The primitive's mode is unchanged, and it does not need to have indices already. Every attribute is copied into a
+// new standalone accessor; the source primitive is not modified.
+func (p *ResolvedPrimitive) Weld(epsilon float32) (*ResolvedPrimitive, error) {
+	if epsilon < 0 {
+		return nil, errors.New("Weld epsilon must not be negative")
+	}
+	indices, err := p.vertexIndices()
+	if err != nil {
+		return nil, err
+	}
+	vertices, err := p.vertexVectors()
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indices {
+		if int(idx) >= len(vertices) {
+			return nil, errors.New("Primitive index is out of range of its attributes")
+		}
+	}
+
+	vertexMap, remap := weldVectors(vertices, indices, epsilon)
+	newIndices := make([]uint32, len(indices))
+	for i, idx := range indices {
+		newIndices[i] = remap[idx]
+	}
+
+	rval, err := p.gatherVertices(vertexMap)
+	if err != nil {
+		return nil, err
+	}
+	rval.Indices = NewIndexAccessor(newIndices)
+	return rval, nil
+}
+
+// Unweld returns a non-indexed copy of the primitive, with a separate vertex for every index of the source primitive.
+// The primitive's mode is unchanged. If the primitive is not indexed, its attributes are still copied into new
+// standalone accessors.
+func (p *ResolvedPrimitive) Unweld() (*ResolvedPrimitive, error) {
+	indices, err := p.vertexIndices()
+	if err != nil {
+		return nil, err
+	}
+	rval, err := p.gatherVertices(indices)
+	if err != nil {
+		return nil, err
+	}
+	rval.Indices = nil
+	return rval, nil
+}
+
+// vertexVectors returns every component of every attribute of each vertex, as with ReadFloats, followed by those of
+// each morph target. The attributes are in a fixed order, with POSITION first.
+func (p *ResolvedPrimitive) vertexVectors() ([][]float32, error) {
+	count := p.VertexCount()
+	rval := make([][]float32, count)
+	for _, attrs := range append([]map[AttributeKey]*ResolvedAccessor{p.Attributes}, p.Targets...) {
+		keys := make([]AttributeKey, 0, len(attrs))
+		for k := range attrs {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if (keys[i] == POSITION) != (keys[j] == POSITION) {
+				return keys[i] == POSITION
+			}
+			return keys[i] < keys[j]
+		})
+
+		for _, k := range keys {
+			a := attrs[k]
+			if a.Count != count {
+				return nil, errors.New("Primitive attributes have different element counts")
+			}
+			values, err := a.ReadFloats()
+			if err != nil {
+				return nil, err
+			}
+			n := a.Type.Count()
+			for v := range rval {
+				rval[v] = append(rval[v], values[v*n:(v+1)*n]...)
+			}
+		}
+	}
+	return rval, nil
+}
+
+// weldVectors merges equal vertices among those referenced by indices, as described by Weld. It returns the source
+// vertex for each welded vertex, and the welded vertex for each source vertex.
+func weldVectors(vertices [][]float32, indices []uint32, epsilon float32) (vertexMap, remap []uint32) {
+	remap = make([]uint32, len(vertices))
+	done := make([]bool, len(vertices))
+
+	if epsilon == 0 {
+		welded := make(map[string]uint32)
+		key := make([]byte, 0, 64)
+		for _, idx := range indices {
+			if done[idx] {
+				continue
+			}
+			done[idx] = true
+
+			key = key[:0]
+			for _, f := range vertices[idx] {
+				b := math.Float32bits(f)
+				key = append(key, byte(b), byte(b>>8), byte(b>>16), byte(b>>24))
+			}
+			w, found := welded[string(key)]
+			if !found {
+				w = uint32(len(vertexMap))
+				welded[string(key)] = w
+				vertexMap = append(vertexMap, idx)
+			}
+			remap[idx] = w
+		}
+		return vertexMap, remap
+	}
+
+	// Hash the first (up to) three components, normally the position, into a grid with cells the size of epsilon, so
+	// that any match must be in one of the neighbouring cells.
+	type cell [3]int64
+	cellOf := func(v []float32) cell {
+		var c cell
+		for d := 0; d < len(c) && d < len(v); d++ {
+			c[d] = int64(math.Floor(float64(v[d] / epsilon)))
+		}
+		return c
+	}
+	within := func(a, b []float32) bool {
+		for i := range a {
+			if d := a[i] - b[i]; d > epsilon || d < -epsilon {
+				return false
+			}
+		}
+		return true
+	}
+
+	grid := make(map[cell][]uint32)
+	for _, idx := range indices {
+		if done[idx] {
+			continue
+		}
+		done[idx] = true
+
+		v := vertices[idx]
+		c := cellOf(v)
+		w, found := uint32(0), false
+		for dx := int64(-1); dx <= 1 && !found; dx++ {
+			for dy := int64(-1); dy <= 1 && !found; dy++ {
+				for dz := int64(-1); dz <= 1 && !found; dz++ {
+					for _, candidate := range grid[cell{c[0] + dx, c[1] + dy, c[2] + dz}] {
+						if within(vertices[vertexMap[candidate]], v) {
+							w, found = candidate, true
+							break
+						}
+					}
+				}
+			}
+		}
+		if !found {
+			w = uint32(len(vertexMap))
+			vertexMap = append(vertexMap, idx)
+			grid[c] = append(grid[c], w)
+		}
+		remap[idx] = w
+	}
+	return vertexMap, remap
+}
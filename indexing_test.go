@@ -0,0 +1,84 @@
+package gltf
+
+import (
+	"reflect"
+	"testing"
+)
+
+// twoTriangles is a non-indexed quad, with the two vertices on the shared diagonal duplicated. The second copy of
+// vertex 2 is slightly offset.
+func twoTriangles() *ResolvedPrimitive {
+	return &ResolvedPrimitive{
+		Primitive: &Primitive{},
+		Mode:      TRIANGLES,
+		Attributes: map[AttributeKey]*ResolvedAccessor{
+			POSITION:    NewFloatAccessor(VEC3, []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 0, 0, 1, 1.001, 0, 0, 1, 0}),
+			TexCoord(0): NewFloatAccessor(VEC2, []float32{0, 1, 1, 1, 1, 0, 0, 1, 1, 0, 0, 0}),
+		},
+	}
+}
+
+func TestWeld(t *testing.T) {
+	exact, err := twoTriangles().Weld(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices, _ := exact.Indices.ReadIndices()
+	if exact.VertexCount() != 5 || !reflect.DeepEqual(indices, []uint32{0, 1, 2, 0, 3, 4}) {
+		t.Errorf("exact weld: expected 5 vertices and indices [0 1 2 0 3 4], got %d and %v", exact.VertexCount(), indices)
+	}
+
+	loose, err := twoTriangles().Weld(0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices, _ = loose.Indices.ReadIndices()
+	if loose.VertexCount() != 4 || !reflect.DeepEqual(indices, []uint32{0, 1, 2, 0, 2, 3}) {
+		t.Errorf("weld with epsilon: expected 4 vertices and indices [0 1 2 0 2 3], got %d and %v", loose.VertexCount(), indices)
+	}
+	if uv, _ := loose.Attributes[TexCoord(0)].ReadFloats(); !reflect.DeepEqual(uv, []float32{0, 1, 1, 1, 1, 0, 0, 0}) {
+		t.Errorf("texture coordinates were not gathered to match, got %v", uv)
+	}
+
+	unwelded, err := loose.Unweld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unwelded.Indices != nil || unwelded.VertexCount() != 6 {
+		t.Fatalf("expected 6 non-indexed vertices, got %d", unwelded.VertexCount())
+	}
+	pos, _ := unwelded.Attributes[POSITION].ReadFloats()
+	if !reflect.DeepEqual(pos, []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 0, 0, 1, 1, 0, 0, 1, 0}) {
+		t.Errorf("unexpected positions after unwelding: %v", pos)
+	}
+}
+
+func TestWeldMorphTargets(t *testing.T) {
+	// The exact duplicate of vertex 0 is displaced differently by the morph target, so it is kept apart.
+	p := twoTriangles()
+	p.Targets = []map[AttributeKey]*ResolvedAccessor{
+		{POSITION: NewFloatAccessor(VEC3, []float32{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0})},
+	}
+	welded, err := p.Weld(0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices, _ := welded.Indices.ReadIndices()
+	if welded.VertexCount() != 5 || !reflect.DeepEqual(indices, []uint32{0, 1, 2, 3, 2, 4}) {
+		t.Errorf("expected 5 vertices and indices [0 1 2 3 2 4], got %d and %v", welded.VertexCount(), indices)
+	}
+	if len(welded.Targets) != 1 {
+		t.Fatalf("expected the morph target to be kept, got %d targets", len(welded.Targets))
+	}
+	if d, _ := welded.Targets[0][POSITION].ReadFloats(); !reflect.DeepEqual(d, []float32{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0}) {
+		t.Errorf("morph target displacements were not gathered to match, got %v", d)
+	}
+
+	unwelded, err := welded.Unweld()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unwelded.Targets) != 1 || unwelded.Targets[0][POSITION].Count != 6 {
+		t.Errorf("expected a morph target with 6 displacements after unwelding")
+	}
+}
@@ -0,0 +1,165 @@
+package gltf
+
+import (
+	"fmt"
+
+	"github.com/bbredesen/vkm"
+)
+
+const EXT_MESH_GPU_INSTANCING = "EXT_mesh_gpu_instancing"
+
+// EXTMeshGPUInstancing is the EXT_mesh_gpu_instancing extension on a node, which maps per-instance attributes to
+// accessors holding one element per instance. See
+// https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Vendor/EXT_mesh_gpu_instancing
+type EXTMeshGPUInstancing struct {
+	Attributes map[AttributeKey]int `json:"attributes"`
+}
+
+// Instance attributes that make up the transform of each instance. Applications may define others, whose names start
+// with an underscore.
+const (
+	INSTANCE_TRANSLATION AttributeKey = "TRANSLATION"
+	INSTANCE_ROTATION    AttributeKey = "ROTATION"
+	INSTANCE_SCALE       AttributeKey = "SCALE"
+)
+
+func init() {
+	RegisterExtension(EXT_MESH_GPU_INSTANCING, ExtensionCodec[EXTMeshGPUInstancing]{}, (*Node)(nil))
+}
+
+// instancingAccessors returns the attributes of the node's EXT_mesh_gpu_instancing extension, or nil if it does not
+// have one.
+func (n *Node) instancingAccessors() (map[AttributeKey]int, error) {
+	ext, _, err := GetExtension[EXTMeshGPUInstancing](n)
+	if ext == nil {
+		return nil, err
+	}
+	return ext.Attributes, err
+}
+
+// resolveInstances sets the instance attributes and transforms of rval from the node's EXT_mesh_gpu_instancing
+// extension.
+func (node *Node) resolveInstances(root *ResolvedGlTF, rval *ResolvedNode) error {
+	ext, found, err := GetExtension[EXTMeshGPUInstancing](node)
+	if err != nil || !found {
+		return err
+	}
+	if node.Mesh == nil {
+		return fmt.Errorf("%s is used on a node without a mesh", EXT_MESH_GPU_INSTANCING)
+	}
+
+	count := -1
+	rval.InstanceAttributes = make(map[AttributeKey]*ResolvedAccessor, len(ext.Attributes))
+	for k, idx := range ext.Attributes {
+		if idx < 0 || idx >= len(root.Accessors) {
+			return fmt.Errorf("%s attribute %s: accessor %d is not a valid accessor", EXT_MESH_GPU_INSTANCING, k, idx)
+		}
+		a := &root.Accessors[idx]
+		if count >= 0 && int(a.Count) != count {
+			return fmt.Errorf("%s attribute %s has %d instances, not %d", EXT_MESH_GPU_INSTANCING, k, a.Count, count)
+		}
+		count = int(a.Count)
+		if err := validateInstanceAttribute(k, a.Accessor); err != nil {
+			return err
+		}
+		rval.InstanceAttributes[k] = a
+	}
+	if count < 0 {
+		return fmt.Errorf("%s has no attributes", EXT_MESH_GPU_INSTANCING)
+	}
+
+	var translations, scales []vkm.Vec3
+	var rotations []float32
+	if a := rval.InstanceAttributes[INSTANCE_TRANSLATION]; a != nil {
+		if translations, err = a.ReadVec3s(); err != nil {
+			return err
+		}
+	}
+	if a := rval.InstanceAttributes[INSTANCE_ROTATION]; a != nil {
+		if rotations, err = a.ReadFloats(); err != nil {
+			return err
+		}
+	}
+	if a := rval.InstanceAttributes[INSTANCE_SCALE]; a != nil {
+		if scales, err = a.ReadVec3s(); err != nil {
+			return err
+		}
+	}
+
+	rval.Instances = make([]vkm.Mat, count)
+	for i := range rval.Instances {
+		t, r, s := vkm.Vec3{}, vkm.Vec{0, 0, 0, 1}, vkm.Vec3{1, 1, 1}
+		if translations != nil {
+			t = translations[i]
+		}
+		if rotations != nil {
+			// Normalized integer rotations are not exactly unit length.
+			r = normalizeQuat(vkm.Vec{rotations[4*i], rotations[4*i+1], rotations[4*i+2], rotations[4*i+3]})
+		}
+		if scales != nil {
+			s = scales[i]
+		}
+		rval.Instances[i] = trsMatrix(t, r, s)
+	}
+	return nil
+}
+
+// validateInstanceAttribute checks the type of an accessor used for an instance transform. Translations and scales may
+// also be stored as integers, which is only valid with KHR_mesh_quantization, but that is not checked.
+func validateInstanceAttribute(k AttributeKey, a *Accessor) error {
+	switch k {
+	case INSTANCE_TRANSLATION, INSTANCE_SCALE:
+		if a.Type != VEC3 {
+			return fmt.Errorf("%s attribute %s can not use accessor %q, which has type %s", EXT_MESH_GPU_INSTANCING, k,
+				a.Name, a.Type)
+		}
+	case INSTANCE_ROTATION:
+		if a.Type != VEC4 {
+			return fmt.Errorf("%s attribute %s can not use accessor %q, which has type %s", EXT_MESH_GPU_INSTANCING, k,
+				a.Name, a.Type)
+		}
+		if a.ComponentType != FLOAT && !(a.Normalized && (a.ComponentType == BYTE || a.ComponentType == SHORT)) {
+			return fmt.Errorf("%s attribute %s can not use accessor %q, which has component type %d with normalized %t",
+				EXT_MESH_GPU_INSTANCING, k, a.Name, a.ComponentType, a.Normalized)
+		}
+	}
+	return nil
+}
+
+// InstanceWorldMatrices returns the world transform of each instance of the node's mesh, given the node's own world
+// transform. That is world itself for a node without EXT_mesh_gpu_instancing, or else world times each of Instances.
+func (n *ResolvedNode) InstanceWorldMatrices(world vkm.Mat) []vkm.Mat {
+	if n.Instances == nil {
+		return []vkm.Mat{world}
+	}
+	rval := make([]vkm.Mat, len(n.Instances))
+	for i, m := range n.Instances {
+		rval[i] = world.MultM(m)
+	}
+	return rval
+}
+
+// MeshInstance is a mesh drawn in a scene by a node, once for each of the node's EXT_mesh_gpu_instancing instances.
+type MeshInstance struct {
+	Node *ResolvedNode
+	Mesh *ResolvedMesh
+	// Instance is the index of the instance in Node.Instances, which is 0 for a node without instances.
+	Instance int
+	// World is the transform of the instance to world space.
+	World vkm.Mat
+}
+
+// MeshInstances returns the draw list of the scene: every mesh drawn by a node, once for each instance of the node, in
+// the given pose (nil for the rest pose), in the order that the scene is traversed: depth first, in document order.
+func (s *ResolvedScene) MeshInstances(pose Pose) []MeshInstance {
+	var rval []MeshInstance
+	s.walk(pose, func(n *ResolvedNode, world vkm.Mat) {
+		if n.Mesh == nil {
+			return
+		}
+		for i, m := range n.InstanceWorldMatrices(world) {
+			rval = append(rval, MeshInstance{Node: n, Mesh: n.Mesh, Instance: i, World: m})
+		}
+	})
+	return rval
+}
@@ -0,0 +1,134 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// instancingDocument draws a triangle twice: once in place, and once scaled by 2, turned a quarter turn around Z and
+// moved along X. The rotations are normalized shorts.
+const instancingDocument = `{
+	"asset": {"version": "2.0"},
+	"extensionsUsed": ["EXT_mesh_gpu_instancing"],
+	"scenes": [{"nodes": [0]}],
+	"nodes": [{
+		"mesh": 0, "translation": [0, 0, 5],
+		"extensions": {"EXT_mesh_gpu_instancing": {"attributes": {"TRANSLATION": 2, "SCALE": 3, "ROTATION": 4}}}
+	}],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 1}}]}],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 1, "type": "SCALAR"},
+		{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3", "min": [0, 0, 0], "max": [1, 1, 0]},
+		{"bufferView": 1, "componentType": 5126, "count": 2, "type": "VEC3"},
+		{"bufferView": 2, "componentType": 5126, "count": 2, "type": "VEC3"},
+		{"bufferView": 3, "componentType": 5122, "normalized": true, "count": 2, "type": "VEC4"}
+	],
+	"bufferViews": [
+		{"buffer": 0, "byteLength": 36},
+		{"buffer": 0, "byteOffset": 36, "byteLength": 24},
+		{"buffer": 0, "byteOffset": 60, "byteLength": 24},
+		{"buffer": 0, "byteOffset": 84, "byteLength": 16}
+	],
+	"buffers": [{"uri": "instancing.bin", "byteLength": 100}]
+}`
+
+func instancingBuffer() []byte {
+	rotations := make([]byte, 0, 16)
+	for _, v := range []int16{0, 0, 0, 32767, 0, 0, 23170, 23170} {
+		rotations = binary.LittleEndian.AppendUint16(rotations, uint16(v))
+	}
+	return concatBytes(
+		float32Bytes(0, 0, 0, 1, 0, 0, 0, 1, 0),
+		float32Bytes(0, 0, 0, 10, 0, 0),
+		float32Bytes(1, 1, 1, 2, 2, 2),
+		rotations,
+	)
+}
+
+func TestInstancing(t *testing.T) {
+	resolved := resolveTestDoc(t, instancingDocument, map[string][]byte{"instancing.bin": instancingBuffer()})
+	n := &resolved.Nodes[0]
+	if len(n.Instances) != 2 || n.InstanceAttributes[INSTANCE_ROTATION] != &resolved.Accessors[4] {
+		t.Fatalf("expected 2 instances with resolved attributes, got %d", len(n.Instances))
+	}
+
+	draws := resolved.Scenes[0].MeshInstances(nil)
+	if len(draws) != 2 || draws[1].Node != n || draws[1].Mesh != n.Mesh || draws[1].Instance != 1 {
+		t.Fatalf("expected a draw for each instance, got %+v", draws)
+	}
+	for i, expected := range []vkm.Vec3{{1, 0, 5}, {10, 2, 5}} {
+		if p := transformPoint(draws[i].World, vkm.Vec3{1, 0, 0}); !approxVec3(p, expected) {
+			t.Errorf("expected instance %d to move (1, 0, 0) to %v, got %v", i, expected, p)
+		}
+	}
+
+	b, err := resolved.Scenes[0].Bounds(BoundsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !approxVec3(b.Box.Min, vkm.Vec3{0, 0, 5}) || !approxVec3(b.Box.Max, vkm.Vec3{10, 2, 5}) {
+		t.Errorf("expected the bounds to cover both instances, got %v", b.Box)
+	}
+
+	bvh, err := resolved.Scenes[0].BuildBVH(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit, ok := bvh.Raycast(Ray{vkm.Vec3{9.5, 0.5, 10}, vkm.Vec3{0, 0, -1}}, float32(math.Inf(1))); !ok || hit.Node != n {
+		t.Error("expected a ray to hit the second instance")
+	}
+
+	// A node without the extension is drawn once at its own transform.
+	if m := vkm.NewMatTranslate(vkm.NewVec(1, 2, 3)); len((&ResolvedNode{}).InstanceWorldMatrices(m)) != 1 {
+		t.Error("expected a single instance for a node without the extension")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "instancing.bin"), instancingBuffer(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, attributes := range []string{
+		`{"TRANSLATION": 2, "SCALE": 1}`,
+		`{"ROTATION": 2}`,
+		`{"TRANSLATION": 4}`,
+		`{"TRANSLATION": 5}`,
+		`{}`,
+	} {
+		doc, err := FromBytes([]byte(strings.Replace(instancingDocument,
+			`{"TRANSLATION": 2, "SCALE": 3, "ROTATION": 4}`, attributes, 1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc.meta.defaultSearchPath = dir
+		if _, err := doc.Resolve(nil); err == nil {
+			t.Errorf("expected an error for attributes %s", attributes)
+		}
+	}
+}
+
+func TestPruneInstancing(t *testing.T) {
+	doc, err := FromBytes([]byte(instancingDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := doc.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Accessors) != 1 || report.Accessors[0] != 0 {
+		t.Fatalf("expected only the unused accessor to be removed, got %v", report.Accessors)
+	}
+	attrs, err := doc.Nodes[0].instancingAccessors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs[INSTANCE_TRANSLATION] != 1 || attrs[INSTANCE_SCALE] != 2 || attrs[INSTANCE_ROTATION] != 3 {
+		t.Errorf("expected the instance accessors to be remapped, got %v", attrs)
+	}
+}
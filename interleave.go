@@ -0,0 +1,342 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// VertexFormat describes the encoding of a single vertex attribute. Values are identical to the equivalent VkFormat
+// enum values, so that a VertexFormat can be cast directly to vk.Format when building a vertex input description.
+type VertexFormat int
+
+const (
+	FORMAT_UNDEFINED VertexFormat = 0
+
+	FORMAT_R8_UNORM   VertexFormat = 9
+	FORMAT_R8_SNORM   VertexFormat = 10
+	FORMAT_R8_USCALED VertexFormat = 11
+	FORMAT_R8_SSCALED VertexFormat = 12
+	FORMAT_R8_UINT    VertexFormat = 13
+	FORMAT_R8_SINT    VertexFormat = 14
+
+	FORMAT_R8G8_UNORM   VertexFormat = 16
+	FORMAT_R8G8_SNORM   VertexFormat = 17
+	FORMAT_R8G8_USCALED VertexFormat = 18
+	FORMAT_R8G8_SSCALED VertexFormat = 19
+	FORMAT_R8G8_UINT    VertexFormat = 20
+	FORMAT_R8G8_SINT    VertexFormat = 21
+
+	FORMAT_R8G8B8_UNORM   VertexFormat = 23
+	FORMAT_R8G8B8_SNORM   VertexFormat = 24
+	FORMAT_R8G8B8_USCALED VertexFormat = 25
+	FORMAT_R8G8B8_SSCALED VertexFormat = 26
+	FORMAT_R8G8B8_UINT    VertexFormat = 27
+	FORMAT_R8G8B8_SINT    VertexFormat = 28
+
+	FORMAT_R8G8B8A8_UNORM   VertexFormat = 37
+	FORMAT_R8G8B8A8_SNORM   VertexFormat = 38
+	FORMAT_R8G8B8A8_USCALED VertexFormat = 39
+	FORMAT_R8G8B8A8_SSCALED VertexFormat = 40
+	FORMAT_R8G8B8A8_UINT    VertexFormat = 41
+	FORMAT_R8G8B8A8_SINT    VertexFormat = 42
+
+	FORMAT_R16_UNORM   VertexFormat = 70
+	FORMAT_R16_SNORM   VertexFormat = 71
+	FORMAT_R16_USCALED VertexFormat = 72
+	FORMAT_R16_SSCALED VertexFormat = 73
+	FORMAT_R16_UINT    VertexFormat = 74
+	FORMAT_R16_SINT    VertexFormat = 75
+
+	FORMAT_R16G16_UNORM   VertexFormat = 77
+	FORMAT_R16G16_SNORM   VertexFormat = 78
+	FORMAT_R16G16_USCALED VertexFormat = 79
+	FORMAT_R16G16_SSCALED VertexFormat = 80
+	FORMAT_R16G16_UINT    VertexFormat = 81
+	FORMAT_R16G16_SINT    VertexFormat = 82
+
+	FORMAT_R16G16B16_UNORM   VertexFormat = 84
+	FORMAT_R16G16B16_SNORM   VertexFormat = 85
+	FORMAT_R16G16B16_USCALED VertexFormat = 86
+	FORMAT_R16G16B16_SSCALED VertexFormat = 87
+	FORMAT_R16G16B16_UINT    VertexFormat = 88
+	FORMAT_R16G16B16_SINT    VertexFormat = 89
+
+	FORMAT_R16G16B16A16_UNORM   VertexFormat = 91
+	FORMAT_R16G16B16A16_SNORM   VertexFormat = 92
+	FORMAT_R16G16B16A16_USCALED VertexFormat = 93
+	FORMAT_R16G16B16A16_SSCALED VertexFormat = 94
+	FORMAT_R16G16B16A16_UINT    VertexFormat = 95
+	FORMAT_R16G16B16A16_SINT    VertexFormat = 96
+
+	FORMAT_R32_UINT   VertexFormat = 98
+	FORMAT_R32_SFLOAT VertexFormat = 100
+
+	FORMAT_R32G32_UINT   VertexFormat = 101
+	FORMAT_R32G32_SFLOAT VertexFormat = 103
+
+	FORMAT_R32G32B32_UINT   VertexFormat = 104
+	FORMAT_R32G32B32_SFLOAT VertexFormat = 106
+
+	FORMAT_R32G32B32A32_UINT   VertexFormat = 107
+	FORMAT_R32G32B32A32_SFLOAT VertexFormat = 109
+)
+
+// numericFormat is the interpretation of the components of a VertexFormat
+type numericFormat int
+
+const (
+	numericFloat numericFormat = iota
+	numericNorm
+	numericScaled
+	numericInt
+)
+
+// vertexFormatBase holds the single component format for each component type and numeric format. Formats with more
+// components follow at the offsets given by vertexFormatOffsets; these are not evenly spaced for 8-bit formats, since
+// the BGR formats are defined between the RGB and RGBA formats.
+var vertexFormatBase = map[ComponentTypeEnum]map[numericFormat]VertexFormat{
+	BYTE:           {numericNorm: FORMAT_R8_SNORM, numericScaled: FORMAT_R8_SSCALED, numericInt: FORMAT_R8_SINT},
+	UNSIGNED_BYTE:  {numericNorm: FORMAT_R8_UNORM, numericScaled: FORMAT_R8_USCALED, numericInt: FORMAT_R8_UINT},
+	SHORT:          {numericNorm: FORMAT_R16_SNORM, numericScaled: FORMAT_R16_SSCALED, numericInt: FORMAT_R16_SINT},
+	UNSIGNED_SHORT: {numericNorm: FORMAT_R16_UNORM, numericScaled: FORMAT_R16_USCALED, numericInt: FORMAT_R16_UINT},
+	UNSIGNED_INT:   {numericInt: FORMAT_R32_UINT},
+	FLOAT:          {numericFloat: FORMAT_R32_SFLOAT},
+}
+
+var vertexFormatOffsets = map[ComponentTypeEnum][4]VertexFormat{
+	BYTE:           {0, 7, 14, 28},
+	UNSIGNED_BYTE:  {0, 7, 14, 28},
+	SHORT:          {0, 7, 14, 21},
+	UNSIGNED_SHORT: {0, 7, 14, 21},
+	UNSIGNED_INT:   {0, 3, 6, 9},
+	FLOAT:          {0, 3, 6, 9},
+}
+
+// NewVertexFormat returns the format matching the given component type and count. Normalized components map to UNORM or
+// SNORM formats. Non-normalized integer components map to UINT or SINT formats if integer is set (e.g. for JOINTS_n),
+// and to USCALED or SSCALED formats otherwise. FORMAT_UNDEFINED is returned for combinations that have no equivalent
+// format, such as normalized UNSIGNED_INT or more than four components.
+func NewVertexFormat(ct ComponentTypeEnum, count int, normalized, integer bool) VertexFormat {
+	nf := numericFloat
+	if ct != FLOAT {
+		if normalized {
+			nf = numericNorm
+		} else if integer {
+			nf = numericInt
+		} else {
+			nf = numericScaled
+		}
+	}
+
+	base, found := vertexFormatBase[ct][nf]
+	if !found || count < 1 || count > 4 {
+		return FORMAT_UNDEFINED
+	}
+	return base + vertexFormatOffsets[ct][count-1]
+}
+
+// decompose returns the component type, component count and numeric format of f, or ok == false if f is not one of the
+// formats defined by this package.
+func (f VertexFormat) decompose() (ct ComponentTypeEnum, count int, nf numericFormat, ok bool) {
+	for ct, formats := range vertexFormatBase {
+		for nf, base := range formats {
+			for i, offset := range vertexFormatOffsets[ct] {
+				if f == base+offset {
+					return ct, i + 1, nf, true
+				}
+			}
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// Size returns the number of bytes used by one element of format f, or zero if f is not defined by this package.
+func (f VertexFormat) Size() int {
+	if ct, count, _, ok := f.decompose(); ok {
+		return ct.Size() * count
+	}
+	return 0
+}
+
+// VertexAttributeRequest selects one attribute for an interleaved vertex buffer.
+type VertexAttributeRequest struct {
+	Key AttributeKey
+	// Format is the desired output format. FORMAT_UNDEFINED keeps the attribute's own encoding. Any attribute may be
+	// converted to one of the 32-bit SFLOAT formats, in which case missing components are filled with zero, except for
+	// a fourth component, which is filled with one. No other conversions are supported.
+	Format VertexFormat
+	// Optional attributes that are missing from the primitive are zero filled instead of causing an error. Format must be
+	// set for an optional attribute.
+	Optional bool
+}
+
+// VertexAttribute describes the location of one attribute in an interleaved vertex buffer.
+type VertexAttribute struct {
+	Key    AttributeKey
+	Format VertexFormat
+	Offset int
+}
+
+// InterleavedPrimitive holds the vertex and index data for a primitive, in a layout ready to upload to the GPU.
+type InterleavedPrimitive struct {
+	Vertices    []byte
+	VertexCount int
+	// Stride is the size of one vertex in bytes. Both Stride and every attribute offset are multiples of 4.
+	Stride     int
+	Attributes []VertexAttribute
+
+	// Indices is nil for a non-indexed primitive.
+	Indices    []byte
+	IndexCount int
+	// IndexType is either UNSIGNED_SHORT or UNSIGNED_INT.
+	IndexType ComponentTypeEnum
+}
+
+// Interleave packs the requested attributes of a primitive into a single vertex buffer, in the order given by layout,
+// and converts the primitive's indices, if it has any, to indexType. If indexType is zero then UNSIGNED_SHORT is used
+// when every index fits, and UNSIGNED_INT otherwise.
+func (p *ResolvedPrimitive) Interleave(layout []VertexAttributeRequest, indexType ComponentTypeEnum) (*InterleavedPrimitive, error) {
+	rval := &InterleavedPrimitive{VertexCount: -1}
+
+	sources := make([]*ResolvedAccessor, len(layout))
+	for i, req := range layout {
+		acc := p.Attributes[req.Key]
+		if acc == nil && !req.Optional {
+			return nil, fmt.Errorf("Primitive has no %s attribute", req.Key)
+		} else if acc == nil && req.Format == FORMAT_UNDEFINED {
+			return nil, fmt.Errorf("Optional attribute %s must have a format", req.Key)
+		}
+		sources[i] = acc
+
+		format := req.Format
+		if acc != nil {
+			if rval.VertexCount >= 0 && acc.Count != rval.VertexCount {
+				return nil, fmt.Errorf("Attribute %s has %d elements, expected %d", req.Key, acc.Count, rval.VertexCount)
+			}
+			rval.VertexCount = acc.Count
+
+			own := acc.vertexFormat(req.Key)
+			if own == FORMAT_UNDEFINED {
+				return nil, fmt.Errorf("Attribute %s has no equivalent vertex format", req.Key)
+			}
+			if format == FORMAT_UNDEFINED {
+				format = own
+			} else if format != own {
+				if ct, _, _, ok := format.decompose(); !ok || ct != FLOAT {
+					return nil, fmt.Errorf("Attribute %s can not be converted to format %d", req.Key, format)
+				}
+			}
+		}
+
+		rval.Attributes = append(rval.Attributes, VertexAttribute{Key: req.Key, Format: format, Offset: rval.Stride})
+		rval.Stride += align4(format.Size())
+	}
+	if rval.VertexCount < 0 {
+		return nil, fmt.Errorf("Primitive has none of the requested attributes")
+	}
+
+	rval.Vertices = make([]byte, rval.VertexCount*rval.Stride)
+	for i, attr := range rval.Attributes {
+		if sources[i] == nil {
+			continue
+		}
+		if err := sources[i].interleaveInto(rval.Vertices, attr, rval.Stride); err != nil {
+			return nil, fmt.Errorf("Attribute %s: %w", attr.Key, err)
+		}
+	}
+
+	if p.Indices != nil {
+		indices, err := p.Indices.ReadIndices()
+		if err != nil {
+			return nil, err
+		}
+		if rval.Indices, rval.IndexType, err = encodeIndices(indices, indexType); err != nil {
+			return nil, err
+		}
+		rval.IndexCount = len(indices)
+	}
+
+	return rval, nil
+}
+
+// vertexFormat returns the format matching the accessor's own encoding, when used for the attribute key.
+func (a *ResolvedAccessor) vertexFormat(key AttributeKey) VertexFormat {
+	integer := len(key) >= 7 && key[:7] == "JOINTS_"
+	return NewVertexFormat(a.ComponentType, a.Type.Count(), a.Normalized, integer)
+}
+
+// interleaveInto writes every element of the accessor into vertices, at the offset and in the format given by attr.
+func (a *ResolvedAccessor) interleaveInto(vertices []byte, attr VertexAttribute, stride int) error {
+	if attr.Format == a.vertexFormat(attr.Key) {
+		data, err := a.PackedData()
+		if err != nil {
+			return err
+		}
+		size := a.Stride()
+		for i := 0; i < a.Count; i++ {
+			copy(vertices[i*stride+attr.Offset:], data[i*size:(i+1)*size])
+		}
+		return nil
+	}
+
+	values, err := a.ReadFloats()
+	if err != nil {
+		return err
+	}
+	_, outCount, _, _ := attr.Format.decompose()
+	inCount := a.Type.Count()
+	for i := 0; i < a.Count; i++ {
+		for c := 0; c < outCount; c++ {
+			var v float32
+			if c < inCount {
+				v = values[i*inCount+c]
+			} else if c == 3 {
+				v = 1
+			}
+			binary.LittleEndian.PutUint32(vertices[i*stride+attr.Offset+4*c:], math.Float32bits(v))
+		}
+	}
+	return nil
+}
+
+// encodeIndices encodes indices as little-endian values of indexType, choosing the smallest type that fits when
+// indexType is zero.
+func encodeIndices(indices []uint32, indexType ComponentTypeEnum) ([]byte, ComponentTypeEnum, error) {
+	var maxIndex uint32
+	for _, idx := range indices {
+		if idx > maxIndex {
+			maxIndex = idx
+		}
+	}
+
+	if indexType == 0 {
+		indexType = UNSIGNED_INT
+		if maxIndex < math.MaxUint16 {
+			indexType = UNSIGNED_SHORT
+		}
+	}
+
+	switch indexType {
+	case UNSIGNED_SHORT:
+		if maxIndex >= math.MaxUint16 {
+			return nil, indexType, fmt.Errorf("Index %d does not fit in UNSIGNED_SHORT", maxIndex)
+		}
+		rval := make([]byte, 2*len(indices))
+		for i, idx := range indices {
+			binary.LittleEndian.PutUint16(rval[2*i:], uint16(idx))
+		}
+		return rval, indexType, nil
+	case UNSIGNED_INT:
+		rval := make([]byte, 4*len(indices))
+		for i, idx := range indices {
+			binary.LittleEndian.PutUint32(rval[4*i:], idx)
+		}
+		return rval, indexType, nil
+	}
+	return nil, indexType, fmt.Errorf("Index type must be UNSIGNED_SHORT or UNSIGNED_INT, got %d", indexType)
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
@@ -0,0 +1,80 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestInterleave(t *testing.T) {
+	data := float32Bytes(0, 0, 0, 1, 0, 0, 0, 1, 0)
+	data = append(data, 255, 0, 0, 0, 255, 0, 0, 0, 255, 0)
+	data = append(data, 0, 0, 2, 0, 1, 0)
+
+	resolved := resolveTestDoc(t, `{
+		"asset": {"version": "2.0"},
+		"meshes": [{"primitives": [{"attributes": {"POSITION": 0, "COLOR_0": 1}, "indices": 2}]}],
+		"accessors": [
+			{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+			{"bufferView": 0, "byteOffset": 36, "componentType": 5121, "normalized": true, "count": 3, "type": "VEC3"},
+			{"bufferView": 0, "byteOffset": 46, "componentType": 5123, "count": 3, "type": "SCALAR"}
+		],
+		"bufferViews": [{"buffer": 0, "byteLength": 52}],
+		"buffers": [{"uri": "tri.bin", "byteLength": 52}]
+	}`, map[string][]byte{"tri.bin": data})
+
+	prim := &resolved.Meshes[0].Primitives[0]
+	ip, err := prim.Interleave([]VertexAttributeRequest{
+		{Key: POSITION},
+		{Key: COLOR_0},
+		{Key: TEXCOORD_0, Format: FORMAT_R32G32_SFLOAT, Optional: true},
+		{Key: COLOR_0, Format: FORMAT_R32G32B32A32_SFLOAT},
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ip.Attributes[1].Format != FORMAT_R8G8B8_UNORM || ip.Attributes[1].Offset != 12 || ip.Attributes[2].Offset != 16 ||
+		ip.Attributes[3].Offset != 24 || ip.Stride != 40 {
+		t.Fatalf("unexpected layout: stride %d, %+v", ip.Stride, ip.Attributes)
+	}
+
+	v1 := ip.Vertices[ip.Stride : 2*ip.Stride]
+	if math.Float32frombits(binary.LittleEndian.Uint32(v1[0:])) != 1 || v1[13] != 255 {
+		t.Errorf("vertex 1 position or color is wrong: %v", v1)
+	}
+	if g, a := math.Float32frombits(binary.LittleEndian.Uint32(v1[28:])), math.Float32frombits(binary.LittleEndian.Uint32(v1[36:])); g != 1 || a != 1 {
+		t.Errorf("converted color for vertex 1 is wrong: g=%f a=%f", g, a)
+	}
+
+	if ip.IndexType != UNSIGNED_SHORT || ip.IndexCount != 3 || binary.LittleEndian.Uint16(ip.Indices[2:]) != 2 {
+		t.Errorf("unexpected index data: %v", ip.Indices)
+	}
+}
+
+func TestNewVertexFormat(t *testing.T) {
+	tests := []struct {
+		ct         ComponentTypeEnum
+		count      int
+		normalized bool
+		integer    bool
+		expected   VertexFormat
+	}{
+		{FLOAT, 3, false, false, FORMAT_R32G32B32_SFLOAT},
+		{UNSIGNED_BYTE, 4, true, false, FORMAT_R8G8B8A8_UNORM},
+		{UNSIGNED_BYTE, 4, false, true, FORMAT_R8G8B8A8_UINT},
+		{SHORT, 3, false, false, FORMAT_R16G16B16_SSCALED},
+		{UNSIGNED_SHORT, 2, true, false, FORMAT_R16G16_UNORM},
+		{BYTE, 4, true, false, FORMAT_R8G8B8A8_SNORM},
+		{UNSIGNED_INT, 1, true, false, FORMAT_UNDEFINED},
+	}
+
+	for _, tc := range tests {
+		if f := NewVertexFormat(tc.ct, tc.count, tc.normalized, tc.integer); f != tc.expected {
+			t.Errorf("NewVertexFormat(%d, %d, %t, %t) = %d, expected %d", tc.ct, tc.count, tc.normalized, tc.integer, f, tc.expected)
+		}
+		if tc.expected != FORMAT_UNDEFINED && tc.expected.Size() != tc.ct.Size()*tc.count {
+			t.Errorf("Size of format %d is %d", tc.expected, tc.expected.Size())
+		}
+	}
+}
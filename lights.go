@@ -0,0 +1,155 @@
+package gltf
+
+import (
+	"fmt"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+const KHR_LIGHTS_PUNCTUAL = "KHR_lights_punctual"
+
+// Light: see https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Khronos/KHR_lights_punctual
+type Light struct {
+	Color     *vkm.Vec3  `json:"color,omitempty"`
+	Intensity *float32   `json:"intensity,omitempty"`
+	Type      LightType  `json:"type"`
+	Range     *float32   `json:"range,omitempty"`
+	Spot      *LightSpot `json:"spot,omitempty"`
+
+	Name       GlTFId `json:"name,omitempty"`
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type LightSpot struct {
+	InnerConeAngle *float32 `json:"innerConeAngle,omitempty"`
+	OuterConeAngle *float32 `json:"outerConeAngle,omitempty"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type LightType string
+
+const (
+	DIRECTIONAL LightType = "directional"
+	POINT       LightType = "point"
+	SPOT        LightType = "spot"
+)
+
+// KHRLightsPunctual is the KHR_lights_punctual extension on the document root.
+type KHRLightsPunctual struct {
+	Lights []Light `json:"lights"`
+}
+
+// KHRLightsPunctualNode is the KHR_lights_punctual extension on a node.
+type KHRLightsPunctualNode struct {
+	Light uint `json:"light"`
+}
+
+func init() {
+	RegisterExtension(KHR_LIGHTS_PUNCTUAL, ExtensionCodec[KHRLightsPunctual]{}, (*GlTF)(nil))
+	RegisterExtension(KHR_LIGHTS_PUNCTUAL, ExtensionCodec[KHRLightsPunctualNode]{}, (*Node)(nil))
+}
+
+// Lights returns the lights defined by the document's KHR_lights_punctual extension, or nil if it does not have one.
+func (gltf *GlTF) Lights() ([]Light, error) {
+	lp, _, err := GetExtension[KHRLightsPunctual](gltf)
+	if lp == nil {
+		return nil, err
+	}
+	return lp.Lights, err
+}
+
+// SetLights replaces the lights in the document's KHR_lights_punctual extension, and adds the extension to
+// ExtensionsUsed. Nodes refer to the lights by index, so existing references are not updated.
+func (gltf *GlTF) SetLights(lights []Light) {
+	SetExtension(gltf, &KHRLightsPunctual{Lights: lights})
+	gltf.ExtensionsUsed = appendUnique(gltf.ExtensionsUsed, KHR_LIGHTS_PUNCTUAL)
+}
+
+// LightIndex returns the index of the light that the node's KHR_lights_punctual extension refers to, and false if it
+// does not have one or it can not be decoded.
+func (n *Node) LightIndex() (uint, bool) {
+	if ext, _, err := GetExtension[KHRLightsPunctualNode](n); ext != nil && err == nil {
+		return ext.Light, true
+	}
+	return 0, false
+}
+
+// SetLightIndex places the light at index i of the document's lights on the node.
+func (n *Node) SetLightIndex(i uint) {
+	SetExtension(n, &KHRLightsPunctualNode{Light: i})
+}
+
+// ResolvedLight masks the optional fields of a light with their values, using the spec defaults for any that are not
+// set. Range is not masked, as a light without one has no range limit.
+type ResolvedLight struct {
+	*Light
+	Color     vkm.Vec3
+	Intensity float32
+	// InnerConeAngle and OuterConeAngle are only meaningful for spot lights.
+	InnerConeAngle, OuterConeAngle float32
+}
+
+func (l *Light) resolve(root *ResolvedGlTF) (ResolvedLight, error) {
+	rval := ResolvedLight{
+		Light:          l,
+		Color:          vkm.Vec3{1, 1, 1},
+		Intensity:      1,
+		OuterConeAngle: math32.Pi / 4,
+	}
+
+	switch l.Type {
+	case DIRECTIONAL, POINT, SPOT:
+	case "":
+		return rval, fmt.Errorf("Light %q type not set", l.Name)
+	default:
+		return rval, fmt.Errorf("Light %q type not recognized: %s", l.Name, l.Type)
+	}
+
+	if l.Color != nil {
+		rval.Color = *l.Color
+	}
+	if l.Intensity != nil {
+		rval.Intensity = *l.Intensity
+	}
+	if l.Spot != nil {
+		if l.Spot.InnerConeAngle != nil {
+			rval.InnerConeAngle = *l.Spot.InnerConeAngle
+		}
+		if l.Spot.OuterConeAngle != nil {
+			rval.OuterConeAngle = *l.Spot.OuterConeAngle
+		}
+	}
+	return rval, nil
+}
+
+// LightInstance is a light placed in a scene by a node.
+type LightInstance struct {
+	Node  *ResolvedNode
+	Light *ResolvedLight
+	// Position is the light's position in world space, which is not meaningful for a directional light. Direction is
+	// the unit world space direction in which the light points, along the node's -Z axis, which is not meaningful for a
+	// point light.
+	Position, Direction vkm.Vec3
+}
+
+// LightInstances returns every node in the scene that has a light, in the given pose (nil for the rest pose), in the
+// order that the scene is traversed: depth first, in document order.
+func (s *ResolvedScene) LightInstances(pose Pose) []LightInstance {
+	var rval []LightInstance
+	s.walk(pose, func(n *ResolvedNode, world vkm.Mat) {
+		if n.Light == nil {
+			return
+		}
+		rval = append(rval, LightInstance{
+			Node:      n,
+			Light:     n.Light,
+			Position:  transformPoint(world, vkm.Vec3{}),
+			Direction: normalizeOr(transformDirection(world, vkm.Vec3{0, 0, -1}), vkm.Vec3{0, 0, -1}),
+		})
+	})
+	return rval
+}
@@ -0,0 +1,95 @@
+package gltf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+const lightsDocument = `{
+	"asset": {"version": "2.0"},
+	"extensionsUsed": ["KHR_lights_punctual"],
+	"extensions": {"KHR_lights_punctual": {"lights": [
+		{"name": "sun", "type": "directional", "color": [1, 0.9, 0.8], "intensity": 3},
+		{"name": "lamp", "type": "spot", "spot": {"innerConeAngle": 0.2}}
+	]}},
+	"scenes": [{"nodes": [0]}],
+	"scene": 0,
+	"nodes": [
+		{"name": "rig", "translation": [0, 10, 0], "children": [1, 2]},
+		{"name": "sun", "rotation": [-0.7071068, 0, 0, 0.7071068], "extensions": {"KHR_lights_punctual": {"light": 0}}},
+		{"name": "lamp", "translation": [1, 0, 0], "extensions": {"KHR_lights_punctual": {"light": 1}}}
+	]
+}`
+
+func TestLights(t *testing.T) {
+	doc, err := FromBytes([]byte(lightsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := doc.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resolved.Lights) != 2 || resolved.Nodes[0].Light != nil || resolved.Nodes[1].Light != &resolved.Lights[0] {
+		t.Fatalf("lights were not resolved onto their nodes")
+	}
+	sun, lamp := &resolved.Lights[0], &resolved.Lights[1]
+	if sun.Type != DIRECTIONAL || sun.Intensity != 3 || sun.Color != (vkm.Vec3{1, 0.9, 0.8}) {
+		t.Errorf("unexpected sun %+v", sun)
+	}
+	if lamp.Type != SPOT || lamp.Intensity != 1 || lamp.Color != (vkm.Vec3{1, 1, 1}) || lamp.Range != nil ||
+		lamp.InnerConeAngle != 0.2 || math.Abs(float64(lamp.OuterConeAngle)-math.Pi/4) > 1e-6 {
+		t.Errorf("expected spec defaults for the lamp, got %+v", lamp)
+	}
+
+	instances := resolved.Scene.LightInstances(nil)
+	if len(instances) != 2 || instances[0].Light != sun || instances[1].Light != lamp {
+		t.Fatalf("expected both lights in traversal order, got %v", instances)
+	}
+	if !approxVec3(instances[0].Direction, vkm.Vec3{0, -1, 0}) {
+		t.Errorf("expected the sun to point down, got %v", instances[0].Direction)
+	}
+	if !approxVec3(instances[1].Position, vkm.Vec3{1, 10, 0}) || !approxVec3(instances[1].Direction, vkm.Vec3{0, 0, -1}) {
+		t.Errorf("unexpected lamp placement %v, %v", instances[1].Position, instances[1].Direction)
+	}
+
+	doc.Nodes[2].SetLightIndex(2)
+	if _, err := doc.Resolve(nil); err == nil {
+		t.Error("expected an error for a node referring to a missing light")
+	}
+}
+
+func TestMergeLights(t *testing.T) {
+	a, err := FromBytes([]byte(lightsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := a.clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.SetLights([]Light{{Name: "only", Type: POINT}})
+	b.Nodes[1].Extensions = nil
+	b.Nodes[2].SetLightIndex(0)
+
+	merged, err := Merge([]*GlTF{a, b}, MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lights, err := merged.Lights()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lights) != 3 || lights[2].Name != "only" {
+		t.Fatalf("expected the lights of both sources, got %+v", lights)
+	}
+	if l, found := merged.Nodes[5].LightIndex(); !found || l != 2 {
+		t.Errorf("expected the second source's node light to be remapped to 2, got %d", l)
+	}
+	if l, found := merged.Nodes[2].LightIndex(); !found || l != 1 {
+		t.Errorf("expected the first source's node light to be unchanged, got %d", l)
+	}
+}
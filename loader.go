@@ -13,6 +13,12 @@ func FromBytes(data []byte) (*GlTF, error) {
 	return &root, err
 }
 
+// ToBytes encodes the document as glTF JSON. Registered extensions are written with their codecs, and all other
+// extensions as they were loaded.
+func (gltf *GlTF) ToBytes() ([]byte, error) {
+	return json.Marshal(gltf)
+}
+
 func FromFile(f *os.File) (*GlTF, error) {
 	stat, err := f.Stat()
 	if err != nil {
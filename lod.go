@@ -0,0 +1,259 @@
+package gltf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// AddMesh appends a new mesh holding prims to the document, and returns its index. The primitives' attributes and
+// indices are copied into a single new buffer, with a buffer view and accessor for each. Each primitive keeps its
+// material, which must be one of this document's materials, and the extensions and extras of its source primitive,
+// except for KHR_draco_mesh_compression. Vertex attributes whose elements are not a multiple of 4 bytes long are padded
+// to one, with a byteStride, so that every element is aligned as the spec requires.
+//
+// The new buffer has no URI. Its data is held in the resolved buffer, so set a URI (or embed the data) before saving
+// the document.
+func (root *ResolvedGlTF) AddMesh(name GlTFId, prims []*ResolvedPrimitive) (uint, error) {
+	idx, err := root.addMesh(name, prims)
+	if err != nil {
+		return 0, err
+	}
+	return idx, root.resolveReferences()
+}
+
+// addMesh is AddMesh without re-resolving the document.
+func (root *ResolvedGlTF) addMesh(name GlTFId, prims []*ResolvedPrimitive) (uint, error) {
+	gltf := root.GlTF
+	bufIdx := uint(len(gltf.Buffers))
+	var data []byte
+
+	addAccessor := func(a *ResolvedAccessor, key AttributeKey, target BufferTargetEnum) (uint, error) {
+		packed, err := a.PackedData()
+		if err != nil {
+			return 0, err
+		}
+		view := BufferView{
+			Buffer:     bufIdx,
+			ByteOffset: uint(len(data)),
+			Target:     target,
+		}
+		// Vertex attribute elements must each start on a 4-byte boundary, which small quantized types such as a VEC3 of
+		// shorts do not when tightly packed, so their elements are padded and given a byteStride.
+		if elemSize := a.Stride(); target == ARRAY_BUFFER && elemSize%4 != 0 {
+			stride := align4(elemSize)
+			padded := make([]byte, a.Count*stride)
+			for e := 0; e < a.Count; e++ {
+				copy(padded[e*stride:], packed[e*elemSize:(e+1)*elemSize])
+			}
+			packed, view.ByteStride = padded, uint(stride)
+		}
+		view.ByteLength = uint(len(packed))
+		gltf.BufferViews = append(gltf.BufferViews, view)
+		data = append(data, packed...)
+		data = append(data, make([]byte, align4(len(data))-len(data))...)
+
+		acc := *a.Accessor
+		viewIdx := uint(len(gltf.BufferViews) - 1)
+		acc.BufferView, acc.ByteOffset, acc.Sparse = &viewIdx, 0, nil
+		if key == POSITION && len(acc.Min) == 0 {
+			// The spec requires bounds on POSITION accessors.
+			values, err := a.ReadFloats()
+			if err != nil {
+				return 0, err
+			}
+			bounded := NewFloatAccessor(a.Type, values)
+			acc.Min, acc.Max = bounded.Min, bounded.Max
+		}
+		gltf.Accessors = append(gltf.Accessors, acc)
+		return uint(len(gltf.Accessors) - 1), nil
+	}
+
+	mesh := Mesh{Name: name}
+	for i, p := range prims {
+		prim, err := root.newPrimitive(p)
+		if err != nil {
+			return 0, fmt.Errorf("Primitive %d: %w", i, err)
+		}
+
+		addAttributes := func(attrs map[AttributeKey]*ResolvedAccessor) (map[AttributeKey]int, error) {
+			keys := make([]AttributeKey, 0, len(attrs))
+			for k := range attrs {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+			rval := make(map[AttributeKey]int, len(attrs))
+			for _, k := range keys {
+				a, err := addAccessor(attrs[k], k, ARRAY_BUFFER)
+				if err != nil {
+					return nil, fmt.Errorf("Primitive %d, attribute %s: %w", i, k, err)
+				}
+				rval[k] = int(a)
+			}
+			return rval, nil
+		}
+
+		if prim.Attributes, err = addAttributes(p.Attributes); err != nil {
+			return 0, err
+		}
+		for _, target := range p.Targets {
+			t, err := addAttributes(target)
+			if err != nil {
+				return 0, err
+			}
+			prim.Targets = append(prim.Targets, t)
+		}
+		if p.Indices != nil {
+			a, err := addAccessor(p.Indices, "", ELEMENT_ARRAY_BUFFER)
+			if err != nil {
+				return 0, fmt.Errorf("Primitive %d, indices: %w", i, err)
+			}
+			prim.Indices = &a
+		}
+		mesh.Primitives = append(mesh.Primitives, prim)
+	}
+
+	gltf.Buffers = append(gltf.Buffers, Buffer{ByteLength: uint(len(data))})
+	root.Buffers = append(root.Buffers, ResolvedBuffer{Data: data})
+	for i := range root.Buffers {
+		// Appending may have moved the source buffers.
+		root.Buffers[i].Buffer = &gltf.Buffers[i]
+	}
+
+	gltf.Meshes = append(gltf.Meshes, mesh)
+	return uint(len(gltf.Meshes) - 1), nil
+}
+
+// newPrimitive returns a primitive with the mode and material of p, and the extensions and extras of its source
+// primitive, but no attributes, indices or targets. The extensions are copied, so that remapping the references in one
+// primitive does not change the other, and KHR_draco_mesh_compression is dropped, since the new primitive's accessors
+// hold the decoded data.
+func (root *ResolvedGlTF) newPrimitive(p *ResolvedPrimitive) (Primitive, error) {
+	var rval Primitive
+	if p.Mode != TRIANGLES {
+		mode := p.Mode
+		rval.Mode = &mode
+	}
+
+	m := p.Material
+	if p.VariantMaterials != nil {
+		// Material may be a selected variant; the variants stay in the copied KHR_materials_variants extension.
+		m = p.DefaultMaterial
+	}
+	if m != nil {
+		idx := -1
+		for j := range root.Materials {
+			if &root.Materials[j] == m {
+				idx = j
+			}
+		}
+		if idx < 0 {
+			return rval, errors.New("Material is not one of the document's materials")
+		}
+		rval.Material = &idx
+	}
+
+	if p.Primitive != nil {
+		b, err := json.Marshal(struct {
+			Extensions `json:"extensions,omitempty"`
+			Extras     `json:"extras,omitempty"`
+		}{p.Extensions, p.Extras})
+		if err != nil {
+			return rval, err
+		}
+		if err := json.Unmarshal(b, &rval); err != nil {
+			return rval, err
+		}
+		delete(rval.Extensions, KHR_DRACO_MESH_COMPRESSION)
+		if len(rval.Extensions) == 0 {
+			rval.Extensions = nil
+		}
+	}
+	return rval, nil
+}
+
+// AddLODs adds levels of detail to a node with the MSFT_lod extension. Each level is a set of primitives, such as
+// those from GenerateLODs, that replaces the primitives of the node's mesh; it is added as a new mesh on a new node
+// with the same transform, which is not part of any scene. Levels should be ordered from most to least detailed.
+//
+// coverage optionally sets the MSFT_screencoverage extra: the screen coverage below which each level, starting with
+// the node itself, stops being used. If given, it should have one more value than there are levels.
+func (root *ResolvedGlTF) AddLODs(node uint, levels [][]*ResolvedPrimitive, coverage []float32) error {
+	gltf := root.GlTF
+	if node >= uint(len(gltf.Nodes)) {
+		return fmt.Errorf("Node %d does not exist", node)
+	}
+
+	var meshName GlTFId
+	if src := gltf.Nodes[node].Mesh; src != nil && *src < uint(len(gltf.Meshes)) {
+		meshName = gltf.Meshes[*src].Name
+	}
+
+	ids := make([]uint, len(levels))
+	for i, prims := range levels {
+		var name GlTFId
+		if meshName != "" {
+			name = GlTFId(fmt.Sprintf("%s.LOD%d", meshName, i+1))
+		}
+		mesh, err := root.addMesh(name, prims)
+		if err != nil {
+			return fmt.Errorf("Could not add level %d: %w", i+1, err)
+		}
+
+		src := &gltf.Nodes[node]
+		lod := Node{
+			Mesh:        &mesh,
+			Skin:        src.Skin,
+			Matrix:      src.Matrix,
+			Rotation:    src.Rotation,
+			Scale:       src.Scale,
+			Translation: src.Translation,
+			Weights:     src.Weights,
+		}
+		if src.Name != "" {
+			lod.Name = GlTFId(fmt.Sprintf("%s.LOD%d", src.Name, i+1))
+		}
+		gltf.Nodes = append(gltf.Nodes, lod)
+		ids[i] = uint(len(gltf.Nodes) - 1)
+	}
+
+	src := &gltf.Nodes[node]
+	SetExtension(src, &MSFTLod{Ids: ids})
+	if coverage != nil {
+		if src.Extras == nil {
+			src.Extras = Extras{}
+		}
+		src.Extras["MSFT_screencoverage"] = coverage
+	}
+	gltf.ExtensionsUsed = appendUnique(gltf.ExtensionsUsed, MSFT_LOD)
+
+	return root.resolveReferences()
+}
+
+const MSFT_LOD = "MSFT_lod"
+
+// MSFTLod is the MSFT_lod extension on a node, listing the nodes that hold its lower levels of detail.
+type MSFTLod struct {
+	Ids []uint `json:"ids"`
+}
+
+func init() {
+	RegisterExtension(MSFT_LOD, ExtensionCodec[MSFTLod]{}, (*Node)(nil))
+}
+
+// lodNodes returns the node indices listed in the node's MSFT_lod extension, or nil if it does not have one.
+func (n *Node) lodNodes() ([]uint, error) {
+	ext, _, err := GetExtension[MSFTLod](n)
+	if ext == nil {
+		return nil, err
+	}
+	return ext.Ids, err
+}
+
+// setLODNodes replaces the node indices in the node's MSFT_lod extension, which must already exist.
+func (n *Node) setLODNodes(ids []uint) {
+	ext, _, _ := GetExtension[MSFTLod](n)
+	ext.Ids = ids
+}
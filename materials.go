@@ -0,0 +1,435 @@
+package gltf
+
+import (
+	"fmt"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// Material extensions that extend the metallic-roughness model. See
+// https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Khronos for each of them.
+const (
+	KHR_MATERIALS_ANISOTROPY        = "KHR_materials_anisotropy"
+	KHR_MATERIALS_CLEARCOAT         = "KHR_materials_clearcoat"
+	KHR_MATERIALS_DISPERSION        = "KHR_materials_dispersion"
+	KHR_MATERIALS_EMISSIVE_STRENGTH = "KHR_materials_emissive_strength"
+	KHR_MATERIALS_IOR               = "KHR_materials_ior"
+	KHR_MATERIALS_IRIDESCENCE       = "KHR_materials_iridescence"
+	KHR_MATERIALS_SHEEN             = "KHR_materials_sheen"
+	KHR_MATERIALS_SPECULAR          = "KHR_materials_specular"
+	KHR_MATERIALS_TRANSMISSION      = "KHR_materials_transmission"
+	KHR_MATERIALS_UNLIT             = "KHR_materials_unlit"
+	KHR_MATERIALS_VOLUME            = "KHR_materials_volume"
+)
+
+// KHRMaterialsClearcoat is the KHR_materials_clearcoat extension on a material.
+type KHRMaterialsClearcoat struct {
+	ClearcoatFactor           *float32           `json:"clearcoatFactor,omitempty"`
+	ClearcoatTexture          *TextureInfo       `json:"clearcoatTexture,omitempty"`
+	ClearcoatRoughnessFactor  *float32           `json:"clearcoatRoughnessFactor,omitempty"`
+	ClearcoatRoughnessTexture *TextureInfo       `json:"clearcoatRoughnessTexture,omitempty"`
+	ClearcoatNormalTexture    *NormalTextureInfo `json:"clearcoatNormalTexture,omitempty"`
+}
+
+// KHRMaterialsTransmission is the KHR_materials_transmission extension on a material.
+type KHRMaterialsTransmission struct {
+	TransmissionFactor  *float32     `json:"transmissionFactor,omitempty"`
+	TransmissionTexture *TextureInfo `json:"transmissionTexture,omitempty"`
+}
+
+// KHRMaterialsVolume is the KHR_materials_volume extension on a material.
+type KHRMaterialsVolume struct {
+	ThicknessFactor     *float32     `json:"thicknessFactor,omitempty"`
+	ThicknessTexture    *TextureInfo `json:"thicknessTexture,omitempty"`
+	AttenuationDistance *float32     `json:"attenuationDistance,omitempty"`
+	AttenuationColor    *vkm.Vec3    `json:"attenuationColor,omitempty"`
+}
+
+// KHRMaterialsSheen is the KHR_materials_sheen extension on a material.
+type KHRMaterialsSheen struct {
+	SheenColorFactor      *vkm.Vec3    `json:"sheenColorFactor,omitempty"`
+	SheenColorTexture     *TextureInfo `json:"sheenColorTexture,omitempty"`
+	SheenRoughnessFactor  *float32     `json:"sheenRoughnessFactor,omitempty"`
+	SheenRoughnessTexture *TextureInfo `json:"sheenRoughnessTexture,omitempty"`
+}
+
+// KHRMaterialsSpecular is the KHR_materials_specular extension on a material.
+type KHRMaterialsSpecular struct {
+	SpecularFactor       *float32     `json:"specularFactor,omitempty"`
+	SpecularTexture      *TextureInfo `json:"specularTexture,omitempty"`
+	SpecularColorFactor  *vkm.Vec3    `json:"specularColorFactor,omitempty"`
+	SpecularColorTexture *TextureInfo `json:"specularColorTexture,omitempty"`
+}
+
+// KHRMaterialsIOR is the KHR_materials_ior extension on a material.
+type KHRMaterialsIOR struct {
+	IOR *float32 `json:"ior,omitempty"`
+}
+
+// KHRMaterialsEmissiveStrength is the KHR_materials_emissive_strength extension on a material.
+type KHRMaterialsEmissiveStrength struct {
+	EmissiveStrength *float32 `json:"emissiveStrength,omitempty"`
+}
+
+// KHRMaterialsUnlit is the KHR_materials_unlit extension on a material, which has no properties.
+type KHRMaterialsUnlit struct{}
+
+// KHRMaterialsIridescence is the KHR_materials_iridescence extension on a material.
+type KHRMaterialsIridescence struct {
+	IridescenceFactor           *float32     `json:"iridescenceFactor,omitempty"`
+	IridescenceTexture          *TextureInfo `json:"iridescenceTexture,omitempty"`
+	IridescenceIOR              *float32     `json:"iridescenceIor,omitempty"`
+	IridescenceThicknessMinimum *float32     `json:"iridescenceThicknessMinimum,omitempty"`
+	IridescenceThicknessMaximum *float32     `json:"iridescenceThicknessMaximum,omitempty"`
+	IridescenceThicknessTexture *TextureInfo `json:"iridescenceThicknessTexture,omitempty"`
+}
+
+// KHRMaterialsAnisotropy is the KHR_materials_anisotropy extension on a material.
+type KHRMaterialsAnisotropy struct {
+	AnisotropyStrength *float32     `json:"anisotropyStrength,omitempty"`
+	AnisotropyRotation *float32     `json:"anisotropyRotation,omitempty"`
+	AnisotropyTexture  *TextureInfo `json:"anisotropyTexture,omitempty"`
+}
+
+// KHRMaterialsDispersion is the KHR_materials_dispersion extension on a material.
+type KHRMaterialsDispersion struct {
+	Dispersion *float32 `json:"dispersion,omitempty"`
+}
+
+func init() {
+	RegisterExtension(KHR_MATERIALS_ANISOTROPY, ExtensionCodec[KHRMaterialsAnisotropy]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_CLEARCOAT, ExtensionCodec[KHRMaterialsClearcoat]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_DISPERSION, ExtensionCodec[KHRMaterialsDispersion]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_EMISSIVE_STRENGTH, ExtensionCodec[KHRMaterialsEmissiveStrength]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_IOR, ExtensionCodec[KHRMaterialsIOR]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_IRIDESCENCE, ExtensionCodec[KHRMaterialsIridescence]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_SHEEN, ExtensionCodec[KHRMaterialsSheen]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_SPECULAR, ExtensionCodec[KHRMaterialsSpecular]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_TRANSMISSION, ExtensionCodec[KHRMaterialsTransmission]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_UNLIT, ExtensionCodec[KHRMaterialsUnlit]{}, (*Material)(nil))
+	RegisterExtension(KHR_MATERIALS_VOLUME, ExtensionCodec[KHRMaterialsVolume]{}, (*Material)(nil))
+}
+
+// ResolvedClearcoat masks the factors of KHR_materials_clearcoat with their values, using the spec defaults for any
+// that are not set, and its texture references with ones that point to the resolved textures.
+type ResolvedClearcoat struct {
+	*KHRMaterialsClearcoat
+	ClearcoatFactor           float32
+	ClearcoatTexture          *ResolvedTextureInfo
+	ClearcoatRoughnessFactor  float32
+	ClearcoatRoughnessTexture *ResolvedTextureInfo
+	ClearcoatNormalTexture    *ResolvedNormalTextureInfo
+}
+
+// ResolvedTransmission is KHR_materials_transmission with the spec defaults and resolved textures.
+type ResolvedTransmission struct {
+	*KHRMaterialsTransmission
+	TransmissionFactor  float32
+	TransmissionTexture *ResolvedTextureInfo
+}
+
+// ResolvedVolume is KHR_materials_volume with the spec defaults and resolved textures. AttenuationDistance is positive
+// infinity when not set, meaning that light is not attenuated.
+type ResolvedVolume struct {
+	*KHRMaterialsVolume
+	ThicknessFactor     float32
+	ThicknessTexture    *ResolvedTextureInfo
+	AttenuationDistance float32
+	AttenuationColor    vkm.Vec3
+}
+
+// ResolvedSheen is KHR_materials_sheen with the spec defaults and resolved textures.
+type ResolvedSheen struct {
+	*KHRMaterialsSheen
+	SheenColorFactor      vkm.Vec3
+	SheenColorTexture     *ResolvedTextureInfo
+	SheenRoughnessFactor  float32
+	SheenRoughnessTexture *ResolvedTextureInfo
+}
+
+// ResolvedSpecular is KHR_materials_specular with the spec defaults and resolved textures.
+type ResolvedSpecular struct {
+	*KHRMaterialsSpecular
+	SpecularFactor       float32
+	SpecularTexture      *ResolvedTextureInfo
+	SpecularColorFactor  vkm.Vec3
+	SpecularColorTexture *ResolvedTextureInfo
+}
+
+// ResolvedIridescence is KHR_materials_iridescence with the spec defaults and resolved textures. The thickness range
+// is in nanometers.
+type ResolvedIridescence struct {
+	*KHRMaterialsIridescence
+	IridescenceFactor           float32
+	IridescenceTexture          *ResolvedTextureInfo
+	IridescenceIOR              float32
+	IridescenceThicknessMinimum float32
+	IridescenceThicknessMaximum float32
+	IridescenceThicknessTexture *ResolvedTextureInfo
+}
+
+// ResolvedAnisotropy is KHR_materials_anisotropy with the spec defaults and resolved textures. AnisotropyRotation is
+// in radians, counter-clockwise from the tangent.
+type ResolvedAnisotropy struct {
+	*KHRMaterialsAnisotropy
+	AnisotropyStrength float32
+	AnisotropyRotation float32
+	AnisotropyTexture  *ResolvedTextureInfo
+}
+
+// resolveExtensions sets the material extensions of rval from those on m.
+func (m *Material) resolveExtensions(root *ResolvedGlTF, rval *ResolvedMaterial) error {
+	rval.IOR, rval.EmissiveStrength = 1.5, 1
+
+	if ext, _, err := GetExtension[KHRMaterialsClearcoat](m); err != nil {
+		return err
+	} else if ext != nil {
+		rc := &ResolvedClearcoat{KHRMaterialsClearcoat: ext}
+		rc.ClearcoatFactor = floatOr(ext.ClearcoatFactor, 0)
+		rc.ClearcoatRoughnessFactor = floatOr(ext.ClearcoatRoughnessFactor, 0)
+		if rc.ClearcoatTexture, err = ext.ClearcoatTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_CLEARCOAT, err)
+		}
+		if rc.ClearcoatRoughnessTexture, err = ext.ClearcoatRoughnessTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_CLEARCOAT, err)
+		}
+		if rc.ClearcoatNormalTexture, err = ext.ClearcoatNormalTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_CLEARCOAT, err)
+		}
+		rval.Clearcoat = rc
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsTransmission](m); err != nil {
+		return err
+	} else if ext != nil {
+		rt := &ResolvedTransmission{KHRMaterialsTransmission: ext}
+		rt.TransmissionFactor = floatOr(ext.TransmissionFactor, 0)
+		if rt.TransmissionTexture, err = ext.TransmissionTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_TRANSMISSION, err)
+		}
+		rval.Transmission = rt
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsVolume](m); err != nil {
+		return err
+	} else if ext != nil {
+		rv := &ResolvedVolume{KHRMaterialsVolume: ext}
+		rv.ThicknessFactor = floatOr(ext.ThicknessFactor, 0)
+		rv.AttenuationDistance = floatOr(ext.AttenuationDistance, math32.Inf(1))
+		if rv.AttenuationDistance <= 0 {
+			return fmt.Errorf("%s: attenuationDistance %v is not positive", KHR_MATERIALS_VOLUME, rv.AttenuationDistance)
+		}
+		rv.AttenuationColor = vec3Or(ext.AttenuationColor, vkm.Vec3{1, 1, 1})
+		if rv.ThicknessTexture, err = ext.ThicknessTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_VOLUME, err)
+		}
+		rval.Volume = rv
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsSheen](m); err != nil {
+		return err
+	} else if ext != nil {
+		rs := &ResolvedSheen{KHRMaterialsSheen: ext}
+		rs.SheenColorFactor = vec3Or(ext.SheenColorFactor, vkm.Vec3{})
+		rs.SheenRoughnessFactor = floatOr(ext.SheenRoughnessFactor, 0)
+		if rs.SheenColorTexture, err = ext.SheenColorTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_SHEEN, err)
+		}
+		if rs.SheenRoughnessTexture, err = ext.SheenRoughnessTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_SHEEN, err)
+		}
+		rval.Sheen = rs
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsSpecular](m); err != nil {
+		return err
+	} else if ext != nil {
+		rs := &ResolvedSpecular{KHRMaterialsSpecular: ext}
+		rs.SpecularFactor = floatOr(ext.SpecularFactor, 1)
+		rs.SpecularColorFactor = vec3Or(ext.SpecularColorFactor, vkm.Vec3{1, 1, 1})
+		if rs.SpecularTexture, err = ext.SpecularTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_SPECULAR, err)
+		}
+		if rs.SpecularColorTexture, err = ext.SpecularColorTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_SPECULAR, err)
+		}
+		rval.Specular = rs
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsIOR](m); err != nil {
+		return err
+	} else if ext != nil {
+		rval.IOR = floatOr(ext.IOR, 1.5)
+		// Zero is allowed as a special case for a perfectly reflective material.
+		if rval.IOR != 0 && rval.IOR < 1 {
+			return fmt.Errorf("%s: ior %v is less than 1", KHR_MATERIALS_IOR, rval.IOR)
+		}
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsUnlit](m); err != nil {
+		return err
+	} else {
+		rval.Unlit = ext != nil
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsIridescence](m); err != nil {
+		return err
+	} else if ext != nil {
+		ri := &ResolvedIridescence{KHRMaterialsIridescence: ext}
+		ri.IridescenceFactor = floatOr(ext.IridescenceFactor, 0)
+		ri.IridescenceIOR = floatOr(ext.IridescenceIOR, 1.3)
+		if ri.IridescenceIOR < 1 {
+			return fmt.Errorf("%s: iridescenceIor %v is less than 1", KHR_MATERIALS_IRIDESCENCE, ri.IridescenceIOR)
+		}
+		ri.IridescenceThicknessMinimum = floatOr(ext.IridescenceThicknessMinimum, 100)
+		ri.IridescenceThicknessMaximum = floatOr(ext.IridescenceThicknessMaximum, 400)
+		if ri.IridescenceThicknessMinimum < 0 || ri.IridescenceThicknessMaximum < 0 {
+			return fmt.Errorf("%s: thickness range [%v, %v] is negative", KHR_MATERIALS_IRIDESCENCE,
+				ri.IridescenceThicknessMinimum, ri.IridescenceThicknessMaximum)
+		}
+		if ri.IridescenceTexture, err = ext.IridescenceTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_IRIDESCENCE, err)
+		}
+		if ri.IridescenceThicknessTexture, err = ext.IridescenceThicknessTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_IRIDESCENCE, err)
+		}
+		rval.Iridescence = ri
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsAnisotropy](m); err != nil {
+		return err
+	} else if ext != nil {
+		ra := &ResolvedAnisotropy{KHRMaterialsAnisotropy: ext}
+		ra.AnisotropyStrength = floatOr(ext.AnisotropyStrength, 0)
+		if ra.AnisotropyStrength < 0 || ra.AnisotropyStrength > 1 {
+			return fmt.Errorf("%s: anisotropyStrength %v is not in [0, 1]", KHR_MATERIALS_ANISOTROPY, ra.AnisotropyStrength)
+		}
+		ra.AnisotropyRotation = floatOr(ext.AnisotropyRotation, 0)
+		if ra.AnisotropyTexture, err = ext.AnisotropyTexture.resolve(root); err != nil {
+			return fmt.Errorf("%s: %w", KHR_MATERIALS_ANISOTROPY, err)
+		}
+		rval.Anisotropy = ra
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsDispersion](m); err != nil {
+		return err
+	} else if ext != nil {
+		rval.Dispersion = floatOr(ext.Dispersion, 0)
+		if rval.Dispersion < 0 {
+			return fmt.Errorf("%s: dispersion %v is negative", KHR_MATERIALS_DISPERSION, rval.Dispersion)
+		}
+	}
+
+	if ext, _, err := GetExtension[KHRMaterialsEmissiveStrength](m); err != nil {
+		return err
+	} else if ext != nil {
+		rval.EmissiveStrength = floatOr(ext.EmissiveStrength, 1)
+		if rval.EmissiveStrength < 0 {
+			return fmt.Errorf("%s: emissiveStrength %v is negative", KHR_MATERIALS_EMISSIVE_STRENGTH, rval.EmissiveStrength)
+		}
+	}
+
+	return nil
+}
+
+// extensionTextureInfos returns pointers to every TextureInfo referenced by the material's extensions, or an error if
+// one of them can not be decoded.
+func (m *Material) extensionTextureInfos() ([]*TextureInfo, error) {
+	var rval []*TextureInfo
+	add := func(tis ...*TextureInfo) {
+		for _, ti := range tis {
+			if ti != nil {
+				rval = append(rval, ti)
+			}
+		}
+	}
+	if ext, _, err := GetExtension[KHRMaterialsClearcoat](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.ClearcoatTexture, ext.ClearcoatRoughnessTexture)
+		if ext.ClearcoatNormalTexture != nil {
+			add(&ext.ClearcoatNormalTexture.TextureInfo)
+		}
+	}
+	if ext, _, err := GetExtension[KHRMaterialsTransmission](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.TransmissionTexture)
+	}
+	if ext, _, err := GetExtension[KHRMaterialsVolume](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.ThicknessTexture)
+	}
+	if ext, _, err := GetExtension[KHRMaterialsSheen](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.SheenColorTexture, ext.SheenRoughnessTexture)
+	}
+	if ext, _, err := GetExtension[KHRMaterialsSpecular](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.SpecularTexture, ext.SpecularColorTexture)
+	}
+	if ext, _, err := GetExtension[KHRMaterialsIridescence](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.IridescenceTexture, ext.IridescenceThicknessTexture)
+	}
+	if ext, _, err := GetExtension[KHRMaterialsAnisotropy](m); err != nil {
+		return nil, err
+	} else if ext != nil {
+		add(ext.AnisotropyTexture)
+	}
+	return rval, nil
+}
+
+// extensionWarnings describes combinations of material extensions that are valid JSON but do not have the intended
+// effect. fallback is set when the document does not require KHR_materials_unlit, so viewers that do not support it
+// will render an unlit material with its metallic-roughness properties instead.
+func (m *ResolvedMaterial) extensionWarnings(fallback bool) []string {
+	var rval []string
+	has := func(name string) bool {
+		_, found := m.Extensions[name]
+		return found
+	}
+
+	if m.Unlit {
+		// Unlit shading only uses the base color, so the lit extensions are ignored.
+		for _, name := range []string{
+			KHR_MATERIALS_ANISOTROPY, KHR_MATERIALS_CLEARCOAT, KHR_MATERIALS_DISPERSION, KHR_MATERIALS_IOR,
+			KHR_MATERIALS_IRIDESCENCE, KHR_MATERIALS_SHEEN, KHR_MATERIALS_SPECULAR, KHR_MATERIALS_TRANSMISSION,
+			KHR_MATERIALS_VOLUME,
+		} {
+			if has(name) {
+				rval = append(rval, fmt.Sprintf("%s has no effect on an unlit material", name))
+			}
+		}
+		// The spec recommends a non-metallic fallback, as a metallic surface is dark without reflections to show.
+		if fallback && (m.PbrMetallicRoughness == nil || floatOr(m.PbrMetallicRoughness.MetallicFactor, 1) != 0) {
+			rval = append(rval, fmt.Sprintf("%s material has no fallback with metallicFactor 0", KHR_MATERIALS_UNLIT))
+		}
+	}
+	if m.Volume != nil && m.Transmission == nil {
+		rval = append(rval, fmt.Sprintf("%s has no effect without %s", KHR_MATERIALS_VOLUME, KHR_MATERIALS_TRANSMISSION))
+	}
+	if has(KHR_MATERIALS_DISPERSION) && m.Volume == nil {
+		rval = append(rval, fmt.Sprintf("%s has no effect without %s", KHR_MATERIALS_DISPERSION, KHR_MATERIALS_VOLUME))
+	}
+	return rval
+}
+
+func floatOr(v *float32, def float32) float32 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func vec3Or(v *vkm.Vec3, def vkm.Vec3) vkm.Vec3 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
@@ -0,0 +1,227 @@
+package gltf
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+const materialExtensionsDocument = `{
+	"asset": {"version": "2.0"},
+	"extensionsUsed": [
+		"KHR_materials_clearcoat", "KHR_materials_emissive_strength", "KHR_materials_ior", "KHR_materials_sheen",
+		"KHR_materials_specular", "KHR_materials_transmission", "KHR_materials_volume", "KHR_texture_transform"
+	],
+	"textures": [{}, {}, {}],
+	"materials": [
+		{"extensions": {
+			"KHR_materials_clearcoat": {
+				"clearcoatFactor": 0.5,
+				"clearcoatTexture": {"index": 1},
+				"clearcoatNormalTexture": {"index": 2, "scale": 2, "extensions": {"KHR_texture_transform": {"texCoord": 1}}}
+			},
+			"KHR_materials_transmission": {"transmissionFactor": 1, "transmissionTexture": {"index": 0}},
+			"KHR_materials_volume": {"thicknessFactor": 0.25, "attenuationDistance": 3},
+			"KHR_materials_sheen": {"sheenColorFactor": [1, 0, 0]},
+			"KHR_materials_specular": {"specularColorTexture": {"index": 2}},
+			"KHR_materials_ior": {"ior": 1.33},
+			"KHR_materials_emissive_strength": {"emissiveStrength": 5}
+		}},
+		{"extensions": {"KHR_materials_volume": {}, "KHR_materials_ior": {}}},
+		{}
+	]
+}`
+
+func TestMaterialExtensions(t *testing.T) {
+	doc, err := FromBytes([]byte(materialExtensionsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := doc.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := resolved.Materials[0]
+	if cc := m.Clearcoat; cc == nil || cc.ClearcoatFactor != 0.5 || cc.ClearcoatRoughnessFactor != 0 ||
+		cc.ClearcoatTexture.Texture != &resolved.Textures[1] || cc.ClearcoatRoughnessTexture != nil {
+		t.Errorf("unexpected clearcoat %+v", cc)
+	} else if nt := cc.ClearcoatNormalTexture; nt.Texture != &resolved.Textures[2] || *nt.Scale != 2 || nt.TexCoord != 1 {
+		t.Errorf("unexpected clearcoat normal texture %+v", nt)
+	}
+	if tr := m.Transmission; tr == nil || tr.TransmissionFactor != 1 || tr.TransmissionTexture.Texture != &resolved.Textures[0] {
+		t.Errorf("unexpected transmission %+v", tr)
+	}
+	if v := m.Volume; v == nil || v.ThicknessFactor != 0.25 || v.AttenuationDistance != 3 || v.AttenuationColor != (vkm.Vec3{1, 1, 1}) {
+		t.Errorf("unexpected volume %+v", v)
+	}
+	if s := m.Sheen; s == nil || s.SheenColorFactor != (vkm.Vec3{1, 0, 0}) || s.SheenRoughnessFactor != 0 {
+		t.Errorf("unexpected sheen %+v", s)
+	}
+	if s := m.Specular; s == nil || s.SpecularFactor != 1 || s.SpecularColorFactor != (vkm.Vec3{1, 1, 1}) ||
+		s.SpecularColorTexture.Texture != &resolved.Textures[2] {
+		t.Errorf("unexpected specular %+v", s)
+	}
+	if m.IOR != 1.33 || m.EmissiveStrength != 5 {
+		t.Errorf("expected ior 1.33 and emissive strength 5, got %v and %v", m.IOR, m.EmissiveStrength)
+	}
+
+	// Empty extensions take every default.
+	m = resolved.Materials[1]
+	if m.Volume == nil || m.Volume.AttenuationDistance != math32.Inf(1) || m.IOR != 1.5 {
+		t.Errorf("expected the defaults for empty extensions, got %+v and ior %v", m.Volume, m.IOR)
+	}
+	m = resolved.Materials[2]
+	if m.Clearcoat != nil || m.Transmission != nil || m.Volume != nil || m.Sheen != nil || m.Specular != nil ||
+		m.IOR != 1.5 || m.EmissiveStrength != 1 {
+		t.Errorf("expected no extensions and the defaults on a plain material, got %+v", m)
+	}
+
+	for _, invalid := range []string{
+		`"KHR_materials_ior": {"ior": 0.5}`,
+		`"KHR_materials_volume": {"attenuationDistance": 0}`,
+		`"KHR_materials_sheen": {"sheenColorTexture": {"index": 3}}`,
+		`"KHR_materials_emissive_strength": {"emissiveStrength": "bright"}`,
+	} {
+		doc, err := FromBytes([]byte(`{"asset": {"version": "2.0"}, "textures": [{}],
+			"materials": [{"extensions": {` + invalid + `}}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := doc.Resolve(nil); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestPruneMaterialExtensionTextures(t *testing.T) {
+	doc, err := FromBytes([]byte(`{
+		"asset": {"version": "2.0"},
+		"scenes": [{"nodes": [0]}],
+		"nodes": [{"mesh": 0}],
+		"meshes": [{"primitives": [{"attributes": {}, "material": 0}]}],
+		"materials": [{"extensions": {"KHR_materials_sheen": {"sheenRoughnessTexture": {"index": 1}}}}],
+		"textures": [{}, {}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Textures) != 1 {
+		t.Fatalf("expected the texture used by the extension to be kept, got %d textures", len(doc.Textures))
+	}
+	ext, _, err := GetExtension[KHRMaterialsSheen](&doc.Materials[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext.SheenRoughnessTexture.Index != 0 {
+		t.Errorf("expected the extension's texture reference to be remapped, got %d", ext.SheenRoughnessTexture.Index)
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"sheenRoughnessTexture":{"index":0}`) {
+		t.Errorf("expected the remapped reference to be written out, got %s", b)
+	}
+}
+
+func TestMaterialExtensionCombinations(t *testing.T) {
+	doc, err := FromBytes([]byte(`{
+		"asset": {"version": "2.0"},
+		"textures": [{}],
+		"materials": [
+			{
+				"pbrMetallicRoughness": {"metallicFactor": 0},
+				"extensions": {"KHR_materials_unlit": {}}
+			},
+			{
+				"extensions": {"KHR_materials_unlit": {}, "KHR_materials_sheen": {}}
+			},
+			{
+				"extensions": {
+					"KHR_materials_iridescence": {"iridescenceFactor": 1, "iridescenceThicknessTexture": {"index": 0}},
+					"KHR_materials_anisotropy": {"anisotropyStrength": 0.5, "anisotropyTexture": {"index": 0}},
+					"KHR_materials_dispersion": {"dispersion": 0.1},
+					"KHR_materials_volume": {}
+				}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := doc.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !resolved.Materials[0].Unlit || !resolved.Materials[1].Unlit || resolved.Materials[2].Unlit {
+		t.Error("expected only the first two materials to be unlit")
+	}
+	m := resolved.Materials[2]
+	if ir := m.Iridescence; ir == nil || ir.IridescenceFactor != 1 || ir.IridescenceIOR != 1.3 ||
+		ir.IridescenceThicknessMinimum != 100 || ir.IridescenceThicknessMaximum != 400 ||
+		ir.IridescenceTexture != nil || ir.IridescenceThicknessTexture.Texture != &resolved.Textures[0] {
+		t.Errorf("unexpected iridescence %+v", ir)
+	}
+	if an := m.Anisotropy; an == nil || an.AnisotropyStrength != 0.5 || an.AnisotropyRotation != 0 ||
+		an.AnisotropyTexture.Texture != &resolved.Textures[0] {
+		t.Errorf("unexpected anisotropy %+v", an)
+	}
+	if m.Dispersion != 0.1 || resolved.Materials[0].Dispersion != 0 {
+		t.Errorf("expected dispersion 0.1 and a default of 0, got %v and %v", m.Dispersion, resolved.Materials[0].Dispersion)
+	}
+
+	// The first material is a valid unlit material. The second has an ignored extension and a metallic fallback, and
+	// the third has a volume without transmission.
+	expected := []string{
+		"Material 1: KHR_materials_sheen has no effect on an unlit material",
+		"Material 1: KHR_materials_unlit material has no fallback with metallicFactor 0",
+		"Material 2: KHR_materials_volume has no effect without KHR_materials_transmission",
+	}
+	var warnings []string
+	for _, w := range resolved.Warnings {
+		if strings.HasPrefix(w, "Material") {
+			warnings = append(warnings, w)
+		}
+	}
+	if strings.Join(warnings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected warnings %q, got %q", expected, warnings)
+	}
+
+	// A document that requires unlit does not need a fallback.
+	doc.ExtensionsUsed = []string{KHR_MATERIALS_UNLIT}
+	doc.ExtensionsRequired = []string{KHR_MATERIALS_UNLIT}
+	doc.Materials = doc.Materials[1:2]
+	RemoveExtension(&doc.Materials[0], KHR_MATERIALS_SHEEN)
+	if resolved, err = doc.Resolve(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range resolved.Warnings {
+		if strings.HasPrefix(w, "Material") {
+			t.Errorf("unexpected warning %q", w)
+		}
+	}
+
+	for _, invalid := range []string{
+		`"KHR_materials_anisotropy": {"anisotropyStrength": 2}`,
+		`"KHR_materials_iridescence": {"iridescenceIor": 0.5}`,
+		`"KHR_materials_iridescence": {"iridescenceThicknessMinimum": -1}`,
+		`"KHR_materials_dispersion": {"dispersion": -1}`,
+	} {
+		doc, err := FromBytes([]byte(`{"asset": {"version": "2.0"}, "materials": [{"extensions": {` + invalid + `}}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := doc.Resolve(nil); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package gltf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bbredesen/vkm"
+)
+
+// MergeOptions controls how Merge and MergeResolved combine documents.
+type MergeOptions struct {
+	// Wrap places the scene roots of each source document under a new parent node, instead of adding them directly to
+	// the merged scene.
+	Wrap bool
+	// Transforms optionally sets the local transform of each wrapper node, indexed by source document. Sources beyond
+	// the end of the slice get the identity transform. Ignored unless Wrap is set.
+	Transforms []vkm.Mat
+	// Names optionally names each wrapper node, indexed by source document. Ignored unless Wrap is set.
+	Names []GlTFId
+}
+
+// Merge combines several documents into a new one. All objects from every source are copied into the result, with
+// indexed references remapped to their new positions, and extensionsUsed and extensionsRequired are the union of those
+// in the sources. Names that collide with a name used by an object of the same type from an earlier source are given a
+// numeric suffix; objects of a single source that share a name are left sharing it.
+//
+// The result has a single scene, which is also the default scene, holding the root nodes of each source's default
+// scene (or its first scene, if no default is set). Nodes from other scenes are copied but not referenced from any
+// scene; call Prune on the result to remove them. The asset description is taken from the first source, and
+// root-level extensions and extras of the other sources are dropped, except that the KHR_lights_punctual lights and
+// the KHR_materials_variants variants of every source are combined.
+//
+// Buffer and image URIs are rewritten, when needed, to be relative to the location of the first source. The sources
+// are not modified.
+func Merge(sources []*GlTF, opts MergeOptions) (*GlTF, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("No documents to merge")
+	}
+
+	rval := &GlTF{}
+	rval.meta = sources[0].meta
+	rval.Scenes = []Scene{{}}
+	rval.Scene = new(uint)
+
+	names := newNameRegistry()
+	for i, src := range sources {
+		clone, err := src.clone()
+		if err != nil {
+			return nil, fmt.Errorf("Could not copy document %d: %w", i, err)
+		}
+		clone.relocateUris(src.meta.defaultSearchPath, rval.meta.defaultSearchPath)
+		names.rename(clone)
+
+		roots := clone.defaultSceneNodes()
+		if i == 0 {
+			rval.Asset, rval.Extras = clone.Asset, clone.Extras
+			// Lights and variants are combined by append, along with those of the other sources.
+			for name, ext := range clone.Extensions {
+				if name == KHR_LIGHTS_PUNCTUAL || name == KHR_MATERIALS_VARIANTS {
+					continue
+				}
+				if rval.Extensions == nil {
+					rval.Extensions = make(Extensions)
+				}
+				rval.Extensions[name] = ext
+			}
+		}
+		if err := rval.append(clone); err != nil {
+			return nil, fmt.Errorf("Could not merge document %d: %w", i, err)
+		}
+
+		offset := uint(len(rval.Nodes) - len(clone.Nodes))
+		for j := range roots {
+			roots[j] += offset
+		}
+
+		if opts.Wrap {
+			transform := vkm.Identity()
+			if i < len(opts.Transforms) {
+				transform = opts.Transforms[i]
+			}
+			wrapper := Node{Children: roots, Matrix: matToArray(transform)}
+			if i < len(opts.Names) {
+				wrapper.Name = names.unique("nodes", opts.Names[i])
+			}
+			rval.Nodes = append(rval.Nodes, wrapper)
+			rval.Scenes[0].Nodes = append(rval.Scenes[0].Nodes, uint(len(rval.Nodes)-1))
+		} else {
+			rval.Scenes[0].Nodes = append(rval.Scenes[0].Nodes, roots...)
+		}
+	}
+
+	return rval, nil
+}
+
+// MergeResolved combines several resolved documents in the same way as Merge. Binary data already loaded into the
+// sources is shared with the result rather than being reloaded, so buffer URIs in the result do not need to be valid.
+func MergeResolved(sources []*ResolvedGlTF, opts MergeOptions) (*ResolvedGlTF, error) {
+	docs := make([]*GlTF, len(sources))
+	for i := range sources {
+		docs[i] = sources[i].GlTF
+	}
+
+	merged, err := Merge(docs, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rval := &ResolvedGlTF{GlTF: merged}
+	for _, src := range sources {
+		for _, rb := range src.Buffers {
+			rval.Buffers = append(rval.Buffers, ResolvedBuffer{
+				Buffer: &merged.Buffers[len(rval.Buffers)],
+				Data:   rb.Data,
+			})
+		}
+	}
+
+	return rval, rval.resolveReferences()
+}
+
+// append moves every object in src to the end of the corresponding arrays in gltf, remapping src's references to match.
+// Scenes are not appended, but KHR_lights_punctual lights and KHR_materials_variants variants are. src is modified and
+// should not be used afterwards.
+func (gltf *GlTF) append(src *GlTF) error {
+	lights, err := gltf.Lights()
+	if err != nil {
+		return err
+	}
+	srcLights, err := src.Lights()
+	if err != nil {
+		return err
+	}
+	variants, err := gltf.Variants()
+	if err != nil {
+		return err
+	}
+	srcVariants, err := src.Variants()
+	if err != nil {
+		return err
+	}
+
+	if err := src.remapReferences(indexMaps{
+		accessors:   offsetMap(len(src.Accessors), len(gltf.Accessors)),
+		buffers:     offsetMap(len(src.Buffers), len(gltf.Buffers)),
+		bufferViews: offsetMap(len(src.BufferViews), len(gltf.BufferViews)),
+		cameras:     offsetMap(len(src.Cameras), len(gltf.Cameras)),
+		images:      offsetMap(len(src.Images), len(gltf.Images)),
+		materials:   offsetMap(len(src.Materials), len(gltf.Materials)),
+		meshes:      offsetMap(len(src.Meshes), len(gltf.Meshes)),
+		nodes:       offsetMap(len(src.Nodes), len(gltf.Nodes)),
+		samplers:    offsetMap(len(src.Samplers), len(gltf.Samplers)),
+		skins:       offsetMap(len(src.Skins), len(gltf.Skins)),
+		textures:    offsetMap(len(src.Textures), len(gltf.Textures)),
+		lights:      offsetMap(len(srcLights), len(lights)),
+		variants:    offsetMap(len(srcVariants), len(variants)),
+	}); err != nil {
+		return err
+	}
+
+	gltf.ExtensionsUsed = appendUnique(gltf.ExtensionsUsed, src.ExtensionsUsed...)
+	gltf.ExtensionsRequired = appendUnique(gltf.ExtensionsRequired, src.ExtensionsRequired...)
+
+	gltf.Accessors = append(gltf.Accessors, src.Accessors...)
+	gltf.Animations = append(gltf.Animations, src.Animations...)
+	gltf.Buffers = append(gltf.Buffers, src.Buffers...)
+	gltf.BufferViews = append(gltf.BufferViews, src.BufferViews...)
+	gltf.Cameras = append(gltf.Cameras, src.Cameras...)
+	gltf.Images = append(gltf.Images, src.Images...)
+	gltf.Materials = append(gltf.Materials, src.Materials...)
+	gltf.Meshes = append(gltf.Meshes, src.Meshes...)
+	gltf.Nodes = append(gltf.Nodes, src.Nodes...)
+	gltf.Samplers = append(gltf.Samplers, src.Samplers...)
+	gltf.Skins = append(gltf.Skins, src.Skins...)
+	gltf.Textures = append(gltf.Textures, src.Textures...)
+
+	if len(srcLights) > 0 {
+		gltf.SetLights(append(lights, srcLights...))
+	}
+	if len(srcVariants) > 0 {
+		gltf.SetVariants(append(variants, srcVariants...))
+	}
+	return nil
+}
+
+// clone returns a deep copy of the document, including its unexported metadata.
+func (gltf *GlTF) clone() (*GlTF, error) {
+	b, err := json.Marshal(gltf)
+	if err != nil {
+		return nil, err
+	}
+	rval, err := FromBytes(b)
+	if err != nil {
+		return nil, err
+	}
+	rval.meta = gltf.meta
+	return rval, nil
+}
+
+// relocateUris rewrites relative buffer and image URIs so that they can be found from dir instead of from srcDir.
+func (gltf *GlTF) relocateUris(srcDir, dir string) {
+	if srcDir == dir {
+		return
+	}
+	relocate := func(uri string) string {
+		if uri == "" || strings.HasPrefix(uri, "data:") || strings.Contains(uri, "://") || filepath.IsAbs(uri) {
+			return uri
+		}
+		abs := filepath.Join(srcDir, filepath.FromSlash(uri))
+		if rel, err := filepath.Rel(dir, abs); err == nil {
+			return filepath.ToSlash(rel)
+		}
+		return filepath.ToSlash(abs)
+	}
+
+	for i := range gltf.Buffers {
+		gltf.Buffers[i].Uri = relocate(gltf.Buffers[i].Uri)
+	}
+	for i := range gltf.Images {
+		gltf.Images[i].Uri = relocate(gltf.Images[i].Uri)
+	}
+}
+
+// defaultSceneNodes returns a copy of the root nodes of the default scene, or of the first scene if there is no
+// default. If there are no scenes, every node without a parent is returned.
+func (gltf *GlTF) defaultSceneNodes() []uint {
+	if gltf.Scene != nil && *gltf.Scene < uint(len(gltf.Scenes)) {
+		return append([]uint(nil), gltf.Scenes[*gltf.Scene].Nodes...)
+	} else if len(gltf.Scenes) > 0 {
+		return append([]uint(nil), gltf.Scenes[0].Nodes...)
+	}
+	return gltf.parentlessNodes()
+}
+
+// parentlessNodes returns the index of every node that is not a child of another node.
+func (gltf *GlTF) parentlessNodes() []uint {
+	isChild := make([]bool, len(gltf.Nodes))
+	for _, n := range gltf.Nodes {
+		for _, c := range n.Children {
+			if c < uint(len(isChild)) {
+				isChild[c] = true
+			}
+		}
+	}
+
+	var rval []uint
+	for i := range gltf.Nodes {
+		if !isChild[i] {
+			rval = append(rval, uint(i))
+		}
+	}
+	return rval
+}
+
+// nameRegistry tracks the names used by each object type in a merged document.
+type nameRegistry map[string]map[GlTFId]bool
+
+func newNameRegistry() nameRegistry {
+	return nameRegistry{}
+}
+
+// used returns the set of names used by objects of the given kind.
+func (nr nameRegistry) used(kind string) map[GlTFId]bool {
+	used := nr[kind]
+	if used == nil {
+		used = map[GlTFId]bool{}
+		nr[kind] = used
+	}
+	return used
+}
+
+// unique returns name if it has not been used by another object of the same kind, or else name with the first free
+// numeric suffix. Empty names are never changed. The returned name is marked as used.
+func (nr nameRegistry) unique(kind string, name GlTFId) GlTFId {
+	if name == "" {
+		return name
+	}
+	used := nr.used(kind)
+
+	rval := name
+	for n := 1; used[rval]; n++ {
+		rval = GlTFId(fmt.Sprintf("%s.%d", name, n))
+	}
+	used[rval] = true
+	return rval
+}
+
+// renameSource renames the objects of one kind from a single source, so that none has a name already registered by
+// another source, and then registers their names. Objects of the source that share a name are not told apart: they
+// keep sharing one, and a new name is chosen that no other object of the source uses.
+func (nr nameRegistry) renameSource(kind string, names []*GlTFId) {
+	used := nr.used(kind)
+	own := make(map[GlTFId]bool, len(names))
+	for _, name := range names {
+		own[*name] = true
+	}
+
+	renamed := map[GlTFId]GlTFId{}
+	for _, name := range names {
+		if *name == "" || !used[*name] {
+			continue
+		}
+		to, found := renamed[*name]
+		if !found {
+			to = *name
+			for n := 1; used[to] || own[to]; n++ {
+				to = GlTFId(fmt.Sprintf("%s.%d", *name, n))
+			}
+			own[to] = true
+			renamed[*name] = to
+		}
+		*name = to
+	}
+
+	for _, name := range names {
+		if *name != "" {
+			used[*name] = true
+		}
+	}
+}
+
+// namesOf returns a pointer to the name of each object, as given by name.
+func namesOf[T any](objs []T, name func(*T) *GlTFId) []*GlTFId {
+	rval := make([]*GlTFId, len(objs))
+	for i := range objs {
+		rval[i] = name(&objs[i])
+	}
+	return rval
+}
+
+// rename gives the named objects in gltf, one source document, names that are not used by the objects of the sources
+// already registered.
+func (nr nameRegistry) rename(gltf *GlTF) {
+	nr.renameSource("accessors", namesOf(gltf.Accessors, func(a *Accessor) *GlTFId { return &a.Name }))
+	nr.renameSource("animations", namesOf(gltf.Animations, func(a *Animation) *GlTFId { return &a.Name }))
+	nr.renameSource("buffers", namesOf(gltf.Buffers, func(b *Buffer) *GlTFId { return &b.Name }))
+	nr.renameSource("bufferViews", namesOf(gltf.BufferViews, func(bv *BufferView) *GlTFId { return &bv.Name }))
+	nr.renameSource("cameras", namesOf(gltf.Cameras, func(c *Camera) *GlTFId { return &c.Name }))
+	nr.renameSource("images", namesOf(gltf.Images, func(img *Image) *GlTFId { return &img.Name }))
+	nr.renameSource("materials", namesOf(gltf.Materials, func(m *Material) *GlTFId { return &m.Name }))
+	nr.renameSource("meshes", namesOf(gltf.Meshes, func(m *Mesh) *GlTFId { return &m.Name }))
+	nr.renameSource("nodes", namesOf(gltf.Nodes, func(n *Node) *GlTFId { return &n.Name }))
+	nr.renameSource("samplers", namesOf(gltf.Samplers, func(s *Sampler) *GlTFId { return &s.Name }))
+	nr.renameSource("skins", namesOf(gltf.Skins, func(s *Skin) *GlTFId { return &s.Name }))
+	nr.renameSource("textures", namesOf(gltf.Textures, func(t *Texture) *GlTFId { return &t.Name }))
+}
+
+func offsetMap(n, offset int) []uint {
+	rval := identityMap(n)
+	for i := range rval {
+		rval[i] += uint(offset)
+	}
+	return rval
+}
+
+func appendUnique(s []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range s {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s = append(s, v)
+		}
+	}
+	return s
+}
+
+// matToArray flattens a matrix into the column-major layout used by Node.Matrix.
+func matToArray(m vkm.Mat) [16]float32 {
+	var rval [16]float32
+	for col := range m {
+		copy(rval[col*4:], m[col][:])
+	}
+	return rval
+}
@@ -0,0 +1,88 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+func TestMerge(t *testing.T) {
+	a, err := FromBytes([]byte(`{
+		"asset": {"version": "2.0", "generator": "a"},
+		"extensionsUsed": ["KHR_materials_unlit"],
+		"scenes": [{"nodes": [0]}],
+		"nodes": [{"name": "prop", "mesh": 0}],
+		"meshes": [{"name": "box", "primitives": [{"attributes": {"POSITION": 0}}]}],
+		"accessors": [{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"}],
+		"bufferViews": [{"buffer": 0, "byteLength": 36}],
+		"buffers": [{"uri": "a.bin", "byteLength": 36}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := a.clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ExtensionsUsed = []string{"KHR_materials_unlit", "KHR_texture_transform"}
+
+	merged, err := Merge([]*GlTF{a, b}, MergeOptions{
+		Wrap:       true,
+		Transforms: []vkm.Mat{vkm.Identity(), vkm.NewMatTranslate(vkm.NewVec(5, 0, 0))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(merged.Nodes) != 4 || len(merged.Meshes) != 2 || len(merged.Accessors) != 2 || len(merged.Buffers) != 2 {
+		t.Fatalf("unexpected array lengths in merged document")
+	}
+	if len(merged.ExtensionsUsed) != 2 {
+		t.Errorf("expected 2 extensions used, got %v", merged.ExtensionsUsed)
+	}
+	if merged.Nodes[0].Name != "prop" || merged.Nodes[2].Name != "prop.1" || merged.Meshes[1].Name != "box.1" {
+		t.Errorf("name collisions were not resolved")
+	}
+
+	wrapper := merged.Nodes[merged.Scenes[0].Nodes[1]]
+	if len(wrapper.Children) != 1 || wrapper.Children[0] != 2 || wrapper.Matrix[12] != 5 {
+		t.Errorf("second source was not wrapped correctly: %+v", wrapper)
+	}
+	if *merged.Nodes[2].Mesh != 1 || merged.Meshes[1].Primitives[0].Attributes[POSITION] != 1 ||
+		*merged.Accessors[1].BufferView != 1 || merged.BufferViews[1].Buffer != 1 {
+		t.Errorf("references in the second source were not remapped")
+	}
+	if *a.Nodes[0].Mesh != 0 || len(a.Nodes) != 1 {
+		t.Errorf("source document was modified")
+	}
+}
+
+func TestMergeNames(t *testing.T) {
+	docs := make([]*GlTF, 3)
+	for i, nodes := range []string{
+		`{"name": "prop"}, {"name": "prop"}, {"name": "prop.1"}`,
+		`{"name": "prop"}, {"name": "prop"}, {"name": "other"}`,
+		`{"name": "other"}, {"name": "x"}, {"name": "x"}, {}`,
+	} {
+		doc, err := FromBytes([]byte(`{"asset": {"version": "2.0"}, "nodes": [` + nodes + `]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs[i] = doc
+	}
+
+	merged, err := Merge(docs, MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicates within a source are kept, and only collisions with an earlier source are renamed.
+	expected := []GlTFId{"prop", "prop", "prop.1", "prop.2", "prop.2", "other", "other.1", "x", "x", ""}
+	if len(merged.Nodes) != len(expected) {
+		t.Fatalf("expected %d nodes, got %d", len(expected), len(merged.Nodes))
+	}
+	for i, name := range expected {
+		if merged.Nodes[i].Name != name {
+			t.Errorf("expected node %d to be named %q, got %q", i, name, merged.Nodes[i].Name)
+		}
+	}
+}
@@ -0,0 +1,573 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/chewxy/math32"
+)
+
+const EXT_MESHOPT_COMPRESSION = "EXT_meshopt_compression"
+
+// EXTMeshoptCompression is the EXT_meshopt_compression extension on a buffer view, which holds the view's data
+// compressed with one of the meshoptimizer codecs. See
+// https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Vendor/EXT_meshopt_compression
+type EXTMeshoptCompression struct {
+	Buffer     uint          `json:"buffer"`
+	ByteOffset uint          `json:"byteOffset,omitempty"`
+	ByteLength uint          `json:"byteLength"`
+	ByteStride uint          `json:"byteStride"`
+	Count      uint          `json:"count"`
+	Mode       MeshoptMode   `json:"mode"`
+	Filter     MeshoptFilter `json:"filter,omitempty"`
+}
+
+// EXTMeshoptCompressionBuffer is the EXT_meshopt_compression extension on a buffer. A fallback buffer holds the
+// uncompressed data of compressed buffer views for loaders without the extension, and need not have a URI at all.
+type EXTMeshoptCompressionBuffer struct {
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+type MeshoptMode string
+
+const (
+	MESHOPT_ATTRIBUTES MeshoptMode = "ATTRIBUTES"
+	MESHOPT_TRIANGLES  MeshoptMode = "TRIANGLES"
+	MESHOPT_INDICES    MeshoptMode = "INDICES"
+)
+
+type MeshoptFilter string
+
+const (
+	MESHOPT_NONE        MeshoptFilter = "NONE"
+	MESHOPT_OCTAHEDRAL  MeshoptFilter = "OCTAHEDRAL"
+	MESHOPT_QUATERNION  MeshoptFilter = "QUATERNION"
+	MESHOPT_EXPONENTIAL MeshoptFilter = "EXPONENTIAL"
+)
+
+func init() {
+	RegisterExtension(EXT_MESHOPT_COMPRESSION, ExtensionCodec[EXTMeshoptCompression]{}, (*BufferView)(nil))
+	RegisterExtension(EXT_MESHOPT_COMPRESSION, ExtensionCodec[EXTMeshoptCompressionBuffer]{}, (*Buffer)(nil))
+}
+
+// isMeshoptFallback reports whether the buffer is an EXT_meshopt_compression fallback without any data of its own.
+func (buf *Buffer) isMeshoptFallback() bool {
+	ext, _, err := GetExtension[EXTMeshoptCompressionBuffer](buf)
+	return err == nil && ext != nil && ext.Fallback && buf.Uri == ""
+}
+
+// decode returns the uncompressed data of a buffer view, which is Count * ByteStride bytes long.
+func (ext *EXTMeshoptCompression) decode(root *ResolvedGlTF) ([]byte, error) {
+	if ext.Buffer >= uint(len(root.Buffers)) {
+		return nil, fmt.Errorf("Compressed buffer %d is not a valid buffer", ext.Buffer)
+	}
+	src := root.Buffers[ext.Buffer].Data
+	if ext.ByteOffset+ext.ByteLength > uint(len(src)) {
+		return nil, fmt.Errorf("Compressed data extends past the end of buffer %d", ext.Buffer)
+	}
+	src = src[ext.ByteOffset : ext.ByteOffset+ext.ByteLength]
+	count, stride := int(ext.Count), int(ext.ByteStride)
+
+	switch ext.Mode {
+	case MESHOPT_ATTRIBUTES:
+		data, err := MeshoptDecodeVertexBuffer(count, stride, src)
+		if err != nil {
+			return nil, err
+		}
+		return data, MeshoptDecodeFilter(ext.Filter, data, count, stride)
+	case MESHOPT_TRIANGLES, MESHOPT_INDICES:
+		if ext.Filter != "" && ext.Filter != MESHOPT_NONE {
+			return nil, fmt.Errorf("Filter %s can not be used with mode %s", ext.Filter, ext.Mode)
+		}
+		if ext.Mode == MESHOPT_TRIANGLES {
+			return MeshoptDecodeIndexBuffer(count, stride, src)
+		}
+		return MeshoptDecodeIndexSequence(count, stride, src)
+	}
+	return nil, fmt.Errorf("Compression mode not recognized: %s", ext.Mode)
+}
+
+// The decoders below follow the reference implementation in meshoptimizer's vertexcodec.cpp, indexcodec.cpp and
+// vertexfilter.cpp, by Arseny Kapoulkine, closely enough that they can be compared side by side.
+
+const (
+	meshoptVertexHeader   = 0xa0
+	meshoptIndexHeader    = 0xe0
+	meshoptSequenceHeader = 0xd0
+
+	meshoptByteGroupSize        = 16
+	meshoptByteGroupDecodeLimit = 24
+	meshoptVertexBlockSizeBytes = 8192
+	meshoptVertexBlockMaxSize   = 256
+	meshoptTailMaxSize          = 32
+)
+
+// MeshoptDecodeVertexBuffer decodes count vertices of stride bytes each, compressed with the meshoptimizer vertex
+// codec, as used by EXT_meshopt_compression's ATTRIBUTES mode.
+func MeshoptDecodeVertexBuffer(count, stride int, src []byte) ([]byte, error) {
+	if stride <= 0 || stride > 256 || stride%4 != 0 {
+		return nil, fmt.Errorf("Vertex stride %d is not a multiple of 4 from 4 to 256", stride)
+	}
+	if len(src) < 1+stride {
+		return nil, fmt.Errorf("Compressed vertex data is too short")
+	}
+	if src[0]&0xf0 != meshoptVertexHeader {
+		return nil, fmt.Errorf("Compressed data is not a vertex buffer")
+	}
+	if version := src[0] & 0x0f; version > 0 {
+		return nil, fmt.Errorf("Vertex codec version %d is not supported", version)
+	}
+
+	dst := make([]byte, count*stride)
+	last := make([]byte, stride)
+	copy(last, src[len(src)-stride:])
+
+	blockSize := meshoptVertexBlockSizeBytes / stride &^ (meshoptByteGroupSize - 1)
+	if blockSize > meshoptVertexBlockMaxSize {
+		blockSize = meshoptVertexBlockMaxSize
+	}
+
+	var buffer [meshoptVertexBlockMaxSize]byte
+	pos := 1
+	for offset := 0; offset < count; offset += blockSize {
+		n := blockSize
+		if offset+n > count {
+			n = count - offset
+		}
+		aligned := (n + meshoptByteGroupSize - 1) &^ (meshoptByteGroupSize - 1)
+
+		for k := 0; k < stride; k++ {
+			var err error
+			if pos, err = meshoptDecodeBytes(src, pos, buffer[:aligned]); err != nil {
+				return nil, err
+			}
+			p := last[k]
+			for i := 0; i < n; i++ {
+				v := (-(buffer[i] & 1) ^ buffer[i]>>1) + p
+				dst[(offset+i)*stride+k] = v
+				p = v
+			}
+		}
+		copy(last, dst[(offset+n-1)*stride:(offset+n)*stride])
+	}
+
+	tail := stride
+	if tail < meshoptTailMaxSize {
+		tail = meshoptTailMaxSize
+	}
+	if len(src)-pos != tail {
+		return nil, fmt.Errorf("Compressed vertex data has %d bytes left over, expected %d", len(src)-pos, tail)
+	}
+	return dst, nil
+}
+
+// meshoptDecodeBytes decodes a channel of one byte per vertex into buffer, whose length is a multiple of the group
+// size, from src starting at pos. It returns the position after the channel.
+func meshoptDecodeBytes(src []byte, pos int, buffer []byte) (int, error) {
+	groups := len(buffer) / meshoptByteGroupSize
+	headerSize := (groups + 3) / 4
+	if len(src)-pos < headerSize {
+		return 0, fmt.Errorf("Compressed vertex data is truncated")
+	}
+	header := src[pos : pos+headerSize]
+	pos += headerSize
+
+	for g := 0; g < groups; g++ {
+		// The tail guarantees that a valid group never reads past the end.
+		if len(src)-pos < meshoptByteGroupDecodeLimit {
+			return 0, fmt.Errorf("Compressed vertex data is truncated")
+		}
+		bitslog2 := header[g/4] >> (g % 4 * 2) & 3
+		pos = meshoptDecodeBytesGroup(src, pos, buffer[g*meshoptByteGroupSize:(g+1)*meshoptByteGroupSize], bitslog2)
+	}
+	return pos, nil
+}
+
+// meshoptDecodeBytesGroup decodes a group of 16 bytes stored with 0, 2, 4 or 8 bits each. Values of 2 or 4 bits that
+// have every bit set are replaced by the next byte after the packed bits.
+func meshoptDecodeBytesGroup(src []byte, pos int, out []byte, bitslog2 byte) int {
+	switch bitslog2 {
+	case 0:
+		for i := range out {
+			out[i] = 0
+		}
+		return pos
+	case 3:
+		copy(out, src[pos:pos+meshoptByteGroupSize])
+		return pos + meshoptByteGroupSize
+	}
+
+	bits := 1 << bitslog2
+	perByte := 8 / bits
+	sentinel := byte(1<<bits - 1)
+	extra := pos + meshoptByteGroupSize/perByte
+	for i := range out {
+		enc := src[pos+i/perByte] >> (8 - bits*(i%perByte+1)) & sentinel
+		if enc == sentinel {
+			enc = src[extra]
+			extra++
+		}
+		out[i] = enc
+	}
+	return extra
+}
+
+// MeshoptDecodeIndexBuffer decodes count triangle list indices of indexSize (2 or 4) bytes each, compressed with the
+// meshoptimizer index codec, as used by EXT_meshopt_compression's TRIANGLES mode.
+func MeshoptDecodeIndexBuffer(count, indexSize int, src []byte) ([]byte, error) {
+	if count%3 != 0 {
+		return nil, fmt.Errorf("Index count %d is not a multiple of 3", count)
+	}
+	if indexSize != 2 && indexSize != 4 {
+		return nil, fmt.Errorf("Index size %d is not 2 or 4", indexSize)
+	}
+	// The shortest valid encoding is the header, a byte per triangle and the 16 byte codeaux table.
+	if len(src) < 1+count/3+16 {
+		return nil, fmt.Errorf("Compressed index data is too short")
+	}
+	if src[0]&0xf0 != meshoptIndexHeader {
+		return nil, fmt.Errorf("Compressed data is not an index buffer")
+	}
+	version := src[0] & 0x0f
+	if version > 1 {
+		return nil, fmt.Errorf("Index codec version %d is not supported", version)
+	}
+
+	var edgeFifo [16][2]uint32
+	var vertexFifo [16]uint32
+	for i := range edgeFifo {
+		edgeFifo[i] = [2]uint32{math.MaxUint32, math.MaxUint32}
+		vertexFifo[i] = math.MaxUint32
+	}
+	edgeOffset, vertexOffset := 0, 0
+	pushEdge := func(a, b uint32) {
+		edgeFifo[edgeOffset] = [2]uint32{a, b}
+		edgeOffset = (edgeOffset + 1) & 15
+	}
+	pushVertex := func(v uint32, cond bool) {
+		vertexFifo[vertexOffset] = v
+		if cond {
+			vertexOffset = (vertexOffset + 1) & 15
+		}
+	}
+
+	var next, last uint32
+	fecMax := 15
+	if version >= 1 {
+		fecMax = 13
+	}
+
+	dst := make([]byte, count*indexSize)
+	write := func(i int, a, b, c uint32) {
+		for k, v := range [3]uint32{a, b, c} {
+			if indexSize == 2 {
+				binary.LittleEndian.PutUint16(dst[(i+k)*2:], uint16(v))
+			} else {
+				binary.LittleEndian.PutUint32(dst[(i+k)*4:], v)
+			}
+		}
+	}
+
+	code := 1
+	data := code + count/3
+	safeEnd := len(src) - 16
+	codeaux := src[safeEnd:]
+
+	for i := 0; i < count; i += 3 {
+		// Each triangle reads at most 16 bytes of data, which the codeaux table guarantees are there.
+		if data > safeEnd {
+			return nil, fmt.Errorf("Compressed index data is truncated")
+		}
+		codetri := src[code]
+		code++
+
+		if codetri < 0xf0 {
+			fe := int(codetri >> 4)
+			edge := edgeFifo[(edgeOffset-1-fe)&15]
+			a, b := edge[0], edge[1]
+
+			fec := int(codetri & 15)
+			var c uint32
+			if fec < fecMax {
+				if fec == 0 {
+					c = next
+					next++
+				} else {
+					c = vertexFifo[(vertexOffset-1-fec)&15]
+				}
+				pushVertex(c, fec == 0)
+			} else {
+				if fec != 15 {
+					// 13 and 14 are deltas of -1 and 1 from the last free index.
+					c = last + uint32(fec-(fec^3))
+				} else {
+					c, data = meshoptDecodeIndex(src, data, last)
+				}
+				last = c
+				pushVertex(c, true)
+			}
+			write(i, a, b, c)
+			pushEdge(c, b)
+			pushEdge(a, c)
+			continue
+		}
+
+		var a, b, c uint32
+		var feb, fec int
+		if codetri < 0xfe {
+			aux := codeaux[codetri&15]
+			feb, fec = int(aux>>4), int(aux&15)
+
+			a = next
+			next++
+			if feb == 0 {
+				b = next
+				next++
+			} else {
+				b = vertexFifo[(vertexOffset-feb)&15]
+			}
+			if fec == 0 {
+				c = next
+				next++
+			} else {
+				c = vertexFifo[(vertexOffset-fec)&15]
+			}
+		} else {
+			aux := src[data]
+			data++
+			fea := 0
+			if codetri != 0xfe {
+				fea = 15
+			}
+			feb, fec = int(aux>>4), int(aux&15)
+			if aux == 0 {
+				next = 0
+			}
+
+			if fea == 0 {
+				a = next
+				next++
+			}
+			if feb == 0 {
+				b = next
+				next++
+			} else {
+				b = vertexFifo[(vertexOffset-feb)&15]
+			}
+			if fec == 0 {
+				c = next
+				next++
+			} else {
+				c = vertexFifo[(vertexOffset-fec)&15]
+			}
+
+			if fea == 15 {
+				a, data = meshoptDecodeIndex(src, data, last)
+				last = a
+			}
+			if feb == 15 {
+				b, data = meshoptDecodeIndex(src, data, last)
+				last = b
+			}
+			if fec == 15 {
+				c, data = meshoptDecodeIndex(src, data, last)
+				last = c
+			}
+		}
+
+		write(i, a, b, c)
+		pushVertex(a, true)
+		pushVertex(b, feb == 0 || feb == 15)
+		pushVertex(c, fec == 0 || fec == 15)
+		pushEdge(b, a)
+		pushEdge(c, b)
+		pushEdge(a, c)
+	}
+
+	if data != safeEnd {
+		return nil, fmt.Errorf("Compressed index data has %d bytes left over", safeEnd-data)
+	}
+	return dst, nil
+}
+
+// MeshoptDecodeIndexSequence decodes count indices of indexSize (2 or 4) bytes each, compressed with the meshoptimizer
+// index sequence codec, as used by EXT_meshopt_compression's INDICES mode.
+func MeshoptDecodeIndexSequence(count, indexSize int, src []byte) ([]byte, error) {
+	if indexSize != 2 && indexSize != 4 {
+		return nil, fmt.Errorf("Index size %d is not 2 or 4", indexSize)
+	}
+	// The shortest valid encoding is the header, a byte per index and a 4 byte tail.
+	if len(src) < 1+count+4 {
+		return nil, fmt.Errorf("Compressed index data is too short")
+	}
+	if src[0]&0xf0 != meshoptSequenceHeader {
+		return nil, fmt.Errorf("Compressed data is not an index sequence")
+	}
+	if version := src[0] & 0x0f; version > 1 {
+		return nil, fmt.Errorf("Index sequence codec version %d is not supported", version)
+	}
+
+	dst := make([]byte, count*indexSize)
+	data, safeEnd := 1, len(src)-4
+	var last [2]uint32
+	for i := 0; i < count; i++ {
+		// Each index reads at most 5 bytes, which the tail guarantees are there.
+		if data >= safeEnd {
+			return nil, fmt.Errorf("Compressed index data is truncated")
+		}
+		var v uint32
+		v, data = meshoptDecodeVByte(src, data)
+
+		// The low bit selects which of the two previous indices the delta is from.
+		current := v & 1
+		v >>= 1
+		index := last[current] + (v>>1 ^ -(v & 1))
+		last[current] = index
+
+		if indexSize == 2 {
+			binary.LittleEndian.PutUint16(dst[i*2:], uint16(index))
+		} else {
+			binary.LittleEndian.PutUint32(dst[i*4:], index)
+		}
+	}
+
+	if data != safeEnd {
+		return nil, fmt.Errorf("Compressed index data has %d bytes left over", safeEnd-data)
+	}
+	return dst, nil
+}
+
+// meshoptDecodeVByte reads a little-endian base 128 value of up to 5 bytes from src at pos.
+func meshoptDecodeVByte(src []byte, pos int) (uint32, int) {
+	lead := src[pos]
+	pos++
+	if lead < 128 {
+		return uint32(lead), pos
+	}
+
+	result, shift := uint32(lead&127), 7
+	for i := 0; i < 4; i++ {
+		group := src[pos]
+		pos++
+		result |= uint32(group&127) << shift
+		shift += 7
+		if group < 128 {
+			break
+		}
+	}
+	return result, pos
+}
+
+// meshoptDecodeIndex reads a zigzag encoded delta from last.
+func meshoptDecodeIndex(src []byte, pos int, last uint32) (uint32, int) {
+	v, pos := meshoptDecodeVByte(src, pos)
+	return last + (v>>1 ^ -(v & 1)), pos
+}
+
+// MeshoptDecodeFilter reverses a meshoptimizer filter in place on count elements of stride bytes each. MESHOPT_NONE,
+// or an empty filter, leaves the data unchanged.
+func MeshoptDecodeFilter(filter MeshoptFilter, data []byte, count, stride int) error {
+	if len(data) < count*stride {
+		return fmt.Errorf("Filtered data is shorter than %d elements of %d bytes", count, stride)
+	}
+
+	switch filter {
+	case "", MESHOPT_NONE:
+		return nil
+
+	case MESHOPT_OCTAHEDRAL:
+		if stride != 4 && stride != 8 {
+			return fmt.Errorf("Octahedral filter stride %d is not 4 or 8", stride)
+		}
+		size := stride / 4
+		limit := float32(int(1)<<(8*size-1) - 1)
+		for i := 0; i < count; i++ {
+			e := data[i*stride:]
+			x, y := float32(readSigned(e, size)), float32(readSigned(e[size:], size))
+			z := float32(readSigned(e[2*size:], size)) - math32.Abs(x) - math32.Abs(y)
+
+			// Fix up the octahedral coordinates for z < 0.
+			t := math32.Min(z, 0)
+			if x >= 0 {
+				x += t
+			} else {
+				x -= t
+			}
+			if y >= 0 {
+				y += t
+			} else {
+				y -= t
+			}
+
+			s := limit / math32.Sqrt(x*x+y*y+z*z)
+			writeSigned(e, size, roundToInt(x*s))
+			writeSigned(e[size:], size, roundToInt(y*s))
+			writeSigned(e[2*size:], size, roundToInt(z*s))
+		}
+		return nil
+
+	case MESHOPT_QUATERNION:
+		if stride != 8 {
+			return fmt.Errorf("Quaternion filter stride %d is not 8", stride)
+		}
+		scale := 1 / math32.Sqrt(2)
+		for i := 0; i < count; i++ {
+			e := data[i*8:]
+			w := readSigned(e[6:], 2)
+
+			// The scale is in the high bits of the fourth component, and the index of the largest component in the low 2.
+			ss := scale / float32(w|3)
+			x := float32(readSigned(e, 2)) * ss
+			y := float32(readSigned(e[2:], 2)) * ss
+			z := float32(readSigned(e[4:], 2)) * ss
+			ww := math32.Sqrt(math32.Max(1-x*x-y*y-z*z, 0))
+
+			qc := int(w & 3)
+			writeSigned(e[((qc+1)&3)*2:], 2, roundToInt(x*32767))
+			writeSigned(e[((qc+2)&3)*2:], 2, roundToInt(y*32767))
+			writeSigned(e[((qc+3)&3)*2:], 2, roundToInt(z*32767))
+			writeSigned(e[(qc&3)*2:], 2, int(ww*32767+0.5))
+		}
+		return nil
+
+	case MESHOPT_EXPONENTIAL:
+		if stride%4 != 0 {
+			return fmt.Errorf("Exponential filter stride %d is not a multiple of 4", stride)
+		}
+		for i := 0; i < count*stride; i += 4 {
+			v := binary.LittleEndian.Uint32(data[i:])
+			m := int32(v<<8) >> 8
+			e := int32(v) >> 24
+			f := math.Float32frombits(uint32(e+127)<<23) * float32(m)
+			binary.LittleEndian.PutUint32(data[i:], math.Float32bits(f))
+		}
+		return nil
+	}
+	return fmt.Errorf("Filter not recognized: %s", filter)
+}
+
+// readSigned reads a little-endian signed integer of size 1 or 2 bytes.
+func readSigned(b []byte, size int) int {
+	if size == 1 {
+		return int(int8(b[0]))
+	}
+	return int(int16(binary.LittleEndian.Uint16(b)))
+}
+
+// writeSigned writes the low size bytes of v, little-endian.
+func writeSigned(b []byte, size int, v int) {
+	b[0] = byte(v)
+	if size == 2 {
+		b[1] = byte(v >> 8)
+	}
+}
+
+// roundToInt rounds half away from zero by adding or subtracting 0.5 and truncating, as the reference implementation
+// does.
+func roundToInt(x float32) int {
+	if x >= 0 {
+		return int(x + 0.5)
+	}
+	return int(x - 0.5)
+}
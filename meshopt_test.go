@@ -0,0 +1,160 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// meshoptVertices is three vertices of 4 bytes, compressed with each of the byte group encodings in turn: 4 bits for
+// the first byte, 2 bits with an escaped value for the second, 8 bits for the third, and zeros for the fourth.
+var (
+	meshoptVertices = []byte{1, 2, 3, 0, 3, 2, 1, 0, 255, 2, 7, 0}
+
+	meshoptVertexStream = concatBytes(
+		[]byte{0xa0},
+		[]byte{0x02, 0x24, 0x70, 0, 0, 0, 0, 0, 0},
+		[]byte{0x01, 0xc0, 0, 0, 0, 0x04},
+		[]byte{0x03, 6, 3, 12, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0x00},
+		make([]byte, 32),
+	)
+)
+
+func concatBytes(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func uint16s(data []byte) []uint16 {
+	rval := make([]uint16, len(data)/2)
+	for i := range rval {
+		rval[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return rval
+}
+
+func TestMeshoptDecodeVertexBuffer(t *testing.T) {
+	data, err := MeshoptDecodeVertexBuffer(3, 4, meshoptVertexStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, meshoptVertices) {
+		t.Errorf("expected %v, got %v", meshoptVertices, data)
+	}
+
+	if _, err := MeshoptDecodeVertexBuffer(3, 4, meshoptVertexStream[:len(meshoptVertexStream)-1]); err == nil {
+		t.Error("expected an error for a truncated tail")
+	}
+	if _, err := MeshoptDecodeVertexBuffer(3, 4, append([]byte{0xa1}, meshoptVertexStream[1:]...)); err == nil {
+		t.Error("expected an error for an unsupported version")
+	}
+}
+
+func TestMeshoptDecodeIndices(t *testing.T) {
+	// Triangles from the codeaux table, a shared edge with the next vertex, a shared edge with a free index, the slow
+	// path with a vertex from the FIFO and a free index, and a shared edge with the last free index plus one.
+	triangles := concatBytes(
+		[]byte{0xe1},
+		[]byte{0xf0, 0x10, 0x0f, 0xfe, 0x0e},
+		[]byte{0x14, 0x1f, 0x05},
+		make([]byte, 16),
+	)
+	data, err := MeshoptDecodeIndexBuffer(15, 2, triangles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{0, 1, 2, 2, 1, 3, 2, 3, 10, 4, 10, 7, 4, 7, 8}
+	if got := uint16s(data); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected triangles %v, got %v", expected, got)
+	}
+	if _, err := MeshoptDecodeIndexBuffer(15, 2, append(triangles, 0)); err == nil {
+		t.Error("expected an error for left over data")
+	}
+
+	sequence := []byte{0xd1, 0x00, 0x04, 0x04, 0x0c, 0x0d, 0x8c, 0x06, 0, 0, 0, 0}
+	if data, err = MeshoptDecodeIndexSequence(6, 2, sequence); err != nil {
+		t.Fatal(err)
+	}
+	expected = []uint16{0, 1, 2, 5, 3, 200}
+	if got := uint16s(data); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected sequence %v, got %v", expected, got)
+	}
+}
+
+func TestMeshoptDecodeFilter(t *testing.T) {
+	exp := binary.LittleEndian.AppendUint32(nil, 0xfe000006)
+	if err := MeshoptDecodeFilter(MESHOPT_EXPONENTIAL, exp, 1, 4); err != nil {
+		t.Fatal(err)
+	}
+	if f := math.Float32frombits(binary.LittleEndian.Uint32(exp)); f != 1.5 {
+		t.Errorf("expected 6 * 2^-2, got %v", f)
+	}
+
+	oct := []byte{0, 0, 127, 9, 100, 100, 127, 9}
+	if err := MeshoptDecodeFilter(MESHOPT_OCTAHEDRAL, oct, 2, 4); err != nil {
+		t.Fatal(err)
+	}
+	if oct[0] != 0 || oct[1] != 0 || oct[2] != 127 || oct[3] != 9 {
+		t.Errorf("expected +Z to be unchanged, got %v", oct[:4])
+	}
+	// (100, 100) is folded over to (27, 27, -73) for the lower hemisphere, then scaled to length 127.
+	x, y, z := float64(int8(oct[4])), float64(int8(oct[5])), float64(int8(oct[6]))
+	if l := math.Sqrt(x*x + y*y + z*z); math.Abs(l-127) > 1 || x != y || math.Abs(z/x+73.0/27) > 0.05 || oct[7] != 9 {
+		t.Errorf("unexpected octahedral normal %v", oct[4:])
+	}
+
+	quat := make([]byte, 16)
+	binary.LittleEndian.PutUint16(quat[6:], 0x7fff)
+	binary.LittleEndian.PutUint16(quat[14:], 0x7ffd)
+	if err := MeshoptDecodeFilter(MESHOPT_QUATERNION, quat, 2, 8); err != nil {
+		t.Fatal(err)
+	}
+	expected := []uint16{0, 0, 0, 32767, 0, 32767, 0, 0}
+	if got := uint16s(quat); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected the largest component in the position given by the low bits, got %v", got)
+	}
+
+	if err := MeshoptDecodeFilter(MESHOPT_QUATERNION, quat, 4, 4); err == nil {
+		t.Error("expected an error for a quaternion stride other than 8")
+	}
+}
+
+func TestMeshoptResolve(t *testing.T) {
+	doc := fmt.Sprintf(`{
+		"asset": {"version": "2.0"},
+		"extensionsUsed": ["EXT_meshopt_compression"],
+		"extensionsRequired": ["EXT_meshopt_compression"],
+		"buffers": [
+			{"uri": "data:application/octet-stream;base64,%s", "byteLength": %d},
+			{"byteLength": 12, "extensions": {"EXT_meshopt_compression": {"fallback": true}}}
+		],
+		"bufferViews": [{"buffer": 1, "byteLength": 12, "byteStride": 4, "extensions": {"EXT_meshopt_compression": {
+			"buffer": 0, "byteLength": %d, "byteStride": 4, "count": 3, "mode": "ATTRIBUTES"
+		}}}],
+		"accessors": [{"bufferView": 0, "componentType": 5121, "count": 3, "type": "VEC4"}]
+	}`, base64.StdEncoding.EncodeToString(meshoptVertexStream), len(meshoptVertexStream), len(meshoptVertexStream))
+
+	resolved := resolveTestDoc(t, doc, nil)
+	if resolved.Buffers[1].Data != nil {
+		t.Error("expected the fallback buffer to have no data")
+	}
+	if !bytes.Equal(resolved.BufferViews[0].Data, meshoptVertices) {
+		t.Errorf("expected the decoded vertices, got %v", resolved.BufferViews[0].Data)
+	}
+	values, err := resolved.Accessors[0].ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[8] != 255 || values[10] != 7 {
+		t.Errorf("unexpected accessor values %v", values)
+	}
+
+	// The fallback buffer can not be read without the extension.
+	resolved.GlTF.BufferViews = append(resolved.GlTF.BufferViews, BufferView{Buffer: 1, ByteLength: 12})
+	if err := resolved.resolveReferences(); err == nil {
+		t.Error("expected an error for an uncompressed view of a fallback buffer without data")
+	}
+}
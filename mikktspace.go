@@ -0,0 +1,511 @@
+package gltf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// GeneratedTangents holds the result of GenerateTangents. The fields have the same meaning as in GeneratedNormals.
+type GeneratedTangents struct {
+	// Tangents holds one tangent per vertex, with the handedness of the bitangent in the fourth component, as in a
+	// glTF TANGENT attribute.
+	Tangents  []vkm.Vec
+	VertexMap []uint32
+	Indices   []uint32
+}
+
+// GenerateTangents computes MikkTSpace tangents for a triangle primitive, as required by the spec when a primitive
+// with a normal texture has no TANGENT attribute. The texture coordinate set used by the material's normal texture is
+// used, or TEXCOORD_0 if the primitive has no normal texture. The primitive must have POSITION and NORMAL attributes;
+// use GenerateNormals first if needed. Use WithTangents to create a primitive with the result.
+func (p *ResolvedPrimitive) GenerateTangents() (*GeneratedTangents, error) {
+	var set uint
+	if p.Material != nil && p.Material.NormalTexture != nil {
+		set = p.Material.NormalTexture.TexCoord
+	}
+	return p.GenerateTangentsFor(set)
+}
+
+// GenerateTangentsFor computes MikkTSpace tangents as with GenerateTangents, using texture coordinate set texCoord.
+//
+// This is a port of the reference MikkTSpace implementation with its default settings, so the results match those of
+// Blender, Substance and other tools that bake normal maps in MikkTSpace. glTF texture coordinates have their origin at
+// the top left, so V is flipped before tangents are computed, which gives tangents and handedness in the convention
+// used by the TANGENT attribute.
+func (p *ResolvedPrimitive) GenerateTangentsFor(texCoord uint) (*GeneratedTangents, error) {
+	posAcc, nrmAcc, uvAcc := p.Attributes[POSITION], p.Attributes[NORMAL], p.Attributes[TexCoord(texCoord)]
+	if posAcc == nil {
+		return nil, errors.New("Primitive has no POSITION attribute")
+	} else if nrmAcc == nil {
+		return nil, errors.New("Primitive has no NORMAL attribute")
+	} else if uvAcc == nil {
+		return nil, fmt.Errorf("Primitive has no %s attribute", TexCoord(texCoord))
+	}
+
+	var m mikkContext
+	var err error
+	if m.pos, err = posAcc.ReadVec3s(); err != nil {
+		return nil, err
+	}
+	if m.nrm, err = nrmAcc.ReadVec3s(); err != nil {
+		return nil, err
+	}
+	if m.uv, err = uvAcc.ReadVec2s(); err != nil {
+		return nil, err
+	}
+	if len(m.nrm) != len(m.pos) || len(m.uv) != len(m.pos) {
+		return nil, errors.New("Primitive attributes have different element counts")
+	}
+	for i := range m.uv {
+		m.uv[i][1] = 1 - m.uv[i][1]
+	}
+
+	tris, err := p.TriangleIndices()
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range tris {
+		if int(idx) >= len(m.pos) {
+			return nil, errors.New("Primitive index is out of range of its POSITION attribute")
+		}
+	}
+
+	spaces := m.generate(tris)
+	corners := make([]vkm.Vec, len(tris))
+	for i, ts := range spaces {
+		w := float32(-1)
+		if ts.orient {
+			w = 1
+		}
+		corners[i] = vkm.Vec{ts.os[0], ts.os[1], ts.os[2], w}
+	}
+
+	rval := &GeneratedTangents{}
+	rval.Tangents, rval.VertexMap, rval.Indices = collapseCorners(tris, len(m.pos), corners, vkm.Vec{1, 0, 0, 1})
+	return rval, nil
+}
+
+// WithTangents returns a copy of the primitive with a TANGENT attribute holding the generated tangents. If the tangents
+// required new vertices, every other attribute is copied to match, as with WithVertices.
+func (p *ResolvedPrimitive) WithTangents(t *GeneratedTangents) (*ResolvedPrimitive, error) {
+	values := make([]float32, 0, 4*len(t.Tangents))
+	for _, v := range t.Tangents {
+		values = append(values, v[0], v[1], v[2], v[3])
+	}
+	return p.withGenerated(TANGENT, NewFloatAccessor(VEC4, values), t.VertexMap, t.Indices)
+}
+
+// The remainder of this file follows the structure of the reference implementation, mikktspace.c by Morten S.
+// Mikkelsen, closely enough that the two can be compared side by side. Quads are not supported, since glTF only has
+// triangles.
+
+const (
+	mikkMarkDegenerate   = 1
+	mikkOrientPreserving = 8
+	mikkGroupWithAny     = 4
+)
+
+type mikkContext struct {
+	pos []vkm.Vec3
+	nrm []vkm.Vec3
+	uv  []vkm.Vec2
+}
+
+type mikkTriInfo struct {
+	faceNeighbors [3]int
+	assigned      [3]*mikkGroup
+	os, ot        vkm.Vec3
+	magS, magT    float32
+	orgFace       int
+	flag          int
+}
+
+type mikkGroup struct {
+	faces            []int
+	vertexRep        uint32
+	orientPreserving bool
+}
+
+type mikkTSpace struct {
+	os     vkm.Vec3
+	magS   float32
+	ot     vkm.Vec3
+	magT   float32
+	orient bool
+}
+
+func mikkNotZero(x float32) bool {
+	return math32.Abs(x) > math.SmallestNonzeroFloat32*(1<<23) // FLT_MIN, the smallest normal float32
+}
+
+func mikkVNotZero(v vkm.Vec3) bool {
+	return mikkNotZero(v[0]) || mikkNotZero(v[1]) || mikkNotZero(v[2])
+}
+
+func mikkNormalize(v vkm.Vec3) vkm.Vec3 {
+	if mikkVNotZero(v) {
+		return v.Scale(1 / v.Length())
+	}
+	return v
+}
+
+// project removes the component of v along n.
+func project(v, n vkm.Vec3) vkm.Vec3 {
+	return v.Sub(n.Scale(n.Dot(v)))
+}
+
+// generate returns a tangent space for every triangle corner in tris, which holds three source vertex indices per
+// triangle.
+func (m *mikkContext) generate(tris []uint32) []mikkTSpace {
+	totalTris := len(tris) / 3
+
+	// GenerateSharedVerticesIndexList: corners with identical position, normal and texture coordinate are merged.
+	type vertexKey struct {
+		p, n vkm.Vec3
+		t    vkm.Vec2
+	}
+	shared := make(map[vertexKey]uint32)
+	listIn := make([]uint32, len(tris))
+	for i, v := range tris {
+		key := vertexKey{m.pos[v], m.nrm[v], m.uv[v]}
+		if rep, found := shared[key]; found {
+			listIn[i] = rep
+		} else {
+			shared[key] = v
+			listIn[i] = v
+		}
+	}
+
+	// Mark degenerate triangles, and move them to the end of the list (DegenPrologue).
+	triInfos := make([]mikkTriInfo, totalTris)
+	order := make([]int, 0, totalTris)
+	var degenerate []int
+	for t := 0; t < totalTris; t++ {
+		p0, p1, p2 := m.pos[listIn[3*t]], m.pos[listIn[3*t+1]], m.pos[listIn[3*t+2]]
+		if p0 == p1 || p0 == p2 || p1 == p2 {
+			degenerate = append(degenerate, t)
+		} else {
+			order = append(order, t)
+		}
+	}
+	goodTris := len(order)
+	order = append(order, degenerate...)
+
+	triList := make([]uint32, len(tris))
+	for t, orig := range order {
+		copy(triList[3*t:3*t+3], listIn[3*orig:3*orig+3])
+		triInfos[t].orgFace = orig
+		if t >= goodTris {
+			triInfos[t].flag = mikkMarkDegenerate
+		}
+	}
+
+	m.initTriInfo(triInfos[:goodTris], triList)
+	buildNeighbors(triInfos[:goodTris], triList)
+	groups := build4RuleGroups(triInfos[:goodTris], triList)
+
+	spaces := make([]mikkTSpace, len(tris))
+	for i := range spaces {
+		spaces[i] = mikkTSpace{os: vkm.Vec3{1, 0, 0}, magS: 1, ot: vkm.Vec3{0, 1, 0}, magT: 1}
+	}
+	m.generateTSpaces(spaces, triInfos, groups, triList)
+
+	// DegenEpilogue: corners of degenerate triangles take the tangent space of the first good corner that shares their
+	// vertex.
+	firstCorner := make(map[uint32]int)
+	for j := 0; j < 3*goodTris; j++ {
+		if _, found := firstCorner[triList[j]]; !found {
+			firstCorner[triList[j]] = j
+		}
+	}
+	for t := goodTris; t < totalTris; t++ {
+		for i := 0; i < 3; i++ {
+			if j, found := firstCorner[triList[3*t+i]]; found {
+				src := &triInfos[j/3]
+				spaces[3*triInfos[t].orgFace+i] = spaces[3*src.orgFace+j%3]
+			}
+		}
+	}
+
+	return spaces
+}
+
+func (m *mikkContext) initTriInfo(triInfos []mikkTriInfo, triList []uint32) {
+	for f := range triInfos {
+		ti := &triInfos[f]
+		ti.faceNeighbors = [3]int{-1, -1, -1}
+		ti.flag |= mikkGroupWithAny
+
+		v1, v2, v3 := m.pos[triList[3*f]], m.pos[triList[3*f+1]], m.pos[triList[3*f+2]]
+		t1, t2, t3 := m.uv[triList[3*f]], m.uv[triList[3*f+1]], m.uv[triList[3*f+2]]
+
+		t21x, t21y := t2[0]-t1[0], t2[1]-t1[1]
+		t31x, t31y := t3[0]-t1[0], t3[1]-t1[1]
+		d1, d2 := v2.Sub(v1), v3.Sub(v1)
+
+		signedAreaSTx2 := t21x*t31y - t21y*t31x
+		vOs := d1.Scale(t31y).Sub(d2.Scale(t21y))
+		vOt := d1.Scale(-t31x).Add(d2.Scale(t21x))
+
+		if signedAreaSTx2 > 0 {
+			ti.flag |= mikkOrientPreserving
+		}
+
+		if mikkNotZero(signedAreaSTx2) {
+			absArea := math32.Abs(signedAreaSTx2)
+			lenOs, lenOt := vOs.Length(), vOt.Length()
+			s := float32(-1)
+			if ti.flag&mikkOrientPreserving != 0 {
+				s = 1
+			}
+			if mikkNotZero(lenOs) {
+				ti.os = vOs.Scale(s / lenOs)
+			}
+			if mikkNotZero(lenOt) {
+				ti.ot = vOt.Scale(s / lenOt)
+			}
+
+			ti.magS = lenOs / absArea
+			ti.magT = lenOt / absArea
+
+			if mikkNotZero(ti.magS) && mikkNotZero(ti.magT) {
+				ti.flag &^= mikkGroupWithAny
+			}
+		}
+	}
+}
+
+// mikkGetEdge returns the edge number of the edge {i0, i1} in a triangle, with its vertices in the triangle's winding
+// order.
+func mikkGetEdge(indices []uint32, i0, i1 uint32) (uint32, uint32, int) {
+	if indices[0] == i0 || indices[0] == i1 {
+		if indices[1] == i0 || indices[1] == i1 {
+			return indices[0], indices[1], 0
+		}
+		return indices[2], indices[0], 2
+	}
+	return indices[1], indices[2], 1
+}
+
+func buildNeighbors(triInfos []mikkTriInfo, triList []uint32) {
+	type edge struct {
+		i0, i1 uint32
+		f      int
+	}
+	edges := make([]edge, 3*len(triInfos))
+	for f := range triInfos {
+		for i := 0; i < 3; i++ {
+			i0, i1 := triList[3*f+i], triList[3*f+(i+1)%3]
+			if i1 < i0 {
+				i0, i1 = i1, i0
+			}
+			edges[3*f+i] = edge{i0, i1, f}
+		}
+	}
+	sort.Slice(edges, func(a, b int) bool {
+		if edges[a].i0 != edges[b].i0 {
+			return edges[a].i0 < edges[b].i0
+		} else if edges[a].i1 != edges[b].i1 {
+			return edges[a].i1 < edges[b].i1
+		}
+		return edges[a].f < edges[b].f
+	})
+
+	for i, e := range edges {
+		i0A, i1A, edgeA := mikkGetEdge(triList[3*e.f:], e.i0, e.i1)
+		if triInfos[e.f].faceNeighbors[edgeA] != -1 {
+			continue
+		}
+
+		for j := i + 1; j < len(edges) && edges[j].i0 == e.i0 && edges[j].i1 == e.i1; j++ {
+			t := edges[j].f
+			i1B, i0B, edgeB := mikkGetEdge(triList[3*t:], edges[j].i0, edges[j].i1)
+			if i0A == i0B && i1A == i1B && triInfos[t].faceNeighbors[edgeB] == -1 {
+				triInfos[e.f].faceNeighbors[edgeA] = t
+				triInfos[t].faceNeighbors[edgeB] = e.f
+				break
+			}
+		}
+	}
+}
+
+func build4RuleGroups(triInfos []mikkTriInfo, triList []uint32) []*mikkGroup {
+	var groups []*mikkGroup
+	for f := range triInfos {
+		for i := 0; i < 3; i++ {
+			if triInfos[f].flag&mikkGroupWithAny != 0 || triInfos[f].assigned[i] != nil {
+				continue
+			}
+
+			g := &mikkGroup{
+				vertexRep:        triList[3*f+i],
+				orientPreserving: triInfos[f].flag&mikkOrientPreserving != 0,
+			}
+			groups = append(groups, g)
+			triInfos[f].assigned[i] = g
+			g.faces = append(g.faces, f)
+
+			if left := triInfos[f].faceNeighbors[i]; left >= 0 {
+				assignRecur(triInfos, triList, left, g)
+			}
+			if right := triInfos[f].faceNeighbors[(i+2)%3]; right >= 0 {
+				assignRecur(triInfos, triList, right, g)
+			}
+		}
+	}
+	return groups
+}
+
+func assignRecur(triInfos []mikkTriInfo, triList []uint32, tri int, g *mikkGroup) bool {
+	ti := &triInfos[tri]
+	i := -1
+	for k := 0; k < 3; k++ {
+		if triList[3*tri+k] == g.vertexRep {
+			i = k
+			break
+		}
+	}
+
+	if ti.assigned[i] == g {
+		return true
+	} else if ti.assigned[i] != nil {
+		return false
+	}
+
+	if ti.flag&mikkGroupWithAny != 0 {
+		// The first group to claim a group-with-anything triangle determines its orientation. This is the only order
+		// dependency in the algorithm.
+		if ti.assigned[0] == nil && ti.assigned[1] == nil && ti.assigned[2] == nil {
+			ti.flag &^= mikkOrientPreserving
+			if g.orientPreserving {
+				ti.flag |= mikkOrientPreserving
+			}
+		}
+	}
+	if (ti.flag&mikkOrientPreserving != 0) != g.orientPreserving {
+		return false
+	}
+
+	g.faces = append(g.faces, tri)
+	ti.assigned[i] = g
+
+	if left := ti.faceNeighbors[i]; left >= 0 {
+		assignRecur(triInfos, triList, left, g)
+	}
+	if right := ti.faceNeighbors[(i+2)%3]; right >= 0 {
+		assignRecur(triInfos, triList, right, g)
+	}
+	return true
+}
+
+func (m *mikkContext) generateTSpaces(spaces []mikkTSpace, triInfos []mikkTriInfo, groups []*mikkGroup, triList []uint32) {
+	// The reference implementation's default angular threshold is 180 degrees.
+	thresCos := math32.Cos(math32.Pi)
+
+	for _, g := range groups {
+		var subGroups [][]int
+		var subSpaces []mikkTSpace
+
+		for _, f := range g.faces {
+			index := -1
+			for k := 0; k < 3; k++ {
+				if triInfos[f].assigned[k] == g {
+					index = k
+					break
+				}
+			}
+
+			n := m.nrm[triList[3*f+index]]
+			vOs := mikkNormalize(project(triInfos[f].os, n))
+			vOt := mikkNormalize(project(triInfos[f].ot, n))
+
+			var members []int
+			for _, t := range g.faces {
+				vOs2 := mikkNormalize(project(triInfos[t].os, n))
+				vOt2 := mikkNormalize(project(triInfos[t].ot, n))
+
+				any := (triInfos[f].flag|triInfos[t].flag)&mikkGroupWithAny != 0
+				sameOrgFace := triInfos[f].orgFace == triInfos[t].orgFace
+				if any || sameOrgFace || (vOs.Dot(vOs2) > thresCos && vOt.Dot(vOt2) > thresCos) {
+					members = append(members, t)
+				}
+			}
+			sort.Ints(members)
+
+			l := 0
+			for ; l < len(subGroups); l++ {
+				if equalInts(members, subGroups[l]) {
+					break
+				}
+			}
+			if l == len(subGroups) {
+				subGroups = append(subGroups, members)
+				subSpaces = append(subSpaces, m.evalTSpace(members, triInfos, triList, g.vertexRep))
+			}
+
+			ts := subSpaces[l]
+			ts.orient = g.orientPreserving
+			spaces[3*triInfos[f].orgFace+index] = ts
+		}
+	}
+}
+
+func (m *mikkContext) evalTSpace(faces []int, triInfos []mikkTriInfo, triList []uint32, vertexRep uint32) mikkTSpace {
+	var res mikkTSpace
+	var angleSum float32
+
+	for _, f := range faces {
+		if triInfos[f].flag&mikkGroupWithAny != 0 {
+			continue
+		}
+
+		i := 0
+		for ; i < 3; i++ {
+			if triList[3*f+i] == vertexRep {
+				break
+			}
+		}
+
+		n := m.nrm[triList[3*f+i]]
+		vOs := mikkNormalize(project(triInfos[f].os, n))
+		vOt := mikkNormalize(project(triInfos[f].ot, n))
+
+		p0 := m.pos[triList[3*f+(i+2)%3]]
+		p1 := m.pos[triList[3*f+i]]
+		p2 := m.pos[triList[3*f+(i+1)%3]]
+		v1 := mikkNormalize(project(p0.Sub(p1), n))
+		v2 := mikkNormalize(project(p2.Sub(p1), n))
+
+		angle := angleBetween(v1, v2)
+		res.os = res.os.Add(vOs.Scale(angle))
+		res.ot = res.ot.Add(vOt.Scale(angle))
+		res.magS += angle * triInfos[f].magS
+		res.magT += angle * triInfos[f].magT
+		angleSum += angle
+	}
+
+	res.os = mikkNormalize(res.os)
+	res.ot = mikkNormalize(res.ot)
+	if angleSum > 0 {
+		res.magS /= angleSum
+		res.magT /= angleSum
+	}
+	return res
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
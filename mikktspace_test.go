@@ -0,0 +1,56 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// uvQuad returns a unit quad in the XY plane facing +Z, textured the usual way up: glTF's UV origin is at the top left
+// of the image, so V decreases as Y increases.
+func uvQuad(uvs ...float32) *ResolvedPrimitive {
+	return &ResolvedPrimitive{
+		Primitive: &Primitive{},
+		Mode:      TRIANGLES,
+		Attributes: map[AttributeKey]*ResolvedAccessor{
+			POSITION:    NewFloatAccessor(VEC3, []float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}),
+			NORMAL:      NewFloatAccessor(VEC3, []float32{0, 0, 1, 0, 0, 1, 0, 0, 1, 0, 0, 1}),
+			TexCoord(0): NewFloatAccessor(VEC2, uvs),
+		},
+		Indices: NewIndexAccessor([]uint32{0, 1, 2, 0, 2, 3}),
+	}
+}
+
+func TestGenerateTangents(t *testing.T) {
+	tests := []struct {
+		name     string
+		uvs      []float32
+		expected vkm.Vec
+	}{
+		{"upright", []float32{0, 1, 1, 1, 1, 0, 0, 0}, vkm.Vec{1, 0, 0, 1}},
+		{"mirrored in U", []float32{1, 1, 0, 1, 0, 0, 1, 0}, vkm.Vec{-1, 0, 0, -1}},
+		{"rotated", []float32{0, 0, 0, 1, 1, 1, 1, 0}, vkm.Vec{0, 1, 0, 1}},
+	}
+
+	for _, tc := range tests {
+		gen, err := uvQuad(tc.uvs...).GenerateTangents()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gen.VertexMap != nil || len(gen.Tangents) != 4 {
+			t.Fatalf("%s: a quad with continuous UVs should not split vertices", tc.name)
+		}
+		for i, tan := range gen.Tangents {
+			// Sub and Dot ignore w, which holds the handedness.
+			if d := add4(tan, tc.expected.Scale(-1)); dot4(d, d) > 1e-8 {
+				t.Errorf("%s: vertex %d: expected tangent %v, got %v", tc.name, i, tc.expected, tan)
+			}
+		}
+	}
+
+	noNormals := uvQuad(0, 1, 1, 1, 1, 0, 0, 0)
+	delete(noNormals.Attributes, NORMAL)
+	if _, err := noNormals.GenerateTangents(); err == nil {
+		t.Error("expected an error for a primitive without normals")
+	}
+}
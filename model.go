@@ -75,14 +75,61 @@ type Node struct {
 	Extras     `json:"extras,omitempty"`
 }
 
+// Material: see https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html#reference-material. Optional fields with
+// non-zero defaults are pointers, so that an absent value can be distinguished from an explicit zero.
 type Material struct {
-	// TODO
+	PbrMetallicRoughness *PbrMetallicRoughness `json:"pbrMetallicRoughness,omitempty"`
+	NormalTexture        *NormalTextureInfo    `json:"normalTexture,omitempty"`
+	OcclusionTexture     *OcclusionTextureInfo `json:"occlusionTexture,omitempty"`
+	EmissiveTexture      *TextureInfo          `json:"emissiveTexture,omitempty"`
+	EmissiveFactor       *vkm.Vec3             `json:"emissiveFactor,omitempty"`
+	AlphaMode            AlphaModeEnum         `json:"alphaMode,omitempty"`
+	AlphaCutoff          *float32              `json:"alphaCutoff,omitempty"`
+	DoubleSided          bool                  `json:"doubleSided,omitempty"`
 
 	Name       GlTFId `json:"name,omitempty"`
 	Extensions `json:"extensions,omitempty"`
 	Extras     `json:"extras,omitempty"`
 }
 
+type PbrMetallicRoughness struct {
+	BaseColorFactor          *vkm.Vec     `json:"baseColorFactor,omitempty"`
+	BaseColorTexture         *TextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor           *float32     `json:"metallicFactor,omitempty"`
+	RoughnessFactor          *float32     `json:"roughnessFactor,omitempty"`
+	MetallicRoughnessTexture *TextureInfo `json:"metallicRoughnessTexture,omitempty"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+// TextureInfo is a reference to a texture, along with the TEXCOORD_n attribute set used to sample it.
+type TextureInfo struct {
+	Index    uint `json:"index"`
+	TexCoord uint `json:"texCoord,omitempty"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type NormalTextureInfo struct {
+	TextureInfo
+	Scale *float32 `json:"scale,omitempty"`
+}
+
+type OcclusionTextureInfo struct {
+	TextureInfo
+	Strength *float32 `json:"strength,omitempty"`
+}
+
+type AlphaModeEnum string
+
+const (
+	OPAQUE AlphaModeEnum = "OPAQUE"
+	MASK   AlphaModeEnum = "MASK"
+	BLEND  AlphaModeEnum = "BLEND"
+)
+
 type Mesh struct {
 	Primitives []Primitive `json:"primitives"`
 	Weights    []float32   `json:"weights"`
@@ -96,9 +143,9 @@ type Primitive struct {
 	Attributes map[AttributeKey]int `json:"attributes"`
 	Indices    *uint                `json:"indices,omitempty"`
 	// May be null, indicating "default" material
-	Material *int      `json:"material,omitempty"`
-	Mode     *ModeEnum `json:"mode,omitempty"`
-	Targets  []string  `json:"targets"` // TODO
+	Material *int                   `json:"material,omitempty"`
+	Mode     *ModeEnum              `json:"mode,omitempty"`
+	Targets  []map[AttributeKey]int `json:"targets,omitempty"`
 
 	Extensions `json:"extensions,omitempty"`
 	Extras     `json:"extras,omitempty"`
@@ -120,6 +167,11 @@ const (
 	WEIGHTS_0               = "WEIGHTS_0"
 )
 
+// TexCoord returns the attribute key for texture coordinate set n, i.e. TEXCOORD_n.
+func TexCoord(n uint) AttributeKey {
+	return AttributeKey(fmt.Sprintf("TEXCOORD_%d", n))
+}
+
 type ModeEnum int
 
 const (
@@ -138,7 +190,7 @@ type Extras map[string]any
 
 // Accessor: see https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html#schema-reference-accessor
 type Accessor struct {
-	BufferView    uint              `json:"bufferView"`
+	BufferView    *uint             `json:"bufferView,omitempty"`
 	ByteOffset    uint              `json:"byteOffset"`
 	ComponentType ComponentTypeEnum `json:"componentType"`
 	Normalized    bool              `json:"normalized"`
@@ -146,7 +198,7 @@ type Accessor struct {
 	Type          AccessorTypeEnum  `json:"type"`
 	Max           []float64         `json:"max"`
 	Min           []float64         `json:"min"`
-	Sparse        SparseAccessor    `json:"sparse"`
+	Sparse        *SparseAccessor   `json:"sparse,omitempty"`
 
 	Name       GlTFId `json:"name,omitempty"`
 	Extensions `json:"extensions,omitempty"`
@@ -335,15 +387,95 @@ type AnimationSampler struct {
 type AnimationSamplerInterpolation string
 
 const (
-	LINEAR       AnimationSamplerInterpolation = "linear"
-	STEP         AnimationSamplerInterpolation = "step"
-	CUBIC_SPLINE AnimationSamplerInterpolation = "cubicspline"
+	LINEAR       AnimationSamplerInterpolation = "LINEAR"
+	STEP         AnimationSamplerInterpolation = "STEP"
+	CUBIC_SPLINE AnimationSamplerInterpolation = "CUBICSPLINE"
+)
+
+type Image struct {
+	Uri        string `json:"uri,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	BufferView *uint  `json:"bufferView,omitempty"`
+
+	Name       GlTFId `json:"name,omitempty"`
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type Sampler struct {
+	MagFilter SamplerFilterEnum `json:"magFilter,omitempty"`
+	MinFilter SamplerFilterEnum `json:"minFilter,omitempty"`
+	WrapS     SamplerWrapEnum   `json:"wrapS,omitempty"`
+	WrapT     SamplerWrapEnum   `json:"wrapT,omitempty"`
+
+	Name       GlTFId `json:"name,omitempty"`
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type SamplerFilterEnum int
+
+const (
+	NEAREST                SamplerFilterEnum = 9728
+	LINEAR_FILTER          SamplerFilterEnum = 9729
+	NEAREST_MIPMAP_NEAREST SamplerFilterEnum = 9984
+	LINEAR_MIPMAP_NEAREST  SamplerFilterEnum = 9985
+	NEAREST_MIPMAP_LINEAR  SamplerFilterEnum = 9986
+	LINEAR_MIPMAP_LINEAR   SamplerFilterEnum = 9987
 )
 
-// Everything below here is TODO
+// SamplerWrapEnum values. Note that the zero value is not a valid wrap mode; an unset wrap mode means REPEAT per the
+// spec.
+type SamplerWrapEnum int
+
+const (
+	CLAMP_TO_EDGE   SamplerWrapEnum = 33071
+	MIRRORED_REPEAT SamplerWrapEnum = 33648
+	REPEAT          SamplerWrapEnum = 10497
+)
+
+type Skin struct {
+	InverseBindMatrices *uint  `json:"inverseBindMatrices,omitempty"`
+	Skeleton            *uint  `json:"skeleton,omitempty"`
+	Joints              []uint `json:"joints"`
+
+	Name       GlTFId `json:"name,omitempty"`
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type Texture struct {
+	Sampler *uint `json:"sampler,omitempty"`
+	Source  *uint `json:"source,omitempty"`
+
+	Name       GlTFId `json:"name,omitempty"`
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
 
-type Image map[string]any
-type Sampler map[string]any
-type Skin map[string]any
-type Texture map[string]any
-type SparseAccessor map[string]any
+// SparseAccessor: see https://registry.khronos.org/glTF/specs/2.0/glTF-2.0.html#reference-accessor-sparse
+type SparseAccessor struct {
+	Count   int                   `json:"count"`
+	Indices SparseAccessorIndices `json:"indices"`
+	Values  SparseAccessorValues  `json:"values"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type SparseAccessorIndices struct {
+	BufferView    uint              `json:"bufferView"`
+	ByteOffset    uint              `json:"byteOffset"`
+	ComponentType ComponentTypeEnum `json:"componentType"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+type SparseAccessorValues struct {
+	BufferView uint `json:"bufferView"`
+	ByteOffset uint `json:"byteOffset"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
@@ -1,6 +1,13 @@
 package gltf
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -32,3 +39,129 @@ func Test_TriangleWithoutIndices(t *testing.T) {
 		t.Log("Unmarshaled and resolved")
 	}
 }
+
+func TestResolveSceneNodes(t *testing.T) {
+	resolved := resolveTestDoc(t, `{
+		"asset": {"version": "2.0"},
+		"scenes": [{"nodes": [2, 0]}],
+		"nodes": [{"name": "a"}, {"name": "b"}, {"name": "c"}]
+	}`, nil)
+	nodes := resolved.Scenes[0].Nodes
+	if len(nodes) != 2 || nodes[0] != &resolved.Nodes[2] || nodes[1] != &resolved.Nodes[0] {
+		t.Errorf("expected the scene to hold nodes c and a")
+	}
+}
+
+// sparseOnlyDoc has an accessor without a buffer view, whose second element is replaced by sparse values, and an
+// unused buffer view.
+const sparseOnlyDoc = `{
+	"asset": {"version": "2.0"},
+	"scenes": [{"nodes": [0]}],
+	"nodes": [{"mesh": 0}],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0}}]}],
+	"accessors": [{
+		"componentType": 5126, "count": 2, "type": "VEC3",
+		"sparse": {"count": 1, "indices": {"bufferView": 1, "componentType": 5121}, "values": {"bufferView": 2}}
+	}],
+	"bufferViews": [
+		{"buffer": 0, "byteLength": 4},
+		{"buffer": 0, "byteOffset": 4, "byteLength": 1},
+		{"buffer": 0, "byteOffset": 8, "byteLength": 12}
+	],
+	"buffers": [{"uri": "sparse.bin", "byteLength": 20}]
+}`
+
+func TestSparseWithoutBufferView(t *testing.T) {
+	data := concatBytes(make([]byte, 4), []byte{1, 0, 0, 0}, float32Bytes(7, 8, 9))
+	resolved := resolveTestDoc(t, sparseOnlyDoc, map[string][]byte{"sparse.bin": data})
+
+	a := &resolved.Accessors[0]
+	if a.BufferView != nil {
+		t.Error("expected no buffer view on the resolved accessor")
+	}
+	values, err := a.ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, []float32{0, 0, 0, 7, 8, 9}) {
+		t.Errorf("expected [0 0 0 7 8 9], got %v", values)
+	}
+
+	report, err := resolved.GlTF.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.BufferViews) != 1 || report.BufferViews[0] != 0 {
+		t.Errorf("expected only the unused buffer view to be removed, got %v", report.BufferViews)
+	}
+	acc := resolved.GlTF.Accessors[0]
+	if acc.BufferView != nil || acc.Sparse.Indices.BufferView != 0 || acc.Sparse.Values.BufferView != 1 {
+		t.Errorf("expected only the sparse buffer views to be remapped, got %+v", acc)
+	}
+
+	b, err := json.Marshal(acc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := fields["bufferView"]; found {
+		t.Errorf("expected no bufferView to be written, got %s", b)
+	}
+}
+
+func TestSparseBufferViewOutOfRange(t *testing.T) {
+	data := concatBytes(make([]byte, 4), []byte{1, 0, 0, 0}, float32Bytes(7, 8, 9))
+	for name, doc := range map[string]string{
+		"indices": strings.Replace(sparseOnlyDoc, `"indices": {"bufferView": 1`, `"indices": {"bufferView": 3`, 1),
+		"values":  strings.Replace(sparseOnlyDoc, `"values": {"bufferView": 2`, `"values": {"bufferView": 3`, 1),
+	} {
+		root, err := FromBytes([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.meta.defaultSearchPath = t.TempDir()
+		if err := os.WriteFile(filepath.Join(root.meta.defaultSearchPath, "sparse.bin"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := root.Resolve(nil); err == nil || !strings.Contains(err.Error(), "sparse "+name+" buffer view 3") {
+			t.Errorf("expected an error for sparse %s in buffer view 3, got %v", name, err)
+		}
+	}
+}
+
+// resolveTestDoc parses a JSON document and resolves it against the given buffer files, which are written to a
+// temporary directory.
+func resolveTestDoc(t *testing.T, doc string, files map[string][]byte) *ResolvedGlTF {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root, err := FromBytes([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.meta.defaultSearchPath = dir
+
+	resolved, err := root.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolved
+}
+
+// float32Bytes encodes values as little-endian floats, as stored in a glTF buffer.
+func float32Bytes(values ...float32) []byte {
+	rval := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		rval = binary.LittleEndian.AppendUint32(rval, math.Float32bits(v))
+	}
+	return rval
+}
@@ -0,0 +1,225 @@
+package gltf
+
+import (
+	"errors"
+	"math"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// NormalOptions controls normal generation by GenerateNormals.
+type NormalOptions struct {
+	// Smooth selects angle-weighted smooth normals. If false, flat normals are generated, with every triangle using its
+	// own face normal.
+	Smooth bool
+	// CreaseAngle is the largest angle, in radians, between two faces that will be smoothed together at a shared
+	// vertex. Zero means no limit, i.e. every face at a vertex is smoothed together. Ignored for flat normals.
+	CreaseAngle float32
+	// WeldDistance is the distance within which two vertices are treated as the same point when smoothing, so that
+	// seams in other attributes (e.g. UVs) do not show as seams in the shading. Zero welds only exactly equal
+	// positions; a negative value disables welding, so that only vertices shared through the index buffer are
+	// smoothed. Ignored for flat normals.
+	WeldDistance float32
+}
+
+// GeneratedNormals holds the result of GenerateNormals.
+type GeneratedNormals struct {
+	// Normals holds one unit normal per vertex. If VertexMap is nil, these are the normals for the primitive's own
+	// vertices; otherwise they are for the new vertices described by VertexMap.
+	Normals []vkm.Vec3
+	// VertexMap is set if some vertices needed more than one normal (always the case for flat normals on an indexed
+	// mesh, or with a crease angle). New vertex i is a copy of source vertex VertexMap[i], and Indices holds the
+	// primitive's triangles in terms of the new vertices.
+	VertexMap []uint32
+	Indices   []uint32
+}
+
+// defaultNormal is used for degenerate triangles and vertices that are not part of any triangle.
+var defaultNormal = vkm.Vec3{0, 0, 1}
+
+// GenerateNormals computes normals for a triangle primitive, as required by the spec when a primitive has no NORMAL
+// attribute. Strips and fans are converted to triangle lists. Use WithNormals to create a primitive with the result.
+func (p *ResolvedPrimitive) GenerateNormals(opts NormalOptions) (*GeneratedNormals, error) {
+	posAcc := p.Attributes[POSITION]
+	if posAcc == nil {
+		return nil, errors.New("Primitive has no POSITION attribute")
+	}
+	pos, err := posAcc.ReadVec3s()
+	if err != nil {
+		return nil, err
+	}
+	tris, err := p.TriangleIndices()
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range tris {
+		if int(idx) >= len(pos) {
+			return nil, errors.New("Primitive index is out of range of its POSITION attribute")
+		}
+	}
+
+	faceNormals := make([]vkm.Vec3, len(tris)/3)
+	for f := range faceNormals {
+		a, b, c := pos[tris[3*f]], pos[tris[3*f+1]], pos[tris[3*f+2]]
+		faceNormals[f] = normalizeOr(cross3(b.Sub(a), c.Sub(a)), defaultNormal)
+	}
+
+	if !opts.Smooth {
+		rval := &GeneratedNormals{
+			Normals:   make([]vkm.Vec3, len(tris)),
+			VertexMap: append([]uint32(nil), tris...),
+			Indices:   make([]uint32, len(tris)),
+		}
+		for i := range tris {
+			rval.Normals[i] = faceNormals[i/3]
+			rval.Indices[i] = uint32(i)
+		}
+		return rval, nil
+	}
+
+	// Collect the faces around each welded point, along with the angle of each face at that point.
+	type incidence struct {
+		face  int
+		angle float32
+	}
+	group := weldPositions(pos, opts.WeldDistance)
+	faces := make(map[uint32][]incidence)
+	for f := range faceNormals {
+		for k := 0; k < 3; k++ {
+			v := pos[tris[3*f+k]]
+			e1 := normalizeOr(pos[tris[3*f+(k+1)%3]].Sub(v), vkm.Vec3{})
+			e2 := normalizeOr(pos[tris[3*f+(k+2)%3]].Sub(v), vkm.Vec3{})
+			g := group[tris[3*f+k]]
+			faces[g] = append(faces[g], incidence{f, angleBetween(e1, e2)})
+		}
+	}
+
+	cosCrease := float32(-1)
+	if opts.CreaseAngle > 0 && opts.CreaseAngle < math32.Pi {
+		cosCrease = math32.Cos(opts.CreaseAngle)
+	}
+
+	cornerNormals := make([]vkm.Vec3, len(tris))
+	for f := range faceNormals {
+		for k := 0; k < 3; k++ {
+			var sum vkm.Vec3
+			for _, inc := range faces[group[tris[3*f+k]]] {
+				if inc.face == f || faceNormals[inc.face].Dot(faceNormals[f]) >= cosCrease-1e-6 {
+					sum = sum.Add(faceNormals[inc.face].Scale(inc.angle))
+				}
+			}
+			cornerNormals[3*f+k] = normalizeOr(sum, faceNormals[f])
+		}
+	}
+
+	rval := &GeneratedNormals{}
+	rval.Normals, rval.VertexMap, rval.Indices = collapseCorners(tris, len(pos), cornerNormals, defaultNormal)
+	return rval, nil
+}
+
+// collapseCorners converts a value for each triangle corner into a value for each vertex. Each source vertex gets one
+// output vertex per distinct value at its corners. If no vertex needs more than one, then the returned values are for
+// the source vertices, and vertexMap and indices are nil; vertices that are not part of any triangle get fallback.
+// Otherwise, new vertex i is a copy of source vertex vertexMap[i], and indices holds the triangles in terms of the new
+// vertices.
+func collapseCorners[T comparable](tris []uint32, vertexCount int, corners []T, fallback T) (values []T, vertexMap, indices []uint32) {
+	type vertexKey struct {
+		source uint32
+		value  T
+	}
+	outIndex := make(map[vertexKey]uint32)
+	indices = make([]uint32, len(tris))
+	for i, src := range tris {
+		key := vertexKey{src, corners[i]}
+		idx, found := outIndex[key]
+		if !found {
+			idx = uint32(len(values))
+			outIndex[key] = idx
+			values = append(values, corners[i])
+			vertexMap = append(vertexMap, src)
+		}
+		indices[i] = idx
+	}
+
+	perVertex := make([]T, vertexCount)
+	for i := range perVertex {
+		perVertex[i] = fallback
+	}
+	seen := make([]bool, vertexCount)
+	for i, src := range vertexMap {
+		if seen[src] {
+			return values, vertexMap, indices
+		}
+		seen[src] = true
+		perVertex[src] = values[i]
+	}
+	return perVertex, nil, nil
+}
+
+// WithNormals returns a copy of the primitive with a NORMAL attribute holding the generated normals. If the normals
+// required new vertices, every other attribute is copied to match, as with WithVertices.
+func (p *ResolvedPrimitive) WithNormals(n *GeneratedNormals) (*ResolvedPrimitive, error) {
+	return p.withGenerated(NORMAL, NewFloatAccessor(VEC3, flattenVec3s(n.Normals)), n.VertexMap, n.Indices)
+}
+
+// weldPositions groups positions that are within distance of each other, returning for each position the index of the
+// first position in its group. A distance of zero groups only identical positions, and a negative distance disables
+// grouping entirely.
+func weldPositions(pos []vkm.Vec3, distance float32) []uint32 {
+	rval := make([]uint32, len(pos))
+	if distance < 0 {
+		for i := range rval {
+			rval[i] = uint32(i)
+		}
+		return rval
+	}
+
+	if distance == 0 {
+		first := make(map[vkm.Vec3]uint32, len(pos))
+		for i, p := range pos {
+			if f, found := first[p]; found {
+				rval[i] = f
+			} else {
+				first[p] = uint32(i)
+				rval[i] = uint32(i)
+			}
+		}
+		return rval
+	}
+
+	// Hash into a grid with cells the size of the weld distance, so that any match must be in one of the 27 cells
+	// around a point.
+	type cell [3]int64
+	cellOf := func(p vkm.Vec3) cell {
+		return cell{
+			int64(math.Floor(float64(p[0] / distance))),
+			int64(math.Floor(float64(p[1] / distance))),
+			int64(math.Floor(float64(p[2] / distance))),
+		}
+	}
+	grid := make(map[cell][]uint32)
+	d2 := distance * distance
+	for i, p := range pos {
+		c := cellOf(p)
+		rval[i] = uint32(i)
+		found := false
+		for dx := int64(-1); dx <= 1 && !found; dx++ {
+			for dy := int64(-1); dy <= 1 && !found; dy++ {
+				for dz := int64(-1); dz <= 1 && !found; dz++ {
+					for _, j := range grid[cell{c[0] + dx, c[1] + dy, c[2] + dz}] {
+						if pos[j].Sub(p).SquareLength() <= d2 {
+							rval[i] = j
+							found = true
+							break
+						}
+					}
+				}
+			}
+		}
+		if !found {
+			grid[c] = append(grid[c], uint32(i))
+		}
+	}
+	return rval
+}
@@ -0,0 +1,73 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// cubeCornerDoc is two triangles of a unit cube meeting at a right angle along the edge from (0,0,0) to (1,0,0): one
+// in the XY plane facing +Z, and one in the XZ plane facing -Y. Vertices 0 and 1 are shared.
+const cubeCornerDoc = `{
+	"asset": {"version": "2.0"},
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0}, "indices": 1}]}],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 4, "type": "VEC3"},
+		{"bufferView": 0, "byteOffset": 48, "componentType": 5125, "count": 6, "type": "SCALAR"}
+	],
+	"bufferViews": [{"buffer": 0, "byteLength": 72}],
+	"buffers": [{"uri": "corner.bin", "byteLength": 72}]
+}`
+
+func cubeCorner(t *testing.T) *ResolvedPrimitive {
+	data := float32Bytes(0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, -1)
+	for _, idx := range []uint32{0, 1, 2, 1, 0, 3} {
+		data = append(data, byte(idx), 0, 0, 0)
+	}
+	resolved := resolveTestDoc(t, cubeCornerDoc, map[string][]byte{"corner.bin": data})
+	return &resolved.Meshes[0].Primitives[0]
+}
+
+func approxVec3(a, b vkm.Vec3) bool {
+	d := a.Sub(b)
+	return d.Dot(d) < 1e-8
+}
+
+func TestGenerateNormals(t *testing.T) {
+	prim := cubeCorner(t)
+
+	flat, err := prim.GenerateNormals(NormalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flat.Normals) != 6 || !approxVec3(flat.Normals[0], vkm.Vec3{0, 0, 1}) || !approxVec3(flat.Normals[3], vkm.Vec3{0, -1, 0}) {
+		t.Errorf("unexpected flat normals: %v", flat.Normals)
+	}
+
+	smooth, err := prim.GenerateNormals(NormalOptions{Smooth: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smooth.VertexMap != nil || len(smooth.Normals) != 4 {
+		t.Fatalf("smooth normals without a crease should not split vertices")
+	}
+	if n := smooth.Normals[0]; !approxVec3(n, vkm.Vec3{0, -0.70710677, 0.70710677}) {
+		t.Errorf("shared vertex normal should be halfway between +Z and -Y, got %v", n)
+	}
+
+	creased, err := prim.GenerateNormals(NormalOptions{Smooth: true, CreaseAngle: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creased.Normals) != 6 || creased.VertexMap == nil {
+		t.Fatalf("a right angle crease should split both shared vertices, got %d vertices", len(creased.Normals))
+	}
+
+	withNormals, err := prim.WithNormals(creased)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withNormals.Attributes[NORMAL].Count != 6 || withNormals.Attributes[POSITION].Count != 6 || withNormals.Indices.Count != 6 {
+		t.Errorf("WithNormals did not rebuild the primitive's vertices")
+	}
+}
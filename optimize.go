@@ -0,0 +1,323 @@
+package gltf
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/bbredesen/vkm"
+)
+
+// DefaultCacheSize is the vertex cache size used by Optimize when none is given. Post-transform caches on current
+// hardware are not true FIFOs, but optimizing for a FIFO of this size gives good results across vendors.
+const DefaultCacheSize = 16
+
+// OptimizeOptions controls Optimize.
+type OptimizeOptions struct {
+	// CacheSize is the size of the simulated FIFO vertex cache, used both for optimization and for the reported
+	// statistics. Zero selects DefaultCacheSize.
+	CacheSize int
+	// OverdrawThreshold enables overdraw optimization if greater than zero. Triangles are reordered to be drawn
+	// roughly front to back from any viewpoint, at the cost of allowing the ACMR to grow by up to this factor; 1.05 is
+	// a typical value. Requires a POSITION attribute.
+	OverdrawThreshold float32
+}
+
+// CacheStats describes how well an index buffer uses a FIFO vertex cache.
+type CacheStats struct {
+	// Transforms is the number of times a vertex must be processed by the vertex shader, i.e. the number of cache misses.
+	Transforms int
+	// ACMR is the average cache miss ratio: transforms per triangle. The best possible is about 0.5 for large regular
+	// meshes, and the worst is 3.
+	ACMR float32
+	// ATVR is the average transform to vertex ratio: transforms per referenced vertex. The best possible is 1.
+	ATVR float32
+}
+
+// OptimizeReport holds the vertex cache statistics of a primitive before and after Optimize.
+type OptimizeReport struct {
+	Before, After CacheStats
+}
+
+// Optimize returns a copy of the primitive, in TRIANGLES mode, optimized for rendering. Triangles are reordered for the
+// vertex cache with OptimizeVertexCache, and optionally for overdraw with OptimizeOverdraw; then vertices are reordered
+// into the order they are first used with OptimizeVertexFetch, with every attribute remapped to match. Vertices that
+// are not used by any triangle are dropped.
+func (p *ResolvedPrimitive) Optimize(opts OptimizeOptions) (*ResolvedPrimitive, OptimizeReport, error) {
+	var report OptimizeReport
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = DefaultCacheSize
+	}
+
+	tris, err := p.TriangleIndices()
+	if err != nil {
+		return nil, report, err
+	}
+	vertexCount := p.VertexCount()
+	for _, idx := range tris {
+		if int(idx) >= vertexCount {
+			return nil, report, errors.New("Primitive index is out of range of its attributes")
+		}
+	}
+	report.Before = AnalyzeVertexCache(tris, vertexCount, opts.CacheSize)
+
+	tris = OptimizeVertexCache(tris, vertexCount, opts.CacheSize)
+
+	if opts.OverdrawThreshold > 0 {
+		posAcc := p.Attributes[POSITION]
+		if posAcc == nil {
+			return nil, report, errors.New("Overdraw optimization requires a POSITION attribute")
+		}
+		pos, err := posAcc.ReadVec3s()
+		if err != nil {
+			return nil, report, err
+		}
+		tris = OptimizeOverdraw(tris, pos, opts.CacheSize, opts.OverdrawThreshold)
+	}
+
+	vertexMap, tris := OptimizeVertexFetch(tris, vertexCount)
+	report.After = AnalyzeVertexCache(tris, len(vertexMap), opts.CacheSize)
+
+	rval, err := p.WithVertices(vertexMap, tris)
+	return rval, report, err
+}
+
+// AnalyzeVertexCache simulates drawing a triangle list through a FIFO vertex cache of the given size.
+func AnalyzeVertexCache(indices []uint32, vertexCount, cacheSize int) CacheStats {
+	var rval CacheStats
+	if len(indices) < 3 {
+		return rval
+	}
+
+	// A vertex is in the cache if it was added within the last cacheSize misses.
+	addedAt := make([]int, vertexCount)
+	referenced := 0
+	for _, v := range indices {
+		if addedAt[v] == 0 {
+			referenced++
+		}
+		if addedAt[v] == 0 || rval.Transforms+1-addedAt[v] > cacheSize {
+			rval.Transforms++
+			addedAt[v] = rval.Transforms
+		}
+	}
+
+	rval.ACMR = float32(rval.Transforms) / float32(len(indices)/3)
+	rval.ATVR = float32(rval.Transforms) / float32(referenced)
+	return rval
+}
+
+// OptimizeVertexCache reorders a triangle list to reduce vertex cache misses, using the Tipsify algorithm (Sander,
+// Nehab and Barczak, "Fast Triangle Reordering for Vertex Locality and Reduced Overdraw", 2007). The triangles
+// themselves, including their winding, are unchanged.
+func OptimizeVertexCache(indices []uint32, vertexCount, cacheSize int) []uint32 {
+	triCount := len(indices) / 3
+
+	// Triangles using each vertex, and the number of those not yet emitted.
+	offsets := make([]int, vertexCount+1)
+	for _, v := range indices[:3*triCount] {
+		offsets[v+1]++
+	}
+	for v := 0; v < vertexCount; v++ {
+		offsets[v+1] += offsets[v]
+	}
+	live := make([]int, vertexCount)
+	adjacency := make([]int, 3*triCount)
+	for i, v := range indices[:3*triCount] {
+		adjacency[offsets[v]+live[v]] = i / 3
+		live[v]++
+	}
+
+	rval := make([]uint32, 0, 3*triCount)
+	emitted := make([]bool, triCount)
+	cacheTime := make([]int, vertexCount)
+	var deadEnd []uint32
+	stamp, cursor := cacheSize+1, 0
+
+	nextVertex := func(candidates []uint32) int {
+		best, bestPriority := -1, -1
+		for _, v := range candidates {
+			if live[v] == 0 {
+				continue
+			}
+			// Prefer the vertex that entered the cache longest ago, as long as fanning around it will not push it out.
+			priority := 0
+			if stamp-cacheTime[v]+2*live[v] <= cacheSize {
+				priority = stamp - cacheTime[v]
+			}
+			if priority > bestPriority {
+				best, bestPriority = int(v), priority
+			}
+		}
+		if best >= 0 {
+			return best
+		}
+
+		for len(deadEnd) > 0 {
+			v := deadEnd[len(deadEnd)-1]
+			deadEnd = deadEnd[:len(deadEnd)-1]
+			if live[v] > 0 {
+				return int(v)
+			}
+		}
+		for ; cursor < vertexCount; cursor++ {
+			if live[cursor] > 0 {
+				return cursor
+			}
+		}
+		return -1
+	}
+
+	var candidates []uint32
+	for fan := nextVertex(nil); fan >= 0; fan = nextVertex(candidates) {
+		candidates = candidates[:0]
+		for _, t := range adjacency[offsets[fan]:offsets[fan+1]] {
+			if emitted[t] {
+				continue
+			}
+			emitted[t] = true
+			for _, v := range indices[3*t : 3*t+3] {
+				rval = append(rval, v)
+				deadEnd = append(deadEnd, v)
+				candidates = append(candidates, v)
+				live[v]--
+				if stamp-cacheTime[v] > cacheSize {
+					cacheTime[v] = stamp
+					stamp++
+				}
+			}
+		}
+	}
+
+	return rval
+}
+
+// OptimizeOverdraw reorders a triangle list, which should already be optimized for the vertex cache, to reduce
+// overdraw. The list is split into clusters at points where the cache would be cold anyway, and where the ACMR within
+// a cluster stays within threshold times that of the whole hard cluster; the clusters are then sorted so that those
+// facing away from the centre of the mesh, which are likely to occlude others, are drawn first.
+func OptimizeOverdraw(indices []uint32, positions []vkm.Vec3, cacheSize int, threshold float32) []uint32 {
+	triCount := len(indices) / 3
+	if triCount == 0 {
+		return append([]uint32(nil), indices...)
+	}
+
+	// Hard boundaries are triangles whose vertices all miss the cache.
+	addedAt := make([]int, len(positions))
+	misses := 0
+	missesOf := func(t int) int {
+		n := 0
+		for _, v := range indices[3*t : 3*t+3] {
+			if addedAt[v] == 0 || misses+1-addedAt[v] > cacheSize {
+				misses++
+				addedAt[v] = misses
+				n++
+			}
+		}
+		return n
+	}
+	resetCache := func() {
+		for i := range addedAt {
+			addedAt[i] = 0
+		}
+		misses = 0
+	}
+
+	triMisses := make([]int, triCount)
+	var hard []int
+	for t := 0; t < triCount; t++ {
+		triMisses[t] = missesOf(t)
+		if t == 0 || triMisses[t] == 3 {
+			hard = append(hard, t)
+		}
+	}
+	hard = append(hard, triCount)
+
+	// Soft boundaries split hard clusters wherever the ACMR so far is good enough.
+	var clusters []int
+	for h := 0; h+1 < len(hard); h++ {
+		start, end := hard[h], hard[h+1]
+		total := 0
+		for t := start; t < end; t++ {
+			total += triMisses[t]
+		}
+		limit := threshold * float32(total) / float32(end-start)
+
+		resetCache()
+		clusters = append(clusters, start)
+		clusterStart, clusterMisses := start, 0
+		for t := start; t < end; t++ {
+			clusterMisses += missesOf(t)
+			if t+1 < end && float32(clusterMisses)/float32(t+1-clusterStart) <= limit {
+				clusters = append(clusters, t+1)
+				clusterStart, clusterMisses = t+1, 0
+				resetCache()
+			}
+		}
+	}
+	clusters = append(clusters, triCount)
+
+	var meshCentroid vkm.Vec3
+	var meshArea float32
+	for t := 0; t < triCount; t++ {
+		a, b, c := positions[indices[3*t]], positions[indices[3*t+1]], positions[indices[3*t+2]]
+		area := cross3(b.Sub(a), c.Sub(a)).Length()
+		meshCentroid = meshCentroid.Add(a.Add(b).Add(c).Scale(area / 3))
+		meshArea += area
+	}
+	if meshArea > 0 {
+		meshCentroid = meshCentroid.Scale(1 / meshArea)
+	}
+
+	type cluster struct {
+		start, end int
+		sortKey    float32
+	}
+	sorted := make([]cluster, len(clusters)-1)
+	for i := range sorted {
+		c := cluster{start: clusters[i], end: clusters[i+1]}
+		var centroid, normal vkm.Vec3
+		var area float32
+		for t := c.start; t < c.end; t++ {
+			p0, p1, p2 := positions[indices[3*t]], positions[indices[3*t+1]], positions[indices[3*t+2]]
+			n := cross3(p1.Sub(p0), p2.Sub(p0))
+			a := n.Length()
+			centroid = centroid.Add(p0.Add(p1).Add(p2).Scale(a / 3))
+			normal = normal.Add(n)
+			area += a
+		}
+		if area > 0 {
+			centroid = centroid.Scale(1 / area)
+		}
+		c.sortKey = normalizeOr(normal, vkm.Vec3{}).Dot(centroid.Sub(meshCentroid))
+		sorted[i] = c
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].sortKey > sorted[j].sortKey
+	})
+
+	rval := make([]uint32, 0, 3*triCount)
+	for _, c := range sorted {
+		rval = append(rval, indices[3*c.start:3*c.end]...)
+	}
+	return rval
+}
+
+// OptimizeVertexFetch renumbers vertices in the order they are first used by indices, so that vertex data is read
+// sequentially. It returns the source vertex for each new vertex, suitable for WithVertices, and the indices in terms
+// of the new vertices. Vertices not used by indices are dropped.
+func OptimizeVertexFetch(indices []uint32, vertexCount int) (vertexMap, newIndices []uint32) {
+	remap := make([]uint32, vertexCount)
+	for i := range remap {
+		remap[i] = ^uint32(0)
+	}
+
+	newIndices = make([]uint32, len(indices))
+	for i, v := range indices {
+		if remap[v] == ^uint32(0) {
+			remap[v] = uint32(len(vertexMap))
+			vertexMap = append(vertexMap, v)
+		}
+		newIndices[i] = remap[v]
+	}
+	return vertexMap, newIndices
+}
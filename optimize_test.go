@@ -0,0 +1,87 @@
+package gltf
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// shuffledGrid returns an n by n grid of quads in the XY plane, with its triangles in a random order.
+func shuffledGrid(n int) *ResolvedPrimitive {
+	var pos []float32
+	for y := 0; y <= n; y++ {
+		for x := 0; x <= n; x++ {
+			pos = append(pos, float32(x), float32(y), 0)
+		}
+	}
+	var tris [][3]uint32
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			v := uint32(y*(n+1) + x)
+			tris = append(tris, [3]uint32{v, v + 1, v + uint32(n) + 2}, [3]uint32{v, v + uint32(n) + 2, v + uint32(n) + 1})
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(tris), func(i, j int) { tris[i], tris[j] = tris[j], tris[i] })
+
+	var indices []uint32
+	for _, t := range tris {
+		indices = append(indices, t[:]...)
+	}
+	return &ResolvedPrimitive{
+		Primitive:  &Primitive{},
+		Mode:       TRIANGLES,
+		Attributes: map[AttributeKey]*ResolvedAccessor{POSITION: NewFloatAccessor(VEC3, pos)},
+		Indices:    NewIndexAccessor(indices),
+	}
+}
+
+// triangleSet returns the position-space triangles of a primitive in a canonical order, for comparison.
+func triangleSet(t *testing.T, p *ResolvedPrimitive) []string {
+	pos, err := p.Attributes[POSITION].ReadVec3s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indices, err := p.TriangleIndices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rval []string
+	for i := 0; i < len(indices); i += 3 {
+		rval = append(rval, fmt.Sprint(pos[indices[i]], pos[indices[i+1]], pos[indices[i+2]]))
+	}
+	sort.Strings(rval)
+	return rval
+}
+
+func TestOptimize(t *testing.T) {
+	prim := shuffledGrid(20)
+	expected := triangleSet(t, prim)
+
+	for _, opts := range []OptimizeOptions{{}, {OverdrawThreshold: 1.05}} {
+		optimized, report, err := prim.Optimize(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.After.ACMR >= report.Before.ACMR || report.After.ACMR > 1 {
+			t.Errorf("%+v: expected a large ACMR improvement, got %+v", opts, report)
+		}
+		if report.After.ATVR < 1 || report.After.ATVR > report.Before.ATVR {
+			t.Errorf("%+v: unexpected ATVR: %+v", opts, report)
+		}
+		if !reflect.DeepEqual(triangleSet(t, optimized), expected) {
+			t.Errorf("%+v: optimization changed the set of triangles", opts)
+		}
+
+		indices, _ := optimized.Indices.ReadIndices()
+		next := uint32(0)
+		for _, idx := range indices {
+			if idx > next {
+				t.Fatalf("%+v: vertices are not in order of first use", opts)
+			} else if idx == next {
+				next++
+			}
+		}
+	}
+}
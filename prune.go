@@ -0,0 +1,390 @@
+package gltf
+
+import (
+	"fmt"
+)
+
+// PruneReport lists the original indices of every object removed by Prune, grouped by object type.
+type PruneReport struct {
+	Accessors   []uint
+	Buffers     []uint
+	BufferViews []uint
+	Cameras     []uint
+	Images      []uint
+	Materials   []uint
+	Meshes      []uint
+	Nodes       []uint
+	Samplers    []uint
+	Skins       []uint
+	Textures    []uint
+}
+
+// Count returns the total number of objects removed.
+func (r PruneReport) Count() int {
+	return len(r.Accessors) + len(r.Buffers) + len(r.BufferViews) + len(r.Cameras) + len(r.Images) +
+		len(r.Materials) + len(r.Meshes) + len(r.Nodes) + len(r.Samplers) + len(r.Skins) + len(r.Textures)
+}
+
+// Prune removes every object that can not be reached from the document's scenes or animations, compacts all of the
+// arrays in the document, and remaps all indexed references to match. Scenes and animations are always kept. If the
+// document has no scenes, then every node without a parent is treated as a root instead.
+//
+// Prune modifies the GlTF in place and must be called before Resolve; any ResolvedGlTF created earlier will no longer
+// match the document. Binary data is not modified, so a pruned buffer view leaves unused bytes in its buffer. Objects
+// that are only referenced from inside an extension are not seen by this function and will be removed, except for the
+// compressed buffers of EXT_meshopt_compression, the buffer views of KHR_draco_mesh_compression, the accessors of
+// EXT_mesh_gpu_instancing, the materials of KHR_materials_variants, and the textures of the material extensions resolved
+// on ResolvedMaterial.
+//
+// An error is returned, and the document is left unmodified, if any reference points outside of its target array or an
+// extension that holds references can not be decoded.
+func (gltf *GlTF) Prune() (PruneReport, error) {
+	// Remapping decodes these too, but that is after the arrays are compacted.
+	if err := gltf.decodeReferenceExtensions(); err != nil {
+		return PruneReport{}, err
+	}
+	r := newReachability(gltf)
+	if err := r.walk(); err != nil {
+		return PruneReport{}, err
+	}
+
+	var report PruneReport
+	var m indexMaps
+
+	gltf.Accessors, m.accessors, report.Accessors = compact(gltf.Accessors, r.accessors)
+	gltf.Buffers, m.buffers, report.Buffers = compact(gltf.Buffers, r.buffers)
+	gltf.BufferViews, m.bufferViews, report.BufferViews = compact(gltf.BufferViews, r.bufferViews)
+	gltf.Cameras, m.cameras, report.Cameras = compact(gltf.Cameras, r.cameras)
+	gltf.Images, m.images, report.Images = compact(gltf.Images, r.images)
+	gltf.Materials, m.materials, report.Materials = compact(gltf.Materials, r.materials)
+	gltf.Meshes, m.meshes, report.Meshes = compact(gltf.Meshes, r.meshes)
+	gltf.Nodes, m.nodes, report.Nodes = compact(gltf.Nodes, r.nodes)
+	gltf.Samplers, m.samplers, report.Samplers = compact(gltf.Samplers, r.samplers)
+	gltf.Skins, m.skins, report.Skins = compact(gltf.Skins, r.skins)
+	gltf.Textures, m.textures, report.Textures = compact(gltf.Textures, r.textures)
+
+	if err := gltf.remapReferences(m); err != nil {
+		return PruneReport{}, err
+	}
+
+	return report, nil
+}
+
+// textureInfos returns pointers to every TextureInfo referenced by the material, including those in its material
+// extensions.
+func (m *Material) textureInfos() ([]*TextureInfo, error) {
+	var rval []*TextureInfo
+	if pbr := m.PbrMetallicRoughness; pbr != nil {
+		if pbr.BaseColorTexture != nil {
+			rval = append(rval, pbr.BaseColorTexture)
+		}
+		if pbr.MetallicRoughnessTexture != nil {
+			rval = append(rval, pbr.MetallicRoughnessTexture)
+		}
+	}
+	if m.NormalTexture != nil {
+		rval = append(rval, &m.NormalTexture.TextureInfo)
+	}
+	if m.OcclusionTexture != nil {
+		rval = append(rval, &m.OcclusionTexture.TextureInfo)
+	}
+	if m.EmissiveTexture != nil {
+		rval = append(rval, m.EmissiveTexture)
+	}
+	ext, err := m.extensionTextureInfos()
+	if err != nil {
+		return nil, err
+	}
+	return append(rval, ext...), nil
+}
+
+// reachability holds one flag per object in a document, set when that object is referenced from a root.
+type reachability struct {
+	gltf *GlTF
+
+	accessors, buffers, bufferViews, cameras, images, materials, meshes, nodes, samplers, skins, textures []bool
+}
+
+func newReachability(gltf *GlTF) *reachability {
+	return &reachability{
+		gltf:        gltf,
+		accessors:   make([]bool, len(gltf.Accessors)),
+		buffers:     make([]bool, len(gltf.Buffers)),
+		bufferViews: make([]bool, len(gltf.BufferViews)),
+		cameras:     make([]bool, len(gltf.Cameras)),
+		images:      make([]bool, len(gltf.Images)),
+		materials:   make([]bool, len(gltf.Materials)),
+		meshes:      make([]bool, len(gltf.Meshes)),
+		nodes:       make([]bool, len(gltf.Nodes)),
+		samplers:    make([]bool, len(gltf.Samplers)),
+		skins:       make([]bool, len(gltf.Skins)),
+		textures:    make([]bool, len(gltf.Textures)),
+	}
+}
+
+func (r *reachability) walk() error {
+	if len(r.gltf.Scenes) > 0 {
+		for i, s := range r.gltf.Scenes {
+			for _, n := range s.Nodes {
+				if err := r.markNode(n); err != nil {
+					return fmt.Errorf("Scene %d: %w", i, err)
+				}
+			}
+		}
+	} else {
+		for _, n := range r.gltf.parentlessNodes() {
+			if err := r.markNode(n); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, a := range r.gltf.Animations {
+		for _, s := range a.Samplers {
+			if err := r.markAccessor(s.Input); err != nil {
+				return fmt.Errorf("Animation %d: %w", i, err)
+			}
+			if err := r.markAccessor(s.Output); err != nil {
+				return fmt.Errorf("Animation %d: %w", i, err)
+			}
+		}
+		for _, ch := range a.Channels {
+			if ch.Target.Node != nil {
+				if err := r.markNode(*ch.Target.Node); err != nil {
+					return fmt.Errorf("Animation %d: %w", i, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *reachability) markNode(idx uint) error {
+	if idx >= uint(len(r.nodes)) {
+		return fmt.Errorf("Node index %d out of range", idx)
+	}
+	if r.nodes[idx] {
+		return nil
+	}
+	r.nodes[idx] = true
+
+	n := &r.gltf.Nodes[idx]
+	if n.Camera != nil {
+		if *n.Camera < 0 || *n.Camera >= len(r.cameras) {
+			return fmt.Errorf("Node %d: camera index %d out of range", idx, *n.Camera)
+		}
+		r.cameras[*n.Camera] = true
+	}
+	if n.Mesh != nil {
+		if err := r.markMesh(*n.Mesh); err != nil {
+			return fmt.Errorf("Node %d: %w", idx, err)
+		}
+	}
+	instanceAttributes, err := n.instancingAccessors()
+	if err != nil {
+		return fmt.Errorf("Node %d: %w", idx, err)
+	}
+	for _, a := range instanceAttributes {
+		if err := r.markAccessor(a); err != nil {
+			return fmt.Errorf("Node %d: %s: %w", idx, EXT_MESH_GPU_INSTANCING, err)
+		}
+	}
+	if n.Skin != nil {
+		if err := r.markSkin(*n.Skin); err != nil {
+			return fmt.Errorf("Node %d: %w", idx, err)
+		}
+	}
+	for _, c := range n.Children {
+		if err := r.markNode(c); err != nil {
+			return err
+		}
+	}
+	lods, err := n.lodNodes()
+	if err != nil {
+		return fmt.Errorf("Node %d: %w", idx, err)
+	}
+	for _, lod := range lods {
+		if err := r.markNode(lod); err != nil {
+			return fmt.Errorf("Node %d: MSFT_lod: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+func (r *reachability) markSkin(idx int) error {
+	if idx < 0 || idx >= len(r.skins) {
+		return fmt.Errorf("Skin index %d out of range", idx)
+	}
+	if r.skins[idx] {
+		return nil
+	}
+	r.skins[idx] = true
+
+	s := &r.gltf.Skins[idx]
+	if s.InverseBindMatrices != nil {
+		if err := r.markAccessor(int(*s.InverseBindMatrices)); err != nil {
+			return fmt.Errorf("Skin %d: %w", idx, err)
+		}
+	}
+	if s.Skeleton != nil {
+		if err := r.markNode(*s.Skeleton); err != nil {
+			return fmt.Errorf("Skin %d: %w", idx, err)
+		}
+	}
+	for _, j := range s.Joints {
+		if err := r.markNode(j); err != nil {
+			return fmt.Errorf("Skin %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+func (r *reachability) markMesh(idx uint) error {
+	if idx >= uint(len(r.meshes)) {
+		return fmt.Errorf("Mesh index %d out of range", idx)
+	}
+	if r.meshes[idx] {
+		return nil
+	}
+	r.meshes[idx] = true
+
+	for i, p := range r.gltf.Meshes[idx].Primitives {
+		for _, a := range p.Attributes {
+			if err := r.markAccessor(a); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+			}
+		}
+		for _, target := range p.Targets {
+			for _, a := range target {
+				if err := r.markAccessor(a); err != nil {
+					return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+				}
+			}
+		}
+		if p.Indices != nil {
+			if err := r.markAccessor(int(*p.Indices)); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+			}
+		}
+		if p.Material != nil {
+			if err := r.markMaterial(*p.Material); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+			}
+		}
+		mappings, err := r.gltf.Meshes[idx].Primitives[i].variantMappings()
+		if err != nil {
+			return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+		}
+		for _, mapping := range mappings {
+			if err := r.markMaterial(int(mapping.Material)); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %s: %w", idx, i, KHR_MATERIALS_VARIANTS, err)
+			}
+		}
+		if ext, _, err := GetExtension[KHRDracoMeshCompression](&r.gltf.Meshes[idx].Primitives[i]); err != nil {
+			return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+		} else if ext != nil {
+			if err := r.markBufferView(ext.BufferView); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %w", idx, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *reachability) markMaterial(idx int) error {
+	if idx < 0 || idx >= len(r.materials) {
+		return fmt.Errorf("Material index %d out of range", idx)
+	}
+	if r.materials[idx] {
+		return nil
+	}
+	r.materials[idx] = true
+
+	textureInfos, err := r.gltf.Materials[idx].textureInfos()
+	if err != nil {
+		return fmt.Errorf("Material %d: %w", idx, err)
+	}
+	for _, ti := range textureInfos {
+		if err := r.markTexture(ti.Index); err != nil {
+			return fmt.Errorf("Material %d: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+func (r *reachability) markTexture(idx uint) error {
+	if idx >= uint(len(r.textures)) {
+		return fmt.Errorf("Texture index %d out of range", idx)
+	}
+	r.textures[idx] = true
+
+	t := &r.gltf.Textures[idx]
+	if t.Sampler != nil {
+		if *t.Sampler >= uint(len(r.samplers)) {
+			return fmt.Errorf("Texture %d: sampler index %d out of range", idx, *t.Sampler)
+		}
+		r.samplers[*t.Sampler] = true
+	}
+	if t.Source != nil {
+		if *t.Source >= uint(len(r.images)) {
+			return fmt.Errorf("Texture %d: image index %d out of range", idx, *t.Source)
+		}
+		r.images[*t.Source] = true
+		if bv := r.gltf.Images[*t.Source].BufferView; bv != nil {
+			if err := r.markBufferView(*bv); err != nil {
+				return fmt.Errorf("Image %d: %w", *t.Source, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *reachability) markAccessor(idx int) error {
+	if idx < 0 || idx >= len(r.accessors) {
+		return fmt.Errorf("Accessor index %d out of range", idx)
+	}
+	if r.accessors[idx] {
+		return nil
+	}
+	r.accessors[idx] = true
+
+	a := &r.gltf.Accessors[idx]
+	if a.BufferView != nil {
+		if err := r.markBufferView(*a.BufferView); err != nil {
+			return fmt.Errorf("Accessor %d: %w", idx, err)
+		}
+	}
+	if a.Sparse != nil {
+		if err := r.markBufferView(a.Sparse.Indices.BufferView); err != nil {
+			return fmt.Errorf("Accessor %d sparse indices: %w", idx, err)
+		}
+		if err := r.markBufferView(a.Sparse.Values.BufferView); err != nil {
+			return fmt.Errorf("Accessor %d sparse values: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+func (r *reachability) markBufferView(idx uint) error {
+	if idx >= uint(len(r.bufferViews)) {
+		return fmt.Errorf("BufferView index %d out of range", idx)
+	}
+	r.bufferViews[idx] = true
+
+	buf := r.gltf.BufferViews[idx].Buffer
+	if buf >= uint(len(r.buffers)) {
+		return fmt.Errorf("BufferView %d: buffer index %d out of range", idx, buf)
+	}
+	r.buffers[buf] = true
+
+	if ext, _, err := GetExtension[EXTMeshoptCompression](&r.gltf.BufferViews[idx]); err != nil {
+		return fmt.Errorf("BufferView %d: %w", idx, err)
+	} else if ext != nil {
+		if ext.Buffer >= uint(len(r.buffers)) {
+			return fmt.Errorf("BufferView %d: compressed buffer index %d out of range", idx, ext.Buffer)
+		}
+		r.buffers[ext.Buffer] = true
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package gltf
+
+import (
+	"testing"
+)
+
+const pruneTestDoc = `{
+	"asset": {"version": "2.0"},
+	"scene": 0,
+	"scenes": [{"nodes": [1]}],
+	"nodes": [{"mesh": 0}, {"mesh": 1, "children": [2]}, {}],
+	"meshes": [
+		{"primitives": [{"attributes": {"POSITION": 0}, "material": 0}]},
+		{"primitives": [{"attributes": {"POSITION": 1}, "indices": 2, "material": 1}]}
+	],
+	"materials": [
+		{"pbrMetallicRoughness": {"baseColorTexture": {"index": 0}}},
+		{"normalTexture": {"index": 1}}
+	],
+	"textures": [{"source": 0, "sampler": 0}, {"source": 1}],
+	"images": [{"uri": "a.png"}, {"uri": "b.png"}],
+	"samplers": [{}],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"},
+		{"bufferView": 1, "componentType": 5126, "count": 3, "type": "VEC3"},
+		{"bufferView": 2, "componentType": 5123, "count": 3, "type": "SCALAR"}
+	],
+	"bufferViews": [
+		{"buffer": 0, "byteLength": 36},
+		{"buffer": 1, "byteLength": 36},
+		{"buffer": 1, "byteOffset": 36, "byteLength": 6}
+	],
+	"buffers": [{"uri": "a.bin", "byteLength": 36}, {"uri": "b.bin", "byteLength": 42}]
+}`
+
+func TestPrune(t *testing.T) {
+	root, err := FromBytes([]byte(pruneTestDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := root.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Count() != 9 {
+		t.Errorf("expected 9 objects removed, got %d: %+v", report.Count(), report)
+	}
+	if len(root.Nodes) != 2 || len(root.Meshes) != 1 || len(root.Materials) != 1 || len(root.Textures) != 1 ||
+		len(root.Images) != 1 || len(root.Samplers) != 0 || len(root.Accessors) != 2 || len(root.BufferViews) != 2 ||
+		len(root.Buffers) != 1 {
+		t.Fatalf("unexpected array lengths after prune: %+v", report)
+	}
+
+	if root.Scenes[0].Nodes[0] != 0 || root.Nodes[0].Children[0] != 1 || *root.Nodes[0].Mesh != 0 {
+		t.Errorf("node references were not remapped")
+	}
+	p := root.Meshes[0].Primitives[0]
+	if p.Attributes[POSITION] != 0 || *p.Indices != 1 || *p.Material != 0 {
+		t.Errorf("primitive references were not remapped: %+v", p)
+	}
+	if root.Materials[0].NormalTexture.Index != 0 || *root.Textures[0].Source != 0 || root.Images[0].Uri != "b.png" {
+		t.Errorf("material references were not remapped")
+	}
+	if *root.Accessors[1].BufferView != 1 || root.BufferViews[0].Buffer != 0 || root.BufferViews[1].Buffer != 0 {
+		t.Errorf("buffer references were not remapped")
+	}
+}
+
+func TestPruneOutOfRange(t *testing.T) {
+	root, err := FromBytes([]byte(`{"asset": {"version": "2.0"}, "scenes": [{"nodes": [3]}], "nodes": [{}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := root.Prune(); err == nil {
+		t.Error("expected an error for an out of range node index")
+	}
+	if len(root.Nodes) != 1 {
+		t.Error("document was modified after a failed prune")
+	}
+}
+
+func TestPruneExtensionDecodeError(t *testing.T) {
+	// Each document references its only accessor and buffer view, but from an extension that can not be decoded.
+	for _, objects := range []string{
+		`"nodes": [{"mesh": 0}], "meshes": [{"primitives": [{"attributes": {},
+			"extensions": {"KHR_draco_mesh_compression": {"bufferView": "0", "attributes": {"POSITION": 0}}}}]}]`,
+		`"nodes": [{"mesh": 0, "extensions": {"EXT_mesh_gpu_instancing": {"attributes": [0]}}}],
+			"meshes": [{"primitives": [{"attributes": {}}]}]`,
+		`"nodes": [{"mesh": 0}], "meshes": [{"primitives": [{"attributes": {}, "material": 0}]}],
+			"materials": [{"extensions": {"KHR_materials_sheen": {"sheenColorTexture": 0}}}], "textures": [{}]`,
+		`"nodes": [{"mesh": 0, "children": [1]}, {"extensions": {"KHR_lights_punctual": {"light": "0"}}}],
+			"meshes": [{"primitives": [{"attributes": {}}]}]`,
+	} {
+		root, err := FromBytes([]byte(`{"asset": {"version": "2.0"}, "scenes": [{"nodes": [0]}], ` + objects + `,
+			"accessors": [{"bufferView": 0, "componentType": 5126, "count": 1, "type": "SCALAR"}],
+			"bufferViews": [{"buffer": 0, "byteLength": 4}], "buffers": [{"byteLength": 4}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodes := len(root.Nodes)
+		if _, err := root.Prune(); err == nil {
+			t.Errorf("expected an error for %s", objects)
+		}
+		if len(root.Accessors) != 1 || len(root.BufferViews) != 1 || len(root.Textures) > 1 || len(root.Nodes) != nodes {
+			t.Error("document was modified after a failed prune")
+		}
+	}
+}
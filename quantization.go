@@ -0,0 +1,464 @@
+package gltf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+const KHR_MESH_QUANTIZATION = "KHR_mesh_quantization"
+
+// attributeFormat is a component type that an attribute may be stored as.
+type attributeFormat struct {
+	componentType ComponentTypeEnum
+	normalized    bool
+}
+
+var (
+	floatFormat         = []attributeFormat{{FLOAT, false}}
+	unsignedNormFormats = []attributeFormat{{FLOAT, false}, {UNSIGNED_BYTE, true}, {UNSIGNED_SHORT, true}}
+	signedNormFormats   = []attributeFormat{{FLOAT, false}, {BYTE, true}, {SHORT, true}}
+	anyIntFormats       = []attributeFormat{
+		{FLOAT, false}, {BYTE, false}, {BYTE, true}, {UNSIGNED_BYTE, false}, {UNSIGNED_BYTE, true},
+		{SHORT, false}, {SHORT, true}, {UNSIGNED_SHORT, false}, {UNSIGNED_SHORT, true},
+	}
+	signedIntFormats = []attributeFormat{{FLOAT, false}, {BYTE, false}, {BYTE, true}, {SHORT, false}, {SHORT, true}}
+)
+
+// attributeRule lists the accessor types and component types that an attribute may use, by the core spec and with
+// KHR_mesh_quantization.
+type attributeRule struct {
+	types           []AccessorTypeEnum
+	core, quantized []attributeFormat
+}
+
+var (
+	vertexAttributeRules = map[AttributeKey]attributeRule{
+		POSITION:   {[]AccessorTypeEnum{VEC3}, floatFormat, anyIntFormats},
+		NORMAL:     {[]AccessorTypeEnum{VEC3}, floatFormat, signedNormFormats},
+		TANGENT:    {[]AccessorTypeEnum{VEC4}, floatFormat, signedNormFormats},
+		"TEXCOORD": {[]AccessorTypeEnum{VEC2}, unsignedNormFormats, anyIntFormats},
+		"COLOR":    {[]AccessorTypeEnum{VEC3, VEC4}, unsignedNormFormats, nil},
+		"JOINTS":   {[]AccessorTypeEnum{VEC4}, []attributeFormat{{UNSIGNED_BYTE, false}, {UNSIGNED_SHORT, false}}, nil},
+		"WEIGHTS":  {[]AccessorTypeEnum{VEC4}, unsignedNormFormats, nil},
+	}
+	targetAttributeRules = map[AttributeKey]attributeRule{
+		POSITION: {[]AccessorTypeEnum{VEC3}, floatFormat, signedIntFormats},
+		NORMAL:   {[]AccessorTypeEnum{VEC3}, floatFormat, signedNormFormats},
+		TANGENT:  {[]AccessorTypeEnum{VEC3}, floatFormat, signedNormFormats},
+	}
+)
+
+// ValidateAttribute checks that the accessor's type and component type are allowed for the attribute by the spec, or
+// also by KHR_mesh_quantization if quantized is set. target selects the rules for the displacements of a morph target
+// rather than for vertex attributes. Attributes that the spec does not define, such as application specific ones
+// starting with an underscore, are not checked.
+func ValidateAttribute(key AttributeKey, a *Accessor, quantized, target bool) error {
+	rules := vertexAttributeRules
+	if target {
+		rules = targetAttributeRules
+	}
+	rule, found := rules[key]
+	if !found {
+		if i := strings.LastIndexByte(string(key), '_'); i > 0 {
+			rule, found = rules[key[:i]]
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	typeOK := false
+	for _, t := range rule.types {
+		typeOK = typeOK || a.Type == t
+	}
+	if !typeOK {
+		return fmt.Errorf("Attribute %s can not use accessor %q, which has type %s", key, a.Name, a.Type)
+	}
+
+	formats := rule.core
+	if quantized && rule.quantized != nil {
+		formats = rule.quantized
+	}
+	for _, f := range formats {
+		if a.ComponentType == f.componentType && (a.Normalized == f.normalized || f.componentType == FLOAT) {
+			return nil
+		}
+	}
+	if !quantized && rule.quantized != nil {
+		return fmt.Errorf("Attribute %s can not use accessor %q, which has component type %d with normalized %t, "+
+			"unless %s is used", key, a.Name, a.ComponentType, a.Normalized, KHR_MESH_QUANTIZATION)
+	}
+	return fmt.Errorf("Attribute %s can not use accessor %q, which has component type %d with normalized %t",
+		key, a.Name, a.ComponentType, a.Normalized)
+}
+
+// attributeWarnings checks the primitive's attributes and morph targets with ValidateAttribute, without
+// KHR_mesh_quantization, and returns the problems found in attribute order.
+func (p *ResolvedPrimitive) attributeWarnings() []string {
+	var rval []string
+	check := func(attrs map[AttributeKey]*ResolvedAccessor, prefix string, target bool) {
+		keys := make([]AttributeKey, 0, len(attrs))
+		for k := range attrs {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+		for _, k := range keys {
+			if err := ValidateAttribute(k, attrs[k].Accessor, false, target); err != nil {
+				rval = append(rval, prefix+err.Error())
+			}
+		}
+	}
+	check(p.Attributes, "", false)
+	for i, target := range p.Targets {
+		check(target, fmt.Sprintf("Target %d: ", i), true)
+	}
+	return rval
+}
+
+// QuantizeOptions controls QuantizeMesh. Each precision is a number of bits, which selects an 8 bit component type
+// when it is at most 8, or else a 16 bit one.
+type QuantizeOptions struct {
+	// PositionBits is the precision of positions, from 1 to 16; 0 selects 14. Positions are stored as unsigned integers
+	// spanning the bounds of the mesh.
+	PositionBits int
+	// NormalBits is the precision of normals and tangents, from 2 to 16; 0 selects 8. They are stored as normalized
+	// signed integers.
+	NormalBits int
+	// TexCoordBits is the precision of texture coordinates, from 1 to 16; 0 selects 12. They are stored as normalized
+	// unsigned integers, so a texture coordinate set is only quantized if every value is between 0 and 1.
+	TexCoordBits int
+}
+
+func (opts QuantizeOptions) withDefaults() (QuantizeOptions, error) {
+	if opts.PositionBits == 0 {
+		opts.PositionBits = 14
+	}
+	if opts.NormalBits == 0 {
+		opts.NormalBits = 8
+	}
+	if opts.TexCoordBits == 0 {
+		opts.TexCoordBits = 12
+	}
+	if opts.PositionBits < 1 || opts.PositionBits > 16 || opts.NormalBits < 2 || opts.NormalBits > 16 ||
+		opts.TexCoordBits < 1 || opts.TexCoordBits > 16 {
+		return opts, fmt.Errorf("Quantization precision out of range: %+v", opts)
+	}
+	return opts, nil
+}
+
+// QuantizeMesh replaces the float POSITION, NORMAL, TANGENT and TEXCOORD_n attributes, and morph target
+// displacements, of every primitive in a mesh with quantized integer attributes, as allowed by KHR_mesh_quantization,
+// which is added to ExtensionsUsed and ExtensionsRequired.
+//
+// Positions are stored relative to the bounds of the mesh, so the mesh needs an extra translation and uniform scale to
+// appear as it did. This is compensated for on every node that uses the mesh: folded into the node's own transform if
+// it has no children and is not animated, or else by moving the mesh onto a new child node that holds the extra
+// transform. It is an error if the mesh is skinned, as skinned meshes ignore the node transform, or if the mesh is moved
+// onto a child node while the morph target weights of the original node are animated.
+//
+// The quantized mesh is added to the document as with AddMesh, and replaces the original on every node; the original
+// mesh is left in place, without any references, for Prune to remove.
+func (root *ResolvedGlTF) QuantizeMesh(mesh uint, opts QuantizeOptions) error {
+	gltf := root.GlTF
+	if mesh >= uint(len(root.Meshes)) {
+		return fmt.Errorf("Mesh %d does not exist", mesh)
+	}
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return err
+	}
+
+	src := &root.Meshes[mesh]
+	q, err := newMeshQuantizer(src, opts)
+	if err != nil {
+		return err
+	}
+
+	// Check every node before modifying anything.
+	animated, weightsAnimated := gltf.animatedNodes()
+	var users []uint
+	for i := range gltf.Nodes {
+		n := &gltf.Nodes[i]
+		if n.Mesh == nil || *n.Mesh != mesh {
+			continue
+		}
+		if n.Skin != nil {
+			return fmt.Errorf("Mesh %d is skinned by node %d, which can not be quantized", mesh, i)
+		}
+		if q.hasTargets && weightsAnimated[uint(i)] && !canFold(n, animated[uint(i)]) {
+			return fmt.Errorf("Mesh %d can not be moved from node %d, whose morph target weights are animated", mesh, i)
+		}
+		users = append(users, uint(i))
+	}
+
+	prims := make([]*ResolvedPrimitive, len(src.Primitives))
+	for i := range src.Primitives {
+		if prims[i], err = q.primitive(&src.Primitives[i]); err != nil {
+			return fmt.Errorf("Primitive %d: %w", i, err)
+		}
+	}
+	quantized, err := root.addMesh(src.Name, prims)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range users {
+		n := &gltf.Nodes[i]
+		if canFold(n, animated[i]) {
+			n.foldQuantization(q.offset, q.scale)
+			n.Mesh = &quantized
+			continue
+		}
+		m := quantized
+		s := vkm.Vec3{q.scale, q.scale, q.scale}
+		offset := q.offset
+		child := Node{Mesh: &m, Weights: n.Weights, Translation: &offset, Scale: &s}
+		if n.Name != "" {
+			child.Name = n.Name + ".quantized"
+		}
+		gltf.Nodes = append(gltf.Nodes, child)
+		n = &gltf.Nodes[i]
+		n.Mesh, n.Weights = nil, nil
+		n.Children = append(n.Children, uint(len(gltf.Nodes)-1))
+	}
+
+	gltf.ExtensionsUsed = appendUnique(gltf.ExtensionsUsed, KHR_MESH_QUANTIZATION)
+	gltf.ExtensionsRequired = appendUnique(gltf.ExtensionsRequired, KHR_MESH_QUANTIZATION)
+	return root.resolveReferences()
+}
+
+// animatedNodes returns the nodes whose transforms are the target of an animation channel, and those whose morph
+// target weights are.
+func (gltf *GlTF) animatedNodes() (transforms, weights map[uint]bool) {
+	transforms, weights = make(map[uint]bool), make(map[uint]bool)
+	for _, a := range gltf.Animations {
+		for _, ch := range a.Channels {
+			if ch.Target.Node == nil {
+				continue
+			}
+			if ch.Target.Path == WEIGHTS {
+				weights[*ch.Target.Node] = true
+			} else {
+				transforms[*ch.Target.Node] = true
+			}
+		}
+	}
+	return transforms, weights
+}
+
+// canFold reports whether the dequantization transform can be folded into the node's own transform.
+func canFold(n *Node, animated bool) bool {
+	return len(n.Children) == 0 && !animated
+}
+
+// foldQuantization post-multiplies the node's transform by a translation and uniform scale.
+func (n *Node) foldQuantization(offset vkm.Vec3, scale float32) {
+	if n.Matrix != [16]float32{} {
+		s := vkm.Vec3{scale, scale, scale}
+		n.Matrix = matToArray(n.LocalMatrix().MultM(trsMatrix(offset, vkm.Vec{0, 0, 0, 1}, s)))
+		return
+	}
+	// T * R * S * T(offset) * S(scale) = T(t + R * S * offset) * R * S(s * scale)
+	t, r, s := n.trs()
+	t = t.Add(transformDirection(trsMatrix(vkm.Vec3{}, r, s), offset))
+	s = s.Scale(scale)
+	n.Translation, n.Scale = &t, &s
+}
+
+// meshQuantizer holds the position transform shared by every primitive of a mesh: a float position is offset + scale
+// * q for the quantized position q.
+type meshQuantizer struct {
+	opts       QuantizeOptions
+	offset     vkm.Vec3
+	scale      float32
+	hasTargets bool
+}
+
+func newMeshQuantizer(m *ResolvedMesh, opts QuantizeOptions) (*meshQuantizer, error) {
+	q := &meshQuantizer{opts: opts}
+	box := EmptyAABB()
+	for i := range m.Primitives {
+		p := &m.Primitives[i]
+		q.hasTargets = q.hasTargets || len(p.Targets) > 0
+		if a := p.Attributes[POSITION]; a != nil {
+			pos, err := a.ReadVec3s()
+			if err != nil {
+				return nil, fmt.Errorf("Primitive %d: %w", i, err)
+			}
+			for _, v := range pos {
+				box = box.Extend(v)
+			}
+		}
+	}
+	if box.IsEmpty() {
+		return q, nil
+	}
+
+	q.offset = box.Min
+	size := box.Size()
+	extent := math32.Max(size[0], math32.Max(size[1], size[2]))
+	q.scale = extent / float32(int(1)<<opts.PositionBits-1)
+	if q.scale == 0 {
+		q.scale = 1
+	}
+	return q, nil
+}
+
+func (q *meshQuantizer) primitive(p *ResolvedPrimitive) (*ResolvedPrimitive, error) {
+	rval := *p
+	rval.Attributes = make(map[AttributeKey]*ResolvedAccessor, len(p.Attributes))
+	for k, a := range p.Attributes {
+		rval.Attributes[k] = a
+		if a.ComponentType != FLOAT {
+			continue
+		}
+
+		var err error
+		switch {
+		case k == POSITION:
+			rval.Attributes[k], err = q.positions(a)
+		case k == NORMAL || k == TANGENT:
+			rval.Attributes[k], err = quantizeSigned(a, q.opts.NormalBits)
+		case strings.HasPrefix(string(k), "TEXCOORD_"):
+			rval.Attributes[k], err = quantizeUnsigned(a, q.opts.TexCoordBits)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Attribute %s: %w", k, err)
+		}
+	}
+
+	rval.Targets = nil
+	for i, target := range p.Targets {
+		rt := make(map[AttributeKey]*ResolvedAccessor, len(target))
+		for k, a := range target {
+			rt[k] = a
+			if a.ComponentType != FLOAT {
+				continue
+			}
+
+			var err error
+			switch k {
+			case POSITION:
+				rt[k], err = q.displacements(a)
+			case NORMAL, TANGENT:
+				rt[k], err = quantizeSigned(a, q.opts.NormalBits)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Target %d, attribute %s: %w", i, k, err)
+			}
+		}
+		rval.Targets = append(rval.Targets, rt)
+	}
+	return &rval, nil
+}
+
+// positions quantizes positions to unsigned integers relative to the mesh bounds.
+func (q *meshQuantizer) positions(a *ResolvedAccessor) (*ResolvedAccessor, error) {
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	ct, limit := UNSIGNED_SHORT, float32(int(1)<<q.opts.PositionBits-1)
+	if q.opts.PositionBits <= 8 {
+		ct = UNSIGNED_BYTE
+	}
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = int(round32(math32.Min(math32.Max((v-q.offset[i%3])/q.scale, 0), limit)))
+	}
+	return newIntAccessor(ct, a.Type, false, ints), nil
+}
+
+// displacements quantizes morph target position displacements with the scale of the positions, as signed integers,
+// or as rescaled floats if some displacement is too large for a SHORT.
+func (q *meshQuantizer) displacements(a *ResolvedAccessor) (*ResolvedAccessor, error) {
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	largest := float32(0)
+	for i := range values {
+		values[i] /= q.scale
+		largest = math32.Max(largest, math32.Abs(values[i]))
+	}
+	if round32(largest) > math.MaxInt16 {
+		return NewFloatAccessor(a.Type, values), nil
+	}
+	ct := SHORT
+	if round32(largest) <= math.MaxInt8 {
+		ct = BYTE
+	}
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = int(round32(v))
+	}
+	return newIntAccessor(ct, a.Type, false, ints), nil
+}
+
+// quantizeSigned stores values between -1 and 1 as normalized signed integers with the given precision.
+func quantizeSigned(a *ResolvedAccessor, bits int) (*ResolvedAccessor, error) {
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	ct, storage := SHORT, float32(math.MaxInt16)
+	if bits <= 8 {
+		ct, storage = BYTE, math.MaxInt8
+	}
+	steps := float32(int(1)<<(bits-1) - 1)
+	ints := make([]int, len(values))
+	for i, v := range values {
+		v = round32(math32.Min(math32.Max(v, -1), 1) * steps)
+		ints[i] = int(round32(v / steps * storage))
+	}
+	return newIntAccessor(ct, a.Type, true, ints), nil
+}
+
+// quantizeUnsigned stores values between 0 and 1 as normalized unsigned integers with the given precision, or returns
+// a unchanged if any value is outside of that range.
+func quantizeUnsigned(a *ResolvedAccessor, bits int) (*ResolvedAccessor, error) {
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if v < 0 || v > 1 {
+			return a, nil
+		}
+	}
+	ct, storage := UNSIGNED_SHORT, float32(math.MaxUint16)
+	if bits <= 8 {
+		ct, storage = UNSIGNED_BYTE, math.MaxUint8
+	}
+	steps := float32(int(1)<<bits - 1)
+	ints := make([]int, len(values))
+	for i, v := range values {
+		ints[i] = int(round32(round32(v*steps) / steps * storage))
+	}
+	return newIntAccessor(ct, a.Type, true, ints), nil
+}
+
+// newIntAccessor creates a standalone accessor, as with NewResolvedAccessor, holding values that fit in the integer
+// component type ct.
+func newIntAccessor(ct ComponentTypeEnum, t AccessorTypeEnum, normalized bool, values []int) *ResolvedAccessor {
+	size := ct.Size()
+	data := make([]byte, size*len(values))
+	for i, v := range values {
+		if size == 1 {
+			data[i] = byte(v)
+		} else {
+			data[2*i], data[2*i+1] = byte(v), byte(v>>8)
+		}
+	}
+	return NewResolvedAccessor(ct, t, normalized, data)
+}
+
+// round32 rounds half away from zero, which math32 does not provide.
+func round32(x float32) float32 {
+	return float32(math.Round(float64(x)))
+}
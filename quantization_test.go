@@ -0,0 +1,266 @@
+package gltf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// quantizedDocument has SHORT positions and normalized BYTE normals, with a placeholder for extensionsUsed.
+const quantizedDocument = `{
+	"asset": {"version": "2.0"},
+	%s
+	"buffers": [{"uri": "quantized.bin", "byteLength": 32}],
+	"bufferViews": [{"buffer": 0, "byteLength": 32}],
+	"accessors": [
+		{"bufferView": 0, "componentType": 5122, "count": 3, "type": "VEC3"},
+		{"bufferView": 0, "byteOffset": 20, "componentType": 5120, "normalized": true, "count": 3, "type": "VEC3"}
+	],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 0, "NORMAL": 1}}]}]
+}`
+
+func quantizedData() []byte {
+	var data []byte
+	for _, v := range []int16{0, 0, 0, 100, -200, 300, -32768, 32767, 1} {
+		data = append(data, byte(v), byte(uint16(v)>>8))
+	}
+	data = append(data, 0, 0)
+	for _, v := range []int8{127, 0, 0, 0, -127, 0, -128, 0, 0} {
+		data = append(data, byte(v))
+	}
+	return append(data, 0, 0, 0)
+}
+
+func TestValidateAttribute(t *testing.T) {
+	shortPos := &Accessor{ComponentType: SHORT, Type: VEC3}
+	if err := ValidateAttribute(POSITION, shortPos, false, false); err == nil {
+		t.Error("expected SHORT positions to be rejected without KHR_mesh_quantization")
+	}
+	if err := ValidateAttribute(POSITION, shortPos, true, false); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateAttribute(NORMAL, shortPos, true, false); err == nil {
+		t.Error("expected non-normalized SHORT normals to be rejected")
+	}
+	if err := ValidateAttribute(NORMAL, &Accessor{ComponentType: SHORT, Normalized: true, Type: VEC3}, true, true); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateAttribute("TEXCOORD_3", &Accessor{ComponentType: BYTE, Type: VEC2}, false, false); err == nil {
+		t.Error("expected BYTE texture coordinates to be rejected without KHR_mesh_quantization")
+	}
+	if err := ValidateAttribute("TEXCOORD_3", &Accessor{ComponentType: UNSIGNED_SHORT, Normalized: true, Type: VEC2}, false, false); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateAttribute(TANGENT, &Accessor{ComponentType: FLOAT, Type: VEC3}, false, false); err == nil {
+		t.Error("expected VEC3 tangents to be rejected")
+	}
+	if err := ValidateAttribute("_CUSTOM", shortPos, false, false); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQuantizedAttributes(t *testing.T) {
+	files := map[string][]byte{"quantized.bin": quantizedData()}
+	// Without the extension the attributes are out of spec, which is only a warning.
+	undeclared := resolveTestDoc(t, fmt.Sprintf(quantizedDocument, ""), files)
+	if len(undeclared.Warnings) != 2 || !strings.HasPrefix(undeclared.Warnings[0], "Mesh 0, primitive 0: Attribute NORMAL") ||
+		!strings.Contains(undeclared.Warnings[1], "unless KHR_mesh_quantization is used") {
+		t.Errorf("expected warnings for the normals and positions, got %q", undeclared.Warnings)
+	}
+
+	declared := fmt.Sprintf(quantizedDocument,
+		`"extensionsUsed": ["KHR_mesh_quantization"], "extensionsRequired": ["KHR_mesh_quantization"],`)
+
+	// With it, a format that the extension does not allow either is an error.
+	doc, err := FromBytes([]byte(strings.Replace(declared, `"normalized": true`, `"normalized": false`, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.meta.defaultSearchPath = t.TempDir()
+	if err := os.WriteFile(filepath.Join(doc.meta.defaultSearchPath, "quantized.bin"), files["quantized.bin"], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doc.Resolve(nil); err == nil || !strings.Contains(err.Error(), "Attribute NORMAL") {
+		t.Errorf("expected unnormalized byte normals to be rejected with KHR_mesh_quantization, got %v", err)
+	}
+
+	resolved := resolveTestDoc(t, declared, files)
+	if len(resolved.Warnings) != 0 {
+		t.Errorf("unexpected warnings %q", resolved.Warnings)
+	}
+	prim := &resolved.Meshes[0].Primitives[0]
+	pos, err := prim.Attributes[POSITION].ReadVec3s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos[1] != (vkm.Vec3{100, -200, 300}) || pos[2] != (vkm.Vec3{-32768, 32767, 1}) {
+		t.Errorf("expected non-normalized positions to be read as integers, got %v", pos)
+	}
+	normals, err := prim.Attributes[NORMAL].ReadVec3s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []vkm.Vec3{{1, 0, 0}, {0, -1, 0}, {-1, 0, 0}}
+	for i := range expected {
+		if normals[i] != expected[i] {
+			t.Errorf("expected normal %d to be %v, got %v", i, expected[i], normals[i])
+		}
+	}
+}
+
+// quantizeSceneDocument has two nodes using mesh 0: a leaf that is translated and scaled, and a parent that is
+// rotated and so can not take the dequantization transform without moving its child.
+const quantizeSceneDocument = `{
+	"asset": {"version": "2.0"},
+	"scenes": [{"nodes": [0, 1]}],
+	"scene": 0,
+	"nodes": [
+		{"name": "leaf", "translation": [1, 2, 3], "scale": [2, 2, 2], "mesh": 0},
+		{"name": "parent", "rotation": [0, 0.7071068, 0, 0.7071068], "mesh": 0, "children": [2]},
+		{"name": "child", "translation": [0, 5, 0]}
+	],
+	"meshes": [{"name": "tri"}]
+}`
+
+// meshWorldPositions returns the world space positions of every vertex of every mesh in the scene, in traversal order.
+func meshWorldPositions(t *testing.T, s *ResolvedScene) []vkm.Vec3 {
+	t.Helper()
+	var rval []vkm.Vec3
+	s.walk(nil, func(n *ResolvedNode, world vkm.Mat) {
+		if n.Mesh == nil {
+			return
+		}
+		for _, p := range n.Mesh.Primitives {
+			pos, err := p.Attributes[POSITION].ReadVec3s()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range pos {
+				rval = append(rval, transformPoint(world, v))
+			}
+		}
+	})
+	return rval
+}
+
+func TestQuantizeMesh(t *testing.T) {
+	resolved := resolveTestDoc(t, quantizeSceneDocument, nil)
+	resolved.GlTF.Meshes = nil
+	prim := &ResolvedPrimitive{
+		Mode: TRIANGLES,
+		Attributes: map[AttributeKey]*ResolvedAccessor{
+			POSITION:   NewFloatAccessor(VEC3, []float32{-1, 0.5, 2, 3, 0.25, 2, -1, 4, -2}),
+			NORMAL:     NewFloatAccessor(VEC3, []float32{0, 0, 1, 0.6, 0.8, 0, 0, -1, 0}),
+			TEXCOORD_0: NewFloatAccessor(VEC2, []float32{0, 0, 1, 0, 0.3, 0.7}),
+			TEXCOORD_1: NewFloatAccessor(VEC2, []float32{0, 0, 2, 0, 0, 1}),
+		},
+	}
+	if _, err := resolved.AddMesh("tri", []*ResolvedPrimitive{prim}); err != nil {
+		t.Fatal(err)
+	}
+	before := meshWorldPositions(t, resolved.Scene)
+
+	if err := resolved.QuantizeMesh(0, QuantizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	doc := resolved.GlTF
+	if !doc.usesExtension(KHR_MESH_QUANTIZATION) || len(doc.ExtensionsRequired) != 1 {
+		t.Errorf("expected KHR_mesh_quantization to be used and required, got %v, %v", doc.ExtensionsUsed, doc.ExtensionsRequired)
+	}
+	if len(doc.Nodes) != 4 || doc.Nodes[1].Mesh != nil || len(doc.Nodes[1].Children) != 2 || *doc.Nodes[3].Mesh != 1 {
+		t.Fatalf("expected the parent's mesh to move onto a new child node, got %+v", doc.Nodes)
+	}
+	if *doc.Nodes[0].Mesh != 1 || len(doc.Nodes[0].Children) != 0 {
+		t.Errorf("expected the leaf to use the quantized mesh directly")
+	}
+
+	after := meshWorldPositions(t, resolved.Scene)
+	if len(after) != len(before) {
+		t.Fatalf("expected %d world positions, got %d", len(before), len(after))
+	}
+	for i := range before {
+		if d := after[i].Sub(before[i]); d.Length() > 1e-3 {
+			t.Errorf("world position %d moved from %v to %v", i, before[i], after[i])
+		}
+	}
+
+	q := &resolved.Meshes[1].Primitives[0]
+	if a := q.Attributes[POSITION]; a.ComponentType != UNSIGNED_SHORT || a.Normalized {
+		t.Errorf("expected UNSIGNED_SHORT positions, got %d, normalized %t", a.ComponentType, a.Normalized)
+	}
+	if a := q.Attributes[NORMAL]; a.ComponentType != BYTE || !a.Normalized {
+		t.Errorf("expected normalized BYTE normals, got %d, normalized %t", a.ComponentType, a.Normalized)
+	}
+	if a := q.Attributes[TEXCOORD_0]; a.ComponentType != UNSIGNED_SHORT || !a.Normalized {
+		t.Errorf("expected normalized UNSIGNED_SHORT texture coordinates, got %d, normalized %t", a.ComponentType, a.Normalized)
+	}
+	if a := q.Attributes[TEXCOORD_1]; a.ComponentType != FLOAT {
+		t.Errorf("expected texture coordinates outside of [0, 1] to stay FLOAT, got %d", a.ComponentType)
+	}
+	normals, err := q.Attributes[NORMAL].ReadVec3s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := normals[1].Sub(vkm.Vec3{0.6, 0.8, 0}); d.Length() > 0.01 {
+		t.Errorf("expected the quantized normal to be close to the original, got %v", normals[1])
+	}
+
+	// Every vertex attribute element must start on a 4-byte boundary, so the 6-byte positions and 3-byte normals are
+	// padded, while the 4-byte texture coordinates are tightly packed.
+	for k, stride := range map[AttributeKey]uint{POSITION: 8, NORMAL: 4, TEXCOORD_0: 0, TEXCOORD_1: 0} {
+		a := q.Attributes[k]
+		bv := a.BufferView
+		if bv.ByteStride != stride || bv.Target != ARRAY_BUFFER {
+			t.Errorf("%s: expected an ARRAY_BUFFER view with byteStride %d, got %d", k, stride, bv.ByteStride)
+		}
+		step := stride
+		if step == 0 {
+			step = uint(a.Stride())
+		}
+		if bv.ByteLength != uint(a.Count)*step {
+			t.Errorf("%s: expected a byteLength of %d, got %d", k, uint(a.Count)*step, bv.ByteLength)
+		}
+		for e := uint(0); e < uint(a.Count); e++ {
+			if offset := bv.ByteOffset + a.ByteOffset + e*step; offset%4 != 0 {
+				t.Errorf("%s: element %d starts at unaligned offset %d", k, e, offset)
+			}
+		}
+	}
+}
+
+func TestQuantizeMeshKeepsEachPrimitive(t *testing.T) {
+	resolved := resolveTestDoc(t, `{
+		"asset": {"version": "2.0"},
+		"nodes": [{"mesh": 0}],
+		"meshes": [{"primitives": [
+			{"attributes": {"POSITION": 0}, "material": 0},
+			{"attributes": {"POSITION": 0}, "material": 1}
+		]}],
+		"materials": [{"name": "red"}, {"name": "blue"}],
+		"accessors": [{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"}],
+		"bufferViews": [{"buffer": 0, "byteLength": 36}],
+		"buffers": [{"uri": "tri.bin", "byteLength": 36}]
+	}`, map[string][]byte{"tri.bin": float32Bytes(0, 0, 0, 1, 0, 0, 0, 1, 0)})
+
+	if err := resolved.QuantizeMesh(0, QuantizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	prims := resolved.Nodes[0].Mesh.Primitives
+	if len(prims) != 2 || prims[0].Material.Name != "red" || prims[1].Material.Name != "blue" {
+		t.Errorf("expected the quantized primitives to keep red and blue, got %+v", prims)
+	}
+
+	// A Draco primitive is quantized from its decoded data, and loses the extension.
+	resolved = resolveTestDoc(t, dracoDocument(), nil)
+	if err := resolved.QuantizeMesh(0, QuantizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	q := &resolved.Nodes[0].Mesh.Primitives[0]
+	if q.Extensions != nil || q.Attributes[POSITION].ComponentType != UNSIGNED_SHORT {
+		t.Errorf("expected a quantized primitive without extensions, got %v", q.Extensions)
+	}
+}
@@ -0,0 +1,224 @@
+package gltf
+
+import "fmt"
+
+// indexMaps holds, for each indexed object type in a document, a map from old index to new index. A nil map leaves
+// references to that type unchanged.
+type indexMaps struct {
+	accessors, buffers, bufferViews, cameras, images, materials, meshes, nodes, samplers, skins, textures []uint
+	// lights indexes the lights of the KHR_lights_punctual extension.
+	lights []uint
+	// variants indexes the material variants of the KHR_materials_variants extension.
+	variants []uint
+}
+
+// remapReferences rewrites every indexed reference in the document according to m. The object arrays themselves are not
+// modified. An error is returned, before any reference is changed, if an extension that holds references can not be
+// decoded.
+func (gltf *GlTF) remapReferences(m indexMaps) error {
+	if err := gltf.decodeReferenceExtensions(); err != nil {
+		return err
+	}
+
+	for i := range gltf.Scenes {
+		remapSlice(gltf.Scenes[i].Nodes, m.nodes)
+	}
+
+	for i := range gltf.Nodes {
+		n := &gltf.Nodes[i]
+		remapSlice(n.Children, m.nodes)
+		remapIntPtr(n.Camera, m.cameras)
+		remapIntPtr(n.Skin, m.skins)
+		remapPtr(n.Mesh, m.meshes)
+		if ids, _ := n.lodNodes(); ids != nil && m.nodes != nil {
+			remapped := append([]uint(nil), ids...)
+			remapSlice(remapped, m.nodes)
+			n.setLODNodes(remapped)
+		}
+		if l, found := n.LightIndex(); found && l < uint(len(m.lights)) {
+			n.SetLightIndex(m.lights[l])
+		}
+		attrs, _ := n.instancingAccessors()
+		remapAttributes(attrs, m.accessors)
+	}
+
+	for i := range gltf.Meshes {
+		for j := range gltf.Meshes[i].Primitives {
+			p := &gltf.Meshes[i].Primitives[j]
+			remapAttributes(p.Attributes, m.accessors)
+			for _, target := range p.Targets {
+				remapAttributes(target, m.accessors)
+			}
+			remapPtr(p.Indices, m.accessors)
+			remapIntPtr(p.Material, m.materials)
+			if ext, _, _ := GetExtension[KHRDracoMeshCompression](p); ext != nil {
+				remapPtr(&ext.BufferView, m.bufferViews)
+			}
+			mappings, _ := p.variantMappings()
+			for k := range mappings {
+				mapping := &mappings[k]
+				if mapping.Material < uint(len(m.materials)) {
+					remapPtr(&mapping.Material, m.materials)
+				}
+				for l, v := range mapping.Variants {
+					if v < uint(len(m.variants)) {
+						mapping.Variants[l] = m.variants[v]
+					}
+				}
+			}
+		}
+	}
+
+	for i := range gltf.Materials {
+		textureInfos, _ := gltf.Materials[i].textureInfos()
+		for _, ti := range textureInfos {
+			remapPtr(&ti.Index, m.textures)
+		}
+	}
+
+	for i := range gltf.Textures {
+		remapPtr(gltf.Textures[i].Sampler, m.samplers)
+		remapPtr(gltf.Textures[i].Source, m.images)
+	}
+
+	for i := range gltf.Images {
+		remapPtr(gltf.Images[i].BufferView, m.bufferViews)
+	}
+
+	for i := range gltf.Accessors {
+		a := &gltf.Accessors[i]
+		remapPtr(a.BufferView, m.bufferViews)
+		if a.Sparse != nil {
+			remapPtr(&a.Sparse.Indices.BufferView, m.bufferViews)
+			remapPtr(&a.Sparse.Values.BufferView, m.bufferViews)
+		}
+	}
+
+	for i := range gltf.BufferViews {
+		bv := &gltf.BufferViews[i]
+		remapPtr(&bv.Buffer, m.buffers)
+		if ext, _, _ := GetExtension[EXTMeshoptCompression](bv); ext != nil {
+			remapPtr(&ext.Buffer, m.buffers)
+		}
+	}
+
+	for i := range gltf.Skins {
+		s := &gltf.Skins[i]
+		remapPtr(s.InverseBindMatrices, m.accessors)
+		remapPtr(s.Skeleton, m.nodes)
+		remapSlice(s.Joints, m.nodes)
+	}
+
+	for i := range gltf.Animations {
+		a := &gltf.Animations[i]
+		for j := range a.Samplers {
+			remapInt(&a.Samplers[j].Input, m.accessors)
+			remapInt(&a.Samplers[j].Output, m.accessors)
+		}
+		for j := range a.Channels {
+			remapPtr(a.Channels[j].Target.Node, m.nodes)
+		}
+	}
+	return nil
+}
+
+// decodeReferenceExtensions decodes every extension in the document that holds indexed references. Decoded extensions
+// are kept on their objects, so remapReferences can then look them up without errors.
+func (gltf *GlTF) decodeReferenceExtensions() error {
+	for i := range gltf.Nodes {
+		n := &gltf.Nodes[i]
+		if _, err := n.lodNodes(); err != nil {
+			return fmt.Errorf("Node %d: %w", i, err)
+		}
+		if _, _, err := GetExtension[KHRLightsPunctualNode](n); err != nil {
+			return fmt.Errorf("Node %d: %w", i, err)
+		}
+		if _, err := n.instancingAccessors(); err != nil {
+			return fmt.Errorf("Node %d: %w", i, err)
+		}
+	}
+	for i := range gltf.Meshes {
+		for j := range gltf.Meshes[i].Primitives {
+			p := &gltf.Meshes[i].Primitives[j]
+			if _, _, err := GetExtension[KHRDracoMeshCompression](p); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %w", i, j, err)
+			}
+			if _, err := p.variantMappings(); err != nil {
+				return fmt.Errorf("Mesh %d, primitive %d: %w", i, j, err)
+			}
+		}
+	}
+	for i := range gltf.Materials {
+		if _, err := gltf.Materials[i].textureInfos(); err != nil {
+			return fmt.Errorf("Material %d: %w", i, err)
+		}
+	}
+	for i := range gltf.BufferViews {
+		if _, _, err := GetExtension[EXTMeshoptCompression](&gltf.BufferViews[i]); err != nil {
+			return fmt.Errorf("BufferView %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// compact returns the elements of s for which keep is true, a map from old to new indices, and the old indices of the
+// removed elements. Entries in the index map for removed elements are meaningless.
+func compact[T any](s []T, keep []bool) ([]T, []uint, []uint) {
+	var rval []T
+	var removed []uint
+	indexMap := make([]uint, len(s))
+	for i := range s {
+		if keep[i] {
+			indexMap[i] = uint(len(rval))
+			rval = append(rval, s[i])
+		} else {
+			removed = append(removed, uint(i))
+		}
+	}
+	return rval, indexMap, removed
+}
+
+// identityMap returns an index map of length n that maps every index to itself.
+func identityMap(n int) []uint {
+	rval := make([]uint, n)
+	for i := range rval {
+		rval[i] = uint(i)
+	}
+	return rval
+}
+
+func remapSlice(s []uint, indexMap []uint) {
+	if indexMap == nil {
+		return
+	}
+	for i := range s {
+		s[i] = indexMap[s[i]]
+	}
+}
+
+func remapPtr(p *uint, indexMap []uint) {
+	if p != nil && indexMap != nil {
+		*p = indexMap[*p]
+	}
+}
+
+func remapInt(p *int, indexMap []uint) {
+	if indexMap != nil {
+		*p = int(indexMap[*p])
+	}
+}
+
+func remapIntPtr(p *int, indexMap []uint) {
+	if p != nil {
+		remapInt(p, indexMap)
+	}
+}
+
+func remapAttributes(attrs map[AttributeKey]int, indexMap []uint) {
+	if indexMap == nil {
+		return
+	}
+	for k, v := range attrs {
+		attrs[k] = int(indexMap[v])
+	}
+}
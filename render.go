@@ -0,0 +1,762 @@
+package gltf
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"sort"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// RenderOptions controls Render.
+type RenderOptions struct {
+	// Width and Height are the size of the output image in pixels.
+	Width, Height int
+	// Camera is a node with a camera, which the scene is viewed through. Its projection is computed for the aspect
+	// ratio of the image. If Camera is nil, a perspective camera looking along -Z (at the front of the model) is placed
+	// to frame the bounds of the whole scene.
+	Camera *ResolvedNode
+	// Pose is the pose of the scene's nodes, or nil for the rest pose.
+	Pose Pose
+	// Background fills the pixels that nothing is drawn over. Nil leaves them transparent.
+	Background color.Color
+	// LightDirection is the world space direction in which a single directional light travels. If it is zero, the light
+	// comes from over the viewer's left shoulder.
+	LightDirection vkm.Vec3
+	// Supersample renders Supersample x Supersample samples for each pixel and averages them, for antialiasing. Zero
+	// and one take a single sample.
+	Supersample int
+}
+
+// renderFov is the vertical field of view of the automatic camera.
+const renderFov = math32.Pi / 4
+
+// renderAmbient is the intensity of the ambient light, relative to the directional light.
+const renderAmbient = 0.25
+
+// Render draws the scene into a new image, on the CPU. It is meant for thumbnails and for comparing against reference
+// images in tests, not for speed or fidelity: triangles are shaded per pixel with a single directional light plus
+// ambient light, using the metallic-roughness material's base color, metallic and roughness factors and textures,
+// occlusion and emission, and COLOR_0. Normal maps, skinning, morph targets and material extensions are not applied,
+// and textures are sampled without mipmaps. Points and lines are not drawn. Meshes with EXT_mesh_gpu_instancing are
+// drawn once per instance.
+//
+// Depth is tested per sample. Back faces are culled unless the material is double sided. OPAQUE and MASK primitives are
+// drawn first, and then BLEND primitives, one triangle at a time from back to front, without writing depth. Shading is
+// done in linear space, and the image holds sRGB values.
+func (s *ResolvedScene) Render(opts RenderOptions) (*image.RGBA, error) {
+	if opts.Width <= 0 || opts.Height <= 0 {
+		return nil, errors.New("Render width and height must be positive")
+	}
+	ss := opts.Supersample
+	if ss < 1 {
+		ss = 1
+	}
+
+	r := &renderer{
+		width:     opts.Width * ss,
+		height:    opts.Height * ss,
+		textures:  make(map[textureKey]*renderTexture),
+		materials: make(map[*ResolvedMaterial]*renderMaterial),
+	}
+	if err := r.setCamera(s, opts); err != nil {
+		return nil, err
+	}
+	r.clear(opts.Background)
+
+	var err error
+	s.walk(opts.Pose, func(n *ResolvedNode, m vkm.Mat) {
+		if n.Mesh == nil || err != nil {
+			return
+		}
+		for _, world := range n.InstanceWorldMatrices(m) {
+			for i := range n.Mesh.Primitives {
+				if err = r.drawPrimitive(&n.Mesh.Primitives[i], world); err != nil {
+					err = fmt.Errorf("Node %q, primitive %d: %w", n.Name, i, err)
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(r.blended, func(i, j int) bool { return r.blended[i].depth > r.blended[j].depth })
+	for i := range r.blended {
+		t := &r.blended[i]
+		r.drawTriangle(t.v, t.stride, t.material, t.frontCW, true)
+	}
+
+	return r.image(opts.Width, opts.Height, ss), nil
+}
+
+type renderer struct {
+	width, height int
+	viewProj      vkm.Mat
+	eye           vkm.Vec3
+	back          vkm.Vec3 // the direction the camera looks away from, in world space
+	ortho         bool
+	light         vkm.Vec3 // unit vector towards the light
+
+	// color holds premultiplied linear RGBA, and depth the window depth of the nearest opaque sample.
+	color []vkm.Vec
+	depth []float32
+
+	blended   []blendTriangle
+	textures  map[textureKey]*renderTexture
+	materials map[*ResolvedMaterial]*renderMaterial
+}
+
+// A renderVertex is a clip space position and its varyings, which are laid out as renderPosition etc. below.
+type renderVertex struct {
+	clip     vkm.Vec
+	varyings []float32
+}
+
+// Offsets of the varyings: world position, world normal, vertex color, and then a UV for each texture coordinate set.
+const (
+	renderPosition = 0
+	renderNormal   = 3
+	renderColor    = 6
+	renderUV       = 10
+)
+
+type blendTriangle struct {
+	v        [3]renderVertex
+	stride   int
+	material *renderMaterial
+	frontCW  bool
+	depth    float32
+}
+
+// setCamera sets up the view and projection, from opts.Camera or framing the scene, and the light.
+func (r *renderer) setCamera(s *ResolvedScene, opts RenderOptions) error {
+	aspect := float32(opts.Width) / float32(opts.Height)
+	var cam *CameraInstance
+
+	if opts.Camera != nil {
+		if opts.Camera.Camera == nil {
+			return fmt.Errorf("Render camera node %q has no camera", opts.Camera.Name)
+		}
+		instances := s.CameraInstances(opts.Pose)
+		for i := range instances {
+			if instances[i].Node == opts.Camera {
+				cam = &instances[i]
+				break
+			}
+		}
+		if cam == nil {
+			return fmt.Errorf("Render camera node %q is not in the scene", opts.Camera.Name)
+		}
+	} else {
+		b, err := s.Bounds(BoundsOptions{Pose: opts.Pose})
+		if err != nil {
+			return err
+		}
+		center, radius := vkm.Vec3{}, float32(1)
+		if !b.IsEmpty() && b.Sphere.Radius > 0 {
+			center, radius = b.Sphere.Center, b.Sphere.Radius
+		}
+		// Fit the sphere in the narrower of the two fields of view, with a small margin.
+		half := renderFov / 2
+		if aspect < 1 {
+			half = math32.Atan(aspect * math32.Tan(half))
+		}
+		radius *= 1.05
+		distance := radius / math32.Sin(half)
+		world := trsMatrix(center.Add(vkm.Vec3{0, 0, distance}), vkm.Vec{0, 0, 0, 1}, vkm.Vec3{1, 1, 1})
+		cam = &CameraInstance{
+			Camera: &ResolvedCamera{Camera: &Camera{Type: PERSPECTIVE, Perspective: CameraPerpsective{
+				Yfov:  renderFov,
+				Znear: math32.Max(distance-radius, distance*1e-3),
+				Zfar:  distance + radius,
+			}}},
+			World: world,
+			View:  world.Inverse(),
+		}
+	}
+
+	var err error
+	if r.viewProj, err = cam.ViewProjection(aspect); err != nil {
+		return err
+	}
+	camWorld := cam.World
+	r.ortho = cam.Camera.Type == ORTHOGRAPHIC
+	r.eye = vkm.Vec3{camWorld[3][0], camWorld[3][1], camWorld[3][2]}
+	right := vkm.Vec3{camWorld[0][0], camWorld[0][1], camWorld[0][2]}
+	up := vkm.Vec3{camWorld[1][0], camWorld[1][1], camWorld[1][2]}
+	r.back = vkm.Vec3{camWorld[2][0], camWorld[2][1], camWorld[2][2]}
+
+	if opts.LightDirection != (vkm.Vec3{}) {
+		r.light = normalizeOr(opts.LightDirection.Scale(-1), r.back)
+	} else {
+		r.light = normalizeOr(right.Scale(-0.5).Add(up).Add(r.back), r.back)
+	}
+	return nil
+}
+
+func (r *renderer) clear(background color.Color) {
+	var bg vkm.Vec
+	if background != nil {
+		cr, cg, cb, ca := background.RGBA()
+		if ca > 0 {
+			a := float32(ca) / 0xffff
+			bg = vkm.Vec{
+				srgbToLinear(float32(cr)/float32(ca)) * a,
+				srgbToLinear(float32(cg)/float32(ca)) * a,
+				srgbToLinear(float32(cb)/float32(ca)) * a,
+				a,
+			}
+		}
+	}
+	r.color = make([]vkm.Vec, r.width*r.height)
+	r.depth = make([]float32, r.width*r.height)
+	for i := range r.color {
+		r.color[i] = bg
+		r.depth[i] = 1
+	}
+}
+
+// drawPrimitive draws the opaque and masked triangles of a primitive, and queues its blended ones.
+func (r *renderer) drawPrimitive(p *ResolvedPrimitive, world vkm.Mat) error {
+	switch p.Mode {
+	case POINTS, LINES, LINE_LOOP, LINE_STRIP:
+		return nil
+	}
+	posAcc := p.Attributes[POSITION]
+	if posAcc == nil {
+		return nil
+	}
+	m, err := r.material(p.Material)
+	if err != nil {
+		return err
+	}
+
+	pos, err := posAcc.ReadVec3s()
+	if err != nil {
+		return err
+	}
+	tris, err := p.TriangleIndices()
+	if err != nil {
+		return err
+	}
+	var normals []vkm.Vec3
+	if a := p.Attributes[NORMAL]; a != nil {
+		if normals, err = a.ReadVec3s(); err != nil {
+			return err
+		}
+	}
+	var colors []float32
+	colorSize := 0
+	if a := p.Attributes[COLOR_0]; a != nil {
+		if colors, err = a.ReadFloats(); err != nil {
+			return err
+		}
+		colorSize = a.Type.Count()
+	}
+	uvs := make([][]vkm.Vec2, m.texCoords)
+	for set := range uvs {
+		if a := p.Attributes[TexCoord(uint(set))]; a != nil {
+			if uvs[set], err = a.ReadVec2s(); err != nil {
+				return err
+			}
+		}
+	}
+
+	stride := renderUV + 2*m.texCoords
+	mvp := r.viewProj.MultM(world)
+	normalMatrix := world.Inverse().Transpose()
+	col0, col1, col2 := vkm.Vec3{world[0][0], world[0][1], world[0][2]}, vkm.Vec3{world[1][0], world[1][1], world[1][2]}, vkm.Vec3{world[2][0], world[2][1], world[2][2]}
+	frontCW := cross3(col0, col1).Dot(col2) < 0
+
+	for t := 0; t+2 < len(tris); t += 3 {
+		var v [3]renderVertex
+		for k := range v {
+			idx := int(tris[t+k])
+			if idx >= len(pos) {
+				return fmt.Errorf("Triangle %d uses vertex %d, which is out of range", t/3, idx)
+			}
+			v[k].clip = mvp.MultV(vkm.Vec{pos[idx][0], pos[idx][1], pos[idx][2], 1})
+			vary := make([]float32, stride)
+			wp := transformPoint(world, pos[idx])
+			copy(vary[renderPosition:], wp[:])
+			if idx < len(normals) {
+				wn := transformDirection(normalMatrix, normals[idx])
+				copy(vary[renderNormal:], wn[:])
+			}
+			copy(vary[renderColor:renderColor+4], []float32{1, 1, 1, 1})
+			if colorSize > 0 && (idx+1)*colorSize <= len(colors) {
+				copy(vary[renderColor:], colors[idx*colorSize:(idx+1)*colorSize])
+			}
+			for set, uv := range uvs {
+				if idx < len(uv) {
+					copy(vary[renderUV+2*set:], uv[idx][:])
+				}
+			}
+			v[k].varyings = vary
+		}
+		if normals == nil {
+			// Flat shading: use the face normal at every corner.
+			p0 := vkm.Vec3{v[0].varyings[0], v[0].varyings[1], v[0].varyings[2]}
+			p1 := vkm.Vec3{v[1].varyings[0], v[1].varyings[1], v[1].varyings[2]}
+			p2 := vkm.Vec3{v[2].varyings[0], v[2].varyings[1], v[2].varyings[2]}
+			face := cross3(p1.Sub(p0), p2.Sub(p0))
+			if frontCW {
+				face = face.Scale(-1)
+			}
+			for k := range v {
+				copy(v[k].varyings[renderNormal:], face[:])
+			}
+		}
+
+		if m.alphaMode == BLEND {
+			depth := (v[0].clip[2] + v[1].clip[2] + v[2].clip[2]) / 3
+			r.blended = append(r.blended, blendTriangle{v: v, stride: stride, material: m, frontCW: frontCW, depth: depth})
+		} else {
+			r.drawTriangle(v, stride, m, frontCW, false)
+		}
+	}
+	return nil
+}
+
+// drawTriangle clips a triangle against the near and far planes and rasterizes what is left.
+func (r *renderer) drawTriangle(v [3]renderVertex, stride int, m *renderMaterial, frontCW, blend bool) {
+	poly := clipPolygon(v[:], stride, 1) // z <= w
+	poly = clipPolygon(poly, stride, -1) // z >= -w
+	for k := 1; k+1 < len(poly); k++ {
+		r.rasterize([3]renderVertex{poly[0], poly[k], poly[k+1]}, stride, m, frontCW, blend)
+	}
+}
+
+// clipPolygon clips a convex polygon in clip space against the plane side * z <= w, interpolating the varyings of new
+// vertices.
+func clipPolygon(poly []renderVertex, stride int, side float32) []renderVertex {
+	var rval []renderVertex
+	dist := func(v renderVertex) float32 { return v.clip[3] - side*v.clip[2] }
+	for i := range poly {
+		a, b := poly[i], poly[(i+1)%len(poly)]
+		da, db := dist(a), dist(b)
+		if da >= 0 {
+			rval = append(rval, a)
+		}
+		if (da >= 0) != (db >= 0) {
+			t := da / (da - db)
+			c := renderVertex{clip: add4(a.clip.Scale(1-t), b.clip.Scale(t)), varyings: make([]float32, stride)}
+			for k := range c.varyings {
+				c.varyings[k] = a.varyings[k] + (b.varyings[k]-a.varyings[k])*t
+			}
+			rval = append(rval, c)
+		}
+	}
+	return rval
+}
+
+// rasterize draws a triangle that lies within the near and far planes, shading the centre of each sample that it
+// covers, with perspective-correct varyings. Samples on an edge belong to the triangle only if it is a top or left
+// edge, so that triangles sharing an edge never both cover a sample.
+func (r *renderer) rasterize(v [3]renderVertex, stride int, m *renderMaterial, frontCW, blend bool) {
+	var sx, sy, sz, iw [3]float32
+	for k := range v {
+		w := v[k].clip[3]
+		if w <= 0 {
+			return
+		}
+		iw[k] = 1 / w
+		sx[k] = (v[k].clip[0]*iw[k]*0.5 + 0.5) * float32(r.width)
+		sy[k] = (0.5 - v[k].clip[1]*iw[k]*0.5) * float32(r.height)
+		sz[k] = v[k].clip[2]*iw[k]*0.5 + 0.5
+	}
+
+	// Counter-clockwise in normalized device coordinates, the default front face, has a negative area in window
+	// coordinates, where y points down.
+	area := (sx[1]-sx[0])*(sy[2]-sy[0]) - (sy[1]-sy[0])*(sx[2]-sx[0])
+	if area == 0 {
+		return
+	}
+	backFace := (area > 0) != frontCW
+	if backFace && !m.doubleSided {
+		return
+	}
+	if area < 0 {
+		v[1], v[2] = v[2], v[1]
+		sx[1], sx[2], sy[1], sy[2], sz[1], sz[2], iw[1], iw[2] = sx[2], sx[1], sy[2], sy[1], sz[2], sz[1], iw[2], iw[1]
+		area = -area
+	}
+
+	minX, maxX := clampInt(int(math32.Floor(min3(sx))), 0, r.width-1), clampInt(int(math32.Ceil(max3(sx))), 0, r.width-1)
+	minY, maxY := clampInt(int(math32.Floor(min3(sy))), 0, r.height-1), clampInt(int(math32.Ceil(max3(sy))), 0, r.height-1)
+
+	edge := func(a, b int, px, py float32) float32 {
+		return (sx[b]-sx[a])*(py-sy[a]) - (sy[b]-sy[a])*(px-sx[a])
+	}
+	topLeft := func(a, b int) bool {
+		dx, dy := sx[b]-sx[a], sy[b]-sy[a]
+		return dy < 0 || (dy == 0 && dx > 0)
+	}
+	inside := func(w float32, a, b int) bool {
+		return w > 0 || (w == 0 && topLeft(a, b))
+	}
+
+	vary := make([]float32, stride)
+	for y := minY; y <= maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x <= maxX; x++ {
+			px := float32(x) + 0.5
+			w0, w1, w2 := edge(1, 2, px, py), edge(2, 0, px, py), edge(0, 1, px, py)
+			if !inside(w0, 1, 2) || !inside(w1, 2, 0) || !inside(w2, 0, 1) {
+				continue
+			}
+			b0, b1, b2 := w0/area, w1/area, w2/area
+			z := b0*sz[0] + b1*sz[1] + b2*sz[2]
+			i := y*r.width + x
+			if z < 0 || z > 1 || z >= r.depth[i] {
+				continue
+			}
+
+			p0, p1, p2 := b0*iw[0], b1*iw[1], b2*iw[2]
+			norm := 1 / (p0 + p1 + p2)
+			p0, p1, p2 = p0*norm, p1*norm, p2*norm
+			for k := range vary {
+				vary[k] = p0*v[0].varyings[k] + p1*v[1].varyings[k] + p2*v[2].varyings[k]
+			}
+
+			rgb, alpha, keep := r.shade(m, vary, backFace)
+			if !keep {
+				continue
+			}
+			if blend {
+				r.color[i] = add4(vkm.Vec{rgb[0] * alpha, rgb[1] * alpha, rgb[2] * alpha, alpha}, r.color[i].Scale(1-alpha))
+			} else {
+				r.color[i] = vkm.Vec{rgb[0], rgb[1], rgb[2], 1}
+				r.depth[i] = z
+			}
+		}
+	}
+}
+
+// shade returns the linear color and alpha of a sample with the given varyings, or false if it is discarded by the
+// alpha cutoff.
+func (r *renderer) shade(m *renderMaterial, vary []float32, backFace bool) (vkm.Vec3, float32, bool) {
+	uv := func(t *renderTextureInfo) vkm.Vec2 {
+		o := renderUV + 2*t.texCoord
+		return t.uvMatrix.Apply(vkm.Vec2{vary[o], vary[o+1]})
+	}
+
+	base := m.baseColor
+	for c := range base {
+		base[c] *= vary[renderColor+c]
+	}
+	if m.baseColorTexture != nil {
+		t := m.baseColorTexture.texture.sample(uv(m.baseColorTexture))
+		for c := range base {
+			base[c] *= t[c]
+		}
+	}
+	alpha := base[3]
+	switch m.alphaMode {
+	case MASK:
+		if alpha < m.alphaCutoff {
+			return vkm.Vec3{}, 0, false
+		}
+		alpha = 1
+	case BLEND:
+		alpha = math32.Min(math32.Max(alpha, 0), 1)
+	default:
+		alpha = 1
+	}
+
+	metallic, roughness := m.metallic, m.roughness
+	if m.metallicRoughnessTexture != nil {
+		t := m.metallicRoughnessTexture.texture.sample(uv(m.metallicRoughnessTexture))
+		roughness *= t[1]
+		metallic *= t[2]
+	}
+	occlusion := float32(1)
+	if m.occlusionTexture != nil {
+		t := m.occlusionTexture.texture.sample(uv(m.occlusionTexture))
+		occlusion = 1 + m.occlusionStrength*(t[0]-1)
+	}
+
+	view := r.back
+	pos := vkm.Vec3{vary[renderPosition], vary[renderPosition+1], vary[renderPosition+2]}
+	if !r.ortho {
+		view = normalizeOr(r.eye.Sub(pos), r.back)
+	}
+	n := normalizeOr(vkm.Vec3{vary[renderNormal], vary[renderNormal+1], vary[renderNormal+2]}, view)
+	if backFace {
+		n = n.Scale(-1)
+	}
+
+	// Lambert diffuse, and a normalized Blinn-Phong specular lobe standing in for the microfacet BRDF, with the
+	// exponent that approximates the GGX lobe of the same roughness.
+	baseRGB := vkm.Vec3{base[0], base[1], base[2]}
+	diffuse := baseRGB.Scale(1 - metallic)
+	f0 := vkm.Vec3{0.04, 0.04, 0.04}.Scale(1 - metallic).Add(baseRGB.Scale(metallic))
+	a := math32.Max(roughness*roughness, 0.03)
+	shininess := math32.Max(2/(a*a)-2, 0)
+
+	nl := math32.Max(n.Dot(r.light), 0)
+	h := normalizeOr(r.light.Add(view), n)
+	spec := (shininess + 8) / 8 * math32.Pow(math32.Max(n.Dot(h), 0), shininess)
+	rgb := diffuse.Add(f0.Scale(spec)).Scale(nl).Add(diffuse.Add(f0).Scale(renderAmbient * occlusion))
+
+	emissive := m.emissive
+	if m.emissiveTexture != nil {
+		t := m.emissiveTexture.texture.sample(uv(m.emissiveTexture))
+		emissive = vkm.Vec3{emissive[0] * t[0], emissive[1] * t[1], emissive[2] * t[2]}
+	}
+	return rgb.Add(emissive), alpha, true
+}
+
+// image resolves the samples into an sRGB image, averaging each ss x ss block.
+func (r *renderer) image(width, height, ss int) *image.RGBA {
+	rval := image.NewRGBA(image.Rect(0, 0, width, height))
+	scale := 1 / float32(ss*ss)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var sum vkm.Vec
+			for sy := 0; sy < ss; sy++ {
+				for sx := 0; sx < ss; sx++ {
+					sum = add4(sum, r.color[(y*ss+sy)*r.width+x*ss+sx])
+				}
+			}
+			sum = sum.Scale(scale)
+			a := math32.Min(sum[3], 1)
+			if a <= 0 {
+				continue
+			}
+			var px color.RGBA
+			px.A = uint8(a*255 + 0.5)
+			for c, dst := range []*uint8{&px.R, &px.G, &px.B} {
+				*dst = uint8(linearToSrgb(sum[c]/a)*a*255 + 0.5)
+			}
+			rval.SetRGBA(x, y, px)
+		}
+	}
+	return rval
+}
+
+// renderMaterial is a material with the spec defaults applied and its textures decoded.
+type renderMaterial struct {
+	baseColor           vkm.Vec
+	metallic, roughness float32
+	emissive            vkm.Vec3
+	alphaMode           AlphaModeEnum
+	alphaCutoff         float32
+	doubleSided         bool
+	occlusionStrength   float32
+
+	baseColorTexture, metallicRoughnessTexture, occlusionTexture, emissiveTexture *renderTextureInfo
+	// texCoords is one more than the highest texture coordinate set used by the textures.
+	texCoords int
+}
+
+type renderTextureInfo struct {
+	texture  *renderTexture
+	texCoord int
+	uvMatrix UVMatrix
+}
+
+func (r *renderer) material(src *ResolvedMaterial) (*renderMaterial, error) {
+	if m, found := r.materials[src]; found {
+		return m, nil
+	}
+	m := &renderMaterial{
+		baseColor:         vkm.Vec{1, 1, 1, 1},
+		metallic:          1,
+		roughness:         1,
+		alphaMode:         OPAQUE,
+		alphaCutoff:       0.5,
+		occlusionStrength: 1,
+	}
+	if src != nil {
+		var err error
+		texture := func(ti *ResolvedTextureInfo, srgb bool) *renderTextureInfo {
+			if ti == nil || err != nil {
+				return nil
+			}
+			var t *renderTextureInfo
+			// A zero matrix is from a texture info that was not made by Resolve, and is treated as the identity.
+			if t, err = r.textureInfo(ti.Texture, ti.TexCoord, srgb); t != nil && ti.UVMatrix != (UVMatrix{}) {
+				t.uvMatrix = ti.UVMatrix
+			}
+			return t
+		}
+
+		if pbr := src.PbrMetallicRoughness; pbr != nil {
+			if pbr.BaseColorFactor != nil {
+				m.baseColor = *pbr.BaseColorFactor
+			}
+			if pbr.MetallicFactor != nil {
+				m.metallic = *pbr.MetallicFactor
+			}
+			if pbr.RoughnessFactor != nil {
+				m.roughness = *pbr.RoughnessFactor
+			}
+			m.baseColorTexture = texture(pbr.BaseColorTexture, true)
+			m.metallicRoughnessTexture = texture(pbr.MetallicRoughnessTexture, false)
+		}
+		if src.EmissiveFactor != nil {
+			m.emissive = *src.EmissiveFactor
+		}
+		m.emissiveTexture = texture(src.EmissiveTexture, true)
+		if occ := src.OcclusionTexture; occ != nil {
+			m.occlusionTexture = texture(&ResolvedTextureInfo{
+				TextureInfo: &occ.TextureInfo, Texture: occ.Texture, TexCoord: occ.TexCoord, UVMatrix: occ.UVMatrix,
+			}, false)
+			if occ.Strength != nil {
+				m.occlusionStrength = *occ.Strength
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if src.AlphaMode != "" {
+			m.alphaMode = src.AlphaMode
+		}
+		if src.AlphaCutoff != nil {
+			m.alphaCutoff = *src.AlphaCutoff
+		}
+		m.doubleSided = src.DoubleSided
+	}
+
+	for _, t := range []*renderTextureInfo{m.baseColorTexture, m.metallicRoughnessTexture, m.occlusionTexture, m.emissiveTexture} {
+		if t != nil && t.texCoord+1 > m.texCoords {
+			m.texCoords = t.texCoord + 1
+		}
+	}
+	r.materials[src] = m
+	return m, nil
+}
+
+type textureKey struct {
+	texture *ResolvedTexture
+	srgb    bool
+}
+
+// renderTexture is a decoded image, with its texels as non-premultiplied RGBA, converted to linear values for color
+// textures.
+type renderTexture struct {
+	width, height int
+	texels        []vkm.Vec
+	wrapS, wrapT  SamplerWrapEnum
+	nearest       bool
+}
+
+// textureInfo decodes a texture, or returns nil if it has no image in a core format.
+func (r *renderer) textureInfo(t *ResolvedTexture, texCoord uint, srgb bool) (*renderTextureInfo, error) {
+	if t == nil || t.Source == nil {
+		return nil, nil
+	}
+	key := textureKey{t, srgb}
+	rt, found := r.textures[key]
+	if !found {
+		img, err := t.Source.Decode()
+		if err != nil {
+			return nil, fmt.Errorf("Could not decode image %q: %w", t.Source.Name, err)
+		}
+		b := img.Bounds()
+		rt = &renderTexture{width: b.Dx(), height: b.Dy(), wrapS: REPEAT, wrapT: REPEAT}
+		if t.Sampler != nil {
+			if t.Sampler.WrapS != 0 {
+				rt.wrapS = t.Sampler.WrapS
+			}
+			if t.Sampler.WrapT != 0 {
+				rt.wrapT = t.Sampler.WrapT
+			}
+			rt.nearest = t.Sampler.MagFilter == NEAREST
+		}
+		rt.texels = make([]vkm.Vec, rt.width*rt.height)
+		for y := 0; y < rt.height; y++ {
+			for x := 0; x < rt.width; x++ {
+				c := color.NRGBA64Model.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA64)
+				texel := vkm.Vec{float32(c.R) / 0xffff, float32(c.G) / 0xffff, float32(c.B) / 0xffff, float32(c.A) / 0xffff}
+				if srgb {
+					for k := 0; k < 3; k++ {
+						texel[k] = srgbToLinear(texel[k])
+					}
+				}
+				rt.texels[y*rt.width+x] = texel
+			}
+		}
+		r.textures[key] = rt
+	}
+	if rt.width == 0 || rt.height == 0 {
+		return nil, nil
+	}
+	return &renderTextureInfo{texture: rt, texCoord: int(texCoord), uvMatrix: IdentityUVMatrix()}, nil
+}
+
+// sample filters the texture at uv, with bilinear filtering unless the sampler's magnification filter is NEAREST.
+func (t *renderTexture) sample(uv vkm.Vec2) vkm.Vec {
+	x, y := uv[0]*float32(t.width), uv[1]*float32(t.height)
+	if t.nearest {
+		return t.texel(int(math32.Floor(x)), int(math32.Floor(y)))
+	}
+	x, y = x-0.5, y-0.5
+	x0, y0 := math32.Floor(x), math32.Floor(y)
+	fx, fy := x-x0, y-y0
+	ix, iy := int(x0), int(y0)
+	top := add4(t.texel(ix, iy).Scale(1-fx), t.texel(ix+1, iy).Scale(fx))
+	bottom := add4(t.texel(ix, iy+1).Scale(1-fx), t.texel(ix+1, iy+1).Scale(fx))
+	return add4(top.Scale(1-fy), bottom.Scale(fy))
+}
+
+func (t *renderTexture) texel(x, y int) vkm.Vec {
+	return t.texels[wrapTexel(y, t.height, t.wrapT)*t.width+wrapTexel(x, t.width, t.wrapS)]
+}
+
+func wrapTexel(i, n int, mode SamplerWrapEnum) int {
+	switch mode {
+	case CLAMP_TO_EDGE:
+		return clampInt(i, 0, n-1)
+	case MIRRORED_REPEAT:
+		i = ((i % (2 * n)) + 2*n) % (2 * n)
+		if i >= n {
+			i = 2*n - 1 - i
+		}
+		return i
+	default:
+		return ((i % n) + n) % n
+	}
+}
+
+func srgbToLinear(c float32) float32 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math32.Pow((c+0.055)/1.055, 2.4)
+}
+
+func linearToSrgb(c float32) float32 {
+	if c <= 0 {
+		return 0
+	} else if c >= 1 {
+		return 1
+	} else if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math32.Pow(c, 1/2.4) - 0.055
+}
+
+func clampInt(i, lo, hi int) int {
+	if i < lo {
+		return lo
+	} else if i > hi {
+		return hi
+	}
+	return i
+}
+
+func min3(v [3]float32) float32 {
+	return math32.Min(v[0], math32.Min(v[1], v[2]))
+}
+
+func max3(v [3]float32) float32 {
+	return math32.Max(v[0], math32.Max(v[1], v[2]))
+}
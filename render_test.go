@@ -0,0 +1,261 @@
+package gltf
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+// updateGolden rewrites the golden images in testdata from the current renderer, with go test -run Render -update.
+var updateGolden = flag.Bool("update", false, "update the golden images in testdata")
+
+// compareGolden compares img with testdata/name.png. A pixel matches if no channel differs by more than tolerance, and
+// up to maxMismatch pixels may differ by more, so that small floating point differences between platforms along
+// triangle edges do not fail the test. On a mismatch, the rendered image is written next to the golden image with an
+// .actual.png suffix for inspection.
+func compareGolden(t *testing.T, name string, img *image.RGBA, tolerance uint8, maxMismatch int) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".png")
+	if *updateGolden {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run the test with -update to create it)", err)
+	}
+	defer f.Close()
+	golden, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if golden.Bounds() != img.Bounds() {
+		t.Fatalf("%s: expected a %v image, got %v", name, golden.Bounds(), img.Bounds())
+	}
+
+	diff := func(a, b uint8) uint8 {
+		if a > b {
+			return a - b
+		}
+		return b - a
+	}
+	mismatched := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			got, want := img.RGBAAt(x, y), color.RGBAModel.Convert(golden.At(x, y)).(color.RGBA)
+			if diff(got.R, want.R) > tolerance || diff(got.G, want.G) > tolerance || diff(got.B, want.B) > tolerance ||
+				diff(got.A, want.A) > tolerance {
+				mismatched++
+			}
+		}
+	}
+	if mismatched > maxMismatch {
+		t.Errorf("%s: %d pixels differ from the golden image by more than %d", name, mismatched, tolerance)
+		actual := filepath.Join("testdata", name+".actual.png")
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Log(err)
+		} else if err := os.WriteFile(actual, buf.Bytes(), 0o644); err != nil {
+			t.Log(err)
+		} else {
+			t.Logf("the rendered image is in %s", actual)
+		}
+	}
+}
+
+// orthoQuadScene returns a scene with the given quad nodes, and a camera node whose view exactly covers the unit quad
+// made by uvQuad.
+func orthoQuadScene(nodes ...*ResolvedNode) (*ResolvedScene, *ResolvedNode) {
+	cam := &ResolvedNode{
+		Node: &Node{Name: "camera", Translation: &vkm.Vec3{0.5, 0.5, 5}},
+		Camera: &ResolvedCamera{Camera: &Camera{Type: ORTHOGRAPHIC, Orthographic: CameraOrthographic{
+			Xmag: 0.5, Ymag: 0.5, Znear: 0.1, Zfar: 10,
+		}}},
+	}
+	return &ResolvedScene{Scene: &Scene{}, Nodes: append(nodes, cam)}, cam
+}
+
+func quadNode(name GlTFId, z float32, m *ResolvedMaterial) *ResolvedNode {
+	p := uvQuad(0, 1, 1, 1, 1, 0, 0, 0)
+	p.Material = m
+	return &ResolvedNode{
+		Node: &Node{Name: name, Translation: &vkm.Vec3{0, 0, z}},
+		Mesh: &ResolvedMesh{Mesh: &Mesh{}, Primitives: []ResolvedPrimitive{*p}},
+	}
+}
+
+func colorMaterial(c vkm.Vec) *ResolvedMaterial {
+	metallic := float32(0)
+	pbr := &PbrMetallicRoughness{BaseColorFactor: &c, MetallicFactor: &metallic}
+	return &ResolvedMaterial{Material: &Material{PbrMetallicRoughness: pbr}, PbrMetallicRoughness: &ResolvedPbrMetallicRoughness{PbrMetallicRoughness: pbr}}
+}
+
+func TestRenderDepthAndAlpha(t *testing.T) {
+	red := colorMaterial(vkm.Vec{1, 0, 0, 1})
+	blue := colorMaterial(vkm.Vec{0, 0, 1, 1})
+	scene, cam := orthoQuadScene(quadNode("near", 1, red), quadNode("far", 0, blue))
+
+	img, err := scene.Render(RenderOptions{Width: 8, Height: 8, Camera: cam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if c := img.RGBAAt(x, y); c.A != 255 || c.R < 2*c.B || c.G != c.B {
+				t.Fatalf("expected the near red quad to cover pixel (%d, %d), got %v", x, y, c)
+			}
+		}
+	}
+
+	// A masked material below its cutoff is discarded, revealing the blue quad.
+	red.AlphaMode, red.PbrMetallicRoughness.BaseColorFactor = MASK, &vkm.Vec{1, 0, 0, 0.25}
+	img, err = scene.Render(RenderOptions{Width: 8, Height: 8, Camera: cam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(4, 4); c.B < 2*c.R {
+		t.Errorf("expected the masked quad to be discarded, got %v", c)
+	}
+
+	// Blended, the red quad is mixed over the blue one, even though it is drawn first.
+	red.AlphaMode, red.PbrMetallicRoughness.BaseColorFactor = BLEND, &vkm.Vec{1, 0, 0, 0.5}
+	img, err = scene.Render(RenderOptions{Width: 8, Height: 8, Camera: cam})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(4, 4); c.A != 255 || c.R < 2*c.G || c.B < 2*c.G || math.Abs(float64(c.R)-float64(c.B)) > 2 {
+		t.Errorf("expected an even mix of red and blue, got %v", c)
+	}
+}
+
+func TestRenderCulling(t *testing.T) {
+	m := colorMaterial(vkm.Vec{1, 1, 1, 1})
+	back := quadNode("back", 0, m)
+	back.Mesh.Primitives[0].Indices = NewIndexAccessor([]uint32{0, 2, 1, 0, 3, 2})
+	scene, cam := orthoQuadScene(back)
+
+	bg := color.RGBA{0, 255, 0, 255}
+	img, err := scene.Render(RenderOptions{Width: 4, Height: 4, Camera: cam, Background: bg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(2, 2); c != bg {
+		t.Errorf("expected the back face to be culled, got %v", c)
+	}
+
+	m.DoubleSided = true
+	img, err = scene.Render(RenderOptions{Width: 4, Height: 4, Camera: cam, Background: bg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(2, 2); c == bg || c.R != c.G || c.G != c.B {
+		t.Errorf("expected the double sided back face to be drawn in grey, got %v", c)
+	}
+}
+
+func TestRenderTexture(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	src.Set(0, 0, color.NRGBA{255, 0, 0, 255})
+	src.Set(1, 0, color.NRGBA{0, 255, 0, 255})
+	src.Set(0, 1, color.NRGBA{0, 0, 255, 255})
+	src.Set(1, 1, color.NRGBA{255, 255, 255, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	tex := &ResolvedTexture{
+		Texture: &Texture{},
+		Sampler: &Sampler{MagFilter: NEAREST},
+		Source:  &ResolvedImage{Image: &Image{}, BufferView: &ResolvedBufferView{BufferView: &BufferView{}, Data: buf.Bytes()}},
+	}
+	m := colorMaterial(vkm.Vec{1, 1, 1, 1})
+	m.PbrMetallicRoughness.BaseColorTexture = &ResolvedTextureInfo{TextureInfo: &TextureInfo{}, Texture: tex}
+	scene, cam := orthoQuadScene(quadNode("quad", 0, m))
+
+	img, err := scene.Render(RenderOptions{Width: 8, Height: 8, Camera: cam, Supersample: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dominant := func(c color.RGBA) string {
+		switch {
+		case c.R > c.G && c.R > c.B:
+			return "red"
+		case c.G > c.R && c.G > c.B:
+			return "green"
+		case c.B > c.R && c.B > c.G:
+			return "blue"
+		}
+		return "grey"
+	}
+	for _, tc := range []struct {
+		x, y     int
+		expected string
+	}{{1, 1, "red"}, {6, 1, "green"}, {1, 6, "blue"}, {6, 6, "grey"}} {
+		if c := img.RGBAAt(tc.x, tc.y); dominant(c) != tc.expected {
+			t.Errorf("expected pixel (%d, %d) to be %s, got %v", tc.x, tc.y, tc.expected, c)
+		}
+	}
+}
+
+func TestRenderAutoFrame(t *testing.T) {
+	scene := &ResolvedScene{Scene: &Scene{}, Nodes: []*ResolvedNode{quadNode("quad", 0, nil)}}
+	img, err := scene.Render(RenderOptions{Width: 32, Height: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The quad is framed in the middle of the image, with a transparent margin on either side.
+	if c := img.RGBAAt(16, 8); c.A != 255 {
+		t.Errorf("expected the quad in the centre of the image, got %v", c)
+	}
+	if c := img.RGBAAt(2, 8); c.A != 0 {
+		t.Errorf("expected the edge of the image to be empty, got %v", c)
+	}
+	covered := 0
+	for y := 0; y < 16; y++ {
+		if img.RGBAAt(16, y).A != 0 {
+			covered++
+		}
+	}
+	if covered < 8 || covered == 16 {
+		t.Errorf("expected the quad to fill most of the image height, covered %d rows", covered)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	// A red quad turned about Y, in front of a larger blended blue quad, seen through the automatic perspective camera
+	// over a grey background.
+	red := colorMaterial(vkm.Vec{1, 0.2, 0.1, 1})
+	red.DoubleSided = true
+	turned := quadNode("turned", 0.5, red)
+	turned.Rotation = &vkm.Vec{0, 0.258819, 0, 0.9659258}
+	blue := colorMaterial(vkm.Vec{0.1, 0.3, 1, 0.6})
+	blue.AlphaMode = BLEND
+	back := quadNode("back", 0, blue)
+	back.Translation, back.Scale = &vkm.Vec3{-0.5, -0.5, 0}, &vkm.Vec3{2, 2, 1}
+	scene := &ResolvedScene{Scene: &Scene{}, Nodes: []*ResolvedNode{turned, back}}
+
+	img, err := scene.Render(RenderOptions{Width: 96, Height: 64, Background: color.RGBA{64, 64, 64, 255}, Supersample: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, "render_quads", img, 2, 8)
+}
@@ -1,12 +1,12 @@
 package gltf
 
 import (
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"github.com/bbredesen/vkm"
+	"strings"
 )
 
 // TODO: resolve's probably need error checking for indexing issues...e.g. scene references a node index that doesn't
@@ -25,8 +25,33 @@ import (
 //
 // Paths will be searched in the order provided and the first matching file will be used. If the GlTF instance  was loaded from a
 // file object, or from a file name, and if uriSearchPath is empty, then that location will be searched by default.
+//
+// Resolve fails if the document requires an extension that is not supported, as described for ResolveWithOptions.
 func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
-	rval := &ResolvedGlTF{GlTF: gltf}
+	return gltf.ResolveWithOptions(ResolveOptions{SearchPaths: uriSearchPath})
+}
+
+// ResolveOptions controls ResolveWithOptions.
+type ResolveOptions struct {
+	// SearchPaths are the paths searched for URIs, as described for Resolve.
+	SearchPaths []string
+	// SupportedExtensions lists extensions that the caller handles itself, which are supported in addition to those
+	// registered with RegisterExtension.
+	SupportedExtensions []string
+}
+
+// ResolveWithOptions is Resolve with more options. Before anything is loaded, the document's extensions are checked with
+// CheckExtensions, supporting the registered extensions and opts.SupportedExtensions. Any warnings are kept in the
+// result's Warnings, along with warnings for material extensions that are combined in a way that has no effect, such
+// as KHR_materials_volume without KHR_materials_transmission, and for mesh attributes whose formats the spec does not
+// allow. Attribute formats are only an error in documents that use KHR_mesh_quantization, which defines what they may
+// be.
+func (gltf *GlTF) ResolveWithOptions(opts ResolveOptions) (*ResolvedGlTF, error) {
+	warnings, err := gltf.CheckExtensions(append(RegisteredExtensions(), opts.SupportedExtensions...))
+	if err != nil {
+		return nil, err
+	}
+	rval := &ResolvedGlTF{GlTF: gltf, Warnings: warnings}
 
 	for i := range gltf.Buffers {
 		if rb, err := gltf.Buffers[i].resolve(rval); err != nil {
@@ -36,10 +61,45 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 		}
 	}
 
+	if err := rval.resolveReferences(); err != nil {
+		return rval, err
+	}
+	fallback := true
+	for _, name := range gltf.ExtensionsRequired {
+		if name == KHR_MATERIALS_UNLIT {
+			fallback = false
+		}
+	}
+	for i := range rval.Materials {
+		for _, w := range rval.Materials[i].extensionWarnings(fallback) {
+			rval.Warnings = append(rval.Warnings, fmt.Sprintf("Material %d: %s", i, w))
+		}
+	}
+	if !gltf.usesExtension(KHR_MESH_QUANTIZATION) {
+		for i := range rval.Meshes {
+			for j := range rval.Meshes[i].Primitives {
+				for _, w := range rval.Meshes[i].Primitives[j].attributeWarnings() {
+					rval.Warnings = append(rval.Warnings, fmt.Sprintf("Mesh %d, primitive %d: %s", i, j, w))
+				}
+			}
+		}
+	}
+	return rval, nil
+}
+
+// resolveReferences (re)builds every resolved object other than the buffers, which must already be loaded. This allows a
+// ResolvedGlTF to be brought back in sync with its GlTF after the document has been modified, without reloading binary
+// data.
+func (rval *ResolvedGlTF) resolveReferences() error {
+	gltf := rval.GlTF
+	rval.Animations, rval.BufferViews, rval.Cameras, rval.Accessors = nil, nil, nil, nil
+	rval.Images, rval.Lights, rval.Textures, rval.Materials, rval.Meshes, rval.Nodes = nil, nil, nil, nil, nil, nil
+	rval.Scene, rval.Scenes, rval.Skins, rval.Variants = nil, nil, nil, nil
+
 	for i := range gltf.BufferViews {
 		// tmp := bv
 		if rbv, err := gltf.BufferViews[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.BufferViews = append(rval.BufferViews, rbv)
 		}
@@ -47,7 +107,7 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 
 	for i := range gltf.Accessors {
 		if rac, err := gltf.Accessors[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Accessors = append(rval.Accessors, rac)
 		}
@@ -55,15 +115,47 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 
 	for i := range gltf.Cameras {
 		if rc, err := gltf.Cameras[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Cameras = append(rval.Cameras, rc)
 		}
 	}
 
+	lights, err := gltf.Lights()
+	if err != nil {
+		return err
+	}
+	for i := range lights {
+		if rl, err := lights[i].resolve(rval); err != nil {
+			return err
+		} else {
+			rval.Lights = append(rval.Lights, rl)
+		}
+	}
+
+	if rval.Variants, err = gltf.Variants(); err != nil {
+		return err
+	}
+
+	for i := range gltf.Images {
+		if ri, err := gltf.Images[i].resolve(rval); err != nil {
+			return err
+		} else {
+			rval.Images = append(rval.Images, ri)
+		}
+	}
+
+	for i := range gltf.Textures {
+		if rt, err := gltf.Textures[i].resolve(rval); err != nil {
+			return err
+		} else {
+			rval.Textures = append(rval.Textures, rt)
+		}
+	}
+
 	for i := range gltf.Materials {
 		if rm, err := gltf.Materials[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Materials = append(rval.Materials, rm)
 		}
@@ -71,7 +163,7 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 
 	for i := range gltf.Meshes {
 		if rm, err := gltf.Meshes[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Meshes = append(rval.Meshes, rm)
 		}
@@ -79,18 +171,25 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 
 	for i := range gltf.Nodes {
 		if rn, err := gltf.Nodes[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Nodes = append(rval.Nodes, rn)
 		}
 	}
+	for i := range gltf.Skins {
+		if rs, err := gltf.Skins[i].resolve(rval); err != nil {
+			return err
+		} else {
+			rval.Skins = append(rval.Skins, rs)
+		}
+	}
 	for i := range rval.Nodes {
 		rval.Nodes[i].populate(rval)
 	}
 
 	for i := range gltf.Animations {
 		if ra, err := gltf.Animations[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Animations = append(rval.Animations, ra)
 		}
@@ -98,7 +197,7 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 
 	for i := range gltf.Scenes {
 		if rs, err := gltf.Scenes[i].resolve(rval); err != nil {
-			return rval, err
+			return err
 		} else {
 			rval.Scenes = append(rval.Scenes, rs)
 		}
@@ -115,7 +214,7 @@ func (gltf *GlTF) Resolve(uriSearchPath []string) (*ResolvedGlTF, error) {
 	// 		rval.Meshes = append(rval.Meshes, rm)
 	// 	}
 	// }
-	return rval, nil
+	return nil
 }
 
 func (node *Node) resolve(root *ResolvedGlTF) (ResolvedNode, error) {
@@ -133,6 +232,17 @@ func (node *Node) resolve(root *ResolvedGlTF) (ResolvedNode, error) {
 		rval.Mesh = &root.Meshes[*node.Mesh]
 	}
 
+	if i, found := node.LightIndex(); found {
+		if i >= uint(len(root.Lights)) {
+			return rval, fmt.Errorf("Node light %d is not a valid light", i)
+		}
+		rval.Light = &root.Lights[i]
+	}
+
+	if err := node.resolveInstances(root, &rval); err != nil {
+		return rval, err
+	}
+
 	return rval, nil
 }
 
@@ -140,6 +250,32 @@ func (node *ResolvedNode) populate(root *ResolvedGlTF) {
 	for i, childIdx := range node.Node.Children {
 		node.Children[i] = &root.Nodes[childIdx]
 	}
+	if node.Node.Skin != nil {
+		node.Skin = &root.Skins[*node.Node.Skin]
+	}
+}
+
+func (s *Skin) resolve(root *ResolvedGlTF) (ResolvedSkin, error) {
+	rval := ResolvedSkin{
+		Skin: s,
+	}
+
+	if s.InverseBindMatrices != nil {
+		rval.InverseBindMatrices = &root.Accessors[*s.InverseBindMatrices]
+	}
+	if s.Skeleton != nil {
+		rval.Skeleton = &root.Nodes[*s.Skeleton]
+	}
+
+	rval.Joints = make([]*ResolvedNode, len(s.Joints))
+	for i, j := range s.Joints {
+		if j >= uint(len(root.Nodes)) {
+			return rval, fmt.Errorf("Skin joint %d is not a valid node", j)
+		}
+		rval.Joints[i] = &root.Nodes[j]
+	}
+
+	return rval, nil
 }
 
 func (buf *Buffer) resolve(root *ResolvedGlTF) (ResolvedBuffer, error) {
@@ -147,8 +283,12 @@ func (buf *Buffer) resolve(root *ResolvedGlTF) (ResolvedBuffer, error) {
 		Buffer: buf,
 	}
 
-	// Load the data; assume the URI just a file (for the moment)
-	data, err := os.ReadFile(root.meta.defaultSearchPath + string(filepath.Separator) + buf.Uri)
+	if buf.isMeshoptFallback() {
+		// Only compressed buffer views use the buffer, and they are decoded from elsewhere.
+		return rval, nil
+	}
+
+	data, err := root.readUri(buf.Uri)
 	if err != nil {
 		return rval, err
 	}
@@ -161,14 +301,44 @@ func (buf *Buffer) resolve(root *ResolvedGlTF) (ResolvedBuffer, error) {
 	return rval, nil
 }
 
+// readUri loads the data referenced by a buffer or image URI, which is either a base64 data URI or a file relative to
+// the document.
+func (root *ResolvedGlTF) readUri(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		header, data, found := strings.Cut(uri, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return nil, errors.New("Data URI is not base64 encoded")
+		}
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return os.ReadFile(root.meta.defaultSearchPath + string(filepath.Separator) + uri)
+}
+
 func (bv *BufferView) resolve(root *ResolvedGlTF) (ResolvedBufferView, error) {
 	rval := ResolvedBufferView{
 		BufferView: bv,
 	}
 
+	if bv.Buffer >= uint(len(root.Buffers)) {
+		return rval, fmt.Errorf("Buffer view buffer %d is not a valid buffer", bv.Buffer)
+	}
 	rval.Buffer = &root.Buffers[bv.Buffer]
-	rval.Data = rval.Buffer.Data[bv.ByteOffset : bv.ByteOffset+bv.ByteLength]
 
+	ext, _, err := GetExtension[EXTMeshoptCompression](bv)
+	if err != nil {
+		return rval, err
+	}
+	if ext != nil {
+		if rval.Data, err = ext.decode(root); err != nil {
+			return rval, fmt.Errorf("Could not decode buffer view %q: %w", bv.Name, err)
+		}
+		return rval, nil
+	}
+
+	if bv.ByteOffset+bv.ByteLength > uint(len(rval.Buffer.Data)) {
+		return rval, fmt.Errorf("Buffer view %q extends past the end of buffer %d", bv.Name, bv.Buffer)
+	}
+	rval.Data = rval.Buffer.Data[bv.ByteOffset : bv.ByteOffset+bv.ByteLength]
 	return rval, nil
 }
 
@@ -177,7 +347,23 @@ func (a *Accessor) resolve(root *ResolvedGlTF) (ResolvedAccessor, error) {
 		Accessor: a,
 	}
 
-	rval.BufferView = &root.BufferViews[a.BufferView]
+	// An accessor without a buffer view is all zeros, unless sparse values are substituted into it.
+	if a.BufferView != nil {
+		if *a.BufferView >= uint(len(root.BufferViews)) {
+			return rval, fmt.Errorf("Accessor buffer view %d is not a valid buffer view", *a.BufferView)
+		}
+		rval.BufferView = &root.BufferViews[*a.BufferView]
+	}
+	if a.Sparse != nil {
+		if a.Sparse.Indices.BufferView >= uint(len(root.BufferViews)) {
+			return rval, fmt.Errorf("Accessor sparse indices buffer view %d is not a valid buffer view", a.Sparse.Indices.BufferView)
+		}
+		if a.Sparse.Values.BufferView >= uint(len(root.BufferViews)) {
+			return rval, fmt.Errorf("Accessor sparse values buffer view %d is not a valid buffer view", a.Sparse.Values.BufferView)
+		}
+		rval.SparseIndices = &root.BufferViews[a.Sparse.Indices.BufferView]
+		rval.SparseValues = &root.BufferViews[a.Sparse.Values.BufferView]
+	}
 
 	return rval, nil
 }
@@ -187,15 +373,15 @@ func (c *Camera) resolve(root *ResolvedGlTF) (ResolvedCamera, error) {
 		Camera: c,
 	}
 
-	if c.Type == PERSPECTIVE {
-		rval.ProjMatrix = vkm.GlTFPerspective(c.Perspective.Yfov, c.Perspective.AspectRatio, c.Perspective.Znear, c.Perspective.Zfar)
-	} else if c.Type == ORTHOGRAPHIC {
-		rval.ProjMatrix = vkm.GlTFOrthoProjection(c.Orthographic.Xmag, c.Orthographic.Ymag, c.Orthographic.Znear, c.Orthographic.Zfar)
-	} else if c.Type == "" {
+	if c.Type == "" {
 		return rval, errors.New("Camera type not set on camera node")
-	} else {
+	} else if c.Type != PERSPECTIVE && c.Type != ORTHOGRAPHIC {
 		return rval, errors.New("Camera type not recognized: " + string(c.Type))
 	}
+	// Cameras without an aspect ratio, and any that are otherwise invalid, are left with a zero ProjMatrix.
+	if proj, err := c.Projection(0); err == nil {
+		rval.ProjMatrix = proj
+	}
 
 	return rval, nil
 }
@@ -207,17 +393,119 @@ func (s *Scene) resolve(root *ResolvedGlTF) (ResolvedScene, error) {
 
 	rval.Nodes = make([]*ResolvedNode, len(s.Nodes))
 	for i := range s.Nodes {
-		rval.Nodes[i] = &root.Nodes[i]
+		rval.Nodes[i] = &root.Nodes[s.Nodes[i]]
+	}
+
+	return rval, nil
+}
+
+func (img *Image) resolve(root *ResolvedGlTF) (ResolvedImage, error) {
+	rval := ResolvedImage{
+		Image: img,
+		root:  root,
+	}
+
+	if img.BufferView != nil {
+		if *img.BufferView >= uint(len(root.BufferViews)) {
+			return rval, fmt.Errorf("Image buffer view %d is not a valid buffer view", *img.BufferView)
+		}
+		rval.BufferView = &root.BufferViews[*img.BufferView]
 	}
 
 	return rval, nil
 }
 
+func (t *Texture) resolve(root *ResolvedGlTF) (ResolvedTexture, error) {
+	rval := ResolvedTexture{
+		Texture: t,
+	}
+
+	if t.Sampler != nil {
+		if *t.Sampler >= uint(len(root.GlTF.Samplers)) {
+			return rval, fmt.Errorf("Texture sampler %d is not a valid sampler", *t.Sampler)
+		}
+		rval.Sampler = &root.GlTF.Samplers[*t.Sampler]
+	}
+	if t.Source != nil {
+		if *t.Source >= uint(len(root.Images)) {
+			return rval, fmt.Errorf("Texture source %d is not a valid image", *t.Source)
+		}
+		rval.Source = &root.Images[*t.Source]
+	}
+
+	return rval, nil
+}
+
+// texture returns the resolved texture at index i, or an error if there is no such texture.
+func (root *ResolvedGlTF) texture(i uint) (*ResolvedTexture, error) {
+	if i >= uint(len(root.Textures)) {
+		return nil, fmt.Errorf("Texture %d is not a valid texture", i)
+	}
+	return &root.Textures[i], nil
+}
+
+func (ti *TextureInfo) resolve(root *ResolvedGlTF) (*ResolvedTextureInfo, error) {
+	if ti == nil {
+		return nil, nil
+	}
+	rval := &ResolvedTextureInfo{TextureInfo: ti}
+	var err error
+	if rval.Texture, err = root.texture(ti.Index); err != nil {
+		return rval, err
+	}
+	if rval.TexCoord, rval.UVMatrix, err = ti.TextureTransform(); err != nil {
+		return rval, fmt.Errorf("Texture %d: %w", ti.Index, err)
+	}
+	return rval, nil
+}
+
+func (nt *NormalTextureInfo) resolve(root *ResolvedGlTF) (*ResolvedNormalTextureInfo, error) {
+	if nt == nil {
+		return nil, nil
+	}
+	ti, err := nt.TextureInfo.resolve(root)
+	if err != nil {
+		return nil, err
+	}
+	return &ResolvedNormalTextureInfo{
+		NormalTextureInfo: nt, Texture: ti.Texture, TexCoord: ti.TexCoord, UVMatrix: ti.UVMatrix,
+	}, nil
+}
+
 func (m *Material) resolve(root *ResolvedGlTF) (ResolvedMaterial, error) {
 	rval := ResolvedMaterial{
 		Material: m,
 	}
 
+	var err error
+	if pbr := m.PbrMetallicRoughness; pbr != nil {
+		rval.PbrMetallicRoughness = &ResolvedPbrMetallicRoughness{PbrMetallicRoughness: pbr}
+		if rval.PbrMetallicRoughness.BaseColorTexture, err = pbr.BaseColorTexture.resolve(root); err != nil {
+			return rval, err
+		}
+		if rval.PbrMetallicRoughness.MetallicRoughnessTexture, err = pbr.MetallicRoughnessTexture.resolve(root); err != nil {
+			return rval, err
+		}
+	}
+	if rval.NormalTexture, err = m.NormalTexture.resolve(root); err != nil {
+		return rval, err
+	}
+	if m.OcclusionTexture != nil {
+		ti, err := m.OcclusionTexture.TextureInfo.resolve(root)
+		if err != nil {
+			return rval, err
+		}
+		rval.OcclusionTexture = &ResolvedOcclusionTextureInfo{
+			OcclusionTextureInfo: m.OcclusionTexture, Texture: ti.Texture, TexCoord: ti.TexCoord, UVMatrix: ti.UVMatrix,
+		}
+	}
+	if rval.EmissiveTexture, err = m.EmissiveTexture.resolve(root); err != nil {
+		return rval, err
+	}
+	if err = m.resolveExtensions(root, &rval); err != nil {
+		return rval, err
+	}
+
 	return rval, nil
 }
 
@@ -226,8 +514,8 @@ func (m *Mesh) resolve(root *ResolvedGlTF) (ResolvedMesh, error) {
 		Mesh: m,
 	}
 
-	for _, primitive := range m.Primitives {
-		if rp, err := primitive.resolve(root); err != nil {
+	for i := range m.Primitives {
+		if rp, err := m.Primitives[i].resolve(root); err != nil {
 			return rval, err
 		} else {
 			rval.Primitives = append(rval.Primitives, rp)
@@ -240,22 +528,73 @@ func (m *Mesh) resolve(root *ResolvedGlTF) (ResolvedMesh, error) {
 func (p *Primitive) resolve(root *ResolvedGlTF) (ResolvedPrimitive, error) {
 	rval := ResolvedPrimitive{
 		Primitive: p,
+		Mode:      TRIANGLES,
+	}
+
+	if p.Mode != nil {
+		rval.Mode = *p.Mode
 	}
 
 	if p.Material != nil {
 		rval.Material = &root.Materials[*p.Material]
+		rval.DefaultMaterial = rval.Material
+	}
+	if err := p.resolveVariants(root, &rval); err != nil {
+		return rval, err
 	}
 
+	// Compressed attributes and indices are replaced with standalone accessors holding the decoded data.
+	draco, compressed, err := GetExtension[KHRDracoMeshCompression](p)
+	if err != nil {
+		return rval, err
+	}
+	var decoded *DracoMesh
+	if compressed {
+		if decoded, err = draco.decode(root); err != nil {
+			return rval, err
+		}
+	}
+
+	quantized := root.usesExtension(KHR_MESH_QUANTIZATION)
 	rval.Attributes = make(map[AttributeKey]*ResolvedAccessor, len(p.Attributes))
 	for k, attrIdx := range p.Attributes {
 		rval.Attributes[k] = &root.Accessors[attrIdx]
+		if id, found := draco.attribute(k); found {
+			if rval.Attributes[k], err = decoded.accessor(id, rval.Attributes[k].Accessor); err != nil {
+				return rval, err
+			}
+		}
+		if quantized {
+			if err := ValidateAttribute(k, rval.Attributes[k].Accessor, true, false); err != nil {
+				return rval, err
+			}
+		}
 	}
 
 	if p.Indices != nil {
 		rval.Indices = &root.Accessors[*p.Indices]
+		if compressed {
+			if rval.Indices, err = decoded.indices(rval.Indices.Accessor); err != nil {
+				return rval, err
+			}
+		}
+	} else if compressed && len(decoded.Faces) > 0 {
+		rval.Indices = NewIndexAccessor(decoded.Faces)
+	}
+
+	for _, target := range p.Targets {
+		rt := make(map[AttributeKey]*ResolvedAccessor, len(target))
+		for k, attrIdx := range target {
+			rt[k] = &root.Accessors[attrIdx]
+			if quantized {
+				if err := ValidateAttribute(k, rt[k].Accessor, true, true); err != nil {
+					return rval, err
+				}
+			}
+		}
+		rval.Targets = append(rval.Targets, rt)
 	}
 
-	//temp comment
 	return rval, nil
 }
 
@@ -20,16 +20,28 @@ type ResolvedGlTF struct {
 	BufferViews []ResolvedBufferView
 	Cameras     []ResolvedCamera
 	Accessors   []ResolvedAccessor
+	Images      []ResolvedImage
+	Lights      []ResolvedLight
+	Textures    []ResolvedTexture
 	Materials   []ResolvedMaterial
 	Meshes      []ResolvedMesh
 	Nodes       []ResolvedNode
 
 	Scene  *ResolvedScene
 	Scenes []ResolvedScene
+	Skins  []ResolvedSkin
+
+	// Variants are the material variants of the KHR_materials_variants extension, which SelectVariant chooses between.
+	Variants []MaterialVariant
+
+	// Warnings describes problems found in the document that did not prevent it from being resolved.
+	Warnings []string
 }
 
 type ResolvedCamera struct {
 	*Camera
+	// ProjMatrix is the camera's projection using its own aspect ratio, or zero if it does not have one. Use Projection
+	// to compute it for the aspect ratio of a viewport.
 	ProjMatrix vkm.Mat
 }
 
@@ -37,7 +49,23 @@ type ResolvedNode struct {
 	*Node
 	Camera   *ResolvedCamera
 	Children []*ResolvedNode
-	Mesh     *ResolvedMesh
+	// Light is set from the node's KHR_lights_punctual extension.
+	Light *ResolvedLight
+	Mesh  *ResolvedMesh
+	Skin  *ResolvedSkin
+	// InstanceAttributes are the per-instance accessors of the node's EXT_mesh_gpu_instancing extension, and Instances
+	// the local transform of each instance, from its TRANSLATION, ROTATION and SCALE. Both are nil for a node without
+	// the extension. An instanced mesh is drawn once per instance, and never at the node's own transform.
+	InstanceAttributes map[AttributeKey]*ResolvedAccessor
+	Instances          []vkm.Mat
+}
+
+type ResolvedSkin struct {
+	*Skin
+	// InverseBindMatrices is nil if the skin does not have them, in which case they are all identity matrices.
+	InverseBindMatrices *ResolvedAccessor
+	Skeleton            *ResolvedNode
+	Joints              []*ResolvedNode
 }
 
 type ResolvedScene struct {
@@ -53,13 +81,17 @@ type ResolvedBuffer struct {
 type ResolvedBufferView struct {
 	*BufferView
 	Buffer *ResolvedBuffer
-	// Data in the BufferView is a subslice (i.e. shared memory) from the source buffer data
+	// Data in the BufferView is a subslice (i.e. shared memory) from the source buffer data, except for a buffer view
+	// compressed with EXT_meshopt_compression, which holds its own decoded data.
 	Data []byte
 }
 
 type ResolvedAccessor struct {
 	*Accessor
 	BufferView *ResolvedBufferView
+	// SparseIndices and SparseValues are only set for sparse accessors
+	SparseIndices *ResolvedBufferView
+	SparseValues  *ResolvedBufferView
 }
 
 type ResolvedMesh struct {
@@ -71,14 +103,90 @@ type ResolvedPrimitive struct {
 	*Primitive
 	Attributes map[AttributeKey]*ResolvedAccessor
 	Indices    *ResolvedAccessor
-	Material   *ResolvedMaterial
+	// Material is the material that the primitive is drawn with. It is set to DefaultMaterial, the primitive's own
+	// material, until another variant is chosen with SelectVariant.
+	Material        *ResolvedMaterial
+	DefaultMaterial *ResolvedMaterial
+	// VariantMaterials maps the index of each material variant in ResolvedGlTF.Variants to the material that the
+	// primitive uses for it, from KHR_materials_variants. Variants that are not mapped use DefaultMaterial.
+	VariantMaterials map[uint]*ResolvedMaterial
+	// Mode is the primitive's topology, with the spec default of TRIANGLES applied when not set in the source.
+	Mode ModeEnum
+	// Targets holds the primitive's morph targets, each of which maps attributes to accessors of displacements.
+	Targets []map[AttributeKey]*ResolvedAccessor
+}
+
+type ResolvedImage struct {
+	*Image
+	// BufferView is set for images stored in the binary data rather than at a URI.
+	BufferView *ResolvedBufferView
+
+	root *ResolvedGlTF
+}
+
+type ResolvedTexture struct {
+	*Texture
+	// Sampler is nil if the texture does not have one, in which case repeat wrapping and automatic filtering apply.
+	Sampler *Sampler
+	Source  *ResolvedImage
 }
 
+// ResolvedTextureInfo masks TexCoord with the texture coordinate set that is actually sampled, which
+// KHR_texture_transform may override, and adds UVMatrix, which transforms the coordinates before sampling. UVMatrix is
+// the identity for a texture info without KHR_texture_transform. The same applies to the normal and occlusion texture
+// infos.
+type ResolvedTextureInfo struct {
+	*TextureInfo
+	Texture  *ResolvedTexture
+	TexCoord uint
+	UVMatrix UVMatrix
+}
+
+type ResolvedNormalTextureInfo struct {
+	*NormalTextureInfo
+	Texture  *ResolvedTexture
+	TexCoord uint
+	UVMatrix UVMatrix
+}
+
+type ResolvedOcclusionTextureInfo struct {
+	*OcclusionTextureInfo
+	Texture  *ResolvedTexture
+	TexCoord uint
+	UVMatrix UVMatrix
+}
+
+type ResolvedPbrMetallicRoughness struct {
+	*PbrMetallicRoughness
+	BaseColorTexture         *ResolvedTextureInfo
+	MetallicRoughnessTexture *ResolvedTextureInfo
+}
+
+// ResolvedMaterial masks each of the material's texture references with one that points to the resolved texture. A
+// texture reference is nil where the source's is.
+//
+// The material extensions are decoded and resolved in the same way. Each extension field is nil if the material does
+// not have that extension, except for the scalar ones, which hold the spec defaults instead: 1.5 for IOR, 1 for
+// EmissiveStrength and 0 for Dispersion. Unlit is set by KHR_materials_unlit.
 type ResolvedMaterial struct {
 	*Material
-
-	// Camera *ResolvedCamera
-	// TODO...
+	PbrMetallicRoughness *ResolvedPbrMetallicRoughness
+	NormalTexture        *ResolvedNormalTextureInfo
+	OcclusionTexture     *ResolvedOcclusionTextureInfo
+	EmissiveTexture      *ResolvedTextureInfo
+
+	Clearcoat        *ResolvedClearcoat
+	Transmission     *ResolvedTransmission
+	Volume           *ResolvedVolume
+	Sheen            *ResolvedSheen
+	Specular         *ResolvedSpecular
+	IOR              float32
+	EmissiveStrength float32
+
+	Unlit       bool
+	Iridescence *ResolvedIridescence
+	Anisotropy  *ResolvedAnisotropy
+	Dispersion  float32
 }
 
 type ResolvedAnimation struct {
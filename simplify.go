@@ -0,0 +1,431 @@
+package gltf
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/bbredesen/vkm"
+)
+
+// SimplifyOptions controls Simplify. At least one of TargetRatio and TargetError must be set.
+type SimplifyOptions struct {
+	// TargetRatio is the fraction of the primitive's triangles to keep, between 0 and 1. Zero simplifies as far as
+	// TargetError allows.
+	TargetRatio float32
+	// TargetError is the largest geometric error allowed, as a fraction of the size of the primitive (the largest
+	// extent of its bounding box). Zero means no limit, so that simplification stops only at TargetRatio.
+	TargetError float32
+	// LockBorder keeps every vertex on an open border of the mesh in place, so that neighbouring primitives still meet
+	// without cracks. Otherwise border vertices may move, but only along the border.
+	LockBorder bool
+}
+
+// LOD is one level of detail generated by GenerateLODs.
+type LOD struct {
+	Primitive *ResolvedPrimitive
+	// Error is the geometric error of this level relative to the source primitive, as a fraction of its size.
+	Error float32
+}
+
+// Weights of the quadrics that keep borders and attribute seams in place, relative to those of the faces.
+const (
+	simplifyBorderWeight = 10
+	simplifySeamWeight   = 1
+)
+
+// Simplify returns a copy of the primitive, in TRIANGLES mode, with fewer triangles, along with the geometric error
+// of the result as a fraction of the primitive's size. Vertices are removed by edge collapse, in order of increasing
+// error as measured by quadric error metrics (Garland and Heckbert, "Surface Simplification Using Quadric Error
+// Metrics", 1997). Every remaining vertex is one of the source vertices, with all of its attributes unchanged.
+//
+// Vertices at the same position but with different attributes (e.g. on a UV seam or a hard edge in the normals) are
+// kept together, so seams stay closed, and vertices on a seam only move along it. Open borders are preserved in the
+// same way, or locked entirely with LockBorder. Vertices on non-manifold edges never move.
+func (p *ResolvedPrimitive) Simplify(opts SimplifyOptions) (*ResolvedPrimitive, float32, error) {
+	if opts.TargetRatio < 0 || opts.TargetRatio > 1 {
+		return nil, 0, errors.New("Simplify target ratio must be between 0 and 1")
+	} else if opts.TargetRatio == 0 && opts.TargetError <= 0 {
+		return nil, 0, errors.New("Simplify needs a target ratio or a target error")
+	}
+
+	posAcc := p.Attributes[POSITION]
+	if posAcc == nil {
+		return nil, 0, errors.New("Primitive has no POSITION attribute")
+	}
+	pos, err := posAcc.ReadVec3s()
+	if err != nil {
+		return nil, 0, err
+	}
+	tris, err := p.TriangleIndices()
+	if err != nil {
+		return nil, 0, err
+	}
+	vertices, err := p.vertexVectors()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, idx := range tris {
+		if int(idx) >= len(vertices) {
+			return nil, 0, errors.New("Primitive index is out of range of its attributes")
+		}
+	}
+
+	// Merge vertices that are identical in every attribute, so that the only remaining splits are real seams.
+	wedgeMap, remap := weldVectors(vertices, tris, 0)
+	s := newSimplifier(pos, weldPositions(pos, 0), wedgeMap, remap, tris, opts.LockBorder)
+
+	target := int(opts.TargetRatio * float32(len(tris)/3))
+	maxError := math.Inf(1)
+	if opts.TargetError > 0 {
+		maxError = float64(opts.TargetError) * float64(opts.TargetError)
+	}
+	resultError := s.run(target, maxError)
+
+	var indices []uint32
+	for t, tri := range s.tris {
+		if s.alive[t] {
+			indices = append(indices, wedgeMap[tri[0]], wedgeMap[tri[1]], wedgeMap[tri[2]])
+		}
+	}
+	vertexMap, indices := OptimizeVertexFetch(indices, len(vertices))
+	rval, err := p.WithVertices(vertexMap, indices)
+	return rval, float32(math.Sqrt(resultError)), err
+}
+
+// GenerateLODs simplifies the primitive once for each ratio in ratios, using opts for everything other than the target
+// ratio. Each level is simplified from the source primitive, so ratios are relative to its triangle count and should
+// normally be decreasing. The results can be added to a document with AddLODs.
+func (p *ResolvedPrimitive) GenerateLODs(ratios []float32, opts SimplifyOptions) ([]LOD, error) {
+	rval := make([]LOD, len(ratios))
+	for i, ratio := range ratios {
+		opts.TargetRatio = ratio
+		prim, e, err := p.Simplify(opts)
+		if err != nil {
+			return nil, err
+		}
+		rval[i] = LOD{Primitive: prim, Error: e}
+	}
+	return rval, nil
+}
+
+// quadric is a symmetric 4x4 matrix measuring the weighted sum of squared distances to a set of planes, along with the
+// total weight.
+type quadric struct {
+	a00, a11, a22, a01, a02, a12 float64
+	b0, b1, b2, c                float64
+	w                            float64
+}
+
+// planeQuadric returns the quadric for the plane through p with unit normal n.
+func planeQuadric(n, p vkm.Vec3, weight float64) quadric {
+	nx, ny, nz := float64(n[0]), float64(n[1]), float64(n[2])
+	d := -(nx*float64(p[0]) + ny*float64(p[1]) + nz*float64(p[2]))
+	return quadric{
+		a00: weight * nx * nx, a11: weight * ny * ny, a22: weight * nz * nz,
+		a01: weight * nx * ny, a02: weight * nx * nz, a12: weight * ny * nz,
+		b0: weight * nx * d, b1: weight * ny * d, b2: weight * nz * d,
+		c: weight * d * d,
+		w: weight,
+	}
+}
+
+func (q quadric) add(o quadric) quadric {
+	return quadric{
+		q.a00 + o.a00, q.a11 + o.a11, q.a22 + o.a22, q.a01 + o.a01, q.a02 + o.a02, q.a12 + o.a12,
+		q.b0 + o.b0, q.b1 + o.b1, q.b2 + o.b2, q.c + o.c,
+		q.w + o.w,
+	}
+}
+
+// error returns the weighted mean squared distance from p to the quadric's planes.
+func (q quadric) error(p vkm.Vec3) float64 {
+	if q.w == 0 {
+		return 0
+	}
+	x, y, z := float64(p[0]), float64(p[1]), float64(p[2])
+	e := q.a00*x*x + q.a11*y*y + q.a22*z*z + 2*(q.a01*x*y+q.a02*x*z+q.a12*y*z) + 2*(q.b0*x+q.b1*y+q.b2*z) + q.c
+	return math.Abs(e) / q.w
+}
+
+// simplifier holds the state of an edge collapse simplification. Triangles are made of wedges, i.e. distinct
+// combinations of attributes, and each wedge belongs to a position vertex shared by every wedge at that position.
+// Collapses move one position vertex onto another, and every wedge at the first onto the matching wedge at the second.
+type simplifier struct {
+	pos      []vkm.Vec3 // by position vertex, scaled so the mesh fits in a unit cube
+	posOf    []uint32   // position vertex of each wedge
+	tris     [][3]uint32
+	alive    []bool
+	live     int
+	adj      [][]int // triangles around each position vertex; may include dead triangles
+	quadrics []quadric
+	border   []bool
+	locked   []bool
+}
+
+func newSimplifier(pos []vkm.Vec3, posGroup, wedgeMap, remap, indices []uint32, lockBorder bool) *simplifier {
+	s := &simplifier{
+		pos:      make([]vkm.Vec3, len(pos)),
+		posOf:    make([]uint32, len(wedgeMap)),
+		adj:      make([][]int, len(pos)),
+		quadrics: make([]quadric, len(pos)),
+		border:   make([]bool, len(pos)),
+		locked:   make([]bool, len(pos)),
+	}
+	for w, src := range wedgeMap {
+		s.posOf[w] = posGroup[src]
+	}
+
+	lo, hi := vkm.Vec3{float32(math.Inf(1)), float32(math.Inf(1)), float32(math.Inf(1))}, vkm.Vec3{float32(math.Inf(-1)), float32(math.Inf(-1)), float32(math.Inf(-1))}
+	for _, idx := range indices {
+		for c := 0; c < 3; c++ {
+			lo[c] = float32(math.Min(float64(lo[c]), float64(pos[idx][c])))
+			hi[c] = float32(math.Max(float64(hi[c]), float64(pos[idx][c])))
+		}
+	}
+	extent := float32(0)
+	for c := 0; c < 3; c++ {
+		extent = max32(extent, hi[c]-lo[c])
+	}
+	scale := float32(1)
+	if extent > 0 {
+		scale = 1 / extent
+	}
+	for i, p := range pos {
+		s.pos[i] = p.Sub(lo).Scale(scale)
+	}
+
+	// Triangles that are degenerate at the position level are dropped up front.
+	for i := 0; i+2 < len(indices); i += 3 {
+		tri := [3]uint32{remap[indices[i]], remap[indices[i+1]], remap[indices[i+2]]}
+		a, b, c := s.posOf[tri[0]], s.posOf[tri[1]], s.posOf[tri[2]]
+		if a == b || a == c || b == c {
+			continue
+		}
+		t := len(s.tris)
+		s.tris = append(s.tris, tri)
+		s.alive = append(s.alive, true)
+		for k := 0; k < 3; k++ {
+			s.adj[s.posOf[tri[k]]] = append(s.adj[s.posOf[tri[k]]], t)
+		}
+	}
+	s.live = len(s.tris)
+
+	// Face quadrics, weighted by area.
+	faceNormals := make([]vkm.Vec3, len(s.tris))
+	for t, tri := range s.tris {
+		p0, p1, p2 := s.pos[s.posOf[tri[0]]], s.pos[s.posOf[tri[1]]], s.pos[s.posOf[tri[2]]]
+		n := cross3(p1.Sub(p0), p2.Sub(p0))
+		area := n.Length() / 2
+		if area == 0 {
+			continue
+		}
+		faceNormals[t] = n.Scale(1 / (2 * area))
+		q := planeQuadric(faceNormals[t], p0, float64(area))
+		for k := 0; k < 3; k++ {
+			s.quadrics[s.posOf[tri[k]]] = s.quadrics[s.posOf[tri[k]]].add(q)
+		}
+	}
+
+	// Classify edges, and add quadrics for planes perpendicular to the mesh along borders and seams, so that moving
+	// a vertex off the line of a border or seam has a cost.
+	type edgeUse struct {
+		tri    int
+		w0, w1 uint32 // the wedges at the edge's lower and higher position vertex
+	}
+	edges := make(map[[2]uint32][]edgeUse)
+	for t, tri := range s.tris {
+		for k := 0; k < 3; k++ {
+			w0, w1 := tri[k], tri[(k+1)%3]
+			if s.posOf[w1] < s.posOf[w0] {
+				w0, w1 = w1, w0
+			}
+			key := [2]uint32{s.posOf[w0], s.posOf[w1]}
+			edges[key] = append(edges[key], edgeUse{t, w0, w1})
+		}
+	}
+	edgePlane := func(key [2]uint32, tri int, weight float64) {
+		p0, p1 := s.pos[key[0]], s.pos[key[1]]
+		e := p1.Sub(p0)
+		n := normalizeOr(cross3(e, faceNormals[tri]), vkm.Vec3{})
+		q := planeQuadric(n, p0, weight*float64(e.SquareLength()))
+		s.quadrics[key[0]] = s.quadrics[key[0]].add(q)
+		s.quadrics[key[1]] = s.quadrics[key[1]].add(q)
+	}
+	for key, uses := range edges {
+		switch {
+		case len(uses) == 1:
+			s.border[key[0]], s.border[key[1]] = true, true
+			s.locked[key[0]] = s.locked[key[0]] || lockBorder
+			s.locked[key[1]] = s.locked[key[1]] || lockBorder
+			edgePlane(key, uses[0].tri, simplifyBorderWeight)
+		case len(uses) > 2:
+			s.locked[key[0]], s.locked[key[1]] = true, true
+		case uses[0].w0 != uses[1].w0 || uses[0].w1 != uses[1].w1:
+			edgePlane(key, uses[0].tri, simplifySeamWeight)
+			edgePlane(key, uses[1].tri, simplifySeamWeight)
+		}
+	}
+
+	return s
+}
+
+// run collapses edges until at most target triangles remain or no collapse has an error below maxError, and returns
+// the largest error of any collapse made. Each pass sorts every candidate collapse by error, then makes as many as
+// possible, with at most one involving any vertex.
+func (s *simplifier) run(target int, maxError float64) float64 {
+	type collapse struct {
+		u, v uint32
+		cost float64
+	}
+	var resultError float64
+
+	for s.live > target {
+		seen := make(map[[2]uint32]bool)
+		var candidates []collapse
+		for t, tri := range s.tris {
+			if !s.alive[t] {
+				continue
+			}
+			for k := 0; k < 3; k++ {
+				a, b := s.posOf[tri[k]], s.posOf[tri[(k+1)%3]]
+				if b < a {
+					a, b = b, a
+				}
+				if seen[[2]uint32{a, b}] {
+					continue
+				}
+				seen[[2]uint32{a, b}] = true
+
+				best := collapse{cost: math.Inf(1)}
+				for _, c := range []collapse{{u: a, v: b}, {u: b, v: a}} {
+					if s.locked[c.u] || (s.border[c.u] && !s.border[c.v]) {
+						continue
+					}
+					c.cost = s.quadrics[c.u].add(s.quadrics[c.v]).error(s.pos[c.v])
+					if c.cost < best.cost {
+						best = c
+					}
+				}
+				if best.cost <= maxError {
+					candidates = append(candidates, best)
+				}
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].cost < candidates[j].cost
+		})
+
+		touched := make(map[uint32]bool)
+		collapsed := 0
+		for _, c := range candidates {
+			if s.live <= target {
+				break
+			}
+			if touched[c.u] || touched[c.v] || !s.collapse(c.u, c.v) {
+				continue
+			}
+			touched[c.u], touched[c.v] = true, true
+			resultError = math.Max(resultError, c.cost)
+			collapsed++
+		}
+		if collapsed == 0 {
+			break
+		}
+	}
+
+	return resultError
+}
+
+// collapse moves position vertex u onto v, if that does not change the topology of the mesh, open a seam, or flip a
+// triangle. It returns whether the collapse was made.
+func (s *simplifier) collapse(u, v uint32) bool {
+	var edgeTris, otherTris []int
+	wedges := make(map[uint32]uint32)
+	neighbours := make(map[uint32]bool)
+	for _, t := range s.adj[u] {
+		if !s.alive[t] {
+			continue
+		}
+		ku, kv := s.corner(t, u), s.corner(t, v)
+		for k := 0; k < 3; k++ {
+			if k != ku {
+				neighbours[s.posOf[s.tris[t][k]]] = true
+			}
+		}
+		if kv < 0 {
+			otherTris = append(otherTris, t)
+			continue
+		}
+
+		// Each wedge at u must move to a single wedge at v, as given by the triangles on either side of the edge.
+		wu, wv := s.tris[t][ku], s.tris[t][kv]
+		if existing, found := wedges[wu]; found && existing != wv {
+			return false
+		}
+		wedges[wu] = wv
+		edgeTris = append(edgeTris, t)
+	}
+
+	if len(edgeTris) == 0 || (s.border[u] && len(edgeTris) != 1) {
+		return false
+	}
+	for _, t := range otherTris {
+		if _, found := wedges[s.tris[t][s.corner(t, u)]]; !found {
+			return false
+		}
+	}
+
+	// The link condition: u and v may only share the neighbours opposite the collapsed edge, or the collapse would
+	// create non-manifold geometry.
+	common := 0
+	counted := make(map[uint32]bool)
+	for _, t := range s.adj[v] {
+		if !s.alive[t] {
+			continue
+		}
+		for _, w := range s.tris[t] {
+			if n := s.posOf[w]; n != v && n != u && neighbours[n] && !counted[n] {
+				counted[n] = true
+				common++
+			}
+		}
+	}
+	if common != len(edgeTris) {
+		return false
+	}
+
+	for _, t := range otherTris {
+		tri := s.tris[t]
+		p := [3]vkm.Vec3{s.pos[s.posOf[tri[0]]], s.pos[s.posOf[tri[1]]], s.pos[s.posOf[tri[2]]]}
+		before := cross3(p[1].Sub(p[0]), p[2].Sub(p[0]))
+		p[s.corner(t, u)] = s.pos[v]
+		after := cross3(p[1].Sub(p[0]), p[2].Sub(p[0]))
+		if after.Dot(before) <= 0 {
+			return false
+		}
+	}
+
+	for _, t := range edgeTris {
+		s.alive[t] = false
+		s.live--
+	}
+	for _, t := range otherTris {
+		k := s.corner(t, u)
+		s.tris[t][k] = wedges[s.tris[t][k]]
+	}
+	s.adj[v] = append(s.adj[v], otherTris...)
+	s.adj[u] = nil
+	s.quadrics[v] = s.quadrics[v].add(s.quadrics[u])
+	return true
+}
+
+// corner returns which corner of triangle t is at position vertex p, or -1 if none is.
+func (s *simplifier) corner(t int, p uint32) int {
+	for k, w := range s.tris[t] {
+		if s.posOf[w] == p {
+			return k
+		}
+	}
+	return -1
+}
@@ -0,0 +1,217 @@
+package gltf
+
+import (
+	"reflect"
+	"testing"
+)
+
+// bumpyGrid returns an n by n grid of quads over the unit square, with a UV seam down the middle column of vertices
+// and a small ripple in Z.
+func bumpyGrid(n int) *ResolvedPrimitive {
+	var pos, uv []float32
+	var indices []uint32
+	vertex := make(map[[3]int]uint32)
+	at := func(x, y, side int) uint32 {
+		if x != n/2 {
+			side = 0
+		}
+		key := [3]int{x, y, side}
+		if v, found := vertex[key]; found {
+			return v
+		}
+		fx, fy := float32(x)/float32(n), float32(y)/float32(n)
+		z := float32(0)
+		if (x+y)%2 == 0 {
+			z = 0.0001
+		}
+		pos = append(pos, fx, fy, z)
+		uv = append(uv, fx+float32(side), fy)
+		vertex[key] = uint32(len(pos)/3 - 1)
+		return vertex[key]
+	}
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			side := 0
+			if x >= n/2 {
+				side = 1
+			}
+			a, b, c, d := at(x, y, side), at(x+1, y, side), at(x+1, y+1, side), at(x, y+1, side)
+			indices = append(indices, a, b, c, a, c, d)
+		}
+	}
+	return &ResolvedPrimitive{
+		Primitive: &Primitive{},
+		Mode:      TRIANGLES,
+		Attributes: map[AttributeKey]*ResolvedAccessor{
+			POSITION:    NewFloatAccessor(VEC3, pos),
+			TexCoord(0): NewFloatAccessor(VEC2, uv),
+		},
+		Indices: NewIndexAccessor(indices),
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	src := bumpyGrid(16)
+
+	simplified, e, err := src.Simplify(SimplifyOptions{TargetRatio: 0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tris, _ := simplified.TriangleIndices()
+	if len(tris)/3 > 51 {
+		t.Errorf("expected at most 51 triangles, got %d", len(tris)/3)
+	}
+	if e > 0.001 {
+		t.Errorf("expected a tiny error for a nearly flat grid, got %f", e)
+	}
+
+	// The corners of the grid can't move.
+	pos, _ := simplified.Attributes[POSITION].ReadVec3s()
+	corners := 0
+	for _, p := range pos {
+		if (p[0] == 0 || p[0] == 1) && (p[1] == 0 || p[1] == 1) {
+			corners++
+		}
+	}
+	if corners != 4 {
+		t.Errorf("expected the 4 corners to be kept, found %d", corners)
+	}
+
+	// Every vertex on the seam column must still have a twin on the other side.
+	uv, _ := simplified.Attributes[TexCoord(0)].ReadVec2s()
+	seam := make(map[float32]int)
+	for i, p := range pos {
+		if p[0] == 0.5 {
+			if uv[i][0] > 1 {
+				seam[p[1]] += 2
+			} else {
+				seam[p[1]]++
+			}
+		}
+	}
+	if len(seam) < 2 {
+		t.Errorf("seam vertices were removed entirely")
+	}
+	for y, count := range seam {
+		if count != 3 {
+			t.Errorf("seam vertex at y=%f is only on one side of the seam", y)
+		}
+	}
+
+	locked, _, err := src.Simplify(SimplifyOptions{TargetRatio: 0.1, LockBorder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lockedPos, _ := locked.Attributes[POSITION].ReadVec3s(); len(lockedPos) < 4*16 {
+		t.Errorf("expected all %d border vertices to be kept, have %d vertices", 4*16, len(lockedPos))
+	}
+
+	if _, _, err := src.Simplify(SimplifyOptions{}); err == nil {
+		t.Error("expected an error with no target")
+	}
+}
+
+func TestAddLODs(t *testing.T) {
+	root := &ResolvedGlTF{GlTF: &GlTF{Nodes: []Node{{Name: "ground"}}, Scenes: []Scene{{Nodes: []uint{0}}}}}
+	src := bumpyGrid(8)
+	mesh, err := root.AddMesh("grid", []*ResolvedPrimitive{src})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.GlTF.Nodes[0].Mesh = &mesh
+
+	lods, err := src.GenerateLODs([]float32{0.5, 0.25}, SimplifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	levels := [][]*ResolvedPrimitive{{lods[0].Primitive}, {lods[1].Primitive}}
+	if err := root.AddLODs(0, levels, []float32{0.5, 0.2, 0.01}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids, _ := root.GlTF.Nodes[0].lodNodes(); len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("unexpected MSFT_lod ids %v", ids)
+	}
+	if root.Nodes[2].Name != "ground.LOD2" || root.Nodes[2].Mesh.Name != "grid.LOD2" {
+		t.Errorf("unexpected LOD node %q with mesh %q", root.Nodes[2].Name, root.Nodes[2].Mesh.Name)
+	}
+	if count := root.Nodes[2].Mesh.Primitives[0].Indices.Count; count > 3*32 {
+		t.Errorf("LOD2 should have at most 32 triangles, has %d", count/3)
+	}
+
+	// The LOD nodes are not in the scene, but must survive pruning.
+	if report, err := root.GlTF.Prune(); err != nil || len(report.Nodes) != 0 {
+		t.Errorf("pruning removed LOD nodes: %v %v", report.Nodes, err)
+	}
+}
+
+func TestAddMeshKeepsEachPrimitive(t *testing.T) {
+	resolved := resolveTestDoc(t, `{
+		"asset": {"version": "2.0"},
+		"meshes": [{"primitives": [
+			{"attributes": {"POSITION": 0}, "material": 0, "extras": {"part": "a"}},
+			{"attributes": {"POSITION": 0}, "material": 1, "mode": 0}
+		]}],
+		"materials": [{"name": "red"}, {"name": "blue"}],
+		"accessors": [{"bufferView": 0, "componentType": 5126, "count": 3, "type": "VEC3"}],
+		"bufferViews": [{"buffer": 0, "byteLength": 36}],
+		"buffers": [{"uri": "tri.bin", "byteLength": 36}]
+	}`, map[string][]byte{"tri.bin": float32Bytes(0, 0, 0, 1, 0, 0, 0, 1, 0)})
+
+	src := resolved.Meshes[0].Primitives
+	mesh, err := resolved.AddMesh("copy", []*ResolvedPrimitive{&src[0], &src[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prims := resolved.GlTF.Meshes[mesh].Primitives
+	if *prims[0].Material != 0 || *prims[1].Material != 1 {
+		t.Errorf("expected materials 0 and 1, got %d and %d", *prims[0].Material, *prims[1].Material)
+	}
+	if prims[0].Mode != nil || *prims[1].Mode != POINTS || prims[0].Extras["part"] != "a" || prims[1].Extras != nil {
+		t.Errorf("expected each primitive's own mode and extras, got %+v", prims)
+	}
+	copied := resolved.Meshes[mesh].Primitives
+	if copied[0].Material.Name != "red" || copied[1].Material.Name != "blue" {
+		t.Errorf("expected red and blue, got %q and %q", copied[0].Material.Name, copied[1].Material.Name)
+	}
+
+	resolved.GlTF.Nodes = []Node{{Mesh: new(uint)}}
+	if err := resolved.resolveReferences(); err != nil {
+		t.Fatal(err)
+	}
+	levels := [][]*ResolvedPrimitive{{&resolved.Meshes[0].Primitives[0], &resolved.Meshes[0].Primitives[1]}}
+	if err := resolved.AddLODs(0, levels, nil); err != nil {
+		t.Fatal(err)
+	}
+	if lod := resolved.Nodes[1].Mesh.Primitives; lod[0].Material.Name != "red" || lod[1].Material.Name != "blue" {
+		t.Errorf("expected the LOD to keep red and blue, got %q and %q", lod[0].Material.Name, lod[1].Material.Name)
+	}
+
+	// A material from another document can not be referenced.
+	foreign := resolved.Meshes[0].Primitives[0]
+	foreign.Material = &ResolvedMaterial{Material: &Material{}}
+	if _, err := resolved.AddMesh("foreign", []*ResolvedPrimitive{&foreign}); err == nil {
+		t.Error("expected an error for a material that is not in the document")
+	}
+}
+
+func TestAddMeshDropsDracoCompression(t *testing.T) {
+	resolved := resolveTestDoc(t, dracoDocument(), nil)
+	mesh, err := resolved.AddMesh("decoded", []*ResolvedPrimitive{&resolved.Meshes[0].Primitives[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ext := resolved.GlTF.Meshes[mesh].Primitives[0].Extensions; ext != nil {
+		t.Errorf("expected no extensions on the decoded primitive, got %v", ext)
+	}
+	if _, found, _ := GetExtension[KHRDracoMeshCompression](&resolved.GlTF.Meshes[0].Primitives[0]); !found {
+		t.Error("expected the source primitive to keep its extension")
+	}
+	positions, err := resolved.Meshes[mesh].Primitives[0].Attributes[POSITION].ReadFloats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(positions, []float32{-1, 0, 0.5, 3, 0, 0.5, 3, 6, 0.5, -1, 6, 2.5}) {
+		t.Errorf("unexpected positions %v", positions)
+	}
+}
@@ -0,0 +1,99 @@
+package gltf
+
+import (
+	"fmt"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+const KHR_TEXTURE_TRANSFORM = "KHR_texture_transform"
+
+// KHRTextureTransform is the KHR_texture_transform extension on a texture info. See
+// https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Khronos/KHR_texture_transform
+type KHRTextureTransform struct {
+	Offset   *vkm.Vec2 `json:"offset,omitempty"`
+	Rotation *float32  `json:"rotation,omitempty"`
+	Scale    *vkm.Vec2 `json:"scale,omitempty"`
+	// TexCoord, if set, replaces the texture info's TexCoord.
+	TexCoord *uint `json:"texCoord,omitempty"`
+}
+
+func init() {
+	RegisterExtension(KHR_TEXTURE_TRANSFORM, ExtensionCodec[KHRTextureTransform]{}, (*TextureInfo)(nil))
+}
+
+// UVMatrix is a 3x3 matrix that transforms homogeneous texture coordinates. Like vkm.Mat it is column-major, so m[2]
+// holds the translation.
+type UVMatrix [3]vkm.Vec3
+
+// IdentityUVMatrix returns the matrix that leaves texture coordinates unchanged.
+func IdentityUVMatrix() UVMatrix {
+	return UVMatrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+}
+
+// Apply returns the transformed texture coordinate.
+func (m UVMatrix) Apply(uv vkm.Vec2) vkm.Vec2 {
+	return vkm.Vec2{
+		m[0][0]*uv[0] + m[1][0]*uv[1] + m[2][0],
+		m[0][1]*uv[0] + m[1][1]*uv[1] + m[2][1],
+	}
+}
+
+// Matrix returns the transform as the product Translation * Rotation * Scale, as defined by the extension, using the
+// defaults for any fields that are not set. A nil transform is the identity.
+func (t *KHRTextureTransform) Matrix() UVMatrix {
+	offset, rotation, scale := vkm.Vec2{}, float32(0), vkm.Vec2{1, 1}
+	if t != nil {
+		if t.Offset != nil {
+			offset = *t.Offset
+		}
+		if t.Rotation != nil {
+			rotation = *t.Rotation
+		}
+		if t.Scale != nil {
+			scale = *t.Scale
+		}
+	}
+	sin, cos := math32.Sincos(rotation)
+	return UVMatrix{
+		{cos * scale[0], -sin * scale[0], 0},
+		{sin * scale[1], cos * scale[1], 0},
+		{offset[0], offset[1], 1},
+	}
+}
+
+// TextureTransform returns the texture coordinate set used by the texture info, with any override from its
+// KHR_texture_transform applied, and the matrix that transforms the coordinates before sampling. It is an error if the
+// extension can not be decoded.
+func (ti *TextureInfo) TextureTransform() (uint, UVMatrix, error) {
+	t, _, err := GetExtension[KHRTextureTransform](ti)
+	if err != nil {
+		return ti.TexCoord, IdentityUVMatrix(), err
+	}
+	texCoord := ti.TexCoord
+	if t != nil && t.TexCoord != nil {
+		texCoord = *t.TexCoord
+	}
+	return texCoord, t.Matrix(), nil
+}
+
+// TransformTexCoords returns a standalone FLOAT VEC2 accessor, as with NewFloatAccessor, holding a copy of the texture
+// coordinates in a with m applied. This bakes a texture transform into the data, for renderers that do not support
+// KHR_texture_transform. As the result is only correct for textures that use the same transform, a primitive whose
+// textures share a coordinate set but not a transform needs a separate set for each.
+func (a *ResolvedAccessor) TransformTexCoords(m UVMatrix) (*ResolvedAccessor, error) {
+	if a.Type != VEC2 {
+		return nil, fmt.Errorf("Accessor %q has type %s, not VEC2", a.Name, a.Type)
+	}
+	uvs, err := a.ReadVec2s()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]float32, 0, 2*len(uvs))
+	for _, uv := range uvs {
+		uv = m.Apply(uv)
+		values = append(values, uv[0], uv[1])
+	}
+	return NewFloatAccessor(VEC2, values), nil
+}
@@ -0,0 +1,79 @@
+package gltf
+
+import (
+	"testing"
+
+	"github.com/bbredesen/vkm"
+)
+
+const textureTransformDocument = `{
+	"asset": {"version": "2.0"},
+	"extensionsUsed": ["KHR_texture_transform"],
+	"textures": [{}],
+	"materials": [{
+		"pbrMetallicRoughness": {
+			"baseColorTexture": {"index": 0, "extensions": {"KHR_texture_transform": {
+				"offset": [0.5, 0], "rotation": 1.5707964, "scale": [2, 2], "texCoord": 1
+			}}},
+			"metallicRoughnessTexture": {"index": 0, "texCoord": 1}
+		},
+		"occlusionTexture": {"index": 0, "texCoord": 2, "extensions": {"KHR_texture_transform": {"offset": [0, 1]}}}
+	}]
+}`
+
+func approxVec2(a, b vkm.Vec2) bool {
+	return approxVec3(vkm.Vec3{a[0], a[1]}, vkm.Vec3{b[0], b[1]})
+}
+
+func TestTextureTransform(t *testing.T) {
+	doc, err := FromBytes([]byte(textureTransformDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := doc.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := resolved.Materials[0]
+
+	base := m.PbrMetallicRoughness.BaseColorTexture
+	if base.TexCoord != 1 {
+		t.Errorf("expected the extension to override the base color texCoord, got %d", base.TexCoord)
+	}
+	// Scaled to (2, 0), rotated a quarter turn to (0, -2), then offset.
+	if uv := base.UVMatrix.Apply(vkm.Vec2{1, 0}); !approxVec2(uv, vkm.Vec2{0.5, -2}) {
+		t.Errorf("expected the base color transform to map (1, 0) to (0.5, -2), got %v", uv)
+	}
+
+	mr := m.PbrMetallicRoughness.MetallicRoughnessTexture
+	if mr.TexCoord != 1 || mr.UVMatrix != IdentityUVMatrix() {
+		t.Errorf("expected texCoord 1 and no transform without the extension, got %d, %v", mr.TexCoord, mr.UVMatrix)
+	}
+
+	occ := m.OcclusionTexture
+	if occ.TexCoord != 2 {
+		t.Errorf("expected the occlusion texture to keep its own texCoord, got %d", occ.TexCoord)
+	}
+	if uv := occ.UVMatrix.Apply(vkm.Vec2{0.25, 0.25}); !approxVec2(uv, vkm.Vec2{0.25, 1.25}) {
+		t.Errorf("expected the occlusion transform to offset the coordinates, got %v", uv)
+	}
+
+	baked, err := NewFloatAccessor(VEC2, []float32{0, 0, 1, 0, 0, 1}).TransformTexCoords(base.UVMatrix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uvs, err := baked.ReadVec2s()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []vkm.Vec2{{0.5, 0}, {0.5, -2}, {2.5, 0}}
+	for i := range expected {
+		if !approxVec2(uvs[i], expected[i]) {
+			t.Errorf("expected baked coordinate %d to be %v, got %v", i, expected[i], uvs[i])
+		}
+	}
+
+	if _, err := NewFloatAccessor(VEC3, []float32{0, 0, 0}).TransformTexCoords(base.UVMatrix); err == nil {
+		t.Error("expected an error for an accessor that is not VEC2")
+	}
+}
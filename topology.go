@@ -0,0 +1,170 @@
+package gltf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VertexCount returns the number of vertices in the primitive, i.e. the element count of its attributes.
+func (p *ResolvedPrimitive) VertexCount() int {
+	if pos := p.Attributes[POSITION]; pos != nil {
+		return pos.Count
+	}
+	for _, a := range p.Attributes {
+		return a.Count
+	}
+	return 0
+}
+
+// vertexIndices returns the primitive's indices, or the sequence 0..VertexCount()-1 for a non-indexed primitive.
+func (p *ResolvedPrimitive) vertexIndices() ([]uint32, error) {
+	if p.Indices != nil {
+		return p.Indices.ReadIndices()
+	}
+
+	rval := make([]uint32, p.VertexCount())
+	for i := range rval {
+		rval[i] = uint32(i)
+	}
+	return rval, nil
+}
+
+// TriangleIndices returns the primitive's triangles as a TRIANGLES index list, three indices per triangle, converting
+// from TRIANGLE_STRIP or TRIANGLE_FAN as needed. Indices are generated for non-indexed primitives. Strips and fans are
+// converted as described by the spec, so that every triangle keeps the winding order of the first:
+//
+//	TRIANGLE_STRIP: triangle i = {v[i], v[i+(1+i%2)], v[i+(2-i%2)]}
+//	TRIANGLE_FAN:   triangle i = {v[i+1], v[i+2], v[0]}
+//
+// An error is returned for point and line primitives.
+func (p *ResolvedPrimitive) TriangleIndices() ([]uint32, error) {
+	v, err := p.vertexIndices()
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Mode {
+	case TRIANGLES:
+		return v[:len(v)-len(v)%3], nil
+	case TRIANGLE_STRIP:
+		var rval []uint32
+		for i := 0; i+2 < len(v); i++ {
+			rval = append(rval, v[i], v[i+1+i%2], v[i+2-i%2])
+		}
+		return rval, nil
+	case TRIANGLE_FAN:
+		var rval []uint32
+		for i := 0; i+2 < len(v); i++ {
+			rval = append(rval, v[i+1], v[i+2], v[0])
+		}
+		return rval, nil
+	}
+	return nil, fmt.Errorf("Can not convert primitive mode %d to triangles", p.Mode)
+}
+
+// LineIndices returns the primitive's lines as a LINES index list, two indices per line, converting from LINE_STRIP
+// or LINE_LOOP as needed. Triangle primitives are converted to the three edges of each triangle, for drawing a
+// wireframe; edges shared between triangles are repeated. Indices are generated for non-indexed primitives. An error
+// is returned for point primitives.
+func (p *ResolvedPrimitive) LineIndices() ([]uint32, error) {
+	switch p.Mode {
+	case TRIANGLES, TRIANGLE_STRIP, TRIANGLE_FAN:
+		tris, err := p.TriangleIndices()
+		if err != nil {
+			return nil, err
+		}
+		rval := make([]uint32, 0, 2*len(tris))
+		for i := 0; i+2 < len(tris); i += 3 {
+			rval = append(rval, tris[i], tris[i+1], tris[i+1], tris[i+2], tris[i+2], tris[i])
+		}
+		return rval, nil
+	case POINTS:
+		return nil, errors.New("Can not convert a POINTS primitive to lines")
+	}
+
+	v, err := p.vertexIndices()
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Mode {
+	case LINES:
+		return v[:len(v)-len(v)%2], nil
+	case LINE_STRIP, LINE_LOOP:
+		var rval []uint32
+		for i := 0; i+1 < len(v); i++ {
+			rval = append(rval, v[i], v[i+1])
+		}
+		if p.Mode == LINE_LOOP && len(v) > 1 {
+			rval = append(rval, v[len(v)-1], v[0])
+		}
+		return rval, nil
+	}
+	return nil, fmt.Errorf("Unknown primitive mode %d", p.Mode)
+}
+
+// WithVertices returns a copy of the primitive, in TRIANGLES mode, whose vertex i is a copy of source vertex
+// vertexMap[i] and whose triangles are given by indices, referring to the new vertices. Every attribute is gathered
+// into a new standalone accessor; the source primitive is not modified.
+func (p *ResolvedPrimitive) WithVertices(vertexMap []uint32, indices []uint32) (*ResolvedPrimitive, error) {
+	rval, err := p.gatherVertices(vertexMap)
+	if err != nil {
+		return nil, err
+	}
+	rval.Mode = TRIANGLES
+	rval.Indices = NewIndexAccessor(indices)
+	return rval, nil
+}
+
+// gatherVertices returns a copy of the primitive whose attributes and morph targets are new standalone accessors, with
+// element i a copy of source vertex vertexMap[i]. Indices are left for the caller to set.
+func (p *ResolvedPrimitive) gatherVertices(vertexMap []uint32) (*ResolvedPrimitive, error) {
+	gather := func(attrs map[AttributeKey]*ResolvedAccessor) (map[AttributeKey]*ResolvedAccessor, error) {
+		rval := make(map[AttributeKey]*ResolvedAccessor, len(attrs))
+		for k, a := range attrs {
+			gathered, err := a.Gather(vertexMap)
+			if err != nil {
+				return nil, fmt.Errorf("Attribute %s: %w", k, err)
+			}
+			rval[k] = gathered
+		}
+		return rval, nil
+	}
+
+	rval := *p
+	var err error
+	if rval.Attributes, err = gather(p.Attributes); err != nil {
+		return nil, err
+	}
+	rval.Targets = nil
+	for i, target := range p.Targets {
+		gathered, err := gather(target)
+		if err != nil {
+			return nil, fmt.Errorf("Morph target %d: %w", i, err)
+		}
+		rval.Targets = append(rval.Targets, gathered)
+	}
+	return &rval, nil
+}
+
+// withGenerated returns a copy of the primitive with the attribute key set to acc. If vertexMap is not nil, then acc
+// holds values for new vertices and every other attribute is gathered to match, as with WithVertices.
+func (p *ResolvedPrimitive) withGenerated(key AttributeKey, acc *ResolvedAccessor, vertexMap, indices []uint32) (*ResolvedPrimitive, error) {
+	var rval *ResolvedPrimitive
+	if vertexMap == nil {
+		copied := *p
+		copied.Attributes = make(map[AttributeKey]*ResolvedAccessor, len(p.Attributes)+1)
+		for k, a := range p.Attributes {
+			copied.Attributes[k] = a
+		}
+		rval = &copied
+	} else {
+		var err error
+		if rval, err = p.WithVertices(vertexMap, indices); err != nil {
+			return nil, err
+		}
+	}
+
+	rval.Attributes[key] = acc
+	return rval, nil
+}
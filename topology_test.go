@@ -0,0 +1,63 @@
+package gltf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func nonIndexedPrimitive(mode ModeEnum, count int) *ResolvedPrimitive {
+	return &ResolvedPrimitive{
+		Primitive:  &Primitive{},
+		Mode:       mode,
+		Attributes: map[AttributeKey]*ResolvedAccessor{POSITION: {Accessor: &Accessor{Count: count, Type: VEC3, ComponentType: FLOAT}}},
+	}
+}
+
+func TestTriangleIndices(t *testing.T) {
+	tests := []struct {
+		mode     ModeEnum
+		count    int
+		expected []uint32
+	}{
+		{TRIANGLES, 7, []uint32{0, 1, 2, 3, 4, 5}},
+		{TRIANGLE_STRIP, 5, []uint32{0, 1, 2, 1, 3, 2, 2, 3, 4}},
+		{TRIANGLE_FAN, 5, []uint32{1, 2, 0, 2, 3, 0, 3, 4, 0}},
+	}
+
+	for _, tc := range tests {
+		got, err := nonIndexedPrimitive(tc.mode, tc.count).TriangleIndices()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("mode %d: expected %v, got %v", tc.mode, tc.expected, got)
+		}
+	}
+
+	if _, err := nonIndexedPrimitive(LINES, 4).TriangleIndices(); err == nil {
+		t.Error("expected an error converting LINES to triangles")
+	}
+}
+
+func TestLineIndices(t *testing.T) {
+	tests := []struct {
+		mode     ModeEnum
+		count    int
+		expected []uint32
+	}{
+		{LINES, 5, []uint32{0, 1, 2, 3}},
+		{LINE_STRIP, 3, []uint32{0, 1, 1, 2}},
+		{LINE_LOOP, 3, []uint32{0, 1, 1, 2, 2, 0}},
+		{TRIANGLES, 3, []uint32{0, 1, 1, 2, 2, 0}},
+	}
+
+	for _, tc := range tests {
+		got, err := nonIndexedPrimitive(tc.mode, tc.count).LineIndices()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("mode %d: expected %v, got %v", tc.mode, tc.expected, got)
+		}
+	}
+}
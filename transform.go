@@ -0,0 +1,98 @@
+package gltf
+
+import (
+	"github.com/bbredesen/vkm"
+)
+
+// LocalMatrix returns the node's transform relative to its parent: Matrix if it is set, or else the product of
+// Translation, Rotation and Scale, with the spec defaults for any that are not set.
+func (n *Node) LocalMatrix() vkm.Mat {
+	if n.Matrix != [16]float32{} {
+		var rval vkm.Mat
+		for col := range rval {
+			copy(rval[col][:], n.Matrix[col*4:col*4+4])
+		}
+		return rval
+	}
+	t, r, s := n.trs()
+	return trsMatrix(t, r, s)
+}
+
+// trs returns the node's translation, rotation and scale, with the spec defaults for any that are not set.
+func (n *Node) trs() (vkm.Vec3, vkm.Vec, vkm.Vec3) {
+	t, r, s := vkm.Vec3{}, vkm.Vec{0, 0, 0, 1}, vkm.Vec3{1, 1, 1}
+	if n.Translation != nil {
+		t = *n.Translation
+	}
+	if n.Rotation != nil {
+		r = *n.Rotation
+	}
+	if n.Scale != nil {
+		s = *n.Scale
+	}
+	return t, r, s
+}
+
+// trsMatrix returns the matrix T * R * S, where r is a unit quaternion in glTF's (x, y, z, w) order.
+func trsMatrix(t vkm.Vec3, r vkm.Vec, s vkm.Vec3) vkm.Mat {
+	x, y, z, w := r[0], r[1], r[2], r[3]
+	return vkm.Mat{
+		{(1 - 2*(y*y+z*z)) * s[0], 2 * (x*y + z*w) * s[0], 2 * (x*z - y*w) * s[0], 0},
+		{2 * (x*y - z*w) * s[1], (1 - 2*(x*x+z*z)) * s[1], 2 * (y*z + x*w) * s[1], 0},
+		{2 * (x*z + y*w) * s[2], 2 * (y*z - x*w) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0},
+		{t[0], t[1], t[2], 1},
+	}
+}
+
+// transformPoint applies m to the point p.
+func transformPoint(m vkm.Mat, p vkm.Vec3) vkm.Vec3 {
+	v := m.MultV(vkm.Vec{p[0], p[1], p[2], 1})
+	return vkm.Vec3{v[0], v[1], v[2]}
+}
+
+// transformDirection applies the linear part of m to the direction d, ignoring translation.
+func transformDirection(m vkm.Mat, d vkm.Vec3) vkm.Vec3 {
+	v := m.MultV(vkm.Vec{d[0], d[1], d[2], 0})
+	return vkm.Vec3{v[0], v[1], v[2]}
+}
+
+// Pose overrides the local transforms of some nodes, for example with the values from an animation. Nodes not in the
+// pose use their own LocalMatrix. A nil Pose is the rest pose of the document.
+type Pose map[*ResolvedNode]vkm.Mat
+
+// LocalMatrix returns the local transform of n in the pose.
+func (pose Pose) LocalMatrix(n *ResolvedNode) vkm.Mat {
+	if m, found := pose[n]; found {
+		return m
+	}
+	return n.LocalMatrix()
+}
+
+// WorldMatrices returns the transform from local to world space of every node in the scene, in the given pose.
+func (s *ResolvedScene) WorldMatrices(pose Pose) map[*ResolvedNode]vkm.Mat {
+	rval := make(map[*ResolvedNode]vkm.Mat)
+	s.walk(pose, func(n *ResolvedNode, world vkm.Mat) {
+		rval[n] = world
+	})
+	return rval
+}
+
+// walk visits every node in the scene, depth first in document order, with its world transform in the given pose.
+func (s *ResolvedScene) walk(pose Pose, visit func(n *ResolvedNode, world vkm.Mat)) {
+	visited := make(map[*ResolvedNode]bool)
+	var walk func(n *ResolvedNode, parent vkm.Mat)
+	walk = func(n *ResolvedNode, parent vkm.Mat) {
+		if visited[n] {
+			return // Not allowed by the spec, but don't loop forever on a cycle
+		}
+		visited[n] = true
+		world := parent.MultM(pose.LocalMatrix(n))
+		visit(n, world)
+		for _, c := range n.Children {
+			walk(c, world)
+		}
+	}
+	for _, n := range s.Nodes {
+		walk(n, vkm.Identity())
+	}
+}
@@ -0,0 +1,128 @@
+package gltf
+
+import "fmt"
+
+const KHR_MATERIALS_VARIANTS = "KHR_materials_variants"
+
+// MaterialVariant: see https://github.com/KhronosGroup/glTF/tree/main/extensions/2.0/Khronos/KHR_materials_variants
+type MaterialVariant struct {
+	Name GlTFId `json:"name"`
+
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+// MaterialVariantMapping assigns a material to a primitive for each of the variants in Variants, which are indices into
+// the document's variants.
+type MaterialVariantMapping struct {
+	Material uint   `json:"material"`
+	Variants []uint `json:"variants"`
+
+	Name       GlTFId `json:"name,omitempty"`
+	Extensions `json:"extensions,omitempty"`
+	Extras     `json:"extras,omitempty"`
+}
+
+// KHRMaterialsVariants is the KHR_materials_variants extension on the document root.
+type KHRMaterialsVariants struct {
+	Variants []MaterialVariant `json:"variants"`
+}
+
+// KHRMaterialsVariantsPrimitive is the KHR_materials_variants extension on a primitive.
+type KHRMaterialsVariantsPrimitive struct {
+	Mappings []MaterialVariantMapping `json:"mappings"`
+}
+
+func init() {
+	RegisterExtension(KHR_MATERIALS_VARIANTS, ExtensionCodec[KHRMaterialsVariants]{}, (*GlTF)(nil))
+	RegisterExtension(KHR_MATERIALS_VARIANTS, ExtensionCodec[KHRMaterialsVariantsPrimitive]{}, (*Primitive)(nil))
+}
+
+// Variants returns the material variants defined by the document's KHR_materials_variants extension, or nil if it
+// does not have one.
+func (gltf *GlTF) Variants() ([]MaterialVariant, error) {
+	mv, _, err := GetExtension[KHRMaterialsVariants](gltf)
+	if mv == nil {
+		return nil, err
+	}
+	return mv.Variants, err
+}
+
+// SetVariants replaces the variants in the document's KHR_materials_variants extension, and adds the extension to
+// ExtensionsUsed. Primitives refer to the variants by index, so existing mappings are not updated.
+func (gltf *GlTF) SetVariants(variants []MaterialVariant) {
+	SetExtension(gltf, &KHRMaterialsVariants{Variants: variants})
+	gltf.ExtensionsUsed = appendUnique(gltf.ExtensionsUsed, KHR_MATERIALS_VARIANTS)
+}
+
+// variantMappings returns the mappings of the primitive's KHR_materials_variants extension, or nil if it does not have
+// one.
+func (p *Primitive) variantMappings() ([]MaterialVariantMapping, error) {
+	ext, _, err := GetExtension[KHRMaterialsVariantsPrimitive](p)
+	if ext == nil {
+		return nil, err
+	}
+	return ext.Mappings, err
+}
+
+// resolveVariants sets the variant materials of rval from the primitive's KHR_materials_variants mappings.
+func (p *Primitive) resolveVariants(root *ResolvedGlTF, rval *ResolvedPrimitive) error {
+	mappings, err := p.variantMappings()
+	if err != nil || mappings == nil {
+		return err
+	}
+	rval.VariantMaterials = make(map[uint]*ResolvedMaterial)
+	for _, mapping := range mappings {
+		if mapping.Material >= uint(len(root.Materials)) {
+			return fmt.Errorf("%s: material %d is not a valid material", KHR_MATERIALS_VARIANTS, mapping.Material)
+		}
+		for _, v := range mapping.Variants {
+			if v >= uint(len(root.Variants)) {
+				return fmt.Errorf("%s: variant %d is not a valid variant", KHR_MATERIALS_VARIANTS, v)
+			}
+			if _, found := rval.VariantMaterials[v]; found {
+				return fmt.Errorf("%s: variant %d is mapped more than once", KHR_MATERIALS_VARIANTS, v)
+			}
+			rval.VariantMaterials[v] = &root.Materials[mapping.Material]
+		}
+	}
+	return nil
+}
+
+// VariantIndex returns the index in Variants of the variant with the given name, and false if there is no such variant.
+func (root *ResolvedGlTF) VariantIndex(name GlTFId) (uint, bool) {
+	for i := range root.Variants {
+		if root.Variants[i].Name == name {
+			return uint(i), true
+		}
+	}
+	return 0, false
+}
+
+// VariantMaterial returns the material that the primitive uses for the variant at index v in the document's Variants,
+// which is its DefaultMaterial if the variant is not mapped.
+func (p *ResolvedPrimitive) VariantMaterial(v uint) *ResolvedMaterial {
+	if m, found := p.VariantMaterials[v]; found {
+		return m
+	}
+	return p.DefaultMaterial
+}
+
+// SelectVariant sets the Material of every primitive to the material that it uses for the variant at index v in
+// Variants. A nil v restores every primitive's DefaultMaterial. It is an error if v is not a valid variant.
+func (root *ResolvedGlTF) SelectVariant(v *uint) error {
+	if v != nil && *v >= uint(len(root.Variants)) {
+		return fmt.Errorf("Variant %d is not a valid variant", *v)
+	}
+	for i := range root.Meshes {
+		for j := range root.Meshes[i].Primitives {
+			p := &root.Meshes[i].Primitives[j]
+			if v == nil {
+				p.Material = p.DefaultMaterial
+			} else {
+				p.Material = p.VariantMaterial(*v)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package gltf
+
+import (
+	"testing"
+)
+
+const variantsDocument = `{
+	"asset": {"version": "2.0"},
+	"extensionsUsed": ["KHR_materials_variants"],
+	"extensions": {"KHR_materials_variants": {"variants": [{"name": "red"}, {"name": "blue"}]}},
+	"scenes": [{"nodes": [0]}],
+	"nodes": [{"mesh": 0}],
+	"meshes": [{"primitives": [
+		{"attributes": {}, "material": 0, "extensions": {"KHR_materials_variants": {"mappings": [
+			{"material": 3, "variants": [0]},
+			{"material": 4, "variants": [1]}
+		]}}},
+		{"attributes": {}, "material": 1}
+	]}],
+	"materials": [{"name": "grey"}, {"name": "white"}, {"name": "unused"}, {"name": "red"}, {"name": "blue"}]
+}`
+
+func TestVariants(t *testing.T) {
+	doc, err := FromBytes([]byte(variantsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := doc.Resolve(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(resolved.Variants) != 2 || resolved.Variants[1].Name != "blue" {
+		t.Fatalf("unexpected variants %+v", resolved.Variants)
+	}
+	blue, found := resolved.VariantIndex("blue")
+	if !found || blue != 1 {
+		t.Errorf("expected blue to be variant 1, got %d, %v", blue, found)
+	}
+	if _, found := resolved.VariantIndex("green"); found {
+		t.Error("expected no green variant")
+	}
+
+	p, plain := &resolved.Meshes[0].Primitives[0], &resolved.Meshes[0].Primitives[1]
+	if p.Material != &resolved.Materials[0] || p.DefaultMaterial != p.Material {
+		t.Errorf("expected the default material before a variant is selected")
+	}
+	if p.VariantMaterial(0) != &resolved.Materials[3] || p.VariantMaterial(blue) != &resolved.Materials[4] ||
+		plain.VariantMaterial(blue) != &resolved.Materials[1] {
+		t.Errorf("unexpected variant materials")
+	}
+
+	if err := resolved.SelectVariant(&blue); err != nil {
+		t.Fatal(err)
+	}
+	if p.Material.Name != "blue" || plain.Material.Name != "white" {
+		t.Errorf("expected blue and the unmapped default, got %q and %q", p.Material.Name, plain.Material.Name)
+	}
+	if err := resolved.SelectVariant(nil); err != nil {
+		t.Fatal(err)
+	}
+	if p.Material.Name != "grey" {
+		t.Errorf("expected the default material to be restored, got %q", p.Material.Name)
+	}
+	invalid := uint(2)
+	if err := resolved.SelectVariant(&invalid); err == nil {
+		t.Error("expected an error for a variant that does not exist")
+	}
+
+	for _, mappings := range []string{
+		`[{"material": 1, "variants": [2]}]`,
+		`[{"material": 5, "variants": [0]}]`,
+		`[{"material": 1, "variants": [0]}, {"material": 2, "variants": [1, 0]}]`,
+	} {
+		doc, err := FromBytes([]byte(`{
+			"asset": {"version": "2.0"},
+			"extensions": {"KHR_materials_variants": {"variants": [{"name": "a"}, {"name": "b"}]}},
+			"meshes": [{"primitives": [{"attributes": {}, "extensions": {"KHR_materials_variants": {"mappings": ` +
+			mappings + `}}}]}],
+			"materials": [{}, {}, {}]
+		}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := doc.Resolve(nil); err == nil {
+			t.Errorf("expected an error for mappings %s", mappings)
+		}
+	}
+}
+
+func TestVariantsPruneAndMerge(t *testing.T) {
+	doc, err := FromBytes([]byte(variantsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := doc.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Materials) != 1 || report.Materials[0] != 2 {
+		t.Errorf("expected only the unused material to be removed, got %v", report.Materials)
+	}
+	mappings, err := doc.Meshes[0].Primitives[0].variantMappings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mappings[0].Material != 2 || mappings[1].Material != 3 {
+		t.Errorf("expected the mapped materials to be kept in place, got %+v", mappings)
+	}
+
+	// Without the default materials, only the variants' materials are kept.
+	doc, err = FromBytes([]byte(variantsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Meshes[0].Primitives = doc.Meshes[0].Primitives[:1]
+	doc.Meshes[0].Primitives[0].Material = nil
+	if _, err := doc.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if mappings, _ = doc.Meshes[0].Primitives[0].variantMappings(); mappings[0].Material != 0 || mappings[1].Material != 1 {
+		t.Errorf("expected the mapped materials to be remapped, got %+v", mappings)
+	}
+
+	a, err := FromBytes([]byte(variantsDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := a.clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := Merge([]*GlTF{a, b}, MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants, err := merged.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != 4 || variants[2].Name != "red" {
+		t.Fatalf("expected the variants of both documents, got %+v", variants)
+	}
+	mappings, err = merged.Meshes[1].Primitives[0].variantMappings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mappings[0].Material != 8 || mappings[0].Variants[0] != 2 || mappings[1].Variants[0] != 3 {
+		t.Errorf("expected the second document's mappings to be offset, got %+v", mappings)
+	}
+}
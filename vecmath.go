@@ -0,0 +1,89 @@
+package gltf
+
+import (
+	"fmt"
+
+	"github.com/bbredesen/vkm"
+	"github.com/chewxy/math32"
+)
+
+// Small vector helpers that are missing from vkm, used by the geometry processing functions in this package.
+
+func cross3(a, b vkm.Vec3) vkm.Vec3 {
+	return vkm.Vec3{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+// vkm's Add, Sub, Dot and Length on a Vec ignore the w component, treating it as a homogeneous direction. These are
+// the full four component versions, for quaternions and colors.
+
+func add4(a, b vkm.Vec) vkm.Vec {
+	return vkm.Vec{a[0] + b[0], a[1] + b[1], a[2] + b[2], a[3] + b[3]}
+}
+
+func dot4(a, b vkm.Vec) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+}
+
+// normalizeOr returns v scaled to unit length, or fallback if v has no usable length.
+func normalizeOr(v vkm.Vec3, fallback vkm.Vec3) vkm.Vec3 {
+	l := v.Length()
+	if l < 1e-20 || math32.IsNaN(l) || math32.IsInf(l, 0) {
+		return fallback
+	}
+	return v.Scale(1 / l)
+}
+
+// angleBetween returns the angle in radians between two unit vectors.
+func angleBetween(a, b vkm.Vec3) float32 {
+	d := a.Dot(b)
+	if d > 1 {
+		d = 1
+	} else if d < -1 {
+		d = -1
+	}
+	return math32.Acos(d)
+}
+
+// ReadVec3s reads a VEC3 accessor as a slice of vectors, converting components as described for ReadFloats.
+func (a *ResolvedAccessor) ReadVec3s() ([]vkm.Vec3, error) {
+	if a.Type != VEC3 {
+		return nil, fmt.Errorf("Accessor %q is not a VEC3 accessor", a.Name)
+	}
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	rval := make([]vkm.Vec3, a.Count)
+	for i := range rval {
+		copy(rval[i][:], values[3*i:])
+	}
+	return rval, nil
+}
+
+// ReadVec2s reads a VEC2 accessor as a slice of vectors, converting components as described for ReadFloats.
+func (a *ResolvedAccessor) ReadVec2s() ([]vkm.Vec2, error) {
+	if a.Type != VEC2 {
+		return nil, fmt.Errorf("Accessor %q is not a VEC2 accessor", a.Name)
+	}
+	values, err := a.ReadFloats()
+	if err != nil {
+		return nil, err
+	}
+	rval := make([]vkm.Vec2, a.Count)
+	for i := range rval {
+		copy(rval[i][:], values[2*i:])
+	}
+	return rval, nil
+}
+
+func flattenVec3s(vs []vkm.Vec3) []float32 {
+	rval := make([]float32, 0, 3*len(vs))
+	for _, v := range vs {
+		rval = append(rval, v[0], v[1], v[2])
+	}
+	return rval
+}